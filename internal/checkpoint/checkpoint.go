@@ -0,0 +1,395 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/speier/smith/pkg/agent/session"
+)
+
+var (
+	// ErrNoCheckpoints indicates there is nothing left to undo
+	ErrNoCheckpoints = errors.New("no checkpoints to undo")
+
+	// ErrNotFound indicates the checkpoint doesn't exist
+	ErrNotFound = errors.New("checkpoint not found")
+)
+
+const (
+	checkpointsDir = "checkpoints"
+	objectsDir     = "objects"
+	manifestExt    = ".json"
+)
+
+// Ref identifies the group a file change belongs to: a task or a chat turn
+type Ref struct {
+	TaskID string
+	Turn   int
+}
+
+// TaskRef returns the checkpoint ref for changes made while working on a task
+func TaskRef(taskID string) Ref {
+	return Ref{TaskID: taskID}
+}
+
+// TurnRef returns the checkpoint ref for changes made during a chat turn
+func TurnRef(turn int) Ref {
+	return Ref{Turn: turn}
+}
+
+// ID returns the checkpoint ID (the task ID, or turn-NNNN for chat turns)
+func (r Ref) ID() string {
+	if r.TaskID != "" {
+		return r.TaskID
+	}
+	return fmt.Sprintf("turn-%04d", r.Turn)
+}
+
+// Checkpoint records the contents of every file touched within a task or chat turn,
+// as they were before the first change
+type Checkpoint = session.Checkpoint
+
+// FileSnapshot is the previous state of a single file
+type FileSnapshot = session.FileSnapshot
+
+// Store satisfies the checkpoints interface of sessions
+var _ session.Checkpoints = (*Store)(nil)
+
+// Store is a content-addressed checkpoint store under .smith/checkpoints.
+// It only touches the working tree on restore, so it works with uncommitted
+// changes and without git.
+type Store struct {
+	root string // Project root
+	dir  string // .smith/checkpoints by default
+	mu   sync.Mutex
+}
+
+// New creates a checkpoint store for the project
+func New(projectPath string) *Store {
+	return NewAt(projectPath, filepath.Join(projectPath, ".smith", checkpointsDir))
+}
+
+// NewAt creates a checkpoint store for the project that keeps its data in dir
+func NewAt(projectPath, dir string) *Store {
+	return &Store{
+		root: projectPath,
+		dir:  dir,
+	}
+}
+
+// Record snapshots a file before it is modified.
+// Only the first snapshot of a file within a checkpoint is kept, so restoring
+// returns the file to its state before the task or turn started.
+func (s *Store) Record(ref Ref, path string) error {
+	rel, err := s.relPath(path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp, err := s.load(ref.ID())
+	if errors.Is(err, ErrNotFound) {
+		cp = &Checkpoint{
+			ID:        ref.ID(),
+			TaskID:    ref.TaskID,
+			Turn:      ref.Turn,
+			CreatedAt: time.Now(),
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// A restored checkpoint that gets new changes (e.g., a retried task) starts over
+	if cp.RestoredAt != nil {
+		cp.Files = nil
+		cp.RestoredAt = nil
+		cp.CreatedAt = time.Now()
+	}
+
+	for _, f := range cp.Files {
+		if f.Path == rel {
+			return nil
+		}
+	}
+
+	snap := FileSnapshot{Path: rel}
+	info, err := os.Stat(filepath.Join(s.root, rel))
+	switch {
+	case err == nil:
+		if info.IsDir() {
+			return fmt.Errorf("cannot checkpoint directory: %s", rel)
+		}
+		data, err := os.ReadFile(filepath.Join(s.root, rel))
+		if err != nil {
+			return fmt.Errorf("reading file: %w", err)
+		}
+		hash, err := s.writeObject(data)
+		if err != nil {
+			return err
+		}
+		snap.Existed = true
+		snap.Hash = hash
+		snap.Mode = info.Mode().Perm()
+	case !os.IsNotExist(err):
+		return fmt.Errorf("checking file: %w", err)
+	}
+
+	cp.Files = append(cp.Files, snap)
+	cp.UpdatedAt = time.Now()
+	return s.save(cp)
+}
+
+// Get returns a checkpoint by ID
+func (s *Store) Get(id string) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(id)
+}
+
+// List returns all checkpoints, oldest first
+func (s *Store) List() ([]*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+// NextTurn returns the number to use for the next chat turn
+func (s *Store) NextTurn() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.list()
+	if err != nil {
+		return 1
+	}
+
+	last := 0
+	for _, cp := range checkpoints {
+		if cp.Turn > last {
+			last = cp.Turn
+		}
+	}
+	return last + 1
+}
+
+// Restore puts every file in the checkpoint back to its recorded state.
+// Files that didn't exist before are removed. Returns the restored paths.
+func (s *Store) Restore(id string) (*Checkpoint, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restore(id)
+}
+
+// Undo restores the most recently changed checkpoint that hasn't been restored yet
+func (s *Store) Undo() (*Checkpoint, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.list()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		if checkpoints[i].RestoredAt == nil {
+			return s.restore(checkpoints[i].ID)
+		}
+	}
+
+	return nil, nil, ErrNoCheckpoints
+}
+
+// restore is Restore for callers holding s.mu
+func (s *Store) restore(id string) (*Checkpoint, []string, error) {
+	cp, err := s.load(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var restored []string
+	for _, f := range cp.Files {
+		if err := s.restoreFile(f); err != nil {
+			return nil, restored, fmt.Errorf("restoring %s: %w", f.Path, err)
+		}
+		restored = append(restored, f.Path)
+	}
+
+	now := time.Now()
+	cp.RestoredAt = &now
+	if err := s.save(cp); err != nil {
+		return nil, restored, err
+	}
+
+	return cp, restored, nil
+}
+
+// restoreFile writes back (or removes) a single file
+func (s *Store) restoreFile(f FileSnapshot) error {
+	fullPath := filepath.Join(s.root, f.Path)
+
+	if !f.Existed {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(s.objectPath(f.Hash))
+	if err != nil {
+		return fmt.Errorf("reading object %s: %w", f.Hash, err)
+	}
+
+	mode := f.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(fullPath, data, mode)
+}
+
+// relPath returns path relative to the project root, rejecting paths outside it
+func (s *Store) relPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.root, path)
+	}
+
+	absRoot, err := filepath.Abs(s.root)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside project: %s", path)
+	}
+	return rel, nil
+}
+
+// writeObject stores content by its SHA-256 hash and returns the hash
+func (s *Store) writeObject(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	path := s.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("creating objects directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return "", fmt.Errorf("writing object: %w", err)
+	}
+	return hash, nil
+}
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.dir, objectsDir, hash[:2], hash[2:])
+}
+
+func (s *Store) manifestPath(id string) string {
+	return filepath.Join(s.dir, id+manifestExt)
+}
+
+func (s *Store) load(id string) (*Checkpoint, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.manifestPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %s: %w", id, err)
+	}
+	return &cp, nil
+}
+
+func (s *Store) save(cp *Checkpoint) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("creating checkpoints directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	return writeFileAtomic(s.manifestPath(cp.ID), data, 0644)
+}
+
+func (s *Store) list() ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoints: %w", err)
+	}
+
+	var checkpoints []*Checkpoint
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, manifestExt) {
+			continue
+		}
+		cp, err := s.load(strings.TrimSuffix(name, manifestExt))
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].UpdatedAt.Before(checkpoints[j].UpdatedAt)
+	})
+	return checkpoints, nil
+}
+
+// writeFileAtomic writes to a temp file and renames it into place
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".smith-tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,217 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRecordAndRestore(t *testing.T) {
+	root := t.TempDir()
+	store := New(root)
+
+	existing := filepath.Join(root, "main.go")
+	created := filepath.Join(root, "pkg", "new.go")
+	writeFile(t, existing, "original")
+
+	ref := TaskRef("task-001")
+	if err := store.Record(ref, existing); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	writeFile(t, existing, "first change")
+
+	// Second change to the same file keeps the original snapshot
+	if err := store.Record(ref, "main.go"); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	writeFile(t, existing, "second change")
+
+	if err := store.Record(ref, created); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	writeFile(t, created, "new file")
+
+	cp, err := store.Get("task-001")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(cp.Files) != 2 {
+		t.Fatalf("Expected 2 files in checkpoint, got %d", len(cp.Files))
+	}
+
+	_, restored, err := store.Restore("task-001")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("Expected 2 restored files, got %v", restored)
+	}
+
+	if got := readFile(t, existing); got != "original" {
+		t.Errorf("main.go = %q, want %q", got, "original")
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("Expected created file to be removed, stat err: %v", err)
+	}
+}
+
+func TestUndoLatest(t *testing.T) {
+	root := t.TempDir()
+	store := New(root)
+
+	path := filepath.Join(root, "notes.txt")
+	writeFile(t, path, "v1")
+
+	turn := store.NextTurn()
+	if turn != 1 {
+		t.Errorf("NextTurn() = %d, want 1", turn)
+	}
+	if err := store.Record(TurnRef(turn), path); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, "v2")
+
+	turn = store.NextTurn()
+	if turn != 2 {
+		t.Errorf("NextTurn() = %d, want 2", turn)
+	}
+	if err := store.Record(TurnRef(turn), path); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, "v3")
+
+	cp, _, err := store.Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if cp.ID != "turn-0002" {
+		t.Errorf("Undo restored %s, want turn-0002", cp.ID)
+	}
+	if got := readFile(t, path); got != "v2" {
+		t.Errorf("After first undo = %q, want v2", got)
+	}
+
+	if _, _, err := store.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if got := readFile(t, path); got != "v1" {
+		t.Errorf("After second undo = %q, want v1", got)
+	}
+
+	if _, _, err := store.Undo(); !errors.Is(err, ErrNoCheckpoints) {
+		t.Errorf("Expected ErrNoCheckpoints, got %v", err)
+	}
+}
+
+func TestUndoConcurrent(t *testing.T) {
+	root := t.TempDir()
+	store := New(root)
+
+	const turns = 8
+	for i := 1; i <= turns; i++ {
+		path := filepath.Join(root, fmt.Sprintf("file%d.txt", i))
+		if err := store.Record(TurnRef(i), path); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, "changed")
+	}
+
+	// Each undo takes a different checkpoint
+	ids := make(chan string, turns)
+	var wg sync.WaitGroup
+	for i := 0; i < turns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cp, _, err := store.Undo()
+			if err != nil {
+				t.Errorf("Undo failed: %v", err)
+				return
+			}
+			ids <- cp.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("checkpoint %s undone twice", id)
+		}
+		seen[id] = true
+	}
+	if _, _, err := store.Undo(); !errors.Is(err, ErrNoCheckpoints) {
+		t.Errorf("Expected ErrNoCheckpoints, got %v", err)
+	}
+}
+
+func TestContentAddressedObjects(t *testing.T) {
+	root := t.TempDir()
+	store := New(root)
+
+	writeFile(t, filepath.Join(root, "a.txt"), "same")
+	writeFile(t, filepath.Join(root, "b.txt"), "same")
+
+	if err := store.Record(TaskRef("task-001"), "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Record(TaskRef("task-002"), "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	var objects int
+	err := filepath.Walk(filepath.Join(root, ".smith", "checkpoints", "objects"), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			objects++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objects != 1 {
+		t.Errorf("Expected identical contents to share 1 object, got %d", objects)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Errorf("Expected 2 checkpoints, got %d", len(list))
+	}
+}
+
+func TestRecordRejectsOutsideProject(t *testing.T) {
+	store := New(t.TempDir())
+
+	if err := store.Record(TaskRef("task-001"), "../outside.txt"); err == nil {
+		t.Error("Expected error for path outside project")
+	}
+	if _, _, err := store.Restore("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
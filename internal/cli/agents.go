@@ -0,0 +1,376 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/speier/smith/internal/engine"
+	"github.com/speier/smith/internal/eventbus"
+	"github.com/speier/smith/internal/registry"
+	"github.com/speier/smith/pkg/agent"
+	"github.com/speier/smith/pkg/agent/coordinator"
+	"github.com/spf13/cobra"
+)
+
+// staleHeartbeat is how long an agent may go without a heartbeat before ps flags it
+const staleHeartbeat = 30 * time.Second
+
+var (
+	agentsRunRole     string
+	agentsRunCount    int
+	agentsRunInterval time.Duration
+
+	agentsPsOutput string
+
+	agentsStopWait bool
+)
+
+var agentsCmd = &cobra.Command{
+	Use:   "agents",
+	Short: "Run and manage headless worker agents",
+	Long: `Run and manage headless worker agents.
+
+Workers can run in separate processes (tmux panes, systemd units) next to
+the TUI. The first smith process to open the project database serves it to
+the others over .smith/broker/smith.sock; if that process exits, another one
+takes over.
+
+Examples:
+  smith agents run --role keymaker --count 3
+  smith agents ps
+  smith agents stop keymaker-4211-2`,
+}
+
+var agentsRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run worker agents in this process until interrupted",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		role := engine.CanonicalRole(agentsRunRole)
+		newAgent, ok := agentConstructors[role]
+		if !ok {
+			return fmt.Errorf("unknown role %q (want architect, keymaker, sentinel or oracle)", agentsRunRole)
+		}
+		if agentsRunCount < 1 {
+			return fmt.Errorf("--count must be at least 1")
+		}
+
+		settings, err := resolveConfig(nil)
+		if err != nil {
+			return err
+		}
+
+		coord, err := coordinator.NewBolt(".")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = coord.Close() }()
+
+		eng, err := engine.New(engine.Config{ProjectPath: ".", Coordinator: coord, Settings: &settings.Config})
+		if err != nil {
+			return fmt.Errorf("creating engine: %w", err)
+		}
+		defer eng.Close()
+
+		// Nobody is at a terminal to approve blocked commands
+		eng.SetAgentApprovalCallback(func(req *engine.ApprovalRequest) (bool, bool) {
+			fmt.Printf("%s  %-22s denied: %s (%s)\n", time.Now().Format("15:04:05"), req.Agent.ID, req.Command, req.Reason)
+			return false, false
+		})
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		workers := make([]agent.Agent, agentsRunCount)
+		ids := make(map[string]bool)
+		for i := range workers {
+			id := fmt.Sprintf("%s-%d-%d", role, os.Getpid(), i+1)
+			ids[id] = true
+			workers[i] = newAgent(agent.Config{
+				AgentID:      id,
+				Coordinator:  coord,
+				Registry:     coord.GetRegistry(),
+				Engine:       eng,
+				PollInterval: agentsRunInterval,
+			})
+		}
+
+		fmt.Printf("Started %d %s agent(s) (pid %d); Ctrl+C to stop\n", len(workers), role, os.Getpid())
+
+		logCtx, stopLog := context.WithCancel(ctx)
+		defer stopLog()
+		go logAgentEvents(logCtx, coord, ids)
+
+		var wg sync.WaitGroup
+		for _, w := range workers {
+			wg.Add(1)
+			go func(w agent.Agent) {
+				defer wg.Done()
+				if err := w.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+					fmt.Fprintf(os.Stderr, "agent stopped: %v\n", err)
+				}
+				_ = w.Stop() // Unregister
+			}(w)
+		}
+		wg.Wait()
+
+		fmt.Println("All agents stopped")
+		return nil
+	},
+}
+
+// agentConstructors creates a worker for each agent role
+var agentConstructors = map[string]func(agent.Config) agent.Agent{
+	"architect": func(cfg agent.Config) agent.Agent { return agent.NewPlanningAgent(cfg) },
+	"keymaker":  func(cfg agent.Config) agent.Agent { return agent.NewImplementationAgent(cfg) },
+	"sentinel":  func(cfg agent.Config) agent.Agent { return agent.NewTestingAgent(cfg) },
+	"oracle":    func(cfg agent.Config) agent.Agent { return agent.NewReviewAgent(cfg) },
+}
+
+// logAgentEvents prints task claims by the given agents and the outcome of
+// the tasks they claimed
+func logAgentEvents(ctx context.Context, coord coordinator.Coordinator, agentIDs map[string]bool) {
+	bus := coord.GetEventBus()
+	filter := coordinator.EventFilter{EventTypes: []coordinator.EventType{
+		coordinator.EventType(eventbus.EventTaskClaimed),
+		coordinator.EventType(eventbus.EventTaskCompleted),
+		coordinator.EventType(eventbus.EventTaskFailed),
+		coordinator.EventType(eventbus.EventTaskCancelled),
+	}}
+	if latest, err := bus.Query(ctx, filter); err == nil && len(latest) > 0 {
+		filter.SinceID = latest[0].ID
+	}
+
+	claimedBy := make(map[string]string) // Task ID -> agent ID
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		events, err := bus.Query(ctx, filter)
+		if err != nil {
+			continue
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			event := events[i]
+			filter.SinceID = event.ID
+			if event.TaskID == nil {
+				continue
+			}
+			taskID := *event.TaskID
+
+			agentID := event.AgentID
+			if event.Type == coordinator.EventType(eventbus.EventTaskClaimed) {
+				if !agentIDs[agentID] {
+					continue
+				}
+				claimedBy[taskID] = agentID
+			} else if agentID = claimedBy[taskID]; agentID == "" {
+				continue
+			} else {
+				delete(claimedBy, taskID)
+			}
+
+			line := fmt.Sprintf("%s  %-22s %-16s %s", event.Timestamp.Local().Format("15:04:05"), agentID, event.Type, taskID)
+			if event.Type != coordinator.EventType(eventbus.EventTaskCompleted) {
+				// Results can be long; errors and reasons are short
+				if detail := formatEventData(event.Data); detail != "" {
+					line += " " + detail
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
+var agentsPsCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List registered agents with heartbeat age and current task",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		coord, err := coordinator.NewBolt(".")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = coord.Close() }()
+
+		ctx := context.Background()
+		agents, err := coord.Registry().List(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		// Claimed tasks record the agent working on them
+		current := make(map[string]string)
+		for _, status := range []string{"wip", "review"} {
+			tasks, err := coord.ListTasks(ctx, coordinator.TaskFilter{Status: status})
+			if err != nil {
+				return err
+			}
+			for _, task := range tasks {
+				if task.AgentID != "" {
+					current[task.AgentID] = task.ID
+				}
+			}
+		}
+
+		records := make([]agentRecord, len(agents))
+		for i, a := range agents {
+			records[i] = toAgentRecord(a, current[a.ID])
+		}
+
+		if agentsPsOutput != "table" {
+			return writeStructured(agentsPsOutput, records)
+		}
+
+		if len(records) == 0 {
+			fmt.Println("No agents")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tROLE\tSTATUS\tPID\tHEARTBEAT\tTASK")
+		for _, r := range records {
+			age := time.Since(r.LastHeartbeat).Round(time.Second)
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s ago\t%s\n", r.ID, r.Role, r.Status, r.PID, age, orDash(r.TaskID))
+		}
+		return w.Flush()
+	},
+}
+
+// agentRecord is the scriptable form of a registered agent
+type agentRecord struct {
+	ID            string    `json:"id" yaml:"id"`
+	Role          string    `json:"role" yaml:"role"`
+	Status        string    `json:"status" yaml:"status"`
+	PID           int       `json:"pid" yaml:"pid"`
+	TaskID        string    `json:"task_id,omitempty" yaml:"task_id,omitempty"`
+	StartedAt     time.Time `json:"started_at" yaml:"started_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat" yaml:"last_heartbeat"`
+}
+
+// toAgentRecord reports agents whose process is gone as dead and agents
+// without a recent heartbeat as stale
+func toAgentRecord(a registry.Agent, taskID string) agentRecord {
+	status := string(a.Status)
+	switch {
+	case a.PID > 0 && !processAlive(a.PID):
+		status = string(registry.StatusDead)
+	case time.Since(a.LastHeartbeat) > staleHeartbeat && a.Status != registry.StatusDead:
+		status = "stale"
+	}
+	if taskID == "" && a.TaskID != nil {
+		taskID = *a.TaskID
+	}
+	return agentRecord{
+		ID:            a.ID,
+		Role:          string(a.Role),
+		Status:        status,
+		PID:           a.PID,
+		TaskID:        taskID,
+		StartedAt:     a.StartedAt,
+		LastHeartbeat: a.LastHeartbeat,
+	}
+}
+
+var agentsStopCmd = &cobra.Command{
+	Use:   "stop <agent-id>...",
+	Short: "Ask agents to exit after their current task",
+	Long: `Ask agents to exit after their current task.
+
+Agents whose process is no longer running are removed from the registry
+right away.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		coord, err := coordinator.NewBolt(".")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = coord.Close() }()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		reg := coord.Registry()
+		var pending []string
+		for _, id := range args {
+			a, err := reg.Get(ctx, id)
+			if err != nil {
+				return fmt.Errorf("agent %s not found", id)
+			}
+
+			if a.PID > 0 && !processAlive(a.PID) {
+				if err := reg.Unregister(ctx, id); err != nil {
+					return err
+				}
+				fmt.Printf("Removed %s (process %d is gone)\n", id, a.PID)
+				continue
+			}
+
+			if err := reg.RequestStop(ctx, id); err != nil {
+				return err
+			}
+			fmt.Printf("Stopping %s after its current task\n", id)
+			pending = append(pending, id)
+		}
+
+		if !agentsStopWait {
+			return nil
+		}
+
+		// Stopped agents unregister themselves
+		for len(pending) > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(500 * time.Millisecond):
+			}
+			remaining := pending[:0]
+			for _, id := range pending {
+				if _, err := reg.Get(ctx, id); err == nil {
+					remaining = append(remaining, id)
+				} else {
+					fmt.Printf("%s stopped\n", id)
+				}
+			}
+			pending = remaining
+		}
+		return nil
+	},
+}
+
+// processAlive reports whether a process with the given PID exists
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
+
+func init() {
+	agentsRunCmd.Flags().StringVar(&agentsRunRole, "role", "", "agent role: architect, keymaker, sentinel or oracle")
+	agentsRunCmd.Flags().IntVar(&agentsRunCount, "count", 1, "number of agents to run")
+	agentsRunCmd.Flags().DurationVar(&agentsRunInterval, "poll-interval", time.Second, "how often idle agents check for tasks")
+	_ = agentsRunCmd.MarkFlagRequired("role")
+
+	agentsPsCmd.Flags().StringVarP(&agentsPsOutput, "output", "o", "table", "output format: table, json or yaml")
+
+	agentsStopCmd.Flags().BoolVar(&agentsStopWait, "wait", false, "wait until the agents have exited")
+
+	agentsCmd.AddCommand(agentsRunCmd)
+	agentsCmd.AddCommand(agentsPsCmd)
+	agentsCmd.AddCommand(agentsStopCmd)
+}
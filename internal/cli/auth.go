@@ -0,0 +1,381 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/speier/smith/internal/config"
+	"github.com/speier/smith/pkg/llm"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	authProfile    string
+	authPassphrase bool
+	authAll        bool
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Sign in to providers and manage saved logins",
+	Long: `Sign in to providers and manage saved logins.
+
+Logins are saved per provider and profile in ~/.smith/credentials.enc,
+encrypted with a random key kept in ~/.smith/credentials.key, or with a
+passphrase if the store is created with --passphrase or with
+` + config.PassphraseEnv + ` set. Passphrase-protected logins are unlocked
+with ` + config.PassphraseEnv + `, or asked for in a terminal.
+
+The profile defaults to the "profile" setting, so different projects can
+sign in with different accounts.
+
+Examples:
+  smith auth login copilot
+  smith auth login openrouter --profile work
+  smith auth status
+  smith auth list
+  smith auth logout openrouter --profile work`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login [provider]",
+	Short: "Sign in to a provider (the configured one if omitted)",
+	Long: `Sign in to a provider (the configured one if omitted).
+
+Copilot signs in with the GitHub device flow. OpenRouter asks for an API key
+(create one at https://openrouter.ai/keys), checks it and saves it; the
+key can also be piped in on stdin.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		providerID, profile, err := authTarget(args)
+		if err != nil {
+			return err
+		}
+		if err := unlockCredentials(authPassphrase); err != nil {
+			return err
+		}
+		_, err = signIn(bufio.NewReader(os.Stdin), providerID, profile, true)
+		return err
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout [provider]",
+	Short: "Remove the saved login of a provider (the configured one if omitted)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if authAll {
+			if err := config.ClearAuth(); err != nil {
+				return err
+			}
+			fmt.Println("Removed all saved logins")
+			return nil
+		}
+
+		providerID, profile, err := authTarget(args)
+		if err != nil {
+			return err
+		}
+		if err := unlockCredentials(false); err != nil {
+			return err
+		}
+		removed, err := config.DeleteAuth(providerID, profile)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("No saved login for %s (profile %s)\n", providerID, profile)
+			return nil
+		}
+		fmt.Printf("Signed out of %s (profile %s)\n", providerID, profile)
+		return nil
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show where logins are kept and which providers are signed in",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resolved, err := resolveConfig(nil)
+		if err != nil {
+			return err
+		}
+		profile := authProfile
+		if profile == "" {
+			profile = profileOf(&resolved.Config)
+		}
+
+		path, err := config.CredentialsPath()
+		if err != nil {
+			return err
+		}
+		encryption, err := config.CredentialsEncryption()
+		if err != nil {
+			return err
+		}
+		switch encryption {
+		case "":
+			fmt.Println("Credentials: none saved yet")
+		case config.EncryptionPassphrase:
+			fmt.Printf("Credentials: %s (encrypted with a passphrase)\n", path)
+		default:
+			keyPath, _ := config.KeyFilePath()
+			fmt.Printf("Credentials: %s (encrypted with key file %s)\n", path, keyPath)
+		}
+		if resolved.Provider != "" {
+			fmt.Printf("Provider:    %s (profile %s)\n", resolved.Provider, profile)
+		}
+		fmt.Println()
+
+		if err := unlockCredentials(false); err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "PROVIDER\tPROFILE\tSTATUS")
+		for _, p := range llm.GetAvailableProviders() {
+			status, err := loginStatus(p.ID, profile)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", p.ID, profile, status)
+		}
+		return w.Flush()
+	},
+}
+
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved logins of all providers and profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlockCredentials(false); err != nil {
+			return err
+		}
+		logins, err := config.ListAuth()
+		if err != nil {
+			return err
+		}
+		if len(logins) == 0 {
+			fmt.Println("No saved logins; run 'smith auth login'")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "PROVIDER\tPROFILE\tSAVED")
+		for _, login := range logins {
+			saved := "-"
+			if !login.UpdatedAt.IsZero() {
+				saved = login.UpdatedAt.Local().Format("2006-01-02 15:04")
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", login.Provider, login.Profile, saved)
+		}
+		return w.Flush()
+	},
+}
+
+// authTarget returns the provider and profile an auth command acts on:
+// the argument and --profile, or else the configured ones
+func authTarget(args []string) (string, string, error) {
+	resolved, err := resolveConfig(nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	providerID := resolved.Provider
+	if len(args) == 1 {
+		providerID = args[0]
+	}
+	if providerID == "" {
+		return "", "", fmt.Errorf("no provider configured; name one, e.g. 'smith auth login copilot'")
+	}
+	if err := config.ValidateKey("provider", providerID); err != nil {
+		return "", "", err
+	}
+
+	profile := authProfile
+	if profile == "" {
+		profile = profileOf(&resolved.Config)
+	}
+	if err := config.ValidateKey("profile", profile); err != nil {
+		return "", "", err
+	}
+	return providerID, profile, nil
+}
+
+// profileOf returns the configured credential profile
+func profileOf(settings *config.Config) string {
+	if settings.Profile == "" {
+		return config.DefaultProfile
+	}
+	return settings.Profile
+}
+
+// unlockCredentials makes sure saved logins can be decrypted: it asks for
+// the passphrase of a passphrase-protected store in a terminal when
+// SMITH_CREDENTIALS_PASSPHRASE is not set. With create, a store that doesn't
+// exist yet gets a new passphrase.
+func unlockCredentials(create bool) error {
+	if os.Getenv(config.PassphraseEnv) != "" {
+		return nil
+	}
+	encryption, err := config.CredentialsEncryption()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case encryption == config.EncryptionPassphrase:
+		passphrase, err := readSecret("Credentials passphrase: ")
+		if err != nil {
+			return fmt.Errorf("%w (%v)", config.ErrLocked, err)
+		}
+		return os.Setenv(config.PassphraseEnv, passphrase)
+
+	case create && encryption == "":
+		passphrase, err := readSecret("New credentials passphrase: ")
+		if err != nil {
+			return err
+		}
+		again, err := readSecret("Repeat passphrase: ")
+		if err != nil {
+			return err
+		}
+		if passphrase == "" || passphrase != again {
+			return fmt.Errorf("passphrases are empty or don't match")
+		}
+		return os.Setenv(config.PassphraseEnv, passphrase)
+
+	case create:
+		return fmt.Errorf("saved logins are encrypted with a key file; run 'smith auth logout --all' to start over with a passphrase")
+	}
+	return nil
+}
+
+// readSecret reads a line from the terminal without echoing it
+func readSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("stdin is not a terminal")
+	}
+	fmt.Print(prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	return string(secret), nil
+}
+
+// loginStatus describes the login of a provider profile
+func loginStatus(providerID, profile string) (string, error) {
+	if providerID == "openrouter" && os.Getenv("OPENROUTER_API_KEY") != "" {
+		return "signed in (OPENROUTER_API_KEY)", nil
+	}
+
+	auth, err := config.LoadAuth(providerID, profile)
+	if err != nil {
+		return "", err
+	}
+	if auth == nil {
+		return "not signed in", nil
+	}
+	if expiresAt, ok := auth.Data["expires_at"].(float64); ok {
+		expires := time.Unix(int64(expiresAt), 0)
+		if time.Now().After(expires) {
+			return "signed in (token expired, renewed on next request)", nil
+		}
+		return fmt.Sprintf("signed in (token valid until %s)", expires.Local().Format("2006-01-02 15:04")), nil
+	}
+	return "signed in", nil
+}
+
+// signIn returns an authenticated provider for a credential profile. The
+// saved login is reused unless force is set; otherwise Copilot runs the
+// GitHub device flow and OpenRouter asks for an API key.
+func signIn(in *bufio.Reader, providerID, profile string, force bool) (llm.Provider, error) {
+	switch providerID {
+	case "copilot":
+		provider := llm.NewCopilotProvider()
+		provider.SetProfile(profile)
+		if !force && provider.LoadAuth() == nil && provider.EnsureAuth() == nil {
+			fmt.Println("Signed in to GitHub Copilot")
+			return provider, nil
+		}
+
+		device, err := provider.Authorize()
+		if err != nil {
+			return nil, fmt.Errorf("starting GitHub sign-in: %w", err)
+		}
+		fmt.Printf("\nOpen %s and enter the code %s\nWaiting for authorization...\n", device.VerificationURI, device.UserCode)
+
+		interval := time.Duration(device.Interval) * time.Second
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		deadline := time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+		for time.Now().Before(deadline) {
+			time.Sleep(interval)
+			token, err := provider.PollForToken(device.DeviceCode)
+			if err != nil {
+				return nil, fmt.Errorf("GitHub sign-in: %w", err)
+			}
+			if token == "pending" {
+				continue
+			}
+			if err := provider.SetAuth(token); err != nil {
+				return nil, err
+			}
+			fmt.Printf("Signed in to GitHub Copilot (profile %s)\n", profile)
+			return provider, nil
+		}
+		return nil, fmt.Errorf("GitHub sign-in code expired; try again")
+
+	case "openrouter":
+		provider := llm.NewOpenRouterProvider()
+		provider.SetProfile(profile)
+		if !force {
+			if auth, err := config.LoadAuth("openrouter", profile); os.Getenv("OPENROUTER_API_KEY") != "" || (err == nil && auth != nil) {
+				return provider, nil
+			}
+		}
+
+		var key string
+		var err error
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Println("Create an API key at https://openrouter.ai/keys")
+			key, err = readSecret("OpenRouter API key: ")
+		} else {
+			key, err = readLine(in)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, fmt.Errorf("no API key given")
+		}
+		if err := provider.SetAuth(key); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Signed in to OpenRouter (profile %s)\n", profile)
+		return provider, nil
+
+	default:
+		return llm.NewProviderForProfile(providerID, profile)
+	}
+}
+
+func init() {
+	authCmd.PersistentFlags().StringVar(&authProfile, "profile", "", "credential profile (default: the profile setting, or \"default\")")
+	authLoginCmd.Flags().BoolVar(&authPassphrase, "passphrase", false, "protect a new credential store with a passphrase instead of a key file")
+	authLogoutCmd.Flags().BoolVar(&authAll, "all", false, "remove every saved login, the store and its key")
+
+	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authLogoutCmd)
+	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authListCmd)
+}
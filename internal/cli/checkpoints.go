@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/speier/smith/internal/checkpoint"
+	"github.com/spf13/cobra"
+)
+
+var checkpointsCmd = &cobra.Command{
+	Use:   "checkpoints",
+	Short: "List and restore file checkpoints",
+	Long: `List and restore file checkpoints.
+
+Every file changed by a tool call is snapshotted into .smith/checkpoints
+before it is modified, grouped by task (agent work) or chat turn.
+Restoring works without git and with uncommitted changes.
+
+Examples:
+  smith checkpoints list
+  smith checkpoints restore task-003
+  smith checkpoints restore turn-0012`,
+}
+
+var checkpointsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List checkpoints",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store := checkpoint.New(".")
+
+		checkpoints, err := store.List()
+		if err != nil {
+			return fmt.Errorf("listing checkpoints: %w", err)
+		}
+
+		if len(checkpoints) == 0 {
+			fmt.Println("No checkpoints")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tFILES\tUPDATED\tSTATUS")
+		for _, cp := range checkpoints {
+			status := "active"
+			if cp.RestoredAt != nil {
+				status = "restored"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", cp.ID, len(cp.Files), cp.UpdatedAt.Format("2006-01-02 15:04:05"), status)
+		}
+		return w.Flush()
+	},
+}
+
+var checkpointsRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Restore files to their state before a task or chat turn",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store := checkpoint.New(".")
+
+		_, restored, err := store.Restore(args[0])
+		if err != nil {
+			return fmt.Errorf("restoring checkpoint: %w", err)
+		}
+
+		fmt.Printf("Restored %d file(s) from %s\n", len(restored), args[0])
+		for _, path := range restored {
+			fmt.Printf("  %s\n", path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	checkpointsCmd.AddCommand(checkpointsListCmd)
+	checkpointsCmd.AddCommand(checkpointsRestoreCmd)
+}
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/speier/smith/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configGlobal     bool
+	configShowOrigin bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get and set configuration values",
+	Long: `Get and set configuration values.
+
+Settings live in the global config (~/.smith/config.yaml) and the project
+config (.smith/config.yaml). Later layers win: built-in defaults, global,
+project, SMITH_* environment variables (agents.oracle.autoLevel is
+SMITH_AGENTS_ORACLE_AUTO_LEVEL) and command flags. Keys are dotted paths
+into the file.
+
+Known keys:
+  ` + strings.Join(config.KnownKeys(), "\n  ") + `
+
+where * is an agent: architect, keymaker, sentinel or oracle.
+
+Examples:
+  smith config get
+  smith config get agents.oracle.model --show-origin
+  smith config set agents.oracle.model o1
+  smith config set --global provider copilot
+  smith config unset agents.oracle.model
+  smith config migrate`,
+}
+
+// resolveConfig resolves the project config with flag overrides and warns
+// about files that still use old keys
+func resolveConfig(flags map[string]string) (*config.Resolved, error) {
+	resolved, err := config.Resolve(".", flags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	for _, path := range resolved.Legacy {
+		fmt.Fprintf(os.Stderr, "warning: %s uses old config keys; run 'smith config migrate'\n", path)
+	}
+	return resolved, nil
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show effective values and the file each came from",
+	Long: `Show effective values and the file each came from.
+
+With a single setting as key, only its value is printed (add --show-origin
+for the file). Without a key, or with a section like "agents", every value
+underneath is listed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var key string
+		if len(args) == 1 {
+			key = args[0]
+		}
+
+		resolved, err := resolveConfig(nil)
+		if err != nil {
+			return err
+		}
+		values := resolved.Values(key)
+
+		if len(values) == 1 && values[0].Key == key {
+			if configShowOrigin {
+				fmt.Printf("%s\t%s\n", values[0].Value, values[0].Source)
+			} else {
+				fmt.Println(values[0].Value)
+			}
+			return nil
+		}
+		if len(values) == 0 {
+			if err := config.ValidateKey(key, ""); err != nil && !isSection(key) {
+				return err
+			}
+			return fmt.Errorf("%s is not set", key)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "KEY\tVALUE\tORIGIN")
+		for _, v := range values {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", v.Key, v.Value, v.Source)
+		}
+		return w.Flush()
+	},
+}
+
+// isSection reports whether key is a prefix of known settings, like "agents.oracle"
+func isSection(key string) bool {
+	for _, pattern := range config.KnownKeys() {
+		parts := strings.Split(pattern, ".")
+		keyParts := strings.Split(key, ".")
+		if len(keyParts) >= len(parts) {
+			continue
+		}
+		match := true
+		for i, part := range keyParts {
+			if parts[i] != "*" && parts[i] != part {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a value in the project config (or global with --global)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := args[0], args[1]
+
+		if configGlobal {
+			if err := config.SetGlobal(key, value); err != nil {
+				return err
+			}
+			path, _ := config.GlobalConfigPath()
+			fmt.Printf("Set %s = %s in %s\n", key, value, path)
+		} else {
+			if err := config.SetLocal(".", key, value); err != nil {
+				return err
+			}
+			fmt.Printf("Set %s = %s in %s\n", key, value, config.LocalConfigPath("."))
+		}
+		return nil
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a value from the project config (or global with --global)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+
+		var removed bool
+		var err error
+		path := config.LocalConfigPath(".")
+		if configGlobal {
+			removed, err = config.UnsetGlobal(key)
+			path, _ = config.GlobalConfigPath()
+		} else {
+			removed, err = config.UnsetLocal(".", key)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !removed {
+			fmt.Printf("%s is not set in %s\n", key, path)
+			return nil
+		}
+		fmt.Printf("Removed %s from %s\n", key, path)
+
+		// Show what takes effect now
+		if v, err := config.Lookup(".", key); err == nil && v != nil {
+			fmt.Printf("Effective value: %s (%s)\n", v.Value, v.Source)
+		}
+		return nil
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Rewrite old config keys (safety_level, agent_models) to the current schema",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := config.LocalConfigPath(".")
+		if configGlobal {
+			var err error
+			if path, err = config.GlobalConfigPath(); err != nil {
+				return err
+			}
+		}
+
+		renamed, err := config.Migrate(path)
+		if err != nil {
+			return err
+		}
+		if renamed == nil {
+			fmt.Printf("%s is up to date\n", path)
+			return nil
+		}
+		for _, r := range renamed {
+			fmt.Printf("  %s\n", r)
+		}
+		fmt.Printf("Migrated %s to version %d\n", path, config.CurrentVersion)
+		return nil
+	},
+}
+
+func init() {
+	configGetCmd.Flags().BoolVar(&configShowOrigin, "show-origin", false, "print the file the value came from")
+	configSetCmd.Flags().BoolVar(&configGlobal, "global", false, "write the global config instead of the project config")
+	configUnsetCmd.Flags().BoolVar(&configGlobal, "global", false, "edit the global config instead of the project config")
+	configMigrateCmd.Flags().BoolVar(&configGlobal, "global", false, "migrate the global config instead of the project config")
+
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configMigrateCmd)
+}
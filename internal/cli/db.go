@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/speier/smith/pkg/agent/storage"
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the project database",
+	Long: `Manage the project database (.smith/smith.db).
+
+The database is migrated automatically when smith opens it, after
+taking a backup next to the database file.
+
+Examples:
+  smith db stats
+  smith db migrate --dry-run
+  smith db compact --keep-days 30`,
+}
+
+var dbMigrateDryRun bool
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the database schema to the current version",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := storage.MigrateDatabase(storage.ProjectDatabasePath("."), dbMigrateDryRun)
+		if err != nil {
+			return fmt.Errorf("migrating database: %w", err)
+		}
+
+		if len(result.Applied) == 0 {
+			fmt.Printf("Database is up to date (schema v%d)\n", result.To)
+			return nil
+		}
+
+		verb := "Applied"
+		if dbMigrateDryRun {
+			verb = "Would apply"
+		}
+		fmt.Printf("%s %d migration(s), schema v%d -> v%d\n", verb, len(result.Applied), result.From, result.To)
+		for _, m := range result.Applied {
+			fmt.Printf("  %3d  %s\n", m.Version, m.Description)
+		}
+		if result.Backup != "" {
+			fmt.Printf("Backup: %s\n", result.Backup)
+		}
+		return nil
+	},
+}
+
+var dbStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show record counts and sizes per bucket",
+	Long: `Show record counts and sizes per bucket.
+
+While other smith processes are running, the stats come from the one
+serving the database to the others.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stats, err := storage.ProjectDatabaseStats(".")
+		if err != nil {
+			return fmt.Errorf("reading database stats: %w", err)
+		}
+
+		fmt.Printf("Database: %s (schema v%d)\n", stats.Path, stats.SchemaVersion)
+		fmt.Printf("File size: %s, free: %s\n\n", formatBytes(stats.FileSize), formatBytes(int64(stats.FreeBytes)))
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "BUCKET\tRECORDS\tSIZE")
+		for _, b := range stats.Buckets {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", b.Name, b.Records, formatBytes(int64(b.Bytes)))
+		}
+		return w.Flush()
+	},
+}
+
+var (
+	dbCompactKeepDays    int
+	dbCompactKeepPerTask int
+)
+
+var dbCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Prune old events and reclaim disk space",
+	Long: `Prune events outside the retention policy and compact the database file.
+
+Pruned events are archived to .smith/archive/ as gzipped NDJSON.
+The policy comes from the retention section of the config and can be
+overridden with flags. It is only applied by this command: smith never
+prunes events on its own. Stop other smith processes in the project first.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := make(map[string]string)
+		if cmd.Flags().Changed("keep-days") {
+			flags["retention.keepDays"] = strconv.Itoa(dbCompactKeepDays)
+		}
+		if cmd.Flags().Changed("keep-per-task") {
+			flags["retention.keepPerTask"] = strconv.Itoa(dbCompactKeepPerTask)
+		}
+		cfg, err := resolveConfig(flags)
+		if err != nil {
+			return err
+		}
+		retention := cfg.Retention
+
+		result, err := storage.CompactProject(".", storage.RetentionPolicy{
+			MaxAge:        time.Duration(retention.KeepDays) * 24 * time.Hour,
+			EventsPerTask: retention.KeepPerTask,
+		})
+		if err != nil {
+			return fmt.Errorf("compacting database: %w", err)
+		}
+
+		if result.Pruned > 0 {
+			fmt.Printf("Archived %d event(s) to %s\n", result.Pruned, result.Archive)
+		} else {
+			fmt.Println("No events to prune")
+		}
+		fmt.Printf("Database size: %s -> %s\n", formatBytes(result.SizeBefore), formatBytes(result.SizeAfter))
+		return nil
+	},
+}
+
+// formatBytes formats a byte count for display
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	dbMigrateCmd.Flags().BoolVar(&dbMigrateDryRun, "dry-run", false, "show pending migrations without applying them")
+	dbCompactCmd.Flags().IntVar(&dbCompactKeepDays, "keep-days", 0, "prune events older than this many days (0 keeps all)")
+	dbCompactCmd.Flags().IntVar(&dbCompactKeepPerTask, "keep-per-task", 0, "keep only the newest N events per task (0 keeps all)")
+
+	dbCmd.AddCommand(dbStatsCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbCompactCmd)
+}
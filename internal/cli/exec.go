@@ -2,29 +2,105 @@ package cli
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/speier/smith/internal/engine"
+	"github.com/speier/smith/pkg/llm"
 	"github.com/spf13/cobra"
 )
 
+// Exit codes of smith exec
+const (
+	ExitOK            = 0
+	ExitError         = 1 // Any other failure
+	ExitToolDenied    = 2 // A command was blocked by the auto-level
+	ExitProviderError = 3 // The LLM provider request failed
+	ExitTimeout       = 4 // --timeout elapsed
+	ExitMaxTurns      = 5 // --max-turns reached with tool calls pending
+)
+
+// CodeError is an error with a specific process exit code
+type CodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *CodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	execEphemeral    bool
+	execProvider     string
+	execModel        string
+	execAutoLevel    string
+	execOutput       string
+	execMaxTurns     int
+	execTimeout      time.Duration
+	execCassette     string
+	execCassetteMode string
+	execAttach       []string
+)
+
 var execCmd = &cobra.Command{
 	Use:   "exec [prompt]",
 	Short: "Execute a single command (non-interactive mode)",
 	Long: `Execute a single command in non-interactive mode.
 
+The prompt runs through the full agent loop: the model can call tools
+(read and edit files, run commands, manage tasks) until it answers.
+Commands not allowed by --auto-level are denied, since nobody is there
+to approve them.
+
 This is useful for:
 - Running from scripts or automation
 - Single-shot commands without entering REPL
 - Piping prompts from files or other commands
 
+Output formats:
+  text         the answer as it streams; reasoning and tool calls on stderr
+  json         one object with the answer, turns and token usage
+  stream-json  one JSON event per line: delta, reasoning, tool_call,
+               tool_result, usage
+
+With --llm-cassette, model requests and responses are recorded to a
+cassette file, or replayed from it without network if it already exists
+(see --llm-cassette-mode). Replays only answer requests that were recorded.
+
+With --attach, files go along with the prompt: images for models with
+vision, PDFs as documents and text files inline. Files the model can't
+take are rejected before anything is sent.
+
+Exit codes:
+  0  success
+  1  other error
+  2  a command was denied by the auto-level
+  3  the LLM provider failed
+  4  --timeout elapsed
+  5  --max-turns reached
+
 Examples:
   smith exec "analyze this file"
   smith exec - < prompt.txt
-  echo "review the API" | smith exec -`,
+  echo "review the API" | smith exec -
+  smith exec --auto-level low -o stream-json "fix the failing test"
+  smith exec --provider openrouter --model openai/gpt-4o "summarize README.md"
+  smith exec --ephemeral "explain main.go"   # leave no .smith/ state behind
+  smith exec --attach screenshot.png "why is the layout broken?"
+  smith exec --llm-cassette demo.json "explain main.go"   # record once, then replay offline`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var prompt string
 
@@ -54,22 +130,174 @@ Examples:
 			return fmt.Errorf("empty prompt")
 		}
 
-		// Create engine
-		eng, err := engine.New(engine.Config{
-			ProjectPath: ".",
+		settings, err := resolveConfig(map[string]string{
+			"provider":  execProvider,
+			"model":     execModel,
+			"autoLevel": execAutoLevel,
 		})
 		if err != nil {
-			return fmt.Errorf("creating engine: %w", err)
+			return err
+		}
+
+		var onEvent func(engine.RunEvent) error
+		switch execOutput {
+		case "text":
+			onEvent = printTextEvent
+		case "stream-json":
+			enc := json.NewEncoder(os.Stdout)
+			onEvent = func(event engine.RunEvent) error { return enc.Encode(event) }
+		case "json":
+		default:
+			return fmt.Errorf("unknown output format %q (want text, json or stream-json)", execOutput)
 		}
 
-		// Execute single command
-		fmt.Printf("💬 Prompt: %s\n\n", prompt)
-		response, err := eng.Chat(prompt)
+		cfg := engine.Config{
+			ProjectPath: ".",
+			Settings:    &settings.Config,
+			Ephemeral:   execEphemeral,
+		}
+		if execCassette != "" {
+			if cfg.LLMProvider, err = cassetteProvider(execCassette, execCassetteMode, settings.Provider, settings.Profile); err != nil {
+				return err
+			}
+		}
+
+		// Create engine
+		eng, err := engine.New(cfg)
 		if err != nil {
-			return fmt.Errorf("execution failed: %w", err)
+			return fmt.Errorf("creating engine: %w", err)
+		}
+		defer eng.Close()
+
+		for _, path := range execAttach {
+			if _, err := eng.Attach(path); err != nil {
+				return err
+			}
 		}
 
-		fmt.Printf("🕶️ Response:\n%s\n", response)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if execTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, execTimeout)
+			defer cancel()
+		}
+
+		result, err := eng.Run(ctx, prompt, engine.RunOptions{MaxTurns: execMaxTurns, OnEvent: onEvent})
+		code := execExitCode(err)
+
+		switch execOutput {
+		case "text":
+			if result != nil && result.Response != "" && !strings.HasSuffix(result.Response, "\n") {
+				fmt.Println()
+			}
+		case "json":
+			record := execRecord{ExitCode: code}
+			if result != nil {
+				record.Response, record.Turns, record.Usage = result.Response, result.Turns, result.Usage
+			}
+			if err != nil {
+				record.Error = err.Error()
+			}
+			if encErr := writeStructured("json", record); encErr != nil && err == nil {
+				return encErr
+			}
+		case "stream-json":
+			if err != nil {
+				_ = json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"type": "error", "error": err.Error(), "exit_code": code})
+			}
+		}
+
+		if err != nil {
+			return &CodeError{Code: code, Err: fmt.Errorf("execution failed: %w", err)}
+		}
 		return nil
 	},
 }
+
+// cassetteProvider replays the cassette at path, or records the configured
+// provider to it. In auto mode an existing file is replayed.
+func cassetteProvider(path, mode, providerID, profile string) (llm.Provider, error) {
+	if mode == "auto" {
+		mode = "record"
+		if _, err := os.Stat(path); err == nil {
+			mode = "replay"
+		}
+	}
+
+	switch mode {
+	case "replay":
+		return llm.LoadReplayProvider(path)
+	case "record":
+		var provider llm.Provider = llm.NewCopilotProvider()
+		if providerID != "" {
+			var err error
+			if provider, err = llm.NewProviderForProfile(providerID, profile); err != nil {
+				return nil, err
+			}
+		}
+		return llm.NewRecordingProvider(provider, path), nil
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q (want auto, record or replay)", mode)
+	}
+}
+
+// execRecord is the --output json result of smith exec
+type execRecord struct {
+	Response string       `json:"response"`
+	Turns    int          `json:"turns"`
+	Usage    engine.Usage `json:"usage"`
+	Error    string       `json:"error,omitempty"`
+	ExitCode int          `json:"exit_code"`
+}
+
+// execExitCode maps a Run error to the exit code documented for smith exec
+func execExitCode(err error) int {
+	var providerErr *engine.ProviderError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, engine.ErrCommandDenied):
+		return ExitToolDenied
+	case errors.As(err, &providerErr):
+		return ExitProviderError
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, engine.ErrMaxTurns):
+		return ExitMaxTurns
+	default:
+		return ExitError
+	}
+}
+
+// printTextEvent streams the answer to stdout and tool activity to stderr
+func printTextEvent(event engine.RunEvent) error {
+	switch event.Type {
+	case engine.RunEventDelta:
+		_, err := fmt.Print(event.Content)
+		return err
+	case engine.RunEventReasoning:
+		fmt.Fprint(os.Stderr, event.Content)
+	case engine.RunEventToolCall:
+		input, _ := json.Marshal(event.Input)
+		fmt.Fprintf(os.Stderr, "\n→ %s %s\n", event.Tool, input)
+	case engine.RunEventToolResult:
+		if event.Error != "" {
+			fmt.Fprintf(os.Stderr, "✗ %s: %s\n", event.Tool, event.Error)
+		}
+	}
+	return nil
+}
+
+func init() {
+	execCmd.Flags().BoolVar(&execEphemeral, "ephemeral", false, "keep tasks, events and checkpoints in memory instead of .smith/")
+	execCmd.Flags().StringVar(&execProvider, "provider", "", "provider to use instead of the configured one")
+	execCmd.Flags().StringVar(&execModel, "model", "", "model to use instead of the configured one")
+	execCmd.Flags().StringVar(&execAutoLevel, "auto-level", "", "commands allowed without approval: low, medium or high (default from config)")
+	execCmd.Flags().StringVarP(&execOutput, "output", "o", "text", "output format: text, json or stream-json")
+	execCmd.Flags().IntVar(&execMaxTurns, "max-turns", engine.DefaultMaxTurns, "maximum model round trips")
+	execCmd.Flags().StringVar(&execCassette, "llm-cassette", "", "record model traffic to this file, or replay it if it exists")
+	execCmd.Flags().StringVar(&execCassetteMode, "llm-cassette-mode", "auto", "cassette mode: auto, record or replay")
+	execCmd.Flags().StringArrayVar(&execAttach, "attach", nil, "attach an image, PDF or text file to the prompt (repeatable)")
+	execCmd.Flags().DurationVar(&execTimeout, "timeout", 0, "stop after this long (e.g. 5m); 0 means no limit")
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/speier/smith/internal/engine"
+	"github.com/speier/smith/pkg/llm"
+)
+
+func TestExecExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"other failure", errors.New("boom"), ExitError},
+		{"command denied", fmt.Errorf("running bash: %w", engine.ErrCommandDenied), ExitToolDenied},
+		{"provider error", &engine.ProviderError{Err: errors.New("503")}, ExitProviderError},
+		{"provider timeout", &engine.ProviderError{Err: context.DeadlineExceeded}, ExitProviderError},
+		{"timeout", fmt.Errorf("turn 3: %w", context.DeadlineExceeded), ExitTimeout},
+		{"max turns", engine.ErrMaxTurns, ExitMaxTurns},
+		{"cancelled", context.Canceled, ExitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execExitCode(tt.err); got != tt.want {
+				t.Errorf("execExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCassetteProvider(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.json")
+	if err := (&llm.Cassette{Version: llm.CassetteVersion}).Save(existing); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.json")
+
+	tests := []struct {
+		name       string
+		path       string
+		mode       string
+		providerID string
+		want       string // "record", "replay", or "" for an error
+	}{
+		{"auto replays an existing cassette", existing, "auto", "", "replay"},
+		{"auto records a new cassette", missing, "auto", "", "record"},
+		{"record overwrites", existing, "record", "", "record"},
+		{"record with a provider", missing, "record", "openrouter", "record"},
+		{"record with an unknown provider", missing, "record", "nonexistent", ""},
+		{"replay", existing, "replay", "", "replay"},
+		{"replay without a cassette", missing, "replay", "", ""},
+		{"unknown mode", existing, "rewind", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := cassetteProvider(tt.path, tt.mode, tt.providerID, "")
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %T", provider)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cassetteProvider failed: %v", err)
+			}
+			var got string
+			switch provider.(type) {
+			case *llm.RecordingProvider:
+				got = "record"
+			case *llm.ReplayProvider:
+				got = "replay"
+			}
+			if got != tt.want {
+				t.Errorf("got %T, want %s", provider, tt.want)
+			}
+		})
+	}
+}
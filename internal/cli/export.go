@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/speier/smith/pkg/agent/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportSession  string
+	exportOutput   string
+	exportFormat   string
+	exportMarkdown bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export tasks, events and usage to a bundle",
+	Long: `Export Smith state to a versioned bundle file.
+
+Exports one session (--session) or everything in the project database,
+including task learnings, events and LLM usage. The format follows the
+output extension (.json, .tar, .tar.gz) unless --format is given; tar
+bundles include a SUMMARY.md.
+
+Examples:
+  smith export --session session-2025-10-17-001 -o bug-report.tar.gz
+  smith export -o backup.json
+  smith export --session session-2025-10-17-001 --markdown`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(storage.ProjectDatabasePath(".")); err != nil {
+			return fmt.Errorf("no Smith database in this directory")
+		}
+
+		store, err := storage.InitProjectStorage(".")
+		if err != nil {
+			return fmt.Errorf("opening storage: %w", err)
+		}
+		defer func() { _ = store.Close() }()
+
+		bundle, err := storage.Export(context.Background(), store, exportSession)
+		if err != nil {
+			return fmt.Errorf("exporting: %w", err)
+		}
+
+		var out io.Writer = os.Stdout
+		if exportOutput != "" && exportOutput != "-" {
+			f, err := os.Create(exportOutput)
+			if err != nil {
+				return fmt.Errorf("creating output: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			out = f
+		}
+
+		if exportMarkdown {
+			_, err = io.WriteString(out, bundle.Markdown())
+			return err
+		}
+
+		format := exportFormat
+		if format == "" {
+			format = bundleFormatFor(exportOutput)
+		}
+		if err := storage.WriteBundle(out, bundle, format); err != nil {
+			return fmt.Errorf("writing bundle: %w", err)
+		}
+
+		if out != os.Stdout {
+			fmt.Fprintf(os.Stderr, "Exported %d task(s), %d event(s) to %s\n", len(bundle.Tasks), len(bundle.Events), exportOutput)
+		}
+		return nil
+	},
+}
+
+// bundleFormatFor picks a bundle format from an output file name
+func bundleFormatFor(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return storage.BundleTarGz
+	case strings.HasSuffix(path, ".tar"):
+		return storage.BundleTar
+	default:
+		return storage.BundleJSON
+	}
+}
+
+var (
+	importOnConflict string
+	importDryRun     bool
+	importSummary    bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Merge an exported bundle into this project",
+	Long: `Merge a bundle written by 'smith export' into this project's database.
+
+Records already present with identical content are skipped. Tasks and
+sessions whose ID is taken by a different record are renamed and every
+reference to them (dependencies, events, usage) is remapped, unless
+--on-conflict is skip or fail. Event IDs are always reassigned.
+
+Examples:
+  smith import bug-report.tar.gz --dry-run
+  smith import backup.json --on-conflict fail
+  smith import bug-report.tar.gz --summary`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("opening bundle: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			in = f
+		}
+
+		bundle, err := storage.ReadBundle(in)
+		if err != nil {
+			return fmt.Errorf("reading bundle: %w", err)
+		}
+
+		if importSummary {
+			fmt.Print(bundle.Markdown())
+			return nil
+		}
+
+		store, err := storage.InitProjectStorage(".")
+		if err != nil {
+			return fmt.Errorf("opening storage: %w", err)
+		}
+		defer func() { _ = store.Close() }()
+
+		result, err := storage.Import(context.Background(), store, bundle, storage.ImportOptions{
+			OnConflict: importOnConflict,
+			DryRun:     importDryRun,
+		})
+		if result != nil {
+			for _, c := range result.Conflicts {
+				if c.NewID != "" {
+					fmt.Printf("  %s %s exists, importing as %s\n", c.Kind, c.ID, c.NewID)
+				} else {
+					fmt.Printf("  %s %s exists, skipped\n", c.Kind, c.ID)
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("importing: %w", err)
+		}
+
+		verb := "Imported"
+		if importDryRun {
+			verb = "Would import"
+		}
+		fmt.Printf("%s %d session(s), %d task(s), %d event(s), %d usage record(s); %d already present\n",
+			verb, result.Sessions, result.Tasks, result.Events, result.Usage, result.Duplicates)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportSession, "session", "", "session to export (default: everything)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file (default: stdout)")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "bundle format: json, tar or tar.gz (default: from output extension)")
+	exportCmd.Flags().BoolVar(&exportMarkdown, "markdown", false, "write a Markdown summary instead of a bundle")
+
+	importCmd.Flags().StringVar(&importOnConflict, "on-conflict", storage.ConflictRename, "how to handle IDs taken by different records: rename, skip or fail")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "show what would be imported without writing")
+	importCmd.Flags().BoolVar(&importSummary, "summary", false, "print the bundle's Markdown summary and exit")
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/speier/smith/internal/config"
+	"github.com/speier/smith/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+// initModelChoices is how many models init lists before asking for an ID
+const initModelChoices = 20
+
+var (
+	initProvider string
+	initModel    string
+	initGlobal   bool
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up a provider and model for this project",
+	Long: `Set up a provider and model for this project.
+
+Picks a provider, signs in unless already signed in (GitHub device flow for
+Copilot, an API key for OpenRouter; see 'smith auth'), lists the models
+the provider offers and saves the choice to .smith/config.yaml, or to
+~/.smith/config.yaml with --global. The first setup is also saved as the
+global default for new projects.
+
+Examples:
+  smith init
+  smith init --provider copilot --model gpt-4o
+  smith init --global`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in := bufio.NewReader(os.Stdin)
+
+		providerID := initProvider
+		if providerID == "" {
+			providers := llm.GetAvailableProviders()
+			fmt.Println("Providers:")
+			for i, p := range providers {
+				fmt.Printf("  %d) %-16s %s\n", i+1, p.Name, p.Description)
+			}
+			choice, err := promptChoice(in, "Provider", len(providers))
+			if err != nil {
+				return err
+			}
+			providerID = providers[choice].ID
+		}
+
+		profile := config.DefaultProfile
+		if resolved, err := config.Resolve(".", nil); err == nil {
+			profile = profileOf(&resolved.Config)
+		}
+		if err := unlockCredentials(false); err != nil {
+			return err
+		}
+		provider, err := signIn(in, providerID, profile, false)
+		if err != nil {
+			return err
+		}
+
+		model := initModel
+		if model == "" {
+			models, err := provider.GetModels()
+			if err != nil {
+				return fmt.Errorf("listing models: %w", err)
+			}
+			model, err = promptModel(in, models)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The first setup also becomes the default for new projects
+		saveGlobal := initGlobal
+		if current, err := config.LookupGlobal("provider"); err == nil && current == "" {
+			saveGlobal = true
+		}
+
+		var paths []string
+		if saveGlobal {
+			if err := setProviderAndModel(config.SetGlobal, providerID, model); err != nil {
+				return err
+			}
+			path, _ := config.GlobalConfigPath()
+			paths = append(paths, path)
+		}
+		if !initGlobal {
+			smithDir := filepath.Join(".", ".smith")
+			if err := os.MkdirAll(smithDir, 0755); err != nil {
+				return fmt.Errorf("creating .smith: %w", err)
+			}
+			if err := config.InitProjectFiles(smithDir); err != nil {
+				return err
+			}
+			setLocal := func(key, value string) error { return config.SetLocal(".", key, value) }
+			if err := setProviderAndModel(setLocal, providerID, model); err != nil {
+				return err
+			}
+			paths = append(paths, config.LocalConfigPath("."))
+		}
+
+		fmt.Printf("\nUsing %s with model %s (saved to %s)\n", provider.GetName(), model, strings.Join(paths, " and "))
+		return nil
+	},
+}
+
+// setProviderAndModel writes the provider and model keys with set
+func setProviderAndModel(set func(key, value string) error, providerID, model string) error {
+	if err := set("provider", providerID); err != nil {
+		return err
+	}
+	return set("model", model)
+}
+
+// promptModel lists the first models and accepts a number or a model ID
+func promptModel(in *bufio.Reader, models []llm.Model) (string, error) {
+	shown := models
+	if len(shown) > initModelChoices {
+		shown = shown[:initModelChoices]
+	}
+
+	fmt.Println("\nModels:")
+	for i, m := range shown {
+		fmt.Printf("  %2d) %s\n", i+1, m.ID)
+	}
+	if len(models) > len(shown) {
+		fmt.Printf("  ... and %d more; type any model ID\n", len(models)-len(shown))
+	}
+
+	for {
+		fmt.Print("Model [1]: ")
+		answer, err := readLine(in)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			return shown[0].ID, nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(shown) {
+			return shown[n-1].ID, nil
+		}
+		for _, m := range models {
+			if m.ID == answer {
+				return m.ID, nil
+			}
+		}
+		fmt.Printf("Unknown model %q\n", answer)
+	}
+}
+
+// promptChoice asks for a number between 1 and n (default 1) and returns its index
+func promptChoice(in *bufio.Reader, label string, n int) (int, error) {
+	for {
+		fmt.Printf("%s [1]: ", label)
+		answer, err := readLine(in)
+		if err != nil {
+			return 0, err
+		}
+		if answer == "" {
+			return 0, nil
+		}
+		if choice, err := strconv.Atoi(answer); err == nil && choice >= 1 && choice <= n {
+			return choice - 1, nil
+		}
+		fmt.Printf("Enter a number from 1 to %d\n", n)
+	}
+}
+
+// readLine reads a trimmed line, failing when input ends before an answer
+func readLine(in *bufio.Reader) (string, error) {
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func init() {
+	initCmd.Flags().StringVar(&initProvider, "provider", "", "provider ID (copilot or openrouter); asked if empty")
+	initCmd.Flags().StringVar(&initModel, "model", "", "model ID; picked from the provider's list if empty")
+	initCmd.Flags().BoolVar(&initGlobal, "global", false, "save as the default for all projects instead of this project")
+}
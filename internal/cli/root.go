@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/speier/smith/internal/engine"
 	"github.com/speier/smith/internal/frontend"
 	"github.com/speier/smith/internal/version"
-	"github.com/speier/smith/pkg/agent/session"
+	"github.com/speier/smith/pkg/agent/coordinator"
+	"github.com/speier/smith/pkg/llm"
 	"github.com/speier/smith/pkg/lotus"
 	"github.com/spf13/cobra"
 )
@@ -28,12 +30,18 @@ The Oracle (review) sees quality and predicts issues.
 Just chat naturally and watch the agents multiply to build your software.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Create session
-		sess := session.NewMockSession()
+		sess, closeSession, err := newChatSession(nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting session: %v\n", err)
+			os.Exit(1)
+		}
 
 		// Create and run chat UI using Lotus runtime
 		// ReactDOM.render(<ChatUI />)
 		ui := frontend.NewChatUI(sess)
-		if err := lotus.Run(ui); err != nil {
+		err = lotus.Run(ui)
+		closeSession()
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error running chat UI: %v\n", err)
 			os.Exit(1)
 		}
@@ -49,6 +57,34 @@ Just chat naturally and watch the agents multiply to build your software.`,
 	SilenceUsage:       true,
 }
 
+// newChatSession creates the engine-backed session of the chat UI in the
+// current directory, using provider if set and the configured one otherwise.
+// The returned function closes the session, engine and coordinator.
+func newChatSession(provider llm.Provider) (*engine.ChatSession, func(), error) {
+	settings, err := resolveConfig(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coord, err := coordinator.NewBolt(".")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eng, err := engine.New(engine.Config{ProjectPath: ".", Coordinator: coord, Settings: &settings.Config, LLMProvider: provider})
+	if err != nil {
+		_ = coord.Close()
+		return nil, nil, fmt.Errorf("creating engine: %w", err)
+	}
+
+	sess := engine.NewChatSession(eng)
+	return sess, func() {
+		sess.Close()
+		eng.Close()
+		_ = coord.Close()
+	}, nil
+}
+
 // SetVersion sets the version for the CLI
 func SetVersion(v string) {
 	rootCmd.Version = v
@@ -76,6 +112,15 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.
 
 	// Add subcommands
 	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(checkpointsCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(tasksCmd)
+	rootCmd.AddCommand(agentsCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(authCmd)
 
 	// Disable auto-generated commands
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
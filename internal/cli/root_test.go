@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/speier/smith/internal/engine"
+	"github.com/speier/smith/pkg/llm"
+)
+
+// commandProvider asks to run one command, then answers without tools
+type commandProvider struct {
+	command string
+	calls   int
+}
+
+func (p *commandProvider) Chat(messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *commandProvider) ChatStream(messages []llm.Message, tools []llm.Tool, callback func(*llm.Response) error) error {
+	p.calls++
+	if p.calls > 1 {
+		return callback(&llm.Response{Content: "Done."})
+	}
+	return callback(&llm.Response{ToolCalls: []llm.ToolCall{{
+		Name:  "run_command",
+		Input: map[string]interface{}{"command": p.command},
+	}}})
+}
+
+func (p *commandProvider) GetModels() ([]llm.Model, error) { return nil, nil }
+func (p *commandProvider) GetName() string                 { return "command" }
+func (p *commandProvider) RequiresAuth() bool              { return false }
+
+func TestChatSessionDeliversAgentApprovals(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	sess, closeSession, err := newChatSession(&commandProvider{command: "git push origin main"})
+	if err != nil {
+		t.Fatalf("newChatSession failed: %v", err)
+	}
+	defer closeSession()
+	if sess.Approvals() == nil {
+		t.Fatal("expected the chat UI's session to deliver approvals")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = engine.WithAgent(ctx, engine.AgentInfo{ID: "agent-keymaker-001", Role: "keymaker"})
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := sess.Engine().ExecuteTask(ctx, "keymaker", "Publish", "Push the branch")
+		result <- err
+	}()
+
+	select {
+	case approval := <-sess.Approvals():
+		if approval.Agent != "agent-keymaker-001" || approval.Command != "git push origin main" {
+			t.Errorf("approval = %s: %s, want agent-keymaker-001: git push origin main", approval.Agent, approval.Command)
+		}
+		approval.Respond(false, false)
+	case <-ctx.Done():
+		t.Fatal("the agent's command never reached the session")
+	}
+
+	if err := <-result; !errors.Is(err, engine.ErrCommandDenied) {
+		t.Errorf("ExecuteTask error = %v, want ErrCommandDenied", err)
+	}
+}
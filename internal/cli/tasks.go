@@ -0,0 +1,520 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/speier/smith/internal/eventbus"
+	"github.com/speier/smith/pkg/agent/coordinator"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	tasksOutput string
+
+	tasksListStatus  string
+	tasksListRole    string
+	tasksListSession string
+
+	tasksCreateDescription string
+	tasksCreateRole        string
+	tasksCreatePriority    string
+	tasksCreateDependsOn   []string
+
+	tasksCancelReason string
+
+	tasksWatchTask string
+)
+
+var tasksCmd = &cobra.Command{
+	Use:   "tasks",
+	Short: "Inspect and manage coordinator tasks",
+	Long: `Inspect and manage the tasks agents work on.
+
+Every subcommand accepts -o table|json|yaml so results can be piped
+into other tools.
+
+Examples:
+  smith tasks list --status backlog
+  smith tasks show task-003 -o json
+  smith tasks create "Add rate limiting" --role implementation --priority high
+  smith tasks cancel task-004 --reason "out of scope"
+  smith tasks retry task-004
+  smith tasks prioritize task-005 high
+  smith tasks watch`,
+}
+
+var tasksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tasks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		coord, err := coordinator.NewBolt(".")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = coord.Close() }()
+
+		tasks, err := coord.ListTasks(context.Background(), coordinator.TaskFilter{
+			Status:    tasksListStatus,
+			Role:      tasksListRole,
+			SessionID: tasksListSession,
+		})
+		if err != nil {
+			return err
+		}
+
+		records := make([]taskRecord, len(tasks))
+		for i, task := range tasks {
+			records[i] = toTaskRecord(task)
+		}
+
+		if tasksOutput != "table" {
+			return writeStructured(tasksOutput, records)
+		}
+
+		if len(records) == 0 {
+			fmt.Println("No tasks")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tSTATUS\tPRIORITY\tROLE\tAGENT\tTITLE")
+		for _, r := range records {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Status, r.Priority, r.Role, orDash(r.AgentID), r.Title)
+		}
+		return w.Flush()
+	},
+}
+
+var tasksShowCmd = &cobra.Command{
+	Use:   "show <task-id>",
+	Short: "Show a task with its result, error and learnings",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		coord, err := coordinator.NewBolt(".")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = coord.Close() }()
+
+		task, err := coord.GetTask(args[0])
+		if err != nil {
+			return err
+		}
+
+		r := toTaskRecord(*task)
+		if tasksOutput != "table" {
+			return writeStructured(tasksOutput, r)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "ID:\t%s\n", r.ID)
+		_, _ = fmt.Fprintf(w, "Title:\t%s\n", r.Title)
+		_, _ = fmt.Fprintf(w, "Status:\t%s\n", r.Status)
+		_, _ = fmt.Fprintf(w, "Priority:\t%s\n", r.Priority)
+		_, _ = fmt.Fprintf(w, "Role:\t%s\n", r.Role)
+		_, _ = fmt.Fprintf(w, "Agent:\t%s\n", orDash(r.AgentID))
+		_, _ = fmt.Fprintf(w, "Session:\t%s\n", orDash(r.SessionID))
+		if len(r.DependsOn) > 0 {
+			_, _ = fmt.Fprintf(w, "Depends on:\t%s\n", strings.Join(r.DependsOn, ", "))
+		}
+		_, _ = fmt.Fprintf(w, "Updated:\t%s\n", r.UpdatedAt.Format("2006-01-02 15:04:05"))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		for _, section := range []struct{ name, body string }{
+			{"Description", r.Description},
+			{"Result", r.Result},
+			{"Error", r.Error},
+			{"Learnings", r.Learnings},
+		} {
+			if section.body != "" {
+				fmt.Printf("\n%s:\n  %s\n", section.name, strings.ReplaceAll(section.body, "\n", "\n  "))
+			}
+		}
+		for _, section := range []struct {
+			name  string
+			items []string
+		}{
+			{"Tried", r.TriedApproaches},
+			{"Blockers", r.Blockers},
+		} {
+			if len(section.items) > 0 {
+				fmt.Printf("\n%s:\n", section.name)
+				for _, item := range section.items {
+					fmt.Printf("  - %s\n", item)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+var tasksCreateCmd = &cobra.Command{
+	Use:   "create <title>",
+	Short: "Add a task to the backlog",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tasksCreateRole != "implementation" && tasksCreateRole != "testing" {
+			return fmt.Errorf("--role must be 'implementation' or 'testing'")
+		}
+		priority, err := parsePriority(tasksCreatePriority)
+		if err != nil {
+			return err
+		}
+
+		coord, err := coordinator.NewBolt(".")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = coord.Close() }()
+
+		opts := []coordinator.TaskOption{coordinator.WithPriority(priority)}
+		if len(tasksCreateDependsOn) > 0 {
+			opts = append(opts, coordinator.WithDependencies(tasksCreateDependsOn...))
+		}
+
+		description := tasksCreateDescription
+		if description == "" {
+			description = args[0]
+		}
+
+		taskID, err := coord.CreateTask(args[0], description, tasksCreateRole, opts...)
+		if err != nil {
+			return err
+		}
+
+		if tasksOutput != "table" {
+			task, err := coord.GetTask(taskID)
+			if err != nil {
+				return err
+			}
+			return writeStructured(tasksOutput, toTaskRecord(*task))
+		}
+		fmt.Println(taskID)
+		return nil
+	},
+}
+
+var tasksCancelCmd = &cobra.Command{
+	Use:   "cancel <task-id>",
+	Short: "Cancel a task so agents skip it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateTask(args[0], "Cancelled", func(coord coordinator.Coordinator) error {
+			return coord.CancelTask(args[0], tasksCancelReason)
+		})
+	},
+}
+
+var tasksRetryCmd = &cobra.Command{
+	Use:   "retry <task-id>",
+	Short: "Move a failed, cancelled or finished task back to the backlog",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateTask(args[0], "Requeued", func(coord coordinator.Coordinator) error {
+			return coord.RetryTask(args[0])
+		})
+	},
+}
+
+var tasksPrioritizeCmd = &cobra.Command{
+	Use:   "prioritize <task-id> <low|medium|high>",
+	Short: "Change a task's priority",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		priority, err := parsePriority(args[1])
+		if err != nil {
+			return err
+		}
+		return updateTask(args[0], "Reprioritized", func(coord coordinator.Coordinator) error {
+			return coord.SetTaskPriority(args[0], priority)
+		})
+	},
+}
+
+var tasksWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Stream task transitions as they happen",
+	Long: `Stream task events (created, claimed, completed, failed, cancelled, ...)
+until interrupted. With -o json, each event is printed as one JSON line.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		filter := coordinator.EventFilter{EventTypes: taskEventTypes}
+		if tasksWatchTask != "" {
+			filter.TaskID = &tasksWatchTask
+		}
+
+		// Start after the newest event so only new transitions are shown
+		latest, err := pollTaskEvents(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if len(latest) > 0 {
+			filter.SinceID = latest[0].ID
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			events, err := pollTaskEvents(ctx, filter)
+			if err != nil {
+				continue // Database busy (e.g. an engine is writing); try again
+			}
+
+			// Events come newest first; print in publish order
+			for i := len(events) - 1; i >= 0; i-- {
+				event := events[i]
+				filter.SinceID = event.ID
+				if tasksOutput == "json" {
+					if err := enc.Encode(toEventRecord(event)); err != nil {
+						return err
+					}
+					continue
+				}
+				taskID := ""
+				if event.TaskID != nil {
+					taskID = *event.TaskID
+				}
+				fmt.Printf("%s  %-10s %-16s %-12s %s\n",
+					event.Timestamp.Local().Format("15:04:05"), taskID, event.Type, event.AgentID, formatEventData(event.Data))
+			}
+		}
+	},
+}
+
+// pollTaskEvents opens the database just long enough to query events, so
+// watch doesn't hold the file lock other smith commands need
+func pollTaskEvents(ctx context.Context, filter coordinator.EventFilter) ([]coordinator.Event, error) {
+	coord, err := coordinator.NewBolt(".")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = coord.Close() }()
+
+	return coord.GetEventBus().Query(ctx, filter)
+}
+
+// taskEventTypes are the events that describe task transitions
+var taskEventTypes = []coordinator.EventType{
+	coordinator.EventType(eventbus.EventTaskCreated),
+	coordinator.EventType(eventbus.EventTaskClaimed),
+	coordinator.EventType(eventbus.EventTaskStarted),
+	coordinator.EventType(eventbus.EventTaskUpdated),
+	coordinator.EventType(eventbus.EventTaskCompleted),
+	coordinator.EventType(eventbus.EventTaskFailed),
+	coordinator.EventType(eventbus.EventTaskAbandoned),
+	coordinator.EventType(eventbus.EventTaskCancelled),
+}
+
+// taskRecord is the scriptable form of a task for JSON and YAML output
+type taskRecord struct {
+	ID              string            `json:"id" yaml:"id"`
+	Title           string            `json:"title" yaml:"title"`
+	Description     string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Status          string            `json:"status" yaml:"status"`
+	Priority        string            `json:"priority" yaml:"priority"`
+	Role            string            `json:"role" yaml:"role"`
+	AgentID         string            `json:"agent_id,omitempty" yaml:"agent_id,omitempty"`
+	SessionID       string            `json:"session_id,omitempty" yaml:"session_id,omitempty"`
+	DependsOn       []string          `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	Result          string            `json:"result,omitempty" yaml:"result,omitempty"`
+	Error           string            `json:"error,omitempty" yaml:"error,omitempty"`
+	Learnings       string            `json:"learnings,omitempty" yaml:"learnings,omitempty"`
+	TriedApproaches []string          `json:"tried_approaches,omitempty" yaml:"tried_approaches,omitempty"`
+	Blockers        []string          `json:"blockers,omitempty" yaml:"blockers,omitempty"`
+	Notes           map[string]string `json:"notes,omitempty" yaml:"notes,omitempty"`
+	StartedAt       time.Time         `json:"started_at" yaml:"started_at"`
+	UpdatedAt       time.Time         `json:"updated_at" yaml:"updated_at"`
+	CompletedAt     *time.Time        `json:"completed_at,omitempty" yaml:"completed_at,omitempty"`
+}
+
+func toTaskRecord(task coordinator.Task) taskRecord {
+	return taskRecord{
+		ID:              task.ID,
+		Title:           task.Title,
+		Description:     task.Description,
+		Status:          task.Status,
+		Priority:        priorityLabel(task.Priority),
+		Role:            task.Role,
+		AgentID:         task.AgentID,
+		SessionID:       task.SessionID,
+		DependsOn:       task.DependsOn,
+		Result:          task.Result,
+		Error:           task.Error,
+		Learnings:       task.Learnings,
+		TriedApproaches: task.TriedApproaches,
+		Blockers:        task.Blockers,
+		Notes:           task.Notes,
+		StartedAt:       task.StartedAt,
+		UpdatedAt:       task.UpdatedAt,
+		CompletedAt:     task.CompletedAt,
+	}
+}
+
+// eventRecord is the JSON form of a task event printed by watch
+type eventRecord struct {
+	ID        int64           `json:"id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Type      string          `json:"type"`
+	TaskID    string          `json:"task_id,omitempty"`
+	AgentID   string          `json:"agent_id"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+func toEventRecord(event coordinator.Event) eventRecord {
+	r := eventRecord{
+		ID:        event.ID,
+		Timestamp: event.Timestamp,
+		Type:      string(event.Type),
+		AgentID:   event.AgentID,
+	}
+	if event.TaskID != nil {
+		r.TaskID = *event.TaskID
+	}
+	if json.Valid([]byte(event.Data)) {
+		r.Data = json.RawMessage(event.Data)
+	}
+	return r
+}
+
+// formatEventData renders an event's JSON data as sorted key=value pairs, skipping empty values
+func formatEventData(data string) string {
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return data
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != "task_id" && fields[k] != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.Quote(fields[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// updateTask applies a change to a task and reports it in the requested format
+func updateTask(taskID, verb string, apply func(coordinator.Coordinator) error) error {
+	coord, err := coordinator.NewBolt(".")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = coord.Close() }()
+
+	if err := apply(coord); err != nil {
+		return err
+	}
+
+	task, err := coord.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+	if tasksOutput != "table" {
+		return writeStructured(tasksOutput, toTaskRecord(*task))
+	}
+	fmt.Printf("%s %s (status: %s, priority: %s)\n", verb, taskID, task.Status, priorityLabel(task.Priority))
+	return nil
+}
+
+// writeStructured prints v as JSON or YAML
+func writeStructured(format string, v interface{}) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unknown output format %q (want table, json or yaml)", format)
+	}
+}
+
+// parsePriority accepts low/medium/high or 0-2
+func parsePriority(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "low", "0":
+		return 0, nil
+	case "medium", "1":
+		return 1, nil
+	case "high", "2":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("invalid priority %q (want low, medium or high)", s)
+}
+
+func priorityLabel(priority int) string {
+	switch priority {
+	case 2:
+		return "high"
+	case 0:
+		return "low"
+	default:
+		return "medium"
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	tasksCmd.PersistentFlags().StringVarP(&tasksOutput, "output", "o", "table", "output format: table, json or yaml")
+
+	tasksListCmd.Flags().StringVar(&tasksListStatus, "status", "", "only tasks with this status (backlog, wip, review, done, cancelled)")
+	tasksListCmd.Flags().StringVar(&tasksListRole, "role", "", "only tasks for this agent role")
+	tasksListCmd.Flags().StringVar(&tasksListSession, "session", "", "only tasks from this session")
+
+	tasksCreateCmd.Flags().StringVarP(&tasksCreateDescription, "description", "d", "", "task description (default: the title)")
+	tasksCreateCmd.Flags().StringVar(&tasksCreateRole, "role", "implementation", "agent role: implementation or testing")
+	tasksCreateCmd.Flags().StringVar(&tasksCreatePriority, "priority", "medium", "priority: low, medium or high")
+	tasksCreateCmd.Flags().StringSliceVar(&tasksCreateDependsOn, "depends-on", nil, "task IDs that must be done first")
+
+	tasksCancelCmd.Flags().StringVar(&tasksCancelReason, "reason", "", "why the task was cancelled")
+
+	tasksWatchCmd.Flags().StringVar(&tasksWatchTask, "task", "", "only events for this task")
+
+	tasksCmd.AddCommand(tasksListCmd)
+	tasksCmd.AddCommand(tasksShowCmd)
+	tasksCmd.AddCommand(tasksCreateCmd)
+	tasksCmd.AddCommand(tasksCancelCmd)
+	tasksCmd.AddCommand(tasksRetryCmd)
+	tasksCmd.AddCommand(tasksPrioritizeCmd)
+	tasksCmd.AddCommand(tasksWatchCmd)
+}
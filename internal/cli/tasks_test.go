@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runSmith runs the smith command line with args and returns what it printed
+func runSmith(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		_, _ = io.Copy(&buf, r)
+		output <- buf.String()
+	}()
+
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	err = rootCmd.Execute()
+	w.Close()
+	return <-output, err
+}
+
+// runTask runs a tasks subcommand with -o json and decodes the task it prints
+func runTask(t *testing.T, args ...string) taskRecord {
+	t.Helper()
+	out, err := runSmith(t, append(append([]string{"tasks"}, args...), "-o", "json")...)
+	if err != nil {
+		t.Fatalf("smith tasks %s failed: %v", strings.Join(args, " "), err)
+	}
+	var record taskRecord
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatalf("decoding %q: %v", out, err)
+	}
+	return record
+}
+
+func TestTasksCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	out, err := runSmith(t, "tasks", "create", "Write parser", "-o", "table")
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	parser := strings.TrimSpace(out)
+	if parser == "" {
+		t.Fatal("expected create to print the task ID")
+	}
+
+	tests := runTask(t, "create", "Test parser", "--role", "testing", "--priority", "high", "--depends-on", parser)
+	if tests.Role != "testing" || tests.Priority != "high" || tests.Status != "backlog" {
+		t.Errorf("created %s/%s/%s, want testing/high/backlog", tests.Role, tests.Priority, tests.Status)
+	}
+	if len(tests.DependsOn) != 1 || tests.DependsOn[0] != parser {
+		t.Errorf("depends on %v, want [%s]", tests.DependsOn, parser)
+	}
+	if tests.Description != "Test parser" {
+		t.Errorf("expected the description to default to the title, got %q", tests.Description)
+	}
+
+	// list
+	out, err = runSmith(t, "tasks", "list", "-o", "json")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	var records []taskRecord
+	if err := json.Unmarshal([]byte(out), &records); err != nil {
+		t.Fatalf("decoding list %q: %v", out, err)
+	}
+	if len(records) != 2 {
+		t.Errorf("listed %d tasks, want 2", len(records))
+	}
+	out, err = runSmith(t, "tasks", "list", "-o", "table")
+	if err != nil {
+		t.Fatalf("list table failed: %v", err)
+	}
+	if !strings.HasPrefix(out, "ID") || !strings.Contains(out, "Write parser") || !strings.Contains(out, "Test parser") {
+		t.Errorf("unexpected table:\n%s", out)
+	}
+
+	// prioritize, cancel and retry
+	out, err = runSmith(t, "tasks", "prioritize", parser, "low", "-o", "table")
+	if err != nil {
+		t.Fatalf("prioritize failed: %v", err)
+	}
+	if want := "Reprioritized " + parser + " (status: backlog, priority: low)\n"; out != want {
+		t.Errorf("prioritize printed %q, want %q", out, want)
+	}
+	if r := runTask(t, "cancel", tests.ID, "--reason", "out of scope"); r.Status != "cancelled" {
+		t.Errorf("status after cancel = %q, want cancelled", r.Status)
+	}
+	if r := runTask(t, "retry", tests.ID); r.Status != "backlog" {
+		t.Errorf("status after retry = %q, want backlog", r.Status)
+	}
+
+	// show
+	out, err = runSmith(t, "tasks", "show", parser, "-o", "yaml")
+	if err != nil {
+		t.Fatalf("show failed: %v", err)
+	}
+	var shown taskRecord
+	if err := yaml.Unmarshal([]byte(out), &shown); err != nil {
+		t.Fatalf("decoding show %q: %v", out, err)
+	}
+	if shown.ID != parser || shown.Priority != "low" {
+		t.Errorf("showed %s with priority %s, want %s with low", shown.ID, shown.Priority, parser)
+	}
+
+	// Errors
+	for _, args := range [][]string{
+		{"tasks", "create", "Bad role", "--role", "review", "-o", "table"},
+		{"tasks", "create", "Bad priority", "--role", "implementation", "--priority", "urgent", "-o", "table"},
+		{"tasks", "show", "task-missing", "-o", "table"},
+		{"tasks", "prioritize", parser, "urgent", "-o", "table"},
+		{"tasks", "list", "-o", "xml"},
+	} {
+		if _, err := runSmith(t, args...); err == nil {
+			t.Errorf("expected smith %s to fail", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  int
+		label string
+	}{
+		{"low", 0, "low"},
+		{"0", 0, "low"},
+		{"Medium", 1, "medium"},
+		{"1", 1, "medium"},
+		{"HIGH", 2, "high"},
+		{"2", 2, "high"},
+	}
+	for _, tt := range tests {
+		got, err := parsePriority(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parsePriority(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+		if label := priorityLabel(got); label != tt.label {
+			t.Errorf("priorityLabel(%d) = %q, want %q", got, label, tt.label)
+		}
+	}
+	if _, err := parsePriority("3"); err == nil {
+		t.Error("expected priority 3 to be rejected")
+	}
+}
+
+func TestFormatEventData(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"task_id": "task-001", "title": "Write parser", "agent": "", "role": "testing"}`, `role="testing" title="Write parser"`},
+		{`{}`, ``},
+		{`not json`, `not json`},
+	}
+	for _, tt := range tests {
+		if got := formatEventData(tt.data); got != tt.want {
+			t.Errorf("formatEventData(%s) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,7 +12,7 @@ import (
 // Config Design Philosophy:
 // - Global config (~/.smith/config.yaml): User's default preferences across all projects
 // - Local config (.smith/config.yaml): Per-project overrides
-// - Local overrides global (simple merge)
+// - Layering (later wins): built-in defaults, global, local, SMITH_* env vars, CLI flags
 // - First run creates global with user's choices
 // - Local only created when user changes something for that project
 // - No local = uses global (sensible default)
@@ -22,150 +23,128 @@ import (
 // 3. Change in project: Save to local, global unchanged
 // 4. Switch projects: Each uses its own local or falls back to global
 
+// CurrentVersion is the config schema version written by this release.
+// Version 1 keys (safety_level, agent_models) are still read; Migrate rewrites them.
+const CurrentVersion = 2
+
 // AgentConfig represents configuration for a specific agent
 type AgentConfig struct {
 	Model     string `yaml:"model,omitempty"`
 	AutoLevel string `yaml:"autoLevel,omitempty"`
 	Reasoning string `yaml:"reasoning,omitempty"` // low/medium/high
+	Fallback  string `yaml:"fallback,omitempty"`  // Routes tried when the model fails (see ParseRoutes)
 }
 
-// Config represents Smith configuration (both global and local use same structure)
+// Config represents Smith configuration (global and local use the same schema)
 // Global: ~/.smith/config.yaml (user defaults)
 // Local: .smith/config.yaml (project overrides)
 type Config struct {
-	// Provider: github-copilot, openrouter, openai
+	// Config version
+	Version int `yaml:"version,omitempty"`
+
+	// Provider: copilot, openrouter, openai
 	// Available providers determined by: auth status + env vars
 	Provider string `yaml:"provider,omitempty"`
 
 	// Model: Default model when not specified
 	Model string `yaml:"model,omitempty"`
 
-	// Safety level: off, low, medium, high
-	SafetyLevel string `yaml:"safety_level,omitempty"`
+	// AutoLevel: commands allowed without approval (low, medium, high)
+	AutoLevel string `yaml:"autoLevel,omitempty"`
 
-	// Optional: Per-agent model overrides (advanced usage)
-	AgentModels map[string]string `yaml:"agent_models,omitempty"` // planning: claude-3.5-sonnet, etc.
+	// Optional: Reasoning effort (low, medium, high) for models that can
+	// think before answering; the model's own default if empty
+	Reasoning string `yaml:"reasoning,omitempty"`
 
-	// Config version
-	Version int `yaml:"version,omitempty"`
-}
+	// Optional: Credential profile used to sign in to providers, e.g. "work"
+	// (see 'smith auth login --profile'); DefaultProfile if empty
+	Profile string `yaml:"profile,omitempty"`
 
-// MergedConfig represents the result of merging global + local configs
-type MergedConfig struct {
-	Config
-	IsLocal bool // True if local config exists and was merged
-}
+	// Optional: Routes tried in order when the model fails with an auth
+	// error, rate limit or outage, e.g. "openrouter:anthropic/claude-3.5-sonnet"
+	Fallback string `yaml:"fallback,omitempty"`
 
-// LoadWithMerge loads both global and local config, merging them appropriately
-// Local config overrides global config for any non-empty fields
-func LoadWithMerge(projectPath string) (*MergedConfig, error) {
-	// Load global config first
-	globalCfg, err := loadGlobal()
-	if err != nil {
-		return nil, fmt.Errorf("loading global config: %w", err)
-	}
+	// Optional: Routes for request classes
+	Routing RoutingConfig `yaml:"routing,omitempty"`
 
-	// Try to load local config
-	localCfg, err := loadLocal(projectPath)
-	if err != nil {
-		return nil, fmt.Errorf("loading local config: %w", err)
-	}
-
-	// Start with global as base
-	merged := &MergedConfig{
-		Config:  *globalCfg,
-		IsLocal: localCfg != nil,
-	}
+	// Optional: Token budget of a session
+	Budget BudgetConfig `yaml:"budget,omitempty"`
 
-	// Merge local over global (local wins)
-	if localCfg != nil {
-		if localCfg.Provider != "" {
-			merged.Provider = localCfg.Provider
-		}
-		if localCfg.Model != "" {
-			merged.Model = localCfg.Model
-		}
-		if localCfg.SafetyLevel != "" {
-			merged.SafetyLevel = localCfg.SafetyLevel
-		}
-		if len(localCfg.AgentModels) > 0 {
-			if merged.AgentModels == nil {
-				merged.AgentModels = make(map[string]string)
-			}
-			for k, v := range localCfg.AgentModels {
-				merged.AgentModels[k] = v
-			}
-		}
-		if localCfg.Version > 0 {
-			merged.Version = localCfg.Version
-		}
-	}
+	// Optional: Per-agent overrides keyed by agent (architect, keymaker, sentinel, oracle)
+	Agents map[string]AgentConfig `yaml:"agents,omitempty"`
 
-	// Set defaults if still empty
-	if merged.SafetyLevel == "" {
-		merged.SafetyLevel = "medium"
-	}
-	if merged.Version == 0 {
-		merged.Version = 1
-	}
+	// Optional: Event log retention, applied by `smith db compact`
+	Retention RetentionConfig `yaml:"retention,omitempty"`
 
-	return merged, nil
+	// Optional: Local cache of idempotent LLM responses like task reports
+	Cache CacheConfig `yaml:"cache,omitempty"`
 }
 
-// loadGlobal loads global config from ~/.smith/config.yaml
-func loadGlobal() (*Config, error) {
-	configDir, err := GetConfigDir()
-	if err != nil {
-		return nil, err
-	}
+// DefaultCacheEntries is how many responses the response cache keeps
+const DefaultCacheEntries = 1000
 
-	configPath := filepath.Join(configDir, configFileName)
+// CacheConfig sets up the response cache in .smith/smith.db. It is off
+// while TTL is zero.
+type CacheConfig struct {
+	TTL        time.Duration `yaml:"ttl,omitempty"`        // How long a response is reused, e.g. 24h
+	MaxEntries int           `yaml:"maxEntries,omitempty"` // Oldest responses are dropped beyond this
+}
 
-	// Return empty config if file doesn't exist
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return &Config{
-			SafetyLevel: "medium",
-			Version:     1,
-		}, nil
-	}
+// RetentionConfig limits how many events are kept in .smith/smith.db.
+// Pruned events are archived under .smith/archive. Zero keeps everything.
+// The policy is only applied by 'smith db compact'; nothing is pruned while
+// smith runs.
+type RetentionConfig struct {
+	KeepDays    int `yaml:"keepDays,omitempty"`    // Prune events older than this many days
+	KeepPerTask int `yaml:"keepPerTask,omitempty"` // Keep only the newest N events of each task
+}
 
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("reading global config: %w", err)
-	}
+// RoutingConfig picks routes by request class
+type RoutingConfig struct {
+	Fast string `yaml:"fast,omitempty"` // Cheap, fast route for classification and summaries
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing global config: %w", err)
-	}
+// What happens when a session uses up budget.sessionTokens
+const (
+	BudgetDowngrade = "downgrade" // Continue on routing.fast
+	BudgetStop      = "stop"      // Fail further requests
+)
 
-	return &cfg, nil
+// BudgetConfig caps the tokens spent in a session. Zero means no cap.
+type BudgetConfig struct {
+	SessionTokens int    `yaml:"sessionTokens,omitempty"`
+	OnExceed      string `yaml:"onExceed,omitempty"` // BudgetDowngrade or BudgetStop
 }
 
-// loadLocal loads local config from .smith/config.yaml in project
-func loadLocal(projectPath string) (*Config, error) {
-	if projectPath == "" {
-		return nil, nil
+// Defaults returns the built-in configuration that config files override
+func Defaults() Config {
+	return Config{
+		Version:   CurrentVersion,
+		AutoLevel: "medium",
+		Agents:    make(map[string]AgentConfig),
+		Budget:    BudgetConfig{OnExceed: BudgetStop},
+		Cache:     CacheConfig{MaxEntries: DefaultCacheEntries},
 	}
+}
 
-	configPath := filepath.Join(projectPath, configDirName, configFileName)
-
-	// Return nil if file doesn't exist (not an error)
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, nil
+// Agent returns the settings of an agent, falling back to the main model,
+// auto-level, reasoning effort and fallback chain for anything the agent
+// doesn't override
+func (c *Config) Agent(name string) AgentConfig {
+	agent := c.Agents[name]
+	if agent.Model == "" {
+		agent.Model = c.Model
 	}
-
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("reading local config: %w", err)
+	if agent.AutoLevel == "" {
+		agent.AutoLevel = c.AutoLevel
 	}
-
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing local config: %w", err)
+	if agent.Reasoning == "" {
+		agent.Reasoning = c.Reasoning
 	}
-
-	return &cfg, nil
+	if agent.Fallback == "" {
+		agent.Fallback = c.Fallback
+	}
+	return agent
 }
 
 // SaveGlobal saves config to global ~/.smith/config.yaml
@@ -215,26 +194,10 @@ func (c *Config) SaveLocal(projectPath string) error {
 	return nil
 }
 
-// GetAgentModel returns the model for a specific agent, falling back to default
-func (c *Config) GetAgentModel(agentName string) string {
-	if c.AgentModels != nil {
-		if model, ok := c.AgentModels[agentName]; ok && model != "" {
-			return model
-		}
-	}
-	return c.Model
-}
-
-// Auth represents stored authentication data
-type Auth struct {
-	Provider string                 `yaml:"provider"`
-	Data     map[string]interface{} `yaml:"data"`
-}
-
 const (
 	configDirName  = ".smith"
 	configFileName = "config.yaml"
-	authFileName   = "auth.yaml"
+	authFileName   = "auth.yaml" // Single-provider logins of older releases
 )
 
 // GetConfigDir returns the path to Smith config directory
@@ -259,88 +222,3 @@ func EnsureConfigDir() (string, error) {
 
 	return configDir, nil
 }
-
-// Load loads the configuration from disk, returns default if not found
-// Deprecated: Use LoadWithMerge(projectPath) instead for proper global/local merging
-func Load() (*Config, error) {
-	return loadGlobal()
-}
-
-// Save saves the configuration to disk
-// Deprecated: Use SaveGlobal() or SaveLocal(projectPath) instead
-func (c *Config) Save() error {
-	return c.SaveGlobal()
-}
-
-// LoadAuth loads authentication data for a provider
-func LoadAuth(provider string) (*Auth, error) {
-	configDir, err := GetConfigDir()
-	if err != nil {
-		return nil, err
-	}
-
-	authPath := filepath.Join(configDir, authFileName)
-
-	// Return nil if file doesn't exist
-	if _, err := os.Stat(authPath); os.IsNotExist(err) {
-		return nil, nil
-	}
-
-	data, err := os.ReadFile(authPath)
-	if err != nil {
-		return nil, fmt.Errorf("reading auth: %w", err)
-	}
-
-	var auth Auth
-	if err := yaml.Unmarshal(data, &auth); err != nil {
-		return nil, fmt.Errorf("parsing auth: %w", err)
-	}
-
-	if auth.Provider != provider {
-		return nil, nil // Auth exists but for different provider
-	}
-
-	return &auth, nil
-}
-
-// SaveAuth saves authentication data for a provider
-func SaveAuth(provider string, data map[string]interface{}) error {
-	configDir, err := EnsureConfigDir()
-	if err != nil {
-		return err
-	}
-
-	authPath := filepath.Join(configDir, authFileName)
-
-	auth := Auth{
-		Provider: provider,
-		Data:     data,
-	}
-
-	yamlData, err := yaml.Marshal(auth)
-	if err != nil {
-		return fmt.Errorf("marshaling auth: %w", err)
-	}
-
-	if err := os.WriteFile(authPath, yamlData, 0600); err != nil {
-		return fmt.Errorf("writing auth: %w", err)
-	}
-
-	return nil
-}
-
-// ClearAuth removes authentication data
-func ClearAuth() error {
-	configDir, err := GetConfigDir()
-	if err != nil {
-		return err
-	}
-
-	authPath := filepath.Join(configDir, authFileName)
-
-	if err := os.Remove(authPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("removing auth: %w", err)
-	}
-
-	return nil
-}
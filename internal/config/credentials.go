@@ -0,0 +1,479 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Provider logins are kept in ~/.smith/credentials.enc, keyed by provider
+// and profile, and encrypted with AES-256-GCM. The key is derived from the
+// passphrase in SMITH_CREDENTIALS_PASSPHRASE if one is set when the store is
+// created, otherwise it is a random key kept in ~/.smith/credentials.key.
+// The store keeps the kind of key it was created with.
+
+// DefaultProfile is the profile used when none is configured
+const DefaultProfile = "default"
+
+// PassphraseEnv holds the passphrase of passphrase-protected credentials
+const PassphraseEnv = "SMITH_CREDENTIALS_PASSPHRASE"
+
+// How the credential store key is obtained
+const (
+	EncryptionPassphrase = "passphrase" // Derived from PassphraseEnv
+	EncryptionKeyFile    = "keyfile"    // Read from credentials.key
+)
+
+var (
+	// ErrLocked is returned for passphrase-protected credentials when
+	// PassphraseEnv is not set
+	ErrLocked = errors.New("credentials are protected by a passphrase; set " + PassphraseEnv)
+	// ErrWrongPassphrase is returned when the credentials can't be decrypted
+	ErrWrongPassphrase = errors.New("cannot decrypt credentials: wrong passphrase or key file")
+)
+
+const (
+	credentialsFileName = "credentials.enc"
+	keyFileName         = "credentials.key"
+	lockFileName        = "credentials.lock"
+	credentialsVersion  = 1
+	pbkdf2Iterations    = 600000
+)
+
+// Auth is the stored login of a provider profile
+type Auth struct {
+	Provider  string                 `json:"provider" yaml:"provider"`
+	Profile   string                 `json:"profile" yaml:"-"`
+	Data      map[string]interface{} `json:"data" yaml:"data"`
+	UpdatedAt time.Time              `json:"updated_at" yaml:"-"`
+}
+
+// credentialsFile is the encrypted store as written to disk
+type credentialsFile struct {
+	Version    int    `json:"version"`
+	Encryption string `json:"encryption"`           // EncryptionPassphrase or EncryptionKeyFile
+	Salt       []byte `json:"salt,omitempty"`       // Passphrase salt
+	Iterations int    `json:"iterations,omitempty"` // PBKDF2-SHA256 iterations
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"` // JSON list of Auth
+}
+
+// credentialStore is the decrypted store
+type credentialStore struct {
+	dir     string
+	header  credentialsFile // Encryption settings; empty until first saved
+	key     []byte
+	entries []Auth
+	legacy  bool // Entries came from auth.yaml
+}
+
+var (
+	credentialsMu sync.Mutex // Serializes the store within this process; see lockCredentials
+	derivedMu     sync.Mutex
+	derivedKeys   = make(map[[sha256.Size]byte][]byte) // PBKDF2 is slow; keys by passphrase and salt
+)
+
+// LoadAuth returns the login of a provider profile ("" is DefaultProfile),
+// or nil if there is none
+func LoadAuth(provider, profile string) (*Auth, error) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	s, err := openCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if i := s.find(provider, profile); i >= 0 {
+		auth := s.entries[i]
+		return &auth, nil
+	}
+	return nil, nil
+}
+
+// SaveAuth stores the login of a provider profile, replacing any previous one
+func SaveAuth(provider, profile string, data map[string]interface{}) error {
+	unlock, err := lockCredentials()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := openCredentials()
+	if err != nil {
+		return err
+	}
+	auth := Auth{Provider: provider, Profile: profileName(profile), Data: data, UpdatedAt: time.Now().UTC()}
+	if i := s.find(provider, profile); i >= 0 {
+		s.entries[i] = auth
+	} else {
+		s.entries = append(s.entries, auth)
+	}
+	return s.save()
+}
+
+// DeleteAuth removes the login of a provider profile and reports whether
+// there was one
+func DeleteAuth(provider, profile string) (bool, error) {
+	unlock, err := lockCredentials()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	s, err := openCredentials()
+	if err != nil {
+		return false, err
+	}
+	i := s.find(provider, profile)
+	if i < 0 {
+		return false, nil
+	}
+	s.entries = append(s.entries[:i], s.entries[i+1:]...)
+	return true, s.save()
+}
+
+// ListAuth returns all stored logins, sorted by provider and profile
+func ListAuth() ([]Auth, error) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	s, err := openCredentials()
+	if err != nil {
+		return nil, err
+	}
+	entries := append([]Auth{}, s.entries...)
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Provider != entries[j].Provider {
+			return entries[i].Provider < entries[j].Provider
+		}
+		return entries[i].Profile < entries[j].Profile
+	})
+	return entries, nil
+}
+
+// ClearAuth removes all stored logins along with the key file
+func ClearAuth() error {
+	unlock, err := lockCredentials()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{credentialsFileName, keyFileName, authFileName} {
+		if err := os.Remove(filepath.Join(configDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// lockCredentials takes the store for a read-modify-write, locking out
+// other goroutines and other smith processes, and returns its release.
+// Readers don't take it: the store and key file are replaced by rename or
+// written before the store that uses them.
+func lockCredentials() (func(), error) {
+	credentialsMu.Lock()
+	configDir, err := EnsureConfigDir()
+	if err != nil {
+		credentialsMu.Unlock()
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(configDir, lockFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		credentialsMu.Unlock()
+		return nil, fmt.Errorf("opening credentials lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		credentialsMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+		credentialsMu.Unlock()
+	}, nil
+}
+
+// CredentialsPath returns the path of the credential store
+func CredentialsPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, credentialsFileName), nil
+}
+
+// KeyFilePath returns the path of the key file of EncryptionKeyFile stores
+func KeyFilePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, keyFileName), nil
+}
+
+// CredentialsEncryption returns how the credential store is encrypted
+// (EncryptionPassphrase or EncryptionKeyFile), or "" if there is no store yet
+func CredentialsEncryption() (string, error) {
+	path, err := CredentialsPath()
+	if err != nil {
+		return "", err
+	}
+	header, err := readCredentialsFile(path)
+	if err != nil || header == nil {
+		return "", err
+	}
+	return header.Encryption, nil
+}
+
+func profileName(profile string) string {
+	if profile == "" {
+		return DefaultProfile
+	}
+	return profile
+}
+
+// find returns the index of a provider profile's login, or -1
+func (s *credentialStore) find(provider, profile string) int {
+	profile = profileName(profile)
+	for i, auth := range s.entries {
+		if auth.Provider == provider && auth.Profile == profile {
+			return i
+		}
+	}
+	return -1
+}
+
+// openCredentials reads and decrypts the store. Without a store, the login
+// in a legacy auth.yaml is returned; the next save moves it into the store.
+func openCredentials() (*credentialStore, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	s := &credentialStore{dir: configDir}
+
+	header, err := readCredentialsFile(filepath.Join(configDir, credentialsFileName))
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return s, s.readLegacy()
+	}
+	s.header = *header
+
+	if s.key, err = s.unlock(false); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(s.key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, header.Nonce, header.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plaintext, &s.entries); err != nil {
+		return nil, fmt.Errorf("parsing credentials: %w", err)
+	}
+	return s, nil
+}
+
+// readCredentialsFile returns the header of the store, or nil if there is none
+func readCredentialsFile(path string) (*credentialsFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading credentials: %w", err)
+	}
+	var header credentialsFile
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if header.Version > credentialsVersion {
+		return nil, fmt.Errorf("%s has version %d, newer than this smith supports (%d)", path, header.Version, credentialsVersion)
+	}
+	return &header, nil
+}
+
+// readLegacy reads the single login of auth.yaml into the default profile
+func (s *credentialStore) readLegacy() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, authFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading auth: %w", err)
+	}
+	var auth Auth
+	if err := yaml.Unmarshal(data, &auth); err != nil {
+		return fmt.Errorf("parsing auth: %w", err)
+	}
+	if auth.Provider != "" {
+		auth.Profile = DefaultProfile
+		s.entries = []Auth{auth}
+		s.legacy = true
+	}
+	return nil
+}
+
+// unlock returns the store key. With create, a new store picks its kind of
+// key: the passphrase if one is set, otherwise a new key file.
+func (s *credentialStore) unlock(create bool) ([]byte, error) {
+	keyPath := filepath.Join(s.dir, keyFileName)
+
+	if create {
+		if os.Getenv(PassphraseEnv) != "" {
+			salt := make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				return nil, fmt.Errorf("generating salt: %w", err)
+			}
+			s.header = credentialsFile{Encryption: EncryptionPassphrase, Salt: salt, Iterations: pbkdf2Iterations}
+			return s.unlock(false)
+		}
+		s.header = credentialsFile{Encryption: EncryptionKeyFile}
+		if key, err := os.ReadFile(keyPath); err == nil && len(key) == 32 {
+			return key, nil // Left behind by an earlier store
+		}
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generating key: %w", err)
+		}
+		if err := os.WriteFile(keyPath, key, 0600); err != nil {
+			return nil, fmt.Errorf("writing key file: %w", err)
+		}
+		return key, nil
+	}
+
+	switch s.header.Encryption {
+	case EncryptionPassphrase:
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" {
+			return nil, ErrLocked
+		}
+		return deriveKey(passphrase, s.header.Salt, s.header.Iterations)
+	case EncryptionKeyFile:
+		key, err := os.ReadFile(keyPath)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s is missing", ErrWrongPassphrase, keyPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading key file: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%w: %s holds %d bytes, not a 32-byte key", ErrWrongPassphrase, keyPath, len(key))
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unknown credentials encryption %q", s.header.Encryption)
+	}
+}
+
+// deriveKey derives the store key from a passphrase with PBKDF2-SHA256
+func deriveKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	id := sha256.Sum256(append(append([]byte(passphrase), 0), salt...))
+
+	derivedMu.Lock()
+	defer derivedMu.Unlock()
+	if key, ok := derivedKeys[id]; ok {
+		return key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	derivedKeys[id] = key
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("credentials key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// save encrypts the entries with a fresh nonce and replaces the store file
+func (s *credentialStore) save() error {
+	if _, err := EnsureConfigDir(); err != nil {
+		return err
+	}
+	if s.key == nil {
+		key, err := s.unlock(true)
+		if err != nil {
+			return err
+		}
+		s.key = key
+	}
+
+	plaintext, err := json.Marshal(s.entries)
+	if err != nil {
+		return fmt.Errorf("marshaling credentials: %w", err)
+	}
+	aead, err := newAEAD(s.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+
+	file := s.header
+	file.Version = credentialsVersion
+	file.Nonce = nonce
+	file.Ciphertext = aead.Seal(nil, nonce, plaintext, nil)
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling credentials: %w", err)
+	}
+
+	// A temp file of our own in the same directory, so the rename is atomic
+	tmp, err := os.CreateTemp(s.dir, credentialsFileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing credentials: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // Gone after the rename
+	_, err = tmp.Write(append(data, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(s.dir, credentialsFileName))
+	}
+	if err != nil {
+		return fmt.Errorf("writing credentials: %w", err)
+	}
+
+	if s.legacy {
+		// Moved into the store
+		if err := os.Remove(filepath.Join(s.dir, authFileName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", authFileName, err)
+		}
+		s.legacy = false
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package config
+
+import "os"
+
+// lockFile does nothing where there are no file locks; credentialsMu still
+// serializes the store within this process
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
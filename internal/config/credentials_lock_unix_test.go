@@ -0,0 +1,49 @@
+//go:build unix
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.lock")
+
+	// Each open file stands in for another process
+	open := func() *os.File {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	first, second := open(), open()
+
+	if err := lockFile(first); err != nil {
+		t.Fatalf("lockFile failed: %v", err)
+	}
+	locked := make(chan error, 1)
+	go func() { locked <- lockFile(second) }()
+
+	select {
+	case err := <-locked:
+		t.Fatalf("second lockFile returned %v while the first held the lock", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := unlockFile(first); err != nil {
+		t.Fatalf("unlockFile failed: %v", err)
+	}
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatalf("second lockFile failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lockFile still waiting after unlock")
+	}
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCredentials(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(PassphraseEnv, "")
+	smithDir := filepath.Join(home, ".smith")
+
+	// A login from an older release is read, then moved into the store
+	if err := os.MkdirAll(smithDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	legacy := "provider: copilot\ndata:\n  refresh_token: gho_legacy\n"
+	if err := os.WriteFile(filepath.Join(smithDir, "auth.yaml"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := LoadAuth("copilot", "")
+	if err != nil || auth == nil || auth.Data["refresh_token"] != "gho_legacy" {
+		t.Fatalf("LoadAuth of legacy auth = %+v, %v", auth, err)
+	}
+
+	if err := SaveAuth("openrouter", "", map[string]interface{}{"api_key": "sk-or-default"}); err != nil {
+		t.Fatalf("SaveAuth failed: %v", err)
+	}
+	if err := SaveAuth("openrouter", "work", map[string]interface{}{"api_key": "sk-or-work"}); err != nil {
+		t.Fatalf("SaveAuth failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(smithDir, "auth.yaml")); !os.IsNotExist(err) {
+		t.Errorf("auth.yaml still exists after saving: %v", err)
+	}
+
+	// Secrets are not stored in the clear
+	data, err := os.ReadFile(filepath.Join(smithDir, "credentials.enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"gho_legacy", "sk-or-default", "sk-or-work"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("credentials.enc contains %q in the clear", secret)
+		}
+	}
+	if encryption, _ := CredentialsEncryption(); encryption != EncryptionKeyFile {
+		t.Errorf("CredentialsEncryption = %q, want %q", encryption, EncryptionKeyFile)
+	}
+
+	logins, err := ListAuth()
+	if err != nil {
+		t.Fatalf("ListAuth failed: %v", err)
+	}
+	var got []string
+	for _, login := range logins {
+		got = append(got, login.Provider+"/"+login.Profile)
+	}
+	want := []string{"copilot/default", "openrouter/default", "openrouter/work"}
+	if len(got) != len(want) {
+		t.Fatalf("ListAuth = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ListAuth = %v, want %v", got, want)
+		}
+	}
+
+	auth, err = LoadAuth("openrouter", "work")
+	if err != nil || auth == nil || auth.Data["api_key"] != "sk-or-work" {
+		t.Errorf("LoadAuth(openrouter, work) = %+v, %v", auth, err)
+	}
+	if auth, err := LoadAuth("copilot", "work"); err != nil || auth != nil {
+		t.Errorf("LoadAuth of a missing profile = %+v, %v; want nil", auth, err)
+	}
+
+	if removed, err := DeleteAuth("openrouter", "work"); err != nil || !removed {
+		t.Errorf("DeleteAuth = %v, %v", removed, err)
+	}
+	if removed, err := DeleteAuth("openrouter", "work"); err != nil || removed {
+		t.Errorf("second DeleteAuth = %v, %v; want false", removed, err)
+	}
+
+	// Without its key file the store can't be read
+	keyPath, _ := KeyFilePath()
+	if err := os.Rename(keyPath, keyPath+".bak"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAuth("copilot", ""); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("LoadAuth without key file: err = %v, want ErrWrongPassphrase", err)
+	}
+	// Nor with a key file that isn't a key
+	if err := os.WriteFile(keyPath, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAuth("copilot", ""); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("LoadAuth with a short key file: err = %v, want ErrWrongPassphrase", err)
+	}
+	if err := os.Rename(keyPath+".bak", keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ClearAuth(); err != nil {
+		t.Fatalf("ClearAuth failed: %v", err)
+	}
+	if logins, err := ListAuth(); err != nil || len(logins) != 0 {
+		t.Errorf("ListAuth after ClearAuth = %v, %v", logins, err)
+	}
+}
+
+func TestCredentialsPassphrase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(PassphraseEnv, "correct horse")
+
+	if err := SaveAuth("copilot", "", map[string]interface{}{"refresh_token": "gho_secret"}); err != nil {
+		t.Fatalf("SaveAuth failed: %v", err)
+	}
+	if encryption, _ := CredentialsEncryption(); encryption != EncryptionPassphrase {
+		t.Errorf("CredentialsEncryption = %q, want %q", encryption, EncryptionPassphrase)
+	}
+	keyPath, _ := KeyFilePath()
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Errorf("passphrase store wrote a key file: %v", err)
+	}
+
+	auth, err := LoadAuth("copilot", "")
+	if err != nil || auth == nil || auth.Data["refresh_token"] != "gho_secret" {
+		t.Fatalf("LoadAuth = %+v, %v", auth, err)
+	}
+
+	t.Setenv(PassphraseEnv, "")
+	if _, err := LoadAuth("copilot", ""); !errors.Is(err, ErrLocked) {
+		t.Errorf("LoadAuth without passphrase: err = %v, want ErrLocked", err)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	if _, err := LoadAuth("copilot", ""); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("LoadAuth with wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+	if err := SaveAuth("openrouter", "", map[string]interface{}{"api_key": "x"}); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("SaveAuth with wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestCredentialsConcurrentSave(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(PassphraseEnv, "")
+
+	// Every save sees the ones before it, so no login is lost
+	const n = 8
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := SaveAuth("openrouter", fmt.Sprintf("p%d", i), map[string]interface{}{"api_key": "k"}); err != nil {
+				t.Errorf("SaveAuth failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if logins, err := ListAuth(); err != nil || len(logins) != n {
+		t.Errorf("ListAuth = %d logins, %v; want %d", len(logins), err, n)
+	}
+	tmps, _ := filepath.Glob(filepath.Join(home, ".smith", "*.tmp"))
+	if len(tmps) != 0 {
+		t.Errorf("temp files left behind: %v", tmps)
+	}
+}
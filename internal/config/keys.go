@@ -0,0 +1,483 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are addressed by dotted keys into the YAML file, e.g.
+// "agents.oracle.model". Keys are edited in the parsed document so comments
+// in the file survive a set or unset.
+
+// SourceDefault is the source of values that no config file sets
+const SourceDefault = "default"
+
+// agentNames are the agents that accept per-agent settings
+var agentNames = []string{"architect", "keymaker", "sentinel", "oracle"}
+
+// keySpec describes a known setting; "*" in a pattern matches an agent name
+type keySpec struct {
+	pattern string
+	check   func(value string) error
+}
+
+var knownKeys = []keySpec{
+	{"provider", oneOf(GetAvailableProviders()...)},
+	{"model", nil},
+	{"autoLevel", oneOf("low", "medium", "high")},
+	{"reasoning", oneOf("low", "medium", "high")},
+	{"profile", validProfile},
+	{"agents.*.model", nil},
+	{"agents.*.autoLevel", oneOf("low", "medium", "high")},
+	{"agents.*.reasoning", oneOf("low", "medium", "high")},
+	{"agents.*.fallback", validRoutes},
+	{"fallback", validRoutes},
+	{"routing.fast", validRoute},
+	{"budget.sessionTokens", nonNegative},
+	{"budget.onExceed", oneOf(BudgetDowngrade, BudgetStop)},
+	{"retention.keepDays", nonNegative},
+	{"retention.keepPerTask", nonNegative},
+	{"cache.ttl", validDuration},
+	{"cache.maxEntries", nonNegative},
+	{"version", supportedVersion},
+}
+
+// legacyAgentNames maps the role names used by version 1 configs to agents
+var legacyAgentNames = map[string]string{
+	"planning":       "architect",
+	"implementation": "keymaker",
+	"testing":        "sentinel",
+	"review":         "oracle",
+}
+
+// legacySnakeKeys are settings first released with snake_case names
+var legacySnakeKeys = map[string]string{
+	"budget.session_tokens":   "budget.sessionTokens",
+	"budget.on_exceed":        "budget.onExceed",
+	"retention.keep_days":     "retention.keepDays",
+	"retention.keep_per_task": "retention.keepPerTask",
+	"cache.max_entries":       "cache.maxEntries",
+}
+
+// legacyKey maps a version 1 or snake_case setting to its current key and value
+func legacyKey(key, value string) (string, string, bool) {
+	if newKey, ok := legacySnakeKeys[key]; ok {
+		return newKey, value, true
+	}
+	switch {
+	case key == "safety_level":
+		if value == "off" {
+			value = "high" // No restrictions
+		}
+		return "autoLevel", value, true
+	case strings.HasPrefix(key, "agent_models."):
+		name := strings.TrimPrefix(key, "agent_models.")
+		if agent, ok := legacyAgentNames[name]; ok {
+			name = agent
+		}
+		return "agents." + name + ".model", value, true
+	case strings.HasPrefix(key, "agents."):
+		parts := strings.SplitN(key, ".", 3)
+		if agent, ok := legacyAgentNames[parts[1]]; ok && len(parts) == 3 {
+			return "agents." + agent + "." + parts[2], value, true
+		}
+	}
+	return "", "", false
+}
+
+// Value is an effective setting and the file it came from
+type Value struct {
+	Key    string
+	Value  string
+	Source string // Config file path, or SourceDefault
+}
+
+// GlobalConfigPath returns the path of the global config (~/.smith/config.yaml)
+func GlobalConfigPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, configFileName), nil
+}
+
+// LocalConfigPath returns the path of the project config (.smith/config.yaml)
+func LocalConfigPath(projectPath string) string {
+	return filepath.Join(projectPath, configDirName, configFileName)
+}
+
+// ValidateKey checks that key is a known setting and value is allowed for it.
+// An empty value only checks the key.
+func ValidateKey(key, value string) error {
+	for _, spec := range knownKeys {
+		if !matchKey(spec.pattern, key) {
+			continue
+		}
+		if value == "" || spec.check == nil {
+			return nil
+		}
+		if err := spec.check(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		return nil
+	}
+	if newKey, _, ok := legacyKey(key, ""); ok {
+		return fmt.Errorf("config key %q was renamed to %q (run 'smith config migrate')", key, newKey)
+	}
+	return fmt.Errorf("unknown config key %q", key)
+}
+
+// KnownKeys returns the patterns of all known settings
+func KnownKeys() []string {
+	keys := make([]string, len(knownKeys))
+	for i, spec := range knownKeys {
+		keys[i] = spec.pattern
+	}
+	return keys
+}
+
+// matchKey reports whether key matches pattern, where "*" matches an agent name
+func matchKey(pattern, key string) bool {
+	patternParts := strings.Split(pattern, ".")
+	keyParts := strings.Split(key, ".")
+	if len(patternParts) != len(keyParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part == "*" {
+			if !contains(agentNames, keyParts[i]) {
+				return false
+			}
+		} else if part != keyParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func oneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		if contains(allowed, value) {
+			return nil
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+// validProfile accepts credential profile names: letters, digits, "-" and "_"
+func validProfile(value string) error {
+	for _, r := range value {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return fmt.Errorf("%q is not a valid profile name (letters, digits, - and _)", value)
+		}
+	}
+	return nil
+}
+
+func supportedVersion(value string) error {
+	if err := nonNegative(value); err != nil {
+		return err
+	}
+	if n, _ := strconv.Atoi(value); n > CurrentVersion {
+		return fmt.Errorf("version %d is newer than this smith supports (%d)", n, CurrentVersion)
+	}
+	return nil
+}
+
+func nonNegative(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q is not a non-negative number", value)
+	}
+	return nil
+}
+
+func validDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("%q is not a duration like 30m or 24h", value)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupGlobal returns the value of a key in the global config alone, or ""
+func LookupGlobal(key string) (string, error) {
+	path, err := GlobalConfigPath()
+	if err != nil {
+		return "", err
+	}
+	doc, err := readConfigNode(path)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	flattenNode(docRoot(doc), "", func(k string, node *yaml.Node) {
+		if k == key {
+			value = node.Value
+		}
+	})
+	return value, nil
+}
+
+// SetGlobal sets a key in the global config
+func SetGlobal(key, value string) error {
+	configDir, err := EnsureConfigDir()
+	if err != nil {
+		return err
+	}
+	return setValue(filepath.Join(configDir, configFileName), key, value, 0600)
+}
+
+// SetLocal sets a key in the project config
+func SetLocal(projectPath, key, value string) error {
+	configDir := filepath.Join(projectPath, configDirName)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+	return setValue(LocalConfigPath(projectPath), key, value, 0644)
+}
+
+// UnsetGlobal removes a key from the global config and reports whether it was set
+func UnsetGlobal(key string) (bool, error) {
+	path, err := GlobalConfigPath()
+	if err != nil {
+		return false, err
+	}
+	return unsetValue(path, key)
+}
+
+// UnsetLocal removes a key from the project config and reports whether it was set
+func UnsetLocal(projectPath, key string) (bool, error) {
+	return unsetValue(LocalConfigPath(projectPath), key)
+}
+
+// setValue writes key=value into the YAML file at path, creating the file
+// and intermediate mappings as needed
+func setValue(path, key, value string, perm os.FileMode) error {
+	if err := ValidateKey(key, value); err != nil {
+		return err
+	}
+
+	doc, err := readConfigNode(path)
+	if err != nil {
+		return err
+	}
+	if docRoot(doc) == nil {
+		// Missing or comment-only file
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+
+	setKey(docRoot(doc), strings.Split(key, "."), value)
+	return writeConfigNode(path, doc, perm)
+}
+
+// setKey sets the key path in a mapping, creating intermediate mappings
+func setKey(node *yaml.Node, parts []string, value string) {
+	for i, part := range parts {
+		if node.Kind != yaml.MappingNode {
+			// A null or scalar parent (e.g. "retention:") becomes a mapping
+			*node = yaml.Node{Kind: yaml.MappingNode}
+		}
+		child := mappingValue(node, part)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, child)
+		}
+		if i == len(parts)-1 {
+			*child = yaml.Node{Kind: yaml.ScalarNode, Value: value, LineComment: child.LineComment}
+		}
+		node = child
+	}
+}
+
+// unsetValue removes key from the YAML file at path, dropping mappings that
+// become empty
+func unsetValue(path, key string) (bool, error) {
+	if err := ValidateKey(key, ""); err != nil {
+		return false, err
+	}
+
+	doc, err := readConfigNode(path)
+	if err != nil {
+		return false, err
+	}
+
+	root := docRoot(doc)
+	if root == nil || !removeKey(root, strings.Split(key, ".")) {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return true, writeConfigNode(path, doc, info.Mode().Perm())
+}
+
+// Migrate rewrites version 1 keys in the config file at path to the current
+// schema and returns the keys it renamed. Current keys already in the file
+// win over the legacy ones they replace.
+func Migrate(path string) ([]string, error) {
+	doc, err := readConfigNode(path)
+	if err != nil {
+		return nil, err
+	}
+	root := docRoot(doc)
+	if root == nil {
+		return nil, nil
+	}
+
+	type rename struct{ from, to, value string }
+	var renames []rename
+	current := make(map[string]bool)
+	version := 1
+	flattenNode(root, "", func(key string, node *yaml.Node) {
+		if newKey, value, ok := legacyKey(key, node.Value); ok {
+			renames = append(renames, rename{key, newKey, value})
+			return
+		}
+		if key == "version" {
+			version, _ = strconv.Atoi(node.Value)
+		}
+		if node.Value != "" {
+			current[key] = true
+		}
+	})
+	if len(renames) == 0 && version >= CurrentVersion {
+		return nil, nil
+	}
+
+	renamed := []string{}
+	for _, r := range renames {
+		removeKey(root, strings.Split(r.from, "."))
+		if !current[r.to] {
+			setKey(root, strings.Split(r.to, "."), r.value)
+		}
+		renamed = append(renamed, r.from+" -> "+r.to)
+	}
+	setKey(root, []string{"version"}, strconv.Itoa(CurrentVersion))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return renamed, writeConfigNode(path, doc, info.Mode().Perm())
+}
+
+// removeKey deletes the key path from a mapping and reports whether it existed
+func removeKey(node *yaml.Node, parts []string) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != parts[0] {
+			continue
+		}
+		value := node.Content[i+1]
+		if len(parts) > 1 {
+			if !removeKey(value, parts[1:]) {
+				return false
+			}
+			if len(value.Content) > 0 {
+				return true
+			}
+		}
+		node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		return true
+	}
+	return false
+}
+
+// mappingValue returns the value node for key in a mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// flattenNode calls fn with the dotted key of every non-mapping value under
+// node; null values are skipped
+func flattenNode(node *yaml.Node, prefix string, fn func(key string, value *yaml.Node)) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		value := node.Content[i+1]
+		switch {
+		case value.Kind == yaml.MappingNode:
+			flattenNode(value, key, fn)
+		case value.Kind == yaml.ScalarNode && value.Tag == "!!null":
+		default:
+			fn(key, value)
+		}
+	}
+}
+
+// docRoot returns the top-level node of a document, or nil for an empty one
+func docRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// readConfigNode parses a config file, returning an empty node if it doesn't exist
+func readConfigNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &yaml.Node{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &doc, nil
+}
+
+// writeConfigNode writes a document back to path
+func writeConfigNode(path string, doc *yaml.Node, perm os.FileMode) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), perm); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
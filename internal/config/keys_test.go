@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigKeys(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	project := t.TempDir()
+	smithDir := filepath.Join(project, ".smith")
+	if err := os.MkdirAll(smithDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitProjectFiles(smithDir); err != nil {
+		t.Fatalf("InitProjectFiles failed: %v", err)
+	}
+
+	globalPath, err := GlobalConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPath := LocalConfigPath(project)
+
+	if err := SetGlobal("provider", "copilot"); err != nil {
+		t.Fatalf("SetGlobal failed: %v", err)
+	}
+	if err := SetGlobal("model", "gpt-4o"); err != nil {
+		t.Fatalf("SetGlobal failed: %v", err)
+	}
+	if err := SetLocal(project, "agents.oracle.model", "o1"); err != nil {
+		t.Fatalf("SetLocal failed: %v", err)
+	}
+	if err := SetLocal(project, "retention.keepDays", "30"); err != nil {
+		t.Fatalf("SetLocal failed: %v", err)
+	}
+
+	want := map[string]Value{
+		"provider":            {Value: "copilot", Source: globalPath},
+		"model":               {Value: "gpt-4o", Source: globalPath},
+		"agents.oracle.model": {Value: "o1", Source: localPath},
+		"retention.keepDays":  {Value: "30", Source: localPath},
+		"autoLevel":           {Value: "medium", Source: localPath},
+	}
+	for key, w := range want {
+		got, err := Lookup(project, key)
+		if err != nil {
+			t.Fatalf("Lookup(%s) failed: %v", key, err)
+		}
+		if got == nil || got.Value != w.Value || got.Source != w.Source {
+			t.Errorf("Lookup(%s) = %+v, want %+v", key, got, w)
+		}
+	}
+
+	// Empty scaffold values don't shadow the global ones, and comments survive
+	data, _ := os.ReadFile(localPath)
+	if !strings.Contains(string(data), "# The Oracle - Reviews code quality") {
+		t.Errorf("expected comments to survive set, got:\n%s", data)
+	}
+	cfg, err := Resolve(project, nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if cfg.Retention.KeepDays != 30 {
+		t.Errorf("expected keepDays 30 after set, got %d", cfg.Retention.KeepDays)
+	}
+
+	values, err := Effective(project, "agents")
+	if err != nil {
+		t.Fatalf("Effective failed: %v", err)
+	}
+	if len(values) != 1 || values[0].Key != "agents.oracle.model" {
+		t.Errorf("expected only agents.oracle.model under agents, got %+v", values)
+	}
+
+	removed, err := UnsetLocal(project, "retention.keepDays")
+	if err != nil || !removed {
+		t.Fatalf("UnsetLocal = %v, %v", removed, err)
+	}
+	if v, _ := Lookup(project, "retention.keepDays"); v != nil {
+		t.Errorf("expected keepDays unset, got %+v", v)
+	}
+	if removed, _ := UnsetGlobal("agents.oracle.model"); removed {
+		t.Error("expected nothing to unset in the global config")
+	}
+
+	// Invalid keys and values are rejected
+	for _, tc := range [][2]string{
+		{"agents.smith.model", "x"},
+		{"autoLevel", "extreme"},
+		{"retention.keepDays", "-1"},
+		{"cache.ttl", "a day"},
+		{"providers", "copilot"},
+	} {
+		if err := SetLocal(project, tc[0], tc[1]); err == nil {
+			t.Errorf("SetLocal(%s, %s) should fail", tc[0], tc[1])
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override settings, e.g.
+// SMITH_MODEL or SMITH_AGENTS_ORACLE_AUTO_LEVEL
+const EnvPrefix = "SMITH_"
+
+// SourceFlag is the source of values set by command-line flags
+const SourceFlag = "flag"
+
+// ValidationError points at the setting that failed validation
+type ValidationError struct {
+	Source string // Config file path, environment variable or SourceFlag
+	Line   int    // Line in the config file, 0 otherwise
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.Source, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Resolved is the effective configuration and where each value came from
+type Resolved struct {
+	Config
+
+	// Legacy lists config files that still use version 1 keys
+	Legacy []string
+
+	values map[string]Value
+}
+
+// Resolve layers the configuration, later layers winning: built-in defaults,
+// the global config, the project config, SMITH_* environment variables and
+// flags (dotted key to value, e.g. {"autoLevel": "low"}).
+// Invalid keys or values in any layer are reported together, each pointing
+// to its file and line, variable or flag. Version 1 keys are read as their
+// current equivalents.
+func Resolve(projectPath string, flags map[string]string) (*Resolved, error) {
+	r := &Resolved{Config: Defaults(), values: make(map[string]Value)}
+	r.set("autoLevel", r.AutoLevel, SourceDefault)
+	r.set("budget.onExceed", r.Budget.OnExceed, SourceDefault)
+	r.set("cache.maxEntries", strconv.Itoa(r.Cache.MaxEntries), SourceDefault)
+
+	var errs []error
+
+	globalPath, err := GlobalConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range []string{globalPath, LocalConfigPath(projectPath)} {
+		doc, err := readConfigNode(path)
+		if err != nil {
+			return nil, err
+		}
+
+		legacy := false
+		flattenNode(docRoot(doc), "", func(key string, node *yaml.Node) {
+			value := node.Value
+			if node.Kind != yaml.ScalarNode {
+				errs = append(errs, &ValidationError{Source: path, Line: node.Line, Err: fmt.Errorf("%s must be a single value", key)})
+				return
+			}
+			if newKey, newValue, ok := legacyKey(key, value); ok {
+				key, value, legacy = newKey, newValue, true
+			}
+			if key == "version" {
+				if n, err := strconv.Atoi(value); err == nil && n < CurrentVersion {
+					legacy = true
+				}
+			}
+			if value == "" {
+				return // Empty values are unset
+			}
+			if err := ValidateKey(key, value); err != nil {
+				errs = append(errs, &ValidationError{Source: path, Line: node.Line, Err: err})
+				return
+			}
+			r.set(key, value, path)
+		})
+		if legacy {
+			r.Legacy = append(r.Legacy, path)
+		}
+	}
+
+	for _, key := range concreteKeys() {
+		name := EnvName(key)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := ValidateKey(key, value); err != nil {
+			errs = append(errs, &ValidationError{Source: name, Err: err})
+			continue
+		}
+		r.set(key, value, name)
+	}
+
+	flagKeys := make([]string, 0, len(flags))
+	for key := range flags {
+		flagKeys = append(flagKeys, key)
+	}
+	sort.Strings(flagKeys)
+	for _, key := range flagKeys {
+		value := flags[key]
+		if value == "" {
+			continue
+		}
+		if err := ValidateKey(key, value); err != nil {
+			errs = append(errs, &ValidationError{Source: SourceFlag, Err: err})
+			continue
+		}
+		r.set(key, value, SourceFlag)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	r.Version = CurrentVersion
+	return r, nil
+}
+
+// set records a validated value and applies it to the config
+func (r *Resolved) set(key, value, source string) {
+	r.values[key] = Value{Key: key, Value: value, Source: source}
+
+	parts := strings.Split(key, ".")
+	switch parts[0] {
+	case "provider":
+		r.Provider = value
+	case "model":
+		r.Model = value
+	case "autoLevel":
+		r.AutoLevel = value
+	case "reasoning":
+		r.Reasoning = value
+	case "profile":
+		r.Profile = value
+	case "fallback":
+		r.Fallback = value
+	case "agents":
+		agent := r.Agents[parts[1]]
+		switch parts[2] {
+		case "model":
+			agent.Model = value
+		case "autoLevel":
+			agent.AutoLevel = value
+		case "reasoning":
+			agent.Reasoning = value
+		case "fallback":
+			agent.Fallback = value
+		}
+		r.Agents[parts[1]] = agent
+	case "routing":
+		r.Routing.Fast = value
+	case "budget":
+		switch parts[1] {
+		case "sessionTokens":
+			r.Budget.SessionTokens, _ = strconv.Atoi(value)
+		case "onExceed":
+			r.Budget.OnExceed = value
+		}
+	case "retention":
+		n, _ := strconv.Atoi(value)
+		switch parts[1] {
+		case "keepDays":
+			r.Retention.KeepDays = n
+		case "keepPerTask":
+			r.Retention.KeepPerTask = n
+		}
+	case "cache":
+		switch parts[1] {
+		case "ttl":
+			r.Cache.TTL, _ = time.ParseDuration(value)
+		case "maxEntries":
+			r.Cache.MaxEntries, _ = strconv.Atoi(value)
+		}
+	}
+}
+
+// Values returns every setting under prefix (all settings if empty) with the
+// source it came from
+func (r *Resolved) Values(prefix string) []Value {
+	var result []Value
+	for key, value := range r.values {
+		if key == "version" {
+			continue // Always the current version once resolved
+		}
+		if prefix == "" || key == prefix || strings.HasPrefix(key, prefix+".") {
+			result = append(result, value)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// Lookup returns the effective value of a single key, or nil if it is unset
+func (r *Resolved) Lookup(key string) *Value {
+	if value, ok := r.values[key]; ok {
+		return &value
+	}
+	return nil
+}
+
+// Effective returns every setting under prefix with the source it came from
+func Effective(projectPath, prefix string) ([]Value, error) {
+	r, err := Resolve(projectPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.Values(prefix), nil
+}
+
+// Lookup returns the effective value of a single key, or nil if it is unset
+func Lookup(projectPath, key string) (*Value, error) {
+	r, err := Resolve(projectPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.Lookup(key), nil
+}
+
+// EnvName returns the environment variable that overrides a key, e.g.
+// agents.oracle.autoLevel -> SMITH_AGENTS_ORACLE_AUTO_LEVEL
+func EnvName(key string) string {
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	for i, r := range key {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+		case unicode.IsUpper(r) && i > 0:
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// concreteKeys expands the known key patterns for every agent, leaving out
+// version which only describes files
+func concreteKeys() []string {
+	var keys []string
+	for _, spec := range knownKeys {
+		if spec.pattern == "version" {
+			continue
+		}
+		if !strings.Contains(spec.pattern, "*") {
+			keys = append(keys, spec.pattern)
+			continue
+		}
+		for _, name := range agentNames {
+			keys = append(keys, strings.Replace(spec.pattern, "*", name, 1))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigs writes the global config under a temporary HOME and the
+// project config, returning the project path
+func writeConfigs(t *testing.T, global, local string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := t.TempDir()
+
+	for path, data := range map[string]string{
+		filepath.Join(home, ".smith", "config.yaml"):    global,
+		filepath.Join(project, ".smith", "config.yaml"): local,
+	} {
+		if data == "" {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return project
+}
+
+func TestResolveLayers(t *testing.T) {
+	project := writeConfigs(t,
+		"version: 2\nprovider: copilot\nmodel: gpt-4o\nreasoning: low\nagents:\n  oracle:\n    model: o1\n    reasoning: high\n",
+		"version: 2\nmodel: claude\nautoLevel: low\nagents:\n  oracle:\n    autoLevel: \"\"\n",
+	)
+	t.Setenv("SMITH_AGENTS_ORACLE_AUTO_LEVEL", "high")
+	t.Setenv("SMITH_MODEL", "from-env")
+	t.Setenv("SMITH_CACHE_TTL", "24h")
+
+	r, err := Resolve(project, map[string]string{"model": "from-flag"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if r.Provider != "copilot" || r.Model != "from-flag" || r.AutoLevel != "low" {
+		t.Errorf("unexpected config %+v", r.Config)
+	}
+	oracle := r.Agent("oracle")
+	if oracle.Model != "o1" || oracle.AutoLevel != "high" || oracle.Reasoning != "high" {
+		t.Errorf("unexpected oracle settings %+v", oracle)
+	}
+	if keymaker := r.Agent("keymaker"); keymaker.Model != "from-flag" || keymaker.AutoLevel != "low" || keymaker.Reasoning != "low" {
+		t.Errorf("expected keymaker to inherit the main settings, got %+v", keymaker)
+	}
+	if r.Cache.TTL != 24*time.Hour || r.Cache.MaxEntries != DefaultCacheEntries {
+		t.Errorf("unexpected cache settings %+v", r.Cache)
+	}
+
+	sources := map[string]string{
+		"provider":                filepath.Join(os.Getenv("HOME"), ".smith", "config.yaml"),
+		"model":                   SourceFlag,
+		"autoLevel":               LocalConfigPath(project),
+		"agents.oracle.autoLevel": "SMITH_AGENTS_ORACLE_AUTO_LEVEL",
+	}
+	for key, want := range sources {
+		if got := r.Lookup(key); got == nil || got.Source != want {
+			t.Errorf("source of %s = %+v, want %s", key, got, want)
+		}
+	}
+}
+
+func TestResolveValidation(t *testing.T) {
+	project := writeConfigs(t, "", "version: 2\nprovider: copilot\nautoLevel: extreme\nagents:\n  smith:\n    model: x\n")
+	t.Setenv("SMITH_PROVIDER", "nope")
+
+	_, err := Resolve(project, nil)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	local := LocalConfigPath(project)
+	for _, want := range []string{local + ":3: invalid value for autoLevel", local + ":6: unknown config key \"agents.smith.model\"", "SMITH_PROVIDER: invalid value for provider"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected a ValidationError, got %T", err)
+	}
+}
+
+func TestMigrateLegacyConfig(t *testing.T) {
+	legacy := "# My settings\nprovider: copilot\nsafety_level: off\nagent_models:\n  implementation: gpt-4o # fast\n  review: o1\nagents:\n  testing:\n    autoLevel: low\n"
+	project := writeConfigs(t, "", legacy)
+
+	// Legacy keys are read as their current equivalents
+	r, err := Resolve(project, nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if r.AutoLevel != "high" || r.Agent("keymaker").Model != "gpt-4o" || r.Agent("oracle").Model != "o1" || r.Agent("sentinel").AutoLevel != "low" {
+		t.Errorf("unexpected resolved legacy config %+v", r.Config)
+	}
+	if len(r.Legacy) != 1 {
+		t.Errorf("expected the project config to be flagged as legacy, got %v", r.Legacy)
+	}
+
+	renamed, err := Migrate(LocalConfigPath(project))
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(renamed) != 4 {
+		t.Errorf("expected 4 renamed keys, got %v", renamed)
+	}
+
+	data, _ := os.ReadFile(LocalConfigPath(project))
+	for _, want := range []string{"# My settings", "version: 2", "autoLevel: high", "keymaker:\n    model: gpt-4o", "sentinel:\n    autoLevel: low"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in migrated config:\n%s", want, data)
+		}
+	}
+	for _, gone := range []string{"safety_level", "agent_models", "testing"} {
+		if strings.Contains(string(data), gone) {
+			t.Errorf("expected %q to be migrated away:\n%s", gone, data)
+		}
+	}
+
+	r, err = Resolve(project, nil)
+	if err != nil || len(r.Legacy) != 0 || r.Agent("oracle").Model != "o1" {
+		t.Errorf("unexpected config after migration: %+v, %v", r, err)
+	}
+	if renamed, _ := Migrate(LocalConfigPath(project)); renamed != nil {
+		t.Errorf("expected a second migration to do nothing, got %v", renamed)
+	}
+}
+
+func TestSnakeCaseKeys(t *testing.T) {
+	project := writeConfigs(t, "", "version: 2\nbudget:\n  session_tokens: 5000\nretention:\n  keep_days: 30\ncache:\n  max_entries: 10\n")
+	t.Setenv("SMITH_RETENTION_KEEP_PER_TASK", "500")
+
+	// snake_case names are read as the camelCase keys sharing their variables
+	if got := EnvName("retention.keepPerTask"); got != "SMITH_RETENTION_KEEP_PER_TASK" {
+		t.Errorf("EnvName(retention.keepPerTask) = %s", got)
+	}
+	r, err := Resolve(project, nil)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if r.Budget.SessionTokens != 5000 || r.Retention.KeepDays != 30 || r.Retention.KeepPerTask != 500 || r.Cache.MaxEntries != 10 {
+		t.Errorf("unexpected config %+v", r.Config)
+	}
+	if v := r.Lookup("retention.keepDays"); v == nil || v.Value != "30" {
+		t.Errorf("Lookup(retention.keepDays) = %+v", v)
+	}
+	if len(r.Legacy) != 1 {
+		t.Errorf("expected the project config to be flagged as legacy, got %v", r.Legacy)
+	}
+
+	renamed, err := Migrate(LocalConfigPath(project))
+	if err != nil || len(renamed) != 3 {
+		t.Fatalf("Migrate = %v, %v; want 3 renamed keys", renamed, err)
+	}
+	data, _ := os.ReadFile(LocalConfigPath(project))
+	for _, want := range []string{"sessionTokens: 5000", "keepDays: 30", "maxEntries: 10"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in migrated config:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route is a provider and model to send requests to
+type Route struct {
+	Provider string // Empty means the configured provider
+	Model    string // Empty means the provider's default model
+}
+
+func (r Route) String() string {
+	if r.Provider == "" {
+		return r.Model
+	}
+	return r.Provider + ":" + r.Model
+}
+
+// ParseRoute reads "provider:model" or just "model" (on the configured
+// provider). Model IDs may contain colons themselves, so the part before
+// the first colon is only taken as the provider if it names one.
+func ParseRoute(value string) (Route, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Route{}, fmt.Errorf("empty route")
+	}
+	if provider, model, ok := strings.Cut(value, ":"); ok && contains(GetAvailableProviders(), provider) {
+		if model == "" {
+			return Route{}, fmt.Errorf("route %q has no model", value)
+		}
+		return Route{Provider: provider, Model: model}, nil
+	}
+	return Route{Model: value}, nil
+}
+
+// ParseRoutes reads a comma-separated fallback chain like
+// "copilot:gpt-4o, openrouter:anthropic/claude-3.5-sonnet"
+func ParseRoutes(value string) ([]Route, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var routes []Route
+	for _, part := range strings.Split(value, ",") {
+		route, err := ParseRoute(part)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func validRoute(value string) error {
+	_, err := ParseRoute(value)
+	return err
+}
+
+func validRoutes(value string) error {
+	_, err := ParseRoutes(value)
+	return err
+}
@@ -8,22 +8,6 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// DefaultConfig represents the default project configuration structure
-type DefaultConfig struct {
-	Provider  string                    `yaml:"provider"`
-	Model     string                    `yaml:"model"`
-	AutoLevel string                    `yaml:"autoLevel"`
-	Agents    map[string]AgentConfigDef `yaml:"agents,omitempty"`
-	Version   int                       `yaml:"version"`
-}
-
-// AgentConfigDef represents per-agent configuration in scaffold
-type AgentConfigDef struct {
-	Model     string `yaml:"model,omitempty"`
-	AutoLevel string `yaml:"autoLevel,omitempty"`
-	Reasoning string `yaml:"reasoning,omitempty"`
-}
-
 // InitProjectFiles creates the default files for a Smith project
 func InitProjectFiles(smithDir string) error {
 	// Create default config.yaml if it doesn't exist
@@ -47,13 +31,15 @@ func createDefaultConfig(path string) error {
 		return nil
 	}
 
-	// Create config struct
-	cfg := DefaultConfig{
-		Provider:  "",
-		Model:     "",
-		AutoLevel: "medium",
-		Version:   1,
-		// Agents map is optional, omitted by default
+	// Provider and model stay empty until chosen; the agents map is in the footer
+	cfg := struct {
+		Version   int    `yaml:"version"`
+		Provider  string `yaml:"provider"`
+		Model     string `yaml:"model"`
+		AutoLevel string `yaml:"autoLevel"`
+	}{
+		Version:   CurrentVersion,
+		AutoLevel: Defaults().AutoLevel,
 	}
 
 	// Marshal to YAML with comments
@@ -67,7 +53,9 @@ func createDefaultConfig(path string) error {
 # This file is gitignored - each developer/project has their own settings
 
 # LLM Provider (required)
-# Run '/settings' in the TUI to configure provider and model
+# Run 'smith init' to pick a provider and model, or 'smith config set'
+# Values here override ~/.smith/config.yaml; SMITH_* environment variables
+# (e.g. SMITH_MODEL, SMITH_AGENTS_ORACLE_AUTO_LEVEL) override both
 
 `
 
@@ -81,18 +69,38 @@ agents:
   # The Architect - Designs feature structure and breaks down work
   architect:
     model: ""  # Will use main model if not specified
+    autoLevel: ""  # low/medium/high, will use main autoLevel if not specified
     
   # The Keymaker - Implements features and writes code
   keymaker:
     model: ""  # Will use main model if not specified
+    autoLevel: ""  # low/medium/high, will use main autoLevel if not specified
     
   # Sentinels - Write tests and hunt bugs
   sentinel:
     model: ""  # Will use main model if not specified
+    autoLevel: ""  # low/medium/high, will use main autoLevel if not specified
     
   # The Oracle - Reviews code quality
   oracle:
     model: ""  # Will use main model if not specified
+    autoLevel: ""  # low/medium/high, will use main autoLevel if not specified
+
+# Reasoning effort (optional) for models that think before answering:
+# low, medium or high, here or per agent (agents.architect.reasoning)
+# reasoning: medium
+
+# Event log retention (optional), applied only by 'smith db compact'
+# Pruned events are archived to .smith/archive/
+# retention:
+#   keepDays: 30
+#   keepPerTask: 500
+
+# Response cache (optional): task reports and other idempotent requests
+# are answered from .smith/smith.db while fresh
+# cache:
+#   ttl: 24h
+#   maxEntries: 1000
 `
 
 	fullContent := header + string(data) + footer
@@ -119,8 +127,18 @@ func createGitignore(path string) error {
 		"# Project-local database (tasks, sessions, events)",
 		"smith.db",
 		"",
+		"# Socket other smith processes use to reach the database",
+		"broker/",
+		"",
 		"# User-specific config with API keys",
 		"config.yaml",
+		"",
+		"# File snapshots for undo",
+		"checkpoints/",
+		"",
+		"# Pre-migration backups and archived events",
+		"smith.db.*",
+		"archive/",
 	}
 
 	content := ""
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/speier/smith/internal/eventbus"
+	"github.com/speier/smith/pkg/agent/coordinator"
+)
+
+// ErrCommandDenied is returned by tool calls whose command was blocked by the
+// safety rules and not approved
+var ErrCommandDenied = errors.New("command denied")
+
+// AgentInfo identifies the background agent a tool call is made on behalf of
+type AgentInfo struct {
+	ID     string // Agent ID (e.g., "agent-keymaker-001")
+	Role   string // Agent role (architect, keymaker, sentinel, oracle)
+	TaskID string // Task the agent is working on
+}
+
+type agentInfoKey struct{}
+
+// WithAgent returns a context carrying the identity of the agent executing a task.
+// ExecuteTask uses it to attach the agent to approval requests and events.
+func WithAgent(ctx context.Context, info AgentInfo) context.Context {
+	return context.WithValue(ctx, agentInfoKey{}, info)
+}
+
+// AgentFromContext returns the agent identity stored in ctx, if any
+func AgentFromContext(ctx context.Context) (AgentInfo, bool) {
+	info, ok := ctx.Value(agentInfoKey{}).(AgentInfo)
+	return info, ok
+}
+
+// ApprovalRequest is a blocked command waiting for a user decision.
+// Requests from background agents carry the agent identity so the UI can
+// show who is asking.
+type ApprovalRequest struct {
+	ID        int64
+	Agent     AgentInfo // Empty for the main chat
+	Command   string
+	Reason    string
+	Level     string // Effective auto-level the command was checked against
+	CreatedAt time.Time
+
+	decision chan approvalDecision
+}
+
+type approvalDecision struct {
+	approved       bool
+	addToAllowlist bool
+}
+
+var approvalSeq int64
+
+func newApprovalRequest(agent AgentInfo, command, reason, level string) *ApprovalRequest {
+	return &ApprovalRequest{
+		ID:        atomic.AddInt64(&approvalSeq, 1),
+		Agent:     agent,
+		Command:   command,
+		Reason:    reason,
+		Level:     level,
+		CreatedAt: time.Now(),
+		decision:  make(chan approvalDecision, 1),
+	}
+}
+
+// Approve allows the command to run, optionally adding it to the session allowlist
+func (r *ApprovalRequest) Approve(addToAllowlist bool) {
+	r.respond(approvalDecision{approved: true, addToAllowlist: addToAllowlist})
+}
+
+// Deny rejects the command
+func (r *ApprovalRequest) Deny() {
+	r.respond(approvalDecision{approved: false})
+}
+
+// respond delivers the decision once; later calls are ignored
+func (r *ApprovalRequest) respond(d approvalDecision) {
+	select {
+	case r.decision <- d:
+	default:
+	}
+}
+
+// toolScope is the identity and safety level a tool call runs under
+type toolScope struct {
+	agent AgentInfo
+	level string
+	turn  int // Chat turn for checkpoints (main chat only)
+}
+
+// isAgent reports whether the scope belongs to a background agent
+func (s toolScope) isAgent() bool {
+	return s.agent.ID != ""
+}
+
+// chatScope returns the scope used by the main chat
+func (e *Engine) chatScope() toolScope {
+	return toolScope{level: e.autoLevel, turn: e.turn}
+}
+
+// agentScope returns the scope for a background agent, using the role's own
+// auto-level if one is configured and the engine level otherwise
+func (e *Engine) agentScope(ctx context.Context, role string) toolScope {
+	agent, _ := AgentFromContext(ctx)
+	if agent.Role == "" {
+		agent.Role = CanonicalRole(role)
+	}
+	if agent.ID == "" {
+		agent.ID = agent.Role
+	}
+	return toolScope{agent: agent, level: e.GetAgentAutoLevel(agent.Role)}
+}
+
+// requestApproval asks for a decision on a blocked command.
+// Chat requests go to the approval callback. Agent requests go to the agent
+// approval callback if set, and are otherwise queued on ApprovalRequests and
+// wait until the UI answers or ctx is cancelled. With no UI subscribed they
+// fail with ErrCommandDenied at once.
+func (e *Engine) requestApproval(ctx context.Context, scope toolScope, command, reason string) (approved, addToAllowlist bool, err error) {
+	if !scope.isAgent() {
+		if e.approvalCallback == nil {
+			return false, false, fmt.Errorf("no approval callback")
+		}
+		approved, addToAllowlist = e.approvalCallback(command, reason)
+		return approved, addToAllowlist, nil
+	}
+
+	req := newApprovalRequest(scope.agent, command, reason, scope.level)
+	e.publishApprovalEvent(ctx, eventbus.EventCommandBlocked, req)
+
+	if e.agentApprovalCallback == nil && atomic.LoadInt32(&e.approvalListeners) == 0 {
+		e.publishApprovalEvent(ctx, eventbus.EventCommandDenied, req)
+		return false, false, fmt.Errorf("%w: no UI to approve it", ErrCommandDenied)
+	}
+
+	if e.agentApprovalCallback != nil {
+		approved, addToAllowlist = e.agentApprovalCallback(req)
+	} else {
+		select {
+		case e.approvals <- req:
+		case <-ctx.Done():
+			return false, false, ctx.Err()
+		}
+
+		select {
+		case d := <-req.decision:
+			approved, addToAllowlist = d.approved, d.addToAllowlist
+		case <-ctx.Done():
+			return false, false, ctx.Err()
+		}
+	}
+
+	if approved {
+		e.publishApprovalEvent(ctx, eventbus.EventCommandApproved, req)
+	} else {
+		e.publishApprovalEvent(ctx, eventbus.EventCommandDenied, req)
+	}
+	return approved, addToAllowlist, nil
+}
+
+// publishApprovalEvent records an approval step on the event bus (best effort)
+func (e *Engine) publishApprovalEvent(ctx context.Context, eventType eventbus.EventType, req *ApprovalRequest) {
+	bus := e.coord.GetEventBus()
+	if bus == nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]string{
+		"command": req.Command,
+		"reason":  req.Reason,
+		"level":   req.Level,
+	})
+
+	var taskID *string
+	if req.Agent.TaskID != "" {
+		id := req.Agent.TaskID
+		taskID = &id
+	}
+
+	_ = bus.Publish(ctx, coordinator.Event{
+		AgentID:   req.Agent.ID,
+		AgentRole: coordinator.AgentRole(req.Agent.Role),
+		Type:      coordinator.EventType(eventType),
+		TaskID:    taskID,
+		Data:      string(data),
+	})
+}
+
+// CanonicalRole maps legacy role names (planning, implementation, testing,
+// review) to the agent names used in config and by agents
+func CanonicalRole(role string) string {
+	switch role {
+	case "planning":
+		return "architect"
+	case "implementation":
+		return "keymaker"
+	case "testing":
+		return "sentinel"
+	case "review":
+		return "oracle"
+	default:
+		return role
+	}
+}
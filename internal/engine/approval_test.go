@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAgentAutoLevelOverride(t *testing.T) {
+	tempDir := t.TempDir()
+
+	eng, err := New(Config{
+		ProjectPath:     tempDir,
+		AutoLevel:       AutoLevelMedium,
+		AgentAutoLevels: map[string]string{"oracle": AutoLevelLow},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Legacy role names resolve to the agent's configured level
+	if got := eng.GetAgentAutoLevel("review"); got != AutoLevelLow {
+		t.Errorf("GetAgentAutoLevel(review) = %v, want %v", got, AutoLevelLow)
+	}
+	if got := eng.GetAgentAutoLevel("testing"); got != AutoLevelMedium {
+		t.Errorf("GetAgentAutoLevel(testing) = %v, want %v", got, AutoLevelMedium)
+	}
+
+	input := map[string]interface{}{"command": "go build"}
+
+	// Oracle at low: build commands are blocked and nobody approves
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	eng.SetAgentApprovalCallback(func(req *ApprovalRequest) (bool, bool) { return false, false })
+	if _, err := eng.runCommand(ctx, eng.agentScope(ctx, "review"), input); err == nil {
+		t.Error("Expected oracle at low level to be blocked on go build")
+	}
+
+	// Sentinel inherits medium: build commands are allowed
+	bg := context.Background()
+	if _, err := eng.runCommand(bg, eng.agentScope(bg, "testing"), input); err != nil {
+		t.Errorf("Expected sentinel at medium level to run go build, got: %v", err)
+	}
+}
+
+func TestAgentAutoLevelFromProjectConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	configDir := filepath.Join(tempDir, ".smith")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := "provider: copilot\nagents:\n  keymaker:\n    autoLevel: high\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	eng, err := New(Config{ProjectPath: tempDir})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	if got := eng.GetAgentAutoLevel("implementation"); got != AutoLevelHigh {
+		t.Errorf("GetAgentAutoLevel(implementation) = %v, want %v", got, AutoLevelHigh)
+	}
+	if got := eng.GetAgentAutoLevel("architect"); got != AutoLevelMedium {
+		t.Errorf("GetAgentAutoLevel(architect) = %v, want %v", got, AutoLevelMedium)
+	}
+}
+
+func TestAgentApprovalRoutedToUI(t *testing.T) {
+	tempDir := t.TempDir()
+
+	eng, err := New(Config{
+		ProjectPath: tempDir,
+		AutoLevel:   AutoLevelLow,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	ctx := WithAgent(context.Background(), AgentInfo{ID: "agent-sentinel-001", Role: "sentinel", TaskID: "task-001"})
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Simulate the UI answering the request
+	requests, stop := eng.ApprovalRequests()
+	defer stop()
+	go func() {
+		select {
+		case req := <-requests:
+			if req.Agent.ID != "agent-sentinel-001" {
+				t.Errorf("Request agent = %q, want agent-sentinel-001", req.Agent.ID)
+			}
+			if req.Command != "go build" {
+				t.Errorf("Request command = %q, want go build", req.Command)
+			}
+			req.Approve(false)
+		case <-ctx.Done():
+		}
+	}()
+
+	input := map[string]interface{}{"command": "go build"}
+	if _, err := eng.runCommand(ctx, eng.agentScope(ctx, "testing"), input); err != nil {
+		t.Errorf("Expected approved command to run, got: %v", err)
+	}
+}
+
+func TestAgentApprovalCancelled(t *testing.T) {
+	tempDir := t.TempDir()
+
+	eng, err := New(Config{
+		ProjectPath: tempDir,
+		AutoLevel:   AutoLevelLow,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	ctx := WithAgent(context.Background(), AgentInfo{ID: "agent-oracle-001", Role: "oracle"})
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	// A UI is subscribed but never answers: the agent must give up when its
+	// context ends
+	_, stop := eng.ApprovalRequests()
+	defer stop()
+	input := map[string]interface{}{"command": "go build"}
+	if _, err := eng.runCommand(ctx, eng.agentScope(ctx, "review"), input); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected unanswered approval to time out, got %v", err)
+	}
+}
+
+func TestAgentApprovalWithoutUI(t *testing.T) {
+	eng, err := New(Config{ProjectPath: t.TempDir(), AutoLevel: AutoLevelLow})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// With no UI subscribed, requests are denied instead of waiting
+	ctx := WithAgent(context.Background(), AgentInfo{ID: "agent-oracle-001", Role: "oracle"})
+	input := map[string]interface{}{"command": "go build"}
+	start := time.Now()
+	if _, err := eng.runCommand(ctx, eng.agentScope(ctx, "review"), input); !errors.Is(err, ErrCommandDenied) {
+		t.Errorf("Expected ErrCommandDenied, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected the denial to be immediate")
+	}
+
+	// Once the last UI leaves, requests are denied again
+	_, stop := eng.ApprovalRequests()
+	stop()
+	if _, err := eng.runCommand(ctx, eng.agentScope(ctx, "review"), input); !errors.Is(err, ErrCommandDenied) {
+		t.Errorf("Expected ErrCommandDenied after unsubscribing, got %v", err)
+	}
+}
+
+func TestSessionApprovals(t *testing.T) {
+	eng, err := New(Config{ProjectPath: t.TempDir(), AutoLevel: AutoLevelLow})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	approvals, stop := eng.SessionApprovals()
+	defer stop()
+	go func() {
+		for approval := range approvals {
+			if approval.Agent != "agent-sentinel-001" || approval.Command != "go build" {
+				t.Errorf("unexpected approval %+v", approval)
+			}
+			approval.Respond(true, false)
+		}
+	}()
+
+	ctx := WithAgent(context.Background(), AgentInfo{ID: "agent-sentinel-001", Role: "sentinel"})
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	input := map[string]interface{}{"command": "go build"}
+	if _, err := eng.runCommand(ctx, eng.agentScope(ctx, "testing"), input); err != nil {
+		t.Errorf("Expected the session to approve the command, got: %v", err)
+	}
+}
+
+func TestChatSessionApprovesChatCommands(t *testing.T) {
+	eng, err := New(Config{ProjectPath: t.TempDir(), AutoLevel: AutoLevelLow})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	sess := NewChatSession(eng)
+
+	go func() {
+		approval := <-sess.Approvals()
+		if approval.Agent != "" || approval.Command != "go build" {
+			t.Errorf("unexpected approval %+v", approval)
+		}
+		approval.Respond(true, false)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	input := map[string]interface{}{"command": "go build"}
+	if _, err := eng.runCommand(ctx, eng.chatScope(), input); err != nil {
+		t.Errorf("Expected the session to approve the command, got: %v", err)
+	}
+
+	// Once closed, the session denies instead of waiting
+	sess.Close()
+	if _, err := eng.runCommand(ctx, eng.chatScope(), input); !errors.Is(err, ErrCommandDenied) {
+		t.Errorf("Expected a closed session to deny, got: %v", err)
+	}
+}
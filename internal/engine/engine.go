@@ -7,8 +7,16 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/speier/smith/internal/checkpoint"
+	"github.com/speier/smith/internal/config"
 	"github.com/speier/smith/pkg/agent/coordinator"
+	"github.com/speier/smith/pkg/agent/session"
+	"github.com/speier/smith/pkg/agent/storage"
+	"github.com/speier/smith/pkg/agent/tools"
 	"github.com/speier/smith/pkg/llm"
 )
 
@@ -17,20 +25,44 @@ import (
 type Engine struct {
 	coord       coordinator.Coordinator
 	llm         llm.Provider
+	settings    *config.Config // Models of the chat and each agent
+	providerMu  sync.Mutex
+	providers   map[string]llm.Provider // Fallback providers by ID, created on first use
 	projectPath string
 	autoLevel   string // Current safety auto-level
 
+	// Per-agent auto-level overrides keyed by agent name (architect, keymaker, sentinel, oracle)
+	agentLevels map[string]string
+
 	// Approval callback for blocked commands
 	approvalCallback func(command, reason string) (approved bool, addToAllowlist bool)
 
+	// Approval routing for background agents: callback if set, otherwise
+	// queued for the UIs subscribed with ApprovalRequests, or denied if none is
+	agentApprovalCallback func(req *ApprovalRequest) (approved bool, addToAllowlist bool)
+	approvals             chan *ApprovalRequest
+	approvalListeners     int32 // Subscribed UIs; accessed atomically
+
+	// Checkpoints of files changed by tool calls, grouped by task or chat turn
+	checkpoints *checkpoint.Store
+	turn        int
+
+	// Temporary directory for ephemeral runs, removed on Close
+	scratchDir string
+
+	// Background processes started by tool calls, stopped when their task ends
+	processes *tools.ProcessManager
+
 	// Conversation state
 	conversationHistory []Message
 	pendingPlan         *Plan
+	attachments         []llm.Part // Files for the next chat message, see Attach
 }
 
 type Message struct {
 	Role    string // "user", "assistant", "system"
 	Content string
+	Parts   []llm.Part // Attached images and files
 }
 
 type Plan struct {
@@ -51,31 +83,105 @@ type Config struct {
 	ProjectPath string
 	LLMProvider llm.Provider
 	AutoLevel   string // Safety auto-level (low/medium/high)
+
+	// AgentAutoLevels overrides the auto-level per agent (e.g., {"oracle": "low"}).
+	// If nil, overrides come from the agents section of the config.
+	AgentAutoLevels map[string]string
+
+	// Settings is the resolved configuration (see config.Resolve).
+	// If nil, it is resolved from the project without flag overrides.
+	Settings *config.Config
+
+	// Ephemeral keeps tasks, events and checkpoints out of the project:
+	// storage is in memory and checkpoints go to a temporary directory.
+	Ephemeral bool
+
+	// Coordinator shares an existing coordinator instead of opening the
+	// project's own, e.g. for workers started alongside the engine.
+	Coordinator coordinator.Coordinator
 }
 
 // New creates a new Smith engine instance
 func New(cfg Config) (*Engine, error) {
-	// Use default copilot provider if not specified
+	settings := cfg.Settings
+	if settings == nil {
+		resolved, err := config.Resolve(cfg.ProjectPath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("loading config: %w", err)
+		}
+		settings = &resolved.Config
+	}
+
+	// Use the configured provider, or copilot if none is configured yet
 	if cfg.LLMProvider == nil {
-		cfg.LLMProvider = llm.NewCopilotProvider()
+		if settings.Provider == "" {
+			cfg.LLMProvider = llm.NewCopilotProvider()
+		} else {
+			provider, err := llm.NewProviderForProfile(settings.Provider, settings.Profile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.LLMProvider = provider
+		}
 	}
 
-	// Default to medium if not specified
 	autoLevel := cfg.AutoLevel
 	if autoLevel == "" {
-		autoLevel = "medium"
+		autoLevel = settings.AutoLevel
+	}
+
+	var coord coordinator.Coordinator
+	var checkpoints *checkpoint.Store
+	var scratchDir string
+	if cfg.Ephemeral {
+		dir, err := os.MkdirTemp("", "smith-ephemeral-*")
+		if err != nil {
+			return nil, fmt.Errorf("creating scratch directory: %w", err)
+		}
+		scratchDir = dir
+		coord = coordinator.NewWithStore(cfg.ProjectPath, storage.NewMemoryStore())
+		checkpoints = checkpoint.NewAt(cfg.ProjectPath, filepath.Join(dir, "checkpoints"))
+	} else {
+		coord = cfg.Coordinator
+		if coord == nil {
+			coord = coordinator.New(cfg.ProjectPath)
+		}
+		checkpoints = checkpoint.New(cfg.ProjectPath)
 	}
 
-	coord := coordinator.New(cfg.ProjectPath)
+	agentLevels := cfg.AgentAutoLevels
+	if agentLevels == nil {
+		agentLevels = make(map[string]string)
+		for name, agent := range settings.Agents {
+			if agent.AutoLevel != "" {
+				agentLevels[name] = agent.AutoLevel
+			}
+		}
+	}
 
 	return &Engine{
 		llm:         cfg.LLMProvider,
+		settings:    settings,
 		coord:       coord,
 		projectPath: cfg.ProjectPath,
 		autoLevel:   autoLevel,
+		agentLevels: agentLevels,
+		approvals:   make(chan *ApprovalRequest, 16),
+		checkpoints: checkpoints,
+		scratchDir:  scratchDir,
+		processes:   tools.NewProcessManager(),
 	}, nil
 }
 
+// Close stops background processes started by the main chat and agents,
+// and removes the scratch directory of an ephemeral engine
+func (e *Engine) Close() {
+	e.processes.StopAll()
+	if e.scratchDir != "" {
+		_ = os.RemoveAll(e.scratchDir)
+	}
+}
+
 // GetCoordinator returns the coordinator instance for accessing task stats and other coordination features
 func (e *Engine) GetCoordinator() coordinator.Coordinator {
 	return e.coord
@@ -113,8 +219,14 @@ First, classify the user's request into one of these categories:
 - write_file: Create or overwrite files
 - read_file: Read file contents
 - edit_file: Replace content in files
+- apply_patch: Apply a unified diff across one or more files
 - list_files: Browse project structure
 - run_command: Execute shell commands
+- run_tests: Run tests (go, pytest, jest) with structured per-test results and coverage
+- start_process / read_process_output / send_process_input / stop_process: Run dev servers in the background
+
+**Go Code Intelligence:**
+- go_symbols, go_definition, go_references, go_implementations, go_callers
 
 **Task Management (Multi-Agent Coordination):**
 - create_task: Delegate work to background agents
@@ -222,6 +334,50 @@ func (e *Engine) getTools() []llm.Tool {
 				"required": []string{"file_path", "old_content", "new_content"},
 			},
 		},
+		{
+			Name:        "apply_patch",
+			Description: "Apply a unified diff to one or more files. Supports creating, deleting and renaming files. Hunks are matched with fuzzy context; if any hunk fails, nothing is changed and the nearest matching context is reported",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"patch": map[string]interface{}{
+						"type":        "string",
+						"description": "Unified diff (git diff format) with paths relative to project root",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Check that the patch applies without changing files (default: false)",
+					},
+				},
+				"required": []string{"patch"},
+			},
+		},
+		// Go Code Intelligence Tools
+		{
+			Name:        "go_symbols",
+			Description: "List functions, types, methods, vars and consts declared in Go packages, with file:line positions",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"package": map[string]interface{}{
+						"type":        "string",
+						"description": "Package pattern relative to project root (default: ./...)",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Only return symbols whose name contains this text (case-insensitive)",
+					},
+					"exported_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only return exported symbols (default: false)",
+					},
+				},
+			},
+		},
+		goTargetTool("go_definition", "Find where a Go symbol is defined"),
+		goTargetTool("go_references", "Find all references to a Go symbol, with the enclosing function of each"),
+		goTargetTool("go_implementations", "Show a Go type's method set and the interfaces it implements, or the types implementing an interface"),
+		goTargetTool("go_callers", "List the functions that call a Go function or method"),
 		{
 			Name:        "list_files",
 			Description: "List files and directories in a path",
@@ -253,6 +409,139 @@ func (e *Engine) getTools() []llm.Tool {
 				"required": []string{"command"},
 			},
 		},
+		{
+			Name:        "run_tests",
+			Description: "Run the project's tests (go test, pytest or jest, auto-detected) and return structured per-test pass/fail/skip status, durations, failure messages with file:line, and coverage",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"framework": map[string]interface{}{
+						"type":        "string",
+						"description": "Test framework (default: detected from project files)",
+						"enum":        []string{"go", "pytest", "jest"},
+					},
+					"targets": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Packages or test files to run (default: all)",
+					},
+					"run": map[string]interface{}{
+						"type":        "string",
+						"description": "Only run tests matching this name pattern",
+					},
+					"coverage": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Collect coverage percentages (default: false)",
+					},
+					"affected": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only run tests affected by changed files (default: false)",
+					},
+					"changed_files": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Changed files for affected mode (default: files changed in this task)",
+					},
+				},
+			},
+		},
+		// Background Process Tools
+		{
+			Name:        "start_process",
+			Description: "Start a long-running command (dev server, watcher) in the background. Returns a process ID; the process is stopped when your task ends",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"command": map[string]interface{}{
+						"type":        "string",
+						"description": "Shell command to run",
+					},
+					"working_dir": map[string]interface{}{
+						"type":        "string",
+						"description": "Working directory relative to project root (default: .)",
+					},
+					"wait_for": map[string]interface{}{
+						"type":        "string",
+						"description": "Wait until this text appears in the output (e.g., 'Listening on')",
+					},
+					"wait_seconds": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum seconds to wait before returning (default: 2, or 30 with wait_for)",
+					},
+				},
+				"required": []string{"command"},
+			},
+		},
+		{
+			Name:        "read_process_output",
+			Description: "Read output from a background process since the last read, and whether it is still running",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Process ID from start_process",
+					},
+					"since": map[string]interface{}{
+						"type":        "integer",
+						"description": "Output offset to read from (default: continue from the last read; 0 for all buffered output)",
+					},
+					"tail": map[string]interface{}{
+						"type":        "integer",
+						"description": "Only return the last N lines",
+					},
+					"wait_for": map[string]interface{}{
+						"type":        "string",
+						"description": "Wait until this text appears in the output (with wait_seconds)",
+					},
+					"wait_seconds": map[string]interface{}{
+						"type":        "integer",
+						"description": "Seconds to wait for output before reading",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		{
+			Name:        "send_process_input",
+			Description: "Write text to a background process's stdin",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Process ID from start_process",
+					},
+					"input": map[string]interface{}{
+						"type":        "string",
+						"description": "Text to send (include a trailing newline for line-based programs)",
+					},
+					"close": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Close stdin after sending (default: false)",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		{
+			Name:        "stop_process",
+			Description: "Stop a background process and all of its child processes",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Process ID from start_process",
+					},
+					"grace_seconds": map[string]interface{}{
+						"type":        "integer",
+						"description": "Seconds to wait after SIGTERM before killing (default: 5)",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
 		// Task Management Tools
 		{
 			Name:        "create_task",
@@ -297,8 +586,8 @@ func (e *Engine) getTools() []llm.Tool {
 				"properties": map[string]interface{}{
 					"status": map[string]interface{}{
 						"type":        "string",
-						"description": "Filter by status: 'backlog', 'wip', 'review', 'done', or empty for all",
-						"enum":        []string{"", "backlog", "wip", "review", "done"},
+						"description": "Filter by status: 'backlog', 'wip', 'review', 'done', 'cancelled', or empty for all",
+						"enum":        []string{"", "backlog", "wip", "review", "done", "cancelled"},
 					},
 				},
 			},
@@ -350,6 +639,39 @@ func (e *Engine) getTools() []llm.Tool {
 	}
 }
 
+// goTargetTool returns the schema for Go tools that act on a single symbol
+func goTargetTool(name, description string) llm.Tool {
+	return llm.Tool{
+		Name:        name,
+		Description: description + ". Identify the symbol by name or by file/line",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"symbol": map[string]interface{}{
+					"type":        "string",
+					"description": "Symbol name: Name, pkg.Name, Type.Method or pkg.Type.Method",
+				},
+				"file": map[string]interface{}{
+					"type":        "string",
+					"description": "File containing the identifier (instead of symbol)",
+				},
+				"line": map[string]interface{}{
+					"type":        "integer",
+					"description": "Line of the identifier in file",
+				},
+				"column": map[string]interface{}{
+					"type":        "integer",
+					"description": "Column of the identifier in file (optional)",
+				},
+				"package": map[string]interface{}{
+					"type":        "string",
+					"description": "Package pattern to search, relative to project root (default: ./...)",
+				},
+			},
+		},
+	}
+}
+
 // getAgentTools returns tools available to background agents (no task management)
 // This prevents agents from creating infinite delegation loops
 func (e *Engine) getAgentTools() []llm.Tool {
@@ -369,24 +691,28 @@ func (e *Engine) getAgentTools() []llm.Tool {
 	return agentTools
 }
 
-// getRoleSystemPrompt returns a role-specific system prompt for background agents
-func (e *Engine) getRoleSystemPrompt(role, taskTitle, taskDescription string) string {
+// getRoleSystemPrompt returns a role-specific system prompt for background agents.
+// It doesn't depend on the task, so providers can cache it as a prompt prefix.
+func (e *Engine) getRoleSystemPrompt(role string) string {
 	// Common tools section
 	toolsSection := `**Available Tools:**
 - write_file: Create or overwrite files
 - read_file: Read file contents  
 - edit_file: Replace content in files
+- apply_patch: Apply a unified diff (multi-file edits, create/delete/rename)
 - list_files: Browse project structure
-- run_command: Execute shell commands (build, test, etc.)`
+- run_command: Execute shell commands (build, etc.)
+- run_tests: Run tests with structured pass/fail/skip results, failure file:line and coverage
+  (affected=true runs only tests touched by your changes)
+- start_process / read_process_output / send_process_input / stop_process:
+  Run servers in the background (e.g., start a dev server, curl it with run_command, stop it)
+- go_symbols / go_definition / go_references / go_implementations / go_callers:
+  Navigate Go code precisely (JSON results with file:line) instead of grepping`
 
 	switch role {
 	case "keymaker", "implementation":
 		return fmt.Sprintf(`You are the Keymaker - a specialized coding agent focused on building features.
 
-**Your Task:**
-Title: %s
-Description: %s
-
 %s
 
 **Your Role & Responsibilities:**
@@ -405,22 +731,18 @@ Description: %s
 - Keep implementations focused and complete
 
 Be professional, thorough, and detail-oriented. Your code should work correctly.`,
-			taskTitle, taskDescription, toolsSection)
+			toolsSection)
 
 	case "sentinel", "testing":
 		return fmt.Sprintf(`You are a Sentinel - a specialized testing agent focused on hunting down bugs relentlessly.
 
-**Your Task:**
-Title: %s
-Description: %s
-
 %s
 
 **Your Role & Responsibilities:**
 1. **Analyze the Code** - Use read_file to understand what needs testing
 2. **Write Test Cases** - Create unit tests, integration tests as appropriate
 3. **Cover Edge Cases** - Think about boundary conditions, errors, edge cases
-4. **Run Tests** - Use run_command to execute tests and verify they pass
+4. **Run Tests** - Use run_tests to execute tests and verify they pass (results are recorded on the task for review)
 5. **Return Summary** - Describe test coverage and results
 
 **Best Practices:**
@@ -433,15 +755,11 @@ Description: %s
 - Run tests to ensure they pass before completing
 
 Be thorough and skeptical. Your tests should catch bugs before production.`,
-			taskTitle, taskDescription, toolsSection)
+			toolsSection)
 
 	case "architect", "planning":
 		return fmt.Sprintf(`You are the Architect - a specialized planning agent focused on designing elegant solutions.
 
-**Your Task:**
-Title: %s
-Description: %s
-
 %s
 
 **Your Role & Responsibilities:**
@@ -450,16 +768,9 @@ Description: %s
 3. **Break Down Work** - Split large features into smaller, focused tasks
 4. **Set Priorities** - Determine urgency: HIGH (critical/blocking), MEDIUM (normal), LOW (nice-to-have)
 5. **Identify Dependencies** - Determine which tasks must complete before others
-6. **Return Structured Plan** - Provide tasks in this exact format:
-
-**Task Breakdown Format:**
-For each task, output:
-TASK: <clear title>
-DESCRIPTION: <detailed description>
-ROLE: <keymaker|sentinel|oracle>
-PRIORITY: <HIGH|MEDIUM|LOW>
-DEPENDS_ON: <task-001, task-002> (or NONE if no dependencies)
----
+6. **Return the Plan** - Number the tasks and give each a title, a detailed
+   description, a role (keymaker|sentinel|oracle), a priority (HIGH|MEDIUM|LOW)
+   and the numbers of the tasks it depends on. The plan becomes the team's tasks.
 
 **Priority Guidelines:**
 - HIGH: Critical bugs, blocking issues, foundational work needed by other tasks
@@ -470,7 +781,7 @@ DEPENDS_ON: <task-001, task-002> (or NONE if no dependencies)
 - Implementation must come before testing
 - Testing must come before review
 - Foundation/infrastructure before features that use it
-- Use task IDs like task-001, task-002 for dependencies
+- Refer to earlier tasks of the plan by their number
 
 **Best Practices:**
 - Read existing code to understand patterns
@@ -481,43 +792,37 @@ DEPENDS_ON: <task-001, task-002> (or NONE if no dependencies)
 - Set realistic priorities based on impact and urgency
 
 Be strategic and thoughtful. Your plans guide the entire team.`,
-			taskTitle, taskDescription, toolsSection)
+			toolsSection)
 
 	case "oracle", "review":
 		return fmt.Sprintf(`You are the Oracle - a specialized code review agent who sees quality and predicts issues.
 
-**Your Task:**
-Title: %s
-Description: %s
-
 %s
 
 **Your Role & Responsibilities:**
 1. **Read the Code** - Use read_file to review implementation
 2. **Check Quality** - Look for bugs, anti-patterns, style issues
 3. **Verify Tests** - Ensure tests exist and provide good coverage
-4. **Run Validation** - Use run_command to build and test
+4. **Run Validation** - Use run_command to build and run_tests to test
 5. **Return Feedback** - Provide constructive review comments
 
 **Best Practices:**
 - Be thorough but constructive
 - Check for common bugs (nil checks, error handling, race conditions)
 - Verify code follows project conventions
+- Check API impact of changed Go symbols with go_references and go_callers
+- Check the recorded test results: new behavior should have new tests, and they must pass
 - Ensure tests are comprehensive
 - Run builds and tests to verify everything works
 - Suggest improvements, not just criticisms
 
 Be critical but helpful. Your reviews improve code quality.`,
-			taskTitle, taskDescription, toolsSection)
+			toolsSection)
 
 	default:
 		// Generic fallback for unknown roles
 		return fmt.Sprintf(`You are a specialized development agent executing a task.
 
-**Your Task:**
-Title: %s
-Description: %s
-
 %s
 
 **Your Job:**
@@ -527,7 +832,7 @@ Description: %s
 4. Return a summary of what you did
 
 Be concise and focused. Execute the task completely.`,
-			taskTitle, taskDescription, toolsSection)
+			toolsSection)
 	}
 }
 
@@ -554,10 +859,14 @@ func (e *Engine) Chat(userMessage string) (string, error) {
 
 // ChatStream sends a message and streams the response
 func (e *Engine) ChatStream(userMessage string, callback func(string) error) error {
+	// File changes made while answering this message are checkpointed together
+	e.turn = e.checkpoints.NextTurn()
+
 	// Add user message to history
 	e.conversationHistory = append(e.conversationHistory, Message{
 		Role:    "user",
 		Content: userMessage,
+		Parts:   e.takeAttachments(),
 	})
 
 	// Convert conversation history to LLM messages with system prompt
@@ -571,6 +880,7 @@ func (e *Engine) ChatStream(userMessage string, callback func(string) error) err
 		messages = append(messages, llm.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
+			Parts:   msg.Parts,
 		})
 	}
 
@@ -579,11 +889,11 @@ func (e *Engine) ChatStream(userMessage string, callback func(string) error) err
 
 	// Stream the response
 	var fullResponse strings.Builder
-	err := e.llm.ChatStream(messages, tools, func(response *llm.Response) error {
+	err := e.providerFor("").ChatStream(messages, tools, func(response *llm.Response) error {
 		// Handle tool calls
 		if len(response.ToolCalls) > 0 {
 			for _, toolCall := range response.ToolCalls {
-				result, err := e.executeToolCall(toolCall)
+				result, err := e.executeToolCall(context.Background(), e.chatScope(), toolCall)
 				if err != nil {
 					return fmt.Errorf("tool execution failed: %w", err)
 				}
@@ -627,6 +937,35 @@ func (e *Engine) GetConversationHistory() []Message {
 func (e *Engine) ClearConversation() {
 	e.conversationHistory = []Message{}
 	e.pendingPlan = nil
+	e.attachments = nil
+}
+
+// Attach adds a file to the next chat message (ChatStream or Run): images
+// are shown to the model, PDFs sent as documents and text files inlined.
+// Relative paths are in the project. Files the chat model can't take are
+// rejected with an error matching llm.ErrUnsupportedContent.
+func (e *Engine) Attach(path string) (llm.Part, error) {
+	part, err := llm.Attachment(e.resolvePath(path))
+	if err != nil {
+		return llm.Part{}, fmt.Errorf("attaching %s: %w", path, err)
+	}
+	if err := llm.CheckParts(e.providerFor(""), e.GetModel(""), []llm.Part{part}); err != nil {
+		return llm.Part{}, fmt.Errorf("attaching %s: %w", path, err)
+	}
+	e.attachments = append(e.attachments, part)
+	return part, nil
+}
+
+// Attachments returns the files waiting for the next chat message
+func (e *Engine) Attachments() []llm.Part {
+	return e.attachments
+}
+
+// takeAttachments returns the files for the next chat message and clears them
+func (e *Engine) takeAttachments() []llm.Part {
+	parts := e.attachments
+	e.attachments = nil
+	return parts
 }
 
 // SetAutoLevel updates the current auto-level
@@ -639,22 +978,279 @@ func (e *Engine) GetAutoLevel() string {
 	return e.autoLevel
 }
 
+// SetAgentAutoLevel overrides the auto-level for a single agent role
+func (e *Engine) SetAgentAutoLevel(role, level string) {
+	if e.agentLevels == nil {
+		e.agentLevels = make(map[string]string)
+	}
+	e.agentLevels[CanonicalRole(role)] = level
+}
+
+// GetAgentAutoLevel returns the effective auto-level for an agent role,
+// falling back to the engine auto-level when the role has no override
+func (e *Engine) GetAgentAutoLevel(role string) string {
+	if level, ok := e.agentLevels[CanonicalRole(role)]; ok && level != "" {
+		return level
+	}
+	return e.autoLevel
+}
+
+// GetModel returns the model used for an agent role, or for the main chat if
+// role is empty; "" means the provider's default model
+func (e *Engine) GetModel(role string) string {
+	if role == "" {
+		return e.settings.Model
+	}
+	return e.settings.Agent(CanonicalRole(role)).Model
+}
+
+// providerFor returns a router for role: its model (see GetModel), then the
+// fallback chain of the role, with routing.fast for fast requests and the
+// session budget applied. Routes on providers that can reason think at the
+// role's reasoning effort; fast requests don't.
+func (e *Engine) providerFor(role string) llm.Provider {
+	fallback, reasoning := e.settings.Fallback, e.settings.Reasoning
+	if role != "" {
+		agent := e.settings.Agent(CanonicalRole(role))
+		fallback, reasoning = agent.Fallback, agent.Reasoning
+	}
+
+	routes := []llm.Route{e.route(config.Route{Model: e.GetModel(role)})}
+	// Routes were validated when the config was resolved
+	chain, _ := config.ParseRoutes(fallback)
+	for _, r := range chain {
+		routes = append(routes, e.route(r))
+	}
+	for i := range routes {
+		if preset, ok := config.GetProviderPreset(routes[i].ProviderID); ok && preset.Capabilities.SupportsReasoning {
+			routes[i].Reasoning = reasoning
+		}
+	}
+
+	opts := llm.RouterOptions{
+		OnUsage: e.recordUsage,
+		Budget: llm.Budget{
+			Limit:     e.settings.Budget.SessionTokens,
+			Downgrade: e.settings.Budget.OnExceed == config.BudgetDowngrade,
+			Spent:     e.sessionTokens,
+		},
+	}
+	if fast, err := config.ParseRoute(e.settings.Routing.Fast); err == nil {
+		route := e.route(fast)
+		opts.Fast = &route
+	}
+	router := llm.NewRouter(routes, opts)
+
+	if e.settings.Cache.TTL <= 0 {
+		return router
+	}
+	return llm.NewCachingProvider(router, responseCache{e}, llm.CacheOptions{
+		Model:    e.GetModel(role),
+		OnLookup: e.recordCacheLookup,
+	})
+}
+
+// route returns the provider of a configured route; routes without a
+// provider, or on the configured one, use the engine's own
+func (e *Engine) route(r config.Route) llm.Route {
+	if r.Provider == "" {
+		r.Provider = e.settings.Provider
+	}
+
+	provider := e.llm
+	if r.Provider != e.settings.Provider {
+		e.providerMu.Lock()
+		if e.providers == nil {
+			e.providers = make(map[string]llm.Provider)
+		}
+		if e.providers[r.Provider] == nil {
+			p, err := llm.NewProviderForProfile(r.Provider, e.settings.Profile)
+			if err != nil {
+				p = unavailableProvider{err: err}
+			}
+			e.providers[r.Provider] = p
+		}
+		provider = e.providers[r.Provider]
+		e.providerMu.Unlock()
+	}
+
+	return llm.Route{ProviderID: r.Provider, Model: r.Model, Provider: llm.WithModel(provider, r.Model)}
+}
+
+// recordUsage adds the tokens of a request to the task in ctx, or to the
+// main chat of the session
+func (e *Engine) recordUsage(ctx context.Context, route llm.Route, usage llm.Usage) {
+	agent, _ := AgentFromContext(ctx)
+	// Usage accounting never fails the request
+	_ = e.coord.RecordUsage(ctx, coordinator.LLMUsage{
+		TaskID:           agent.TaskID,
+		Provider:         route.ProviderID,
+		Model:            route.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		CachedTokens:     usage.CachedTokens,
+		ReasoningTokens:  usage.ReasoningTokens,
+	})
+}
+
+// recordCacheLookup counts a response cache hit or miss like recordUsage
+func (e *Engine) recordCacheLookup(ctx context.Context, hit bool) {
+	agent, _ := AgentFromContext(ctx)
+	usage := coordinator.LLMUsage{TaskID: agent.TaskID}
+	if hit {
+		usage.CacheHits = 1
+	} else {
+		usage.CacheMisses = 1
+	}
+	_ = e.coord.RecordUsage(ctx, usage)
+}
+
+// responseCache keeps responses of idempotent requests in the project
+// database, as configured by the cache settings
+type responseCache struct {
+	e *Engine
+}
+
+func (c responseCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return c.e.coord.GetCachedResponse(ctx, key)
+}
+
+func (c responseCache) Put(ctx context.Context, key string, data []byte) error {
+	cache := c.e.settings.Cache
+	return c.e.coord.CacheResponse(ctx, key, data, cache.TTL, cache.MaxEntries)
+}
+
+// sessionTokens returns the tokens spent in the current session
+func (e *Engine) sessionTokens(ctx context.Context) (int, error) {
+	session, err := e.coord.GetCurrentSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+	usage, err := e.coord.GetSessionUsage(ctx, session.SessionID)
+	if err != nil {
+		return 0, err
+	}
+	return usage.TotalTokens, nil
+}
+
+// unavailableProvider stands in for a fallback provider that can't be
+// created, so the router moves on to the next route
+type unavailableProvider struct {
+	err error
+}
+
+func (p unavailableProvider) Chat(messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	return nil, fmt.Errorf("%w: %v", llm.ErrUnavailable, p.err)
+}
+
+func (p unavailableProvider) ChatStream(messages []llm.Message, tools []llm.Tool, callback func(*llm.Response) error) error {
+	return fmt.Errorf("%w: %v", llm.ErrUnavailable, p.err)
+}
+
+func (p unavailableProvider) GetModels() ([]llm.Model, error) { return nil, p.err }
+func (p unavailableProvider) GetName() string                 { return "unavailable" }
+func (p unavailableProvider) RequiresAuth() bool              { return false }
+
 // SetApprovalCallback sets the callback for command approval requests
 // The callback receives (command, reason) and returns (approved, addToAllowlist)
 func (e *Engine) SetApprovalCallback(callback func(command, reason string) (bool, bool)) {
 	e.approvalCallback = callback
 }
 
+// SetAgentApprovalCallback sets the callback for approval requests from background agents
+// When no callback is set, agent requests are queued on ApprovalRequests for the UI
+func (e *Engine) SetAgentApprovalCallback(callback func(req *ApprovalRequest) (bool, bool)) {
+	e.agentApprovalCallback = callback
+}
+
+// ApprovalRequests subscribes a UI to approval requests from background
+// agents until stop is called. The UI should answer each request with
+// Approve or Deny; the agent waits until it does. While no UI is subscribed,
+// agent requests are denied right away.
+func (e *Engine) ApprovalRequests() (requests <-chan *ApprovalRequest, stop func()) {
+	atomic.AddInt32(&e.approvalListeners, 1)
+	var once sync.Once
+	return e.approvals, func() {
+		once.Do(func() {
+			if atomic.AddInt32(&e.approvalListeners, -1) > 0 {
+				return
+			}
+			// Nobody is left to answer what is still queued
+			for {
+				select {
+				case req := <-e.approvals:
+					req.Deny()
+				default:
+					return
+				}
+			}
+		})
+	}
+}
+
+// SessionApprovals subscribes a chat session to approval requests from
+// background agents (see session.Session.Approvals) until stop is called
+func (e *Engine) SessionApprovals() (approvals <-chan session.Approval, stop func()) {
+	requests, unsubscribe := e.ApprovalRequests()
+	out := make(chan session.Approval)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case req := <-requests:
+				approval := session.Approval{
+					Agent:   req.Agent.ID,
+					Command: req.Command,
+					Reason:  req.Reason,
+					Respond: func(approved, addToAllowlist bool) {
+						if approved {
+							req.Approve(addToAllowlist)
+						} else {
+							req.Deny()
+						}
+					},
+				}
+				select {
+				case out <- approval:
+				case <-done:
+					req.Deny()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+}
+
 // ExecuteTask executes a task using LLM with agent tools (no task management)
 // This is used by background agents to implement/test features
+// Tool calls run under the role's own auto-level (see GetAgentAutoLevel) and
+// carry the agent identity from ctx (see WithAgent)
 func (e *Engine) ExecuteTask(ctx context.Context, role, taskTitle, taskDescription string) (string, error) {
+	scope := e.agentScope(ctx, role)
+
+	// Servers started for the task don't outlive it
+	defer e.processes.StopOwner(processOwner(scope))
+
 	// Get role-specific system prompt
-	systemPrompt := e.getRoleSystemPrompt(role, taskTitle, taskDescription)
+	systemPrompt := e.getRoleSystemPrompt(role)
 
-	// Build messages with system prompt
+	// Build messages with system prompt; the task goes last so the system
+	// prompt stays a stable prefix across tasks
 	messages := []llm.Message{
 		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: fmt.Sprintf("Execute this task: %s", taskDescription)},
+		{Role: "user", Content: fmt.Sprintf("Execute this task:\nTitle: %s\nDescription: %s", taskTitle, taskDescription)},
 	}
 
 	// Get agent tools (no task management)
@@ -662,11 +1258,11 @@ func (e *Engine) ExecuteTask(ctx context.Context, role, taskTitle, taskDescripti
 
 	// Execute with LLM
 	var fullResponse strings.Builder
-	err := e.llm.ChatStream(messages, tools, func(response *llm.Response) error {
+	err := llm.V2(e.providerFor(role)).ChatStreamContext(ctx, messages, tools, func(response *llm.Response) error {
 		// Handle tool calls
 		if len(response.ToolCalls) > 0 {
 			for _, toolCall := range response.ToolCalls {
-				result, err := e.executeToolCall(toolCall)
+				result, err := e.executeToolCall(ctx, scope, toolCall)
 				if err != nil {
 					return fmt.Errorf("tool execution failed: %w", err)
 				}
@@ -705,6 +1301,15 @@ func (e *Engine) GetStatus() string {
 `, stats.Backlog, stats.WIP, stats.Review, stats.Done)
 }
 
+// resolvePath returns an absolute path as is and a relative one in the project,
+// the way the tools in pkg/agent/tools resolve paths
+func (e *Engine) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(e.projectPath, path)
+}
+
 // handleWriteFile handles the write_file tool call
 func (e *Engine) handleWriteFile(input map[string]interface{}) (string, error) {
 	filePath, ok := input["file_path"].(string)
@@ -798,6 +1403,24 @@ func (e *Engine) handleEditFile(input map[string]interface{}) (string, error) {
 	return fmt.Sprintf("✅ Edited: %s", filePath), nil
 }
 
+// runTool validates and executes a tool from pkg/agent/tools
+func (e *Engine) runTool(ctx context.Context, tool tools.Tool, input map[string]interface{}) (string, error) {
+	if err := tool.Validate(input); err != nil {
+		return "", err
+	}
+
+	result, err := tool.Execute(ctx, input)
+	if err != nil {
+		if result != nil && result.Output != "" {
+			// Include the tool's report (e.g., failed patch hunks) so the model can correct itself
+			return "", fmt.Errorf("%w\n%s", err, result.Output)
+		}
+		return "", err
+	}
+
+	return result.Output, nil
+}
+
 // handleListFiles handles the list_files tool call
 func (e *Engine) handleListFiles(input map[string]interface{}) (string, error) {
 	dirPath, _ := input["directory"].(string)
@@ -827,8 +1450,13 @@ func (e *Engine) handleListFiles(input map[string]interface{}) (string, error) {
 	return result.String(), nil
 }
 
-// handleRunCommand handles the run_command tool call
+// handleRunCommand handles the run_command tool call from the main chat
 func (e *Engine) handleRunCommand(input map[string]interface{}) (string, error) {
+	return e.runCommand(context.Background(), e.chatScope(), input)
+}
+
+// runCommand runs a shell command under the given scope's auto-level
+func (e *Engine) runCommand(ctx context.Context, scope toolScope, input map[string]interface{}) (string, error) {
 	command, ok := input["command"].(string)
 	if !ok {
 		return "", fmt.Errorf("command is required")
@@ -841,29 +1469,12 @@ func (e *Engine) handleRunCommand(input map[string]interface{}) (string, error)
 		workingDir = filepath.Join(e.projectPath, workingDir)
 	}
 
-	// Safety check - validate command against auto-level rules
-	checkResult := IsCommandAllowed(command, e.autoLevel)
-	if !checkResult.Allowed {
-		// Command blocked - request approval if callback is set
-		if e.approvalCallback != nil {
-			approved, addToAllowlist := e.approvalCallback(command, checkResult.Reason)
-			if !approved {
-				return "", fmt.Errorf("command denied by user")
-			}
-			// If approved and should be added to allowlist
-			if addToAllowlist {
-				AddToSessionAllowlist(command)
-			}
-			// Fall through to execute the approved command
-		} else {
-			// No approval callback - deny immediately
-			return "", fmt.Errorf("command blocked by safety rules (%s): %s\nCommand: %s\nReason: %s",
-				e.autoLevel, checkResult.Reason, command, checkResult.Reason)
-		}
+	if err := e.authorizeCommand(ctx, scope, command); err != nil {
+		return "", err
 	}
 
 	// Use sh -c to execute command (works on Unix-like systems)
-	cmd := exec.Command("sh", "-c", command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
 	cmd.Dir = workingDir
 
 	output, err := cmd.CombinedOutput()
@@ -879,6 +1490,144 @@ func (e *Engine) handleRunCommand(input map[string]interface{}) (string, error)
 	return fmt.Sprintf("Exit code: %d\n%s", exitCode, string(output)), nil
 }
 
+// authorizeCommand checks a command against the scope's auto-level rules,
+// asking for approval when it is blocked
+func (e *Engine) authorizeCommand(ctx context.Context, scope toolScope, command string) error {
+	checkResult := IsCommandAllowed(command, scope.level)
+	if checkResult.Allowed {
+		return nil
+	}
+
+	// Command blocked - chat asks the approval callback, agents are routed to the UI
+	if e.approvalCallback == nil && !scope.isAgent() {
+		// No approval callback - deny immediately
+		return fmt.Errorf("%w by safety rules (%s): %s\nCommand: %s\nReason: %s",
+			ErrCommandDenied, scope.level, checkResult.Reason, command, checkResult.Reason)
+	}
+
+	approved, addToAllowlist, err := e.requestApproval(ctx, scope, command, checkResult.Reason)
+	if err != nil {
+		return fmt.Errorf("approval request failed: %w", err)
+	}
+	if !approved {
+		return fmt.Errorf("%w by user", ErrCommandDenied)
+	}
+	// If approved and should be added to allowlist
+	if addToAllowlist {
+		AddToSessionAllowlist(command)
+	}
+	return nil
+}
+
+// runTests runs the project's tests and records the results on the agent's task
+func (e *Engine) runTests(ctx context.Context, scope toolScope, input map[string]interface{}) (string, error) {
+	tool := tools.NewRunTestsTool(e.projectPath, 0)
+	if err := tool.Validate(input); err != nil {
+		return "", err
+	}
+
+	// Affected mode defaults to the files changed in this task or turn, so it works without git
+	if affected, _ := input["affected"].(bool); affected && input["changed_files"] == nil {
+		if files := e.changedFiles(scope); len(files) > 0 {
+			input["changed_files"] = files
+		}
+	}
+
+	plan, err := tool.Plan(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if !plan.Empty {
+		if err := e.authorizeCommand(ctx, scope, plan.Command()); err != nil {
+			plan.Cleanup()
+			return "", err
+		}
+	}
+
+	result, err := tool.ExecutePlan(ctx, plan)
+	if err != nil {
+		if result != nil && result.Output != "" {
+			return "", fmt.Errorf("%w\n%s", err, result.Output)
+		}
+		return "", err
+	}
+
+	if report, ok := result.Data.(*tools.TestReport); ok && scope.agent.TaskID != "" {
+		if err := e.coord.RecordTestRun(scope.agent.TaskID, testRun(scope, report)); err != nil {
+			return "", fmt.Errorf("failed to record test run: %w", err)
+		}
+	}
+
+	return result.Output, nil
+}
+
+// startProcess starts a background process after checking the command against the scope's auto-level
+func (e *Engine) startProcess(ctx context.Context, scope toolScope, input map[string]interface{}) (string, error) {
+	tool := tools.NewStartProcessTool(e.projectPath, e.processes, processOwner(scope))
+	if err := tool.Validate(input); err != nil {
+		return "", err
+	}
+
+	if err := e.authorizeCommand(ctx, scope, input["command"].(string)); err != nil {
+		return "", err
+	}
+
+	return e.runTool(ctx, tool, input)
+}
+
+// processOwner returns the owner background processes are tracked under:
+// the agent's task, or the main chat session
+func processOwner(scope toolScope) string {
+	if scope.isAgent() {
+		if scope.agent.TaskID != "" {
+			return scope.agent.TaskID
+		}
+		return scope.agent.ID
+	}
+	return "chat"
+}
+
+// changedFiles returns the files checkpointed for the scope's task or turn
+func (e *Engine) changedFiles(scope toolScope) []string {
+	cp, err := e.checkpoints.Get(e.checkpointRef(scope).ID())
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, f := range cp.Files {
+		files = append(files, f.Path)
+	}
+	return files
+}
+
+// testRun converts a test report into the record stored on a task
+func testRun(scope toolScope, report *tools.TestReport) coordinator.TestRun {
+	run := coordinator.TestRun{
+		Framework: report.Framework,
+		Command:   report.Command,
+		AgentID:   scope.agent.ID,
+		Passed:    report.Passed,
+		Failed:    report.Failed,
+		Skipped:   report.Skipped,
+		Duration:  report.Duration,
+		Coverage:  report.Coverage,
+		Errors:    report.Errors,
+		RanAt:     time.Now(),
+	}
+	for _, tc := range report.Tests {
+		run.Tests = append(run.Tests, coordinator.TestCaseResult{
+			Name:     tc.Name,
+			Suite:    tc.Suite,
+			Status:   tc.Status,
+			Duration: tc.Duration,
+			Message:  tc.Message,
+			Location: tc.Location,
+		})
+	}
+	return run
+}
+
 // handleCreateTask handles the create_task tool call
 func (e *Engine) handleCreateTask(input map[string]interface{}) (string, error) {
 	title, ok := input["title"].(string)
@@ -982,10 +1731,11 @@ func (e *Engine) handleListTasks(input map[string]interface{}) (string, error) {
 
 	for _, task := range tasks {
 		statusEmoji := map[string]string{
-			"backlog": "📥",
-			"wip":     "🔄",
-			"review":  "👀",
-			"done":    "✅",
+			"backlog":   "📥",
+			"wip":       "🔄",
+			"review":    "👀",
+			"done":      "✅",
+			"cancelled": "🚫",
 		}[task.Status]
 		result.WriteString(fmt.Sprintf("  %s %s - %s (%s)\n", statusEmoji, task.ID, task.Title, task.Role))
 	}
@@ -1020,6 +1770,12 @@ func (e *Engine) handleGetTask(input map[string]interface{}) (string, error) {
 		result.WriteString(fmt.Sprintf("  Error: %s\n", task.Error))
 	}
 
+	if n := len(task.TestRuns); n > 0 {
+		run := task.TestRuns[n-1]
+		result.WriteString(fmt.Sprintf("  Tests: %d passed, %d failed, %d skipped (%s, %d runs)\n",
+			run.Passed, run.Failed, run.Skipped, run.Command, task.TestSummary.Runs))
+	}
+
 	return result.String(), nil
 }
 
@@ -1097,7 +1853,7 @@ Be insightful and constructive in your feedback.`
 	}
 
 	// Get response from the specialist agent
-	response, err := e.llm.Chat(messages, nil)
+	response, err := e.providerFor(agentRole).Chat(messages, nil)
 	if err != nil {
 		return "", fmt.Errorf("consultation failed: %w", err)
 	}
@@ -1127,8 +1883,65 @@ type Status struct {
 	BlockedCount    int
 }
 
-// executeToolCall executes a tool call and returns the result
-func (e *Engine) executeToolCall(toolCall llm.ToolCall) (string, error) {
+// Checkpoints returns the checkpoint store for undoing file changes
+func (e *Engine) Checkpoints() *checkpoint.Store {
+	return e.checkpoints
+}
+
+// checkpointFiles records files' contents before a tool modifies them.
+// Agent changes are grouped by task, chat changes by turn.
+func (e *Engine) checkpointFiles(scope toolScope, paths ...string) error {
+	ref := e.checkpointRef(scope)
+	for _, path := range paths {
+		if err := e.checkpoints.Record(ref, e.resolvePath(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointRef returns the checkpoint a scope's changes are grouped under
+func (e *Engine) checkpointRef(scope toolScope) checkpoint.Ref {
+	if scope.isAgent() {
+		taskID := scope.agent.TaskID
+		if taskID == "" {
+			taskID = scope.agent.ID
+		}
+		return checkpoint.TaskRef(taskID)
+	}
+	return checkpoint.TurnRef(scope.turn)
+}
+
+// toolPaths returns the files a tool call will modify
+func toolPaths(toolCall llm.ToolCall) []string {
+	switch toolCall.Name {
+	case "write_file", "edit_file":
+		if filePath, ok := toolCall.Input["file_path"].(string); ok && filePath != "" {
+			return []string{filePath}
+		}
+	case "apply_patch":
+		patch, _ := toolCall.Input["patch"].(string)
+		files, err := tools.ParsePatch(patch)
+		if err != nil {
+			return nil // Handler reports the parse error
+		}
+		var paths []string
+		for _, fp := range files {
+			paths = append(paths, fp.Paths()...)
+		}
+		return paths
+	}
+	return nil
+}
+
+// executeToolCall executes a tool call under the given scope and returns the result
+func (e *Engine) executeToolCall(ctx context.Context, scope toolScope, toolCall llm.ToolCall) (string, error) {
+	if paths := toolPaths(toolCall); len(paths) > 0 {
+		if err := e.checkpointFiles(scope, paths...); err != nil {
+			return "", fmt.Errorf("failed to checkpoint file: %w", err)
+		}
+	}
+
 	switch toolCall.Name {
 	case "write_file":
 		return e.handleWriteFile(toolCall.Input)
@@ -1136,10 +1949,32 @@ func (e *Engine) executeToolCall(toolCall llm.ToolCall) (string, error) {
 		return e.handleReadFile(toolCall.Input)
 	case "edit_file":
 		return e.handleEditFile(toolCall.Input)
+	case "apply_patch":
+		return e.runTool(ctx, tools.NewApplyPatchTool(e.projectPath), toolCall.Input)
+	case "go_symbols":
+		return e.runTool(ctx, tools.NewGoSymbolsTool(e.projectPath), toolCall.Input)
+	case "go_definition":
+		return e.runTool(ctx, tools.NewGoDefinitionTool(e.projectPath), toolCall.Input)
+	case "go_references":
+		return e.runTool(ctx, tools.NewGoReferencesTool(e.projectPath), toolCall.Input)
+	case "go_implementations":
+		return e.runTool(ctx, tools.NewGoImplementationsTool(e.projectPath), toolCall.Input)
+	case "go_callers":
+		return e.runTool(ctx, tools.NewGoCallersTool(e.projectPath), toolCall.Input)
 	case "list_files":
 		return e.handleListFiles(toolCall.Input)
 	case "run_command":
-		return e.handleRunCommand(toolCall.Input)
+		return e.runCommand(ctx, scope, toolCall.Input)
+	case "run_tests":
+		return e.runTests(ctx, scope, toolCall.Input)
+	case "start_process":
+		return e.startProcess(ctx, scope, toolCall.Input)
+	case "read_process_output":
+		return e.runTool(ctx, tools.NewReadProcessOutputTool(e.processes, processOwner(scope)), toolCall.Input)
+	case "send_process_input":
+		return e.runTool(ctx, tools.NewSendProcessInputTool(e.processes, processOwner(scope)), toolCall.Input)
+	case "stop_process":
+		return e.runTool(ctx, tools.NewStopProcessTool(e.processes, processOwner(scope)), toolCall.Input)
 	case "create_task":
 		return e.handleCreateTask(toolCall.Input)
 	case "list_tasks":
@@ -1206,11 +2041,12 @@ func (e *Engine) processMessage(input string) string {
 		messages[i] = llm.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
+			Parts:   msg.Parts,
 		}
 	}
 
 	// Call LLM
-	response, err := e.llm.Chat(messages, nil)
+	response, err := e.providerFor("").Chat(messages, nil)
 	if err != nil {
 		return fmt.Sprintf("Error: %v", err)
 	}
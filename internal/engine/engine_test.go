@@ -1,9 +1,41 @@
 package engine
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/speier/smith/internal/checkpoint"
+	"github.com/speier/smith/pkg/llm"
 )
 
+// TestMain gives the tests an empty home and no SMITH_* variables, so
+// config.Resolve and provider logins don't see the developer's ~/.smith
+func TestMain(m *testing.M) {
+	// Tests that run go test keep the build cache of the real home
+	if cache, err := os.UserCacheDir(); err == nil && os.Getenv("GOCACHE") == "" {
+		os.Setenv("GOCACHE", filepath.Join(cache, "go-build"))
+	}
+
+	home, err := os.MkdirTemp("", "smith-engine-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("USERPROFILE", home)
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, "SMITH_") {
+			os.Unsetenv(env[:strings.IndexByte(env, '=')])
+		}
+	}
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 // TestEngineCreation tests that the engine creates successfully with coordinator
 func TestEngineCreation(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -272,3 +304,279 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// TestToolCallCheckpoints tests that file tools are checkpointed per task and per chat turn
+func TestToolCallCheckpoints(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	engine, err := New(Config{ProjectPath: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	path := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(path, []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Agent change is grouped under its task
+	ctx := WithAgent(context.Background(), AgentInfo{ID: "agent-keymaker-001", Role: "keymaker", TaskID: "task-007"})
+	_, err = engine.executeToolCall(ctx, engine.agentScope(ctx, "implementation"), llm.ToolCall{
+		Name:  "write_file",
+		Input: map[string]interface{}{"file_path": "main.go", "content": "package broken"},
+	})
+	if err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+
+	// Chat change is grouped under the current turn
+	engine.turn = engine.Checkpoints().NextTurn()
+	_, err = engine.executeToolCall(context.Background(), engine.chatScope(), llm.ToolCall{
+		Name:  "write_file",
+		Input: map[string]interface{}{"file_path": "README.md", "content": "# Demo"},
+	})
+	if err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+
+	if _, _, err := engine.Checkpoints().Restore("task-007"); err != nil {
+		t.Fatalf("Restore task failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "package main" {
+		t.Errorf("main.go = %q, want original contents", string(data))
+	}
+
+	cp, _, err := engine.Checkpoints().Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if cp.Turn != 1 {
+		t.Errorf("Undo restored %s, want turn 1", cp.ID)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "README.md")); !os.IsNotExist(err) {
+		t.Error("README.md should be removed by undo")
+	}
+}
+
+// TestEphemeralEngine tests that an ephemeral engine writes nothing under .smith
+func TestEphemeralEngine(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	engine, err := New(Config{ProjectPath: tmpDir, Ephemeral: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	if _, err := engine.GetCoordinator().CreateTask("Demo", "Ephemeral task", "keymaker"); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	_, err = engine.executeToolCall(context.Background(), engine.chatScope(), llm.ToolCall{
+		Name:  "write_file",
+		Input: map[string]interface{}{"file_path": "main.go", "content": "package main"},
+	})
+	if err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+	if _, err := engine.Checkpoints().Get(checkpoint.TurnRef(0).ID()); err != nil {
+		t.Errorf("expected checkpoint in scratch directory: %v", err)
+	}
+
+	scratch := engine.scratchDir
+	engine.Close()
+
+	if _, err := os.Stat(filepath.Join(tmpDir, ".smith")); !os.IsNotExist(err) {
+		t.Error("ephemeral engine should not create .smith")
+	}
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Error("scratch directory should be removed on Close")
+	}
+}
+
+// TestApplyPatchTool tests that apply_patch edits files and checkpoints every touched path
+func TestApplyPatchTool(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	engine, err := New(Config{ProjectPath: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("alpha\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-alpha\n+beta\n" +
+		"--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1 @@\n+new\n"
+
+	engine.turn = engine.Checkpoints().NextTurn()
+	if _, err := engine.executeToolCall(context.Background(), engine.chatScope(), llm.ToolCall{
+		Name:  "apply_patch",
+		Input: map[string]interface{}{"patch": patch},
+	}); err != nil {
+		t.Fatalf("apply_patch failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "a.txt"))
+	if string(data) != "beta\n" {
+		t.Errorf("a.txt = %q, want patched contents", string(data))
+	}
+
+	cp, _, err := engine.Checkpoints().Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(cp.Files) != 2 {
+		t.Errorf("Expected 2 checkpointed files, got %d", len(cp.Files))
+	}
+
+	// A failing hunk is reported and nothing is written
+	_, err = engine.executeToolCall(context.Background(), engine.chatScope(), llm.ToolCall{
+		Name:  "apply_patch",
+		Input: map[string]interface{}{"patch": "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-gamma\n+delta\n"},
+	})
+	if err == nil || !contains(err.Error(), "nearest match") {
+		t.Errorf("Expected hunk report in error, got: %v", err)
+	}
+}
+
+// TestCheckpointAbsolutePath tests that files named by absolute paths are
+// checkpointed where they are, not under the project root
+func TestCheckpointAbsolutePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	engine, err := New(Config{ProjectPath: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	path := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(path, []byte("alpha\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.checkpointFiles(engine.chatScope(), path); err != nil {
+		t.Fatalf("checkpointFiles failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("beta\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, _, err := engine.Checkpoints().Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(cp.Files) != 1 || cp.Files[0].Path != "a.txt" {
+		t.Errorf("checkpointed %+v, want a.txt", cp.Files)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "alpha\n" {
+		t.Errorf("a.txt = %q after undo, want the original contents", data)
+	}
+}
+
+// TestRunTestsRecordsOnTask tests that agent test runs are stored on their task
+func TestRunTestsRecordsOnTask(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	engine, err := New(Config{ProjectPath: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	taskID, err := engine.GetCoordinator().CreateTask("Test calc", "Add tests", "testing")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	ctx := WithAgent(context.Background(), AgentInfo{ID: "agent-sentinel-001", Role: "sentinel", TaskID: taskID})
+	scope := engine.agentScope(ctx, "testing")
+	scope.level = "high" // Don't route go test through approval
+
+	files := map[string]string{
+		"go.mod":          "module example.com/calc\n\ngo 1.21\n",
+		"calc.go":         "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+		"calc_test.go":    "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"bad\")\n\t}\n}\n",
+		"other/other.go":  "package other\n",
+		"other/x_test.go": "package other\n\nimport \"testing\"\n\nfunc TestOther(t *testing.T) {}\n",
+	}
+	for path, content := range files {
+		// Only the calc package goes through write_file, so it's the only changed package
+		if filepath.Dir(path) == "other" {
+			if err := os.MkdirAll(filepath.Join(tmpDir, "other"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, path), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if _, err := engine.executeToolCall(ctx, scope, llm.ToolCall{
+			Name:  "write_file",
+			Input: map[string]interface{}{"file_path": path, "content": content},
+		}); err != nil {
+			t.Fatalf("write_file failed: %v", err)
+		}
+	}
+
+	output, err := engine.executeToolCall(ctx, scope, llm.ToolCall{
+		Name:  "run_tests",
+		Input: map[string]interface{}{"affected": true},
+	})
+	if err != nil {
+		t.Fatalf("run_tests failed: %v", err)
+	}
+	if !contains(output, "1 passed, 0 failed") {
+		t.Errorf("Expected only calc tests to run, got: %s", output)
+	}
+
+	task, err := engine.GetCoordinator().GetTask(taskID)
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if len(task.TestRuns) != 1 {
+		t.Fatalf("Expected 1 recorded test run, got %d", len(task.TestRuns))
+	}
+	run := task.TestRuns[0]
+	if run.AgentID != "agent-sentinel-001" || run.Passed != 1 || run.Tests[0].Name != "TestAdd" {
+		t.Errorf("Unexpected test run: %+v", run)
+	}
+}
+
+// TestProcessesStoppedWithTask tests that background processes are scoped to and stopped with their task
+func TestProcessesStoppedWithTask(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	engine, err := New(Config{ProjectPath: tmpDir})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	ctx := WithAgent(context.Background(), AgentInfo{ID: "agent-keymaker-001", Role: "keymaker", TaskID: "task-001"})
+	scope := engine.agentScope(ctx, "implementation")
+	scope.level = "high"
+
+	output, err := engine.executeToolCall(ctx, scope, llm.ToolCall{
+		Name:  "start_process",
+		Input: map[string]interface{}{"command": "echo serving; sleep 60", "wait_for": "serving"},
+	})
+	if err != nil {
+		t.Fatalf("start_process failed: %v", err)
+	}
+	if !contains(output, "proc-1") || !contains(output, "running") {
+		t.Errorf("Expected running process in output, got: %s", output)
+	}
+
+	// The main chat can't reach the task's process
+	if _, err := engine.executeToolCall(context.Background(), engine.chatScope(), llm.ToolCall{
+		Name:  "read_process_output",
+		Input: map[string]interface{}{"id": "proc-1"},
+	}); err == nil {
+		t.Error("Expected chat to be unable to read the task's process")
+	}
+
+	if n := engine.processes.StopOwner(processOwner(scope)); n != 1 {
+		t.Errorf("Expected 1 process stopped with the task, got %d", n)
+	}
+	if len(engine.processes.List("")) != 0 {
+		t.Error("Expected no processes after the task ended")
+	}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/speier/smith/pkg/llm"
+)
+
+// TaskOutcome is what an agent reports after finishing a task
+type TaskOutcome struct {
+	Summary    string   `json:"summary" desc:"One or two sentences on what was done"`
+	Learnings  string   `json:"learnings" desc:"Insight worth remembering for similar tasks; empty if none"`
+	Approaches []string `json:"approaches" desc:"Approaches that were tried"`
+	Blockers   []string `json:"blockers" desc:"What blocked or slowed the work; empty if nothing"`
+}
+
+// ReviewVerdict is the Oracle's decision on reviewed work
+type ReviewVerdict struct {
+	Approved bool     `json:"approved" desc:"Whether the work is ready as it is"`
+	Summary  string   `json:"summary" desc:"One or two sentences on the state of the work"`
+	Issues   []string `json:"issues" desc:"Problems that must be fixed; empty if approved"`
+}
+
+// PlanResult is the Architect's breakdown of a feature into tasks
+type PlanResult struct {
+	Summary string        `json:"summary" desc:"One or two sentences on the approach"`
+	Tasks   []PlannedTask `json:"tasks" desc:"Tasks in the order they should be done"`
+}
+
+// PlannedTask is one task of a PlanResult
+type PlannedTask struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Role        string `json:"role" enum:"keymaker,sentinel,oracle"`
+	Priority    string `json:"priority" enum:"high,medium,low"`
+	DependsOn   []int  `json:"depends_on" desc:"Numbers (starting at 1) of earlier tasks in this plan that must finish first"`
+}
+
+// Schemas of the reports agents make
+var (
+	TaskOutcomeSchema   = llm.SchemaFor[TaskOutcome]("task_outcome", "Outcome of a finished task")
+	ReviewVerdictSchema = llm.SchemaFor[ReviewVerdict]("review_verdict", "Verdict of a code review")
+	PlanResultSchema    = llm.SchemaFor[PlanResult]("plan", "Tasks that implement a feature")
+)
+
+// Report has the role's model restate the result of a task as a T matching
+// schema, e.g. Report[ReviewVerdict](ctx, e, "oracle", title, result,
+// ReviewVerdictSchema). It is sent as a fast request (see llm.ClassFast) and
+// is idempotent, so the response cache can answer it (see llm.WithCacheable).
+func Report[T any](ctx context.Context, e *Engine, role, taskTitle, result string, schema *llm.Schema) (*T, error) {
+	messages := []llm.Message{
+		{Role: "system", Content: "You turn the report of a finished task into structured data. Use only what the report says."},
+		{Role: "user", Content: fmt.Sprintf("Task: %s\n\nReport from the %s:\n%s", taskTitle, CanonicalRole(role), result)},
+	}
+
+	value, err := llm.Structured[T](llm.WithCacheable(llm.WithRequestClass(ctx, llm.ClassFast)), e.providerFor(role), messages, schema)
+	if err != nil {
+		return nil, fmt.Errorf("%s report: %w", schema.Name, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/speier/smith/internal/config"
+	"github.com/speier/smith/pkg/llm"
+)
+
+// reportProvider answers from a script and records the tools and response
+// schema of each request
+type reportProvider struct {
+	native  bool
+	replies []*llm.Response
+	tools   [][]llm.Tool
+	schemas []*llm.Schema
+}
+
+func (p *reportProvider) ChatContext(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	p.tools = append(p.tools, tools)
+	p.schemas = append(p.schemas, llm.ResponseSchemaFrom(ctx))
+	reply := p.replies[0]
+	if len(p.replies) > 1 {
+		p.replies = p.replies[1:]
+	}
+	return reply, nil
+}
+
+func (p *reportProvider) ChatStreamContext(ctx context.Context, messages []llm.Message, tools []llm.Tool, callback func(*llm.Response) error) error {
+	response, err := p.ChatContext(ctx, messages, tools)
+	if err != nil {
+		return err
+	}
+	return callback(response)
+}
+
+func (p *reportProvider) Chat(messages []llm.Message, tools []llm.Tool) (*llm.Response, error) {
+	return p.ChatContext(context.Background(), messages, tools)
+}
+
+func (p *reportProvider) ChatStream(messages []llm.Message, tools []llm.Tool, callback func(*llm.Response) error) error {
+	return p.ChatStreamContext(context.Background(), messages, tools, callback)
+}
+
+func (p *reportProvider) SupportsResponseSchema() bool    { return p.native }
+func (p *reportProvider) GetModels() ([]llm.Model, error) { return nil, nil }
+func (p *reportProvider) GetName() string                 { return "report" }
+func (p *reportProvider) RequiresAuth() bool              { return false }
+
+func newReportEngine(t *testing.T, provider llm.Provider) *Engine {
+	t.Helper()
+	settings := config.Defaults()
+	return newReportEngineWith(t, provider, &settings)
+}
+
+func newReportEngineWith(t *testing.T, provider llm.Provider, settings *config.Config) *Engine {
+	t.Helper()
+	engine, err := New(Config{ProjectPath: t.TempDir(), LLMProvider: provider, Settings: settings, Ephemeral: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+
+	// Without native support the schema is a tool; prose is sent back once
+	provider := &reportProvider{replies: []*llm.Response{
+		{Content: "Looks fine overall, but there is no test.", Done: true},
+		{Done: true, ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "review_verdict", Input: map[string]interface{}{
+			"approved": false, "summary": "Needs a test", "issues": []interface{}{"add a test for Parse"},
+		}}}},
+	}}
+	engine := newReportEngine(t, provider)
+	verdict, err := Report[ReviewVerdict](ctx, engine, "review", "Review parser", "...", ReviewVerdictSchema)
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if verdict.Approved || len(verdict.Issues) != 1 || verdict.Issues[0] != "add a test for Parse" {
+		t.Errorf("unexpected verdict %+v", verdict)
+	}
+	if len(provider.tools) != 2 || len(provider.tools[0]) != 1 || provider.tools[0][0].Name != "review_verdict" {
+		t.Errorf("expected the verdict tool offered twice, got %v", provider.tools)
+	}
+	if provider.schemas[0] != nil {
+		t.Error("response schema sent to a provider without native support")
+	}
+
+	// Native support: the schema goes as response_format and JSON is read
+	// from the text
+	provider = &reportProvider{native: true, replies: []*llm.Response{
+		{Content: "```json\n{\"summary\": \"Two steps\", \"tasks\": [{\"title\": \"Build\", \"description\": \"d\", \"role\": \"keymaker\", \"priority\": \"high\", \"depends_on\": []}]}\n```", Done: true},
+	}}
+	engine = newReportEngine(t, provider)
+	plan, err := Report[PlanResult](ctx, engine, "planning", "Plan", "...", PlanResultSchema)
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if len(plan.Tasks) != 1 || plan.Tasks[0].Role != "keymaker" {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if provider.schemas[0] != PlanResultSchema || len(provider.tools[0]) != 0 {
+		t.Errorf("expected the plan schema natively without tools, got %v and %v", provider.schemas[0], provider.tools[0])
+	}
+
+	// Answers that never match the schema fail after a few attempts
+	provider = &reportProvider{native: true, replies: []*llm.Response{
+		{Content: `{"summary": "x", "tasks": [{"title": "Build", "role": "wizard"}]}`, Done: true},
+	}}
+	engine = newReportEngine(t, provider)
+	if _, err := Report[PlanResult](ctx, engine, "planning", "Plan", "...", PlanResultSchema); !errors.Is(err, llm.ErrInvalidOutput) {
+		t.Errorf("expected ErrInvalidOutput, got %v", err)
+	}
+	if len(provider.schemas) != llm.StructuredAttempts {
+		t.Errorf("expected %d attempts, got %d", llm.StructuredAttempts, len(provider.schemas))
+	}
+}
+
+func TestReportCache(t *testing.T) {
+	ctx := context.Background()
+	settings := config.Defaults()
+	settings.Cache.TTL = time.Hour
+
+	provider := &reportProvider{native: true, replies: []*llm.Response{
+		{Content: `{"approved": true, "summary": "Good", "issues": []}`, Done: true, PromptTokens: 50, TotalTokens: 60},
+	}}
+	engine := newReportEngineWith(t, provider, &settings)
+
+	// The same report is only asked for once
+	for i := 0; i < 2; i++ {
+		verdict, err := Report[ReviewVerdict](ctx, engine, "review", "Review parser", "All good", ReviewVerdictSchema)
+		if err != nil || !verdict.Approved {
+			t.Fatalf("Report = %+v, %v", verdict, err)
+		}
+	}
+	if len(provider.schemas) != 1 {
+		t.Errorf("expected one request to the provider, got %d", len(provider.schemas))
+	}
+	if _, err := Report[ReviewVerdict](ctx, engine, "review", "Review parser", "Needs work", ReviewVerdictSchema); err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if len(provider.schemas) != 2 {
+		t.Errorf("expected a different report to miss the cache, got %d requests", len(provider.schemas))
+	}
+
+	session, err := engine.coord.GetCurrentSession(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage, err := engine.coord.GetSessionUsage(ctx, session.SessionID)
+	if err != nil {
+		t.Fatalf("GetSessionUsage failed: %v", err)
+	}
+	if usage.CacheHits != 1 || usage.CacheMisses != 2 || usage.TotalTokens != 120 {
+		t.Errorf("expected 1 hit, 2 misses and 120 tokens, got %+v", usage)
+	}
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/speier/smith/pkg/llm"
+)
+
+// DefaultMaxTurns limits model round trips in Run when RunOptions.MaxTurns is 0
+const DefaultMaxTurns = 25
+
+// ErrMaxTurns is returned by Run when the model still wants to call tools
+// after the last allowed turn
+var ErrMaxTurns = errors.New("maximum turns reached")
+
+// ProviderError wraps a failed request to the LLM provider; errors.Is
+// matches the llm error kinds through it (e.g. llm.ErrRateLimit)
+type ProviderError struct {
+	Err error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("provider error: %v", e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// RunEventType identifies a step of Run
+type RunEventType string
+
+const (
+	RunEventDelta      RunEventType = "delta"       // Assistant text as it streams
+	RunEventReasoning  RunEventType = "reasoning"   // Model thinking as it streams, before its text
+	RunEventToolCall   RunEventType = "tool_call"   // Tool call requested by the model
+	RunEventToolResult RunEventType = "tool_result" // Output (or error) of a tool call
+	RunEventUsage      RunEventType = "usage"       // Token usage of the whole run, sent last
+)
+
+// Usage counts tokens across all turns of a run
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	ReasoningTokens  int `json:"reasoning_tokens"` // Part of CompletionTokens
+}
+
+// RunEvent is a single step of Run reported to RunOptions.OnEvent
+type RunEvent struct {
+	Type    RunEventType           `json:"type"`
+	Turn    int                    `json:"turn"`
+	Content string                 `json:"content,omitempty"` // Delta text, reasoning or tool output
+	CallID  string                 `json:"call_id,omitempty"`
+	Tool    string                 `json:"tool,omitempty"`
+	Input   map[string]interface{} `json:"input,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	Usage   *Usage                 `json:"usage,omitempty"`
+}
+
+// RunOptions configures Run
+type RunOptions struct {
+	MaxTurns int                  // Model round trips; DefaultMaxTurns if 0
+	OnEvent  func(RunEvent) error // Called for every step; an error stops the run
+}
+
+// RunResult summarizes a finished run
+type RunResult struct {
+	Response string // Final assistant text
+	Turns    int
+	Usage    Usage
+}
+
+// Run answers a prompt with the full tool loop: tool calls run under the
+// engine auto-level and their results go back to the model until it answers
+// without calling tools.
+// Failures are distinguishable with errors.Is/As: ErrCommandDenied,
+// *ProviderError, ErrMaxTurns and ctx.Err() for cancellation and timeouts.
+func (e *Engine) Run(ctx context.Context, prompt string, opts RunOptions) (*RunResult, error) {
+	maxTurns := opts.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = DefaultMaxTurns
+	}
+	emit := func(event RunEvent) error {
+		if opts.OnEvent == nil {
+			return nil
+		}
+		return opts.OnEvent(event)
+	}
+
+	// File changes made during the run are checkpointed together
+	e.turn = e.checkpoints.NextTurn()
+	scope := e.chatScope()
+
+	e.conversationHistory = append(e.conversationHistory, Message{Role: "user", Content: prompt, Parts: e.takeAttachments()})
+	messages := []llm.Message{{Role: "system", Content: e.getSystemPrompt()}}
+	for _, msg := range e.conversationHistory {
+		messages = append(messages, llm.Message{Role: msg.Role, Content: msg.Content, Parts: msg.Parts})
+	}
+	tools := e.getTools()
+
+	result := &RunResult{}
+	for turn := 1; ; turn++ {
+		if turn > maxTurns {
+			return result, fmt.Errorf("%w (%d)", ErrMaxTurns, maxTurns)
+		}
+		result.Turns = turn
+
+		reply, err := e.streamTurn(ctx, messages, tools, func(kind RunEventType, delta string) error {
+			return emit(RunEvent{Type: kind, Turn: turn, Content: delta})
+		})
+		result.Usage.PromptTokens += reply.usage.PromptTokens
+		result.Usage.CompletionTokens += reply.usage.CompletionTokens
+		result.Usage.TotalTokens += reply.usage.TotalTokens
+		result.Usage.ReasoningTokens += reply.usage.ReasoningTokens
+		if err != nil {
+			return result, err
+		}
+
+		calls := reply.toolCalls
+		for i := range calls {
+			if calls[i].ID == "" {
+				calls[i].ID = fmt.Sprintf("call_%d_%d", turn, i+1)
+			}
+		}
+		messages = append(messages, llm.Message{Role: "assistant", Content: reply.content, ToolCalls: calls})
+
+		if len(calls) == 0 {
+			result.Response = reply.content
+			break
+		}
+
+		for _, call := range calls {
+			if err := emit(RunEvent{Type: RunEventToolCall, Turn: turn, CallID: call.ID, Tool: call.Name, Input: call.Input}); err != nil {
+				return result, err
+			}
+
+			output, err := e.executeToolCall(ctx, scope, call)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result, ctxErr
+			}
+
+			event := RunEvent{Type: RunEventToolResult, Turn: turn, CallID: call.ID, Tool: call.Name, Content: output}
+			if err != nil {
+				// Other tool errors go back to the model so it can recover
+				event.Error = err.Error()
+				output = fmt.Sprintf("Error: %v", err)
+			}
+			if emitErr := emit(event); emitErr != nil {
+				return result, emitErr
+			}
+			if errors.Is(err, ErrCommandDenied) {
+				return result, fmt.Errorf("%s: %w", call.Name, err)
+			}
+
+			messages = append(messages, llm.Message{Role: "tool", Content: output, ToolCallID: call.ID})
+		}
+	}
+
+	e.conversationHistory = append(e.conversationHistory, Message{Role: "assistant", Content: result.Response})
+
+	usage := result.Usage
+	if err := emit(RunEvent{Type: RunEventUsage, Turn: result.Turns, Usage: &usage}); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// turnReply is what the model sent back in one turn of Run
+type turnReply struct {
+	content   string
+	toolCalls []llm.ToolCall
+	usage     Usage
+}
+
+// streamTurn streams one model response, passing reasoning and text deltas
+// to onDelta as RunEventReasoning and RunEventDelta
+func (e *Engine) streamTurn(ctx context.Context, messages []llm.Message, tools []llm.Tool, onDelta func(RunEventType, string) error) (turnReply, error) {
+	var reply turnReply
+	var content strings.Builder
+	var callbackErr error
+	err := llm.V2(e.providerFor("")).ChatStreamContext(ctx, messages, tools, func(response *llm.Response) error {
+		if response.Reasoning != "" {
+			if err := onDelta(RunEventReasoning, response.Reasoning); err != nil {
+				callbackErr = err
+				return err
+			}
+		}
+		if response.Content != "" {
+			content.WriteString(response.Content)
+			if err := onDelta(RunEventDelta, response.Content); err != nil {
+				callbackErr = err
+				return err
+			}
+		}
+		reply.toolCalls = append(reply.toolCalls, response.ToolCalls...)
+		// Streaming providers report the running total
+		if response.TotalTokens > 0 {
+			reply.usage = Usage{
+				PromptTokens:     response.PromptTokens,
+				CompletionTokens: response.CompletionTokens,
+				TotalTokens:      response.TotalTokens,
+				ReasoningTokens:  response.ReasoningTokens,
+			}
+		}
+		return nil
+	})
+	reply.content = content.String()
+
+	switch {
+	case callbackErr != nil:
+		return reply, callbackErr
+	case ctx.Err() != nil:
+		return reply, ctx.Err()
+	case err != nil:
+		return reply, &ProviderError{Err: err}
+	}
+	return reply, nil
+}
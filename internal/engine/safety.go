@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -55,7 +56,10 @@ type CheckResult struct {
 }
 
 var LoadedRules *Rules
-var sessionAllowlist = make([]string, 0)
+var (
+	sessionAllowlist   = make([]string, 0)
+	sessionAllowlistMu sync.RWMutex // agents run concurrently
+)
 
 // init loads the embedded rules at startup
 func init() {
@@ -183,17 +187,23 @@ func AddToSessionAllowlist(cmd string) {
 	}
 	cmd = strings.TrimSpace(cmd)
 	if !isInSessionAllowlist(cmd) {
+		sessionAllowlistMu.Lock()
 		sessionAllowlist = append(sessionAllowlist, cmd)
+		sessionAllowlistMu.Unlock()
 	}
 }
 
 // ClearSessionAllowlist clears the runtime allowlist
 func ClearSessionAllowlist() {
+	sessionAllowlistMu.Lock()
+	defer sessionAllowlistMu.Unlock()
 	sessionAllowlist = make([]string, 0)
 }
 
 // GetSessionAllowlist returns the current session allowlist
 func GetSessionAllowlist() []string {
+	sessionAllowlistMu.RLock()
+	defer sessionAllowlistMu.RUnlock()
 	return append([]string{}, sessionAllowlist...)
 }
 
@@ -232,6 +242,8 @@ func isInList(item string, list []string) bool {
 }
 
 func isInSessionAllowlist(cmd string) bool {
+	sessionAllowlistMu.RLock()
+	defer sessionAllowlistMu.RUnlock()
 	for _, allowed := range sessionAllowlist {
 		if cmd == allowed {
 			return true
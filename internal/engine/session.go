@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/speier/smith/pkg/agent/session"
+	"github.com/speier/smith/pkg/llm"
+)
+
+// ChatSession is a session.Session backed by an engine: messages go to the
+// main chat, and commands blocked for agents or the chat itself are
+// delivered on Approvals for the user to answer
+type ChatSession struct {
+	engine    *Engine
+	approvals chan session.Approval
+	stop      func()
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewChatSession subscribes a session to the engine's approval requests.
+// Close releases the subscription; it doesn't close the engine.
+func NewChatSession(e *Engine) *ChatSession {
+	agentApprovals, stop := e.SessionApprovals()
+	s := &ChatSession{
+		engine:    e,
+		approvals: make(chan session.Approval),
+		stop:      stop,
+		done:      make(chan struct{}),
+	}
+
+	// Chat commands wait for the user like agent commands do
+	e.SetApprovalCallback(func(command, reason string) (bool, bool) {
+		type decision struct{ approved, addToAllowlist bool }
+		answered := make(chan decision, 1)
+		approval := session.Approval{
+			Command: command,
+			Reason:  reason,
+			Respond: func(approved, addToAllowlist bool) {
+				select {
+				case answered <- decision{approved, addToAllowlist}:
+				default:
+				}
+			},
+		}
+		select {
+		case s.approvals <- approval:
+		case <-s.done:
+			return false, false
+		}
+		select {
+		case d := <-answered:
+			return d.approved, d.addToAllowlist
+		case <-s.done:
+			return false, false
+		}
+	})
+
+	go func() {
+		for approval := range agentApprovals {
+			select {
+			case s.approvals <- approval:
+			case <-s.done:
+				approval.Respond(false, false)
+			}
+		}
+	}()
+	return s
+}
+
+// Engine returns the engine the session talks to
+func (s *ChatSession) Engine() *Engine {
+	return s.engine
+}
+
+// SendMessage sends a message to the main chat and streams the response.
+// A failure ends the stream with an error line.
+func (s *ChatSession) SendMessage(message string) (<-chan string, error) {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		err := s.engine.ChatStream(message, func(chunk string) error {
+			ch <- chunk
+			return nil
+		})
+		if err != nil {
+			ch <- fmt.Sprintf("\nError: %v", err)
+		}
+	}()
+	return ch, nil
+}
+
+// GetHistory returns the user and assistant messages of the main chat
+func (s *ChatSession) GetHistory() []session.Message {
+	var history []session.Message
+	for _, msg := range s.engine.GetConversationHistory() {
+		if msg.Role == "user" || msg.Role == "assistant" {
+			history = append(history, session.Message{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	return history
+}
+
+// Attach adds a file to the next message
+func (s *ChatSession) Attach(path string) (llm.Part, error) {
+	return s.engine.Attach(path)
+}
+
+// Approvals delivers commands waiting for the user's decision
+func (s *ChatSession) Approvals() <-chan session.Approval {
+	return s.approvals
+}
+
+// Checkpoints returns the store the engine's tools snapshot files into
+func (s *ChatSession) Checkpoints() session.Checkpoints {
+	return s.engine.Checkpoints()
+}
+
+// Reset clears the conversation history
+func (s *ChatSession) Reset() {
+	s.engine.ClearConversation()
+}
+
+// Close stops delivering approvals; requests still waiting are denied
+func (s *ChatSession) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.stop()
+	})
+}
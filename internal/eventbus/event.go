@@ -21,6 +21,7 @@ const (
 	EventTaskCompleted EventType = "task_completed"
 	EventTaskFailed    EventType = "task_failed"
 	EventTaskAbandoned EventType = "task_abandoned"
+	EventTaskCancelled EventType = "task_cancelled"
 
 	// File lock events
 	EventFileLocked     EventType = "file_locked"
@@ -124,6 +124,7 @@ func (eb *EventBus) Query(ctx context.Context, filter EventFilter) ([]Event, err
 		AgentID:    filter.AgentID,
 		EventTypes: eventTypes,
 		TaskID:     filter.TaskID,
+		SinceID:    filter.SinceID,
 	}
 
 	storageEvents, err := eb.store.QueryEvents(ctx, storageFilter)
@@ -134,11 +135,6 @@ func (eb *EventBus) Query(ctx context.Context, filter EventFilter) ([]Event, err
 	// Convert storage.Event to eventbus.Event and apply additional filters
 	var events []Event
 	for _, se := range storageEvents {
-		// Apply SinceID filter (BBolt doesn't support this natively)
-		if filter.SinceID > 0 && se.ID <= filter.SinceID {
-			continue
-		}
-
 		// Apply AgentRole filter if specified
 		if filter.AgentRole != nil && se.AgentRole != string(*filter.AgentRole) {
 			continue
@@ -170,18 +166,14 @@ func (eb *EventBus) Query(ctx context.Context, filter EventFilter) ([]Event, err
 
 // GetLatestEventID returns the ID of the latest event
 func (eb *EventBus) GetLatestEventID(ctx context.Context) (int64, error) {
-	// Query all events and get the max ID (storage interface doesn't provide MAX)
-	events, err := eb.store.QueryEvents(ctx, storage.EventFilter{})
+	// Events are returned newest first
+	events, err := eb.store.QueryEvents(ctx, storage.EventFilter{Limit: 1})
 	if err != nil {
 		return 0, fmt.Errorf("failed to query events: %w", err)
 	}
 
-	var maxID int64
-	for _, event := range events {
-		if event.ID > maxID {
-			maxID = event.ID
-		}
+	if len(events) == 0 {
+		return 0, nil
 	}
-
-	return maxID, nil
+	return events[0].ID, nil
 }
@@ -2,7 +2,6 @@ package eventbus
 
 import (
 	"context"
-	"os"
 	"testing"
 	"time"
 
@@ -10,22 +9,8 @@ import (
 )
 
 func setupTestDB(t *testing.T) (storage.Store, func()) {
-	tmpDir, err := os.MkdirTemp("", "smith-eventbus-test-*")
-	if err != nil {
-		t.Fatalf("failed to create temp dir: %v", err)
-	}
-
-	store, err := storage.InitProjectStorage(tmpDir)
-	if err != nil {
-		t.Fatalf("failed to init storage: %v", err)
-	}
-
-	cleanup := func() {
-		_ = store.Close()
-		_ = os.RemoveAll(tmpDir)
-	}
-
-	return store, cleanup
+	store := storage.NewMemoryStore()
+	return store, func() { _ = store.Close() }
 }
 
 func TestPublishAndQuery(t *testing.T) {
@@ -3,6 +3,7 @@ package frontend
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/speier/smith/pkg/agent/session"
 	"github.com/speier/smith/pkg/lotus"
@@ -26,7 +27,7 @@ func NewChatUI(sess session.Session) *ChatUI {
 	}
 
 	// Setup input with inline handler
-	app.input = lotus.CreateInput("Type your message...", func(value string) {
+	app.input = lotus.Input("Type your message...", func(_ lotus.Context, value string) {
 		if value != "" {
 			app.handleSubmit(value)
 		}
@@ -35,6 +36,10 @@ func NewChatUI(sess session.Session) *ChatUI {
 	// Load existing history
 	app.loadHistory()
 
+	if approvals := sess.Approvals(); approvals != nil {
+		go app.watchApprovals(approvals)
+	}
+
 	return app
 }
 
@@ -111,7 +116,7 @@ func (app *ChatUI) handleSubmit(value string) {
 }
 
 // Render - 3-panel layout: header, messages, input (React render pattern)
-func (app *ChatUI) Render() *lotus.Element {
+func (app *ChatUI) Render(ctx lotus.Context) *lotus.Element {
 	content := lotus.VStack(
 		// Messages (fills remaining space with scrolling)
 		lotus.Box(app.messageList.Render()).
@@ -150,6 +155,8 @@ func (app *ChatUI) handleCommand(text string) {
 		helpText += "  /help - Show available commands\n"
 		helpText += "  /clear - Clear conversation history\n"
 		helpText += "  /model - Change LLM model\n"
+		helpText += "  /undo [task-id] - Revert file changes from the last turn or a task\n"
+		helpText += "  /attach <path> - Attach an image, PDF or text file to the next message\n"
 		app.messageList.AddMessage("system", helpText)
 
 	case "clear", "cls":
@@ -158,11 +165,64 @@ func (app *ChatUI) handleCommand(text string) {
 	case "model":
 		app.showModelPicker(args)
 
+	case "undo":
+		app.undo(args)
+
+	case "attach":
+		app.attach(args)
+
 	default:
 		app.messageList.AddMessage("system", fmt.Sprintf("Unknown command: /%s (try /help)", cmd))
 	}
 }
 
+// attach adds a file to the next message
+func (app *ChatUI) attach(args []string) {
+	if len(args) == 0 {
+		app.messageList.AddMessage("system", "Usage: /attach <path>")
+		return
+	}
+	path := strings.Join(args, " ")
+	part, err := app.session.Attach(path)
+	if err != nil {
+		app.messageList.AddMessage("system", fmt.Sprintf("Attach failed: %v", err))
+		return
+	}
+	app.messageList.AddMessage("system", fmt.Sprintf("Attached %s (%s)", path, part.MIMEType))
+}
+
+// undo restores files from the latest checkpoint, or from a task's checkpoint.
+// It goes through the session's store so it is serialized with the
+// snapshots the session's tools are taking.
+func (app *ChatUI) undo(args []string) {
+	store := app.session.Checkpoints()
+	if store == nil {
+		app.messageList.AddMessage("system", "Undo failed: this session keeps no checkpoints; use 'smith checkpoints restore' instead")
+		return
+	}
+
+	var (
+		cp       *session.Checkpoint
+		restored []string
+		err      error
+	)
+	if len(args) > 0 {
+		cp, restored, err = store.Restore(args[0])
+	} else {
+		cp, restored, err = store.Undo()
+	}
+	if err != nil {
+		app.messageList.AddMessage("system", fmt.Sprintf("Undo failed: %v", err))
+		return
+	}
+
+	msg := fmt.Sprintf("Restored %d file(s) from %s", len(restored), cp.ID)
+	for _, path := range restored {
+		msg += "\n  " + path
+	}
+	app.messageList.AddMessage("system", msg)
+}
+
 // showClearConfirmation shows a modal to confirm clearing conversation
 func (app *ChatUI) showClearConfirmation() {
 	app.modal = lotusui.NewModal().
@@ -193,6 +253,54 @@ func (app *ChatUI) showClearConfirmation() {
 	app.modal.Show()
 }
 
+// watchApprovals prompts for each blocked command in turn
+func (app *ChatUI) watchApprovals(approvals <-chan session.Approval) {
+	for approval := range approvals {
+		answered := make(chan struct{})
+		app.showApproval(approval, func() { close(answered) })
+		<-answered
+	}
+}
+
+// showApproval shows a modal asking whether an agent or the chat may run a command
+func (app *ChatUI) showApproval(approval session.Approval, done func()) {
+	who := approval.Agent
+	if who == "" {
+		who = "Smith"
+	}
+
+	// Closing the modal without a choice denies the command
+	var once sync.Once
+	respond := func(approved, addToAllowlist bool, verdict string) {
+		once.Do(func() {
+			approval.Respond(approved, addToAllowlist)
+			app.messageList.AddMessage("system", fmt.Sprintf("%s %s: %s", verdict, who, approval.Command))
+			done()
+		})
+	}
+	answer := func(approved, addToAllowlist bool, verdict string) func() {
+		return func() {
+			respond(approved, addToAllowlist, verdict)
+			app.modal.Close()
+		}
+	}
+
+	app.modal = lotusui.NewModal().
+		WithTitle("Approve Command").
+		WithContent(lotus.Text(fmt.Sprintf("%s wants to run:\n\n  %s\n\n%s", who, approval.Command, approval.Reason))).
+		WithButtons([]lotusui.ModalButton{
+			{Label: "Approve", Variant: "primary", OnClick: answer(true, false, "Approved")},
+			{Label: "Always", Variant: "secondary", OnClick: answer(true, true, "Allowed for this session")},
+			{Label: "Deny", Variant: "danger", OnClick: answer(false, false, "Denied")},
+		}).
+		WithOnClose(func() { respond(false, false, "Denied") })
+
+	app.modal.Show()
+	if app.renderCallback != nil {
+		app.renderCallback()
+	}
+}
+
 // showModelPicker shows a modal to select a model
 func (app *ChatUI) showModelPicker(args []string) {
 	// If model specified in args, use it directly
@@ -3,7 +3,6 @@ package locks
 import (
 	"context"
 	"errors"
-	"os"
 	"testing"
 
 	"github.com/speier/smith/internal/eventbus"
@@ -12,24 +11,8 @@ import (
 )
 
 func setupTestDB(t *testing.T) (storage.Store, *registry.Registry, func()) {
-	tmpDir, err := os.MkdirTemp("", "smith-locks-test-*")
-	if err != nil {
-		t.Fatalf("failed to create temp dir: %v", err)
-	}
-
-	store, err := storage.InitProjectStorage(tmpDir)
-	if err != nil {
-		t.Fatalf("failed to init storage: %v", err)
-	}
-
-	reg := registry.New(store)
-
-	cleanup := func() {
-		_ = store.Close()
-		_ = os.RemoveAll(tmpDir)
-	}
-
-	return store, reg, cleanup
+	store := storage.NewMemoryStore()
+	return store, registry.New(store), func() { _ = store.Close() }
 }
 
 func TestAcquireAndRelease(t *testing.T) {
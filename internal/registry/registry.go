@@ -16,6 +16,9 @@ const (
 	StatusActive AgentStatus = "active"
 	StatusIdle   AgentStatus = "idle"
 	StatusDead   AgentStatus = "dead"
+
+	// StatusStopping asks the agent to exit after its current task
+	StatusStopping AgentStatus = "stopping"
 )
 
 // Agent represents an agent in the registry
@@ -90,6 +93,20 @@ func (r *Registry) UpdateStatus(ctx context.Context, agentID string, status Agen
 	return nil
 }
 
+// RequestStop asks an agent, possibly in another process, to stop
+func (r *Registry) RequestStop(ctx context.Context, agentID string) error {
+	return r.UpdateStatus(ctx, agentID, StatusStopping)
+}
+
+// StopRequested reports whether RequestStop was called for an agent
+func (r *Registry) StopRequested(ctx context.Context, agentID string) (bool, error) {
+	agent, err := r.store.GetAgent(ctx, agentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get agent: %w", err)
+	}
+	return agent.Status == string(StatusStopping), nil
+}
+
 // AssignTask assigns a task to an agent
 func (r *Registry) AssignTask(ctx context.Context, agentID string, taskID string) error {
 	agent, err := r.store.GetAgent(ctx, agentID)
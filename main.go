@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -10,6 +11,10 @@ import (
 func main() {
 	if err := cli.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var codeErr *cli.CodeError
+		if errors.As(err, &codeErr) {
+			os.Exit(codeErr.Code)
+		}
 		os.Exit(1)
 	}
 }
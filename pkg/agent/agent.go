@@ -2,7 +2,10 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/speier/smith/pkg/agent/coordinator"
@@ -82,7 +85,7 @@ func (a *BaseAgent) Role() eventbus.AgentRole {
 // 4. Complete or fail the task
 func (a *BaseAgent) StartLoop(ctx context.Context, executor func(context.Context, *coordinator.Task) (string, error)) error {
 	// Register with the coordinator (convert eventbus.AgentRole to coordinator.AgentRole)
-	if err := a.registry.Register(ctx, a.ID, coordinator.AgentRole(a.role), 0); err != nil {
+	if err := a.registry.Register(ctx, a.ID, coordinator.AgentRole(a.role), os.Getpid()); err != nil {
 		return fmt.Errorf("failed to register agent: %w", err)
 	}
 
@@ -105,6 +108,11 @@ func (a *BaseAgent) StartLoop(ctx context.Context, executor func(context.Context
 			// Heartbeat
 			_ = a.registry.Heartbeat(ctx, a.ID)
 
+			// Exit between tasks when `smith agents stop` asks us to
+			if stop, err := a.registry.StopRequested(ctx, a.ID); err == nil && stop {
+				return a.Stop()
+			}
+
 			// Poll for available tasks
 			tasks, err := a.coord.GetAvailableTasks()
 			if err != nil {
@@ -115,7 +123,7 @@ func (a *BaseAgent) StartLoop(ctx context.Context, executor func(context.Context
 			// Filter tasks by role (only pick up tasks for our role)
 			var myTasks []coordinator.Task
 			for _, task := range tasks {
-				if task.Role == "" || task.Role == string(a.role) {
+				if task.Role == "" || engine.CanonicalRole(task.Role) == string(a.role) {
 					myTasks = append(myTasks, task)
 				}
 			}
@@ -163,14 +171,19 @@ func (a *BaseAgent) StartLoop(ctx context.Context, executor func(context.Context
 				fullTask.Description += contextInfo
 			}
 
-			// Execute the task
-			result, err := executor(ctx, fullTask)
+			// Execute the task (agent identity is used for approvals and per-agent safety level)
+			taskCtx := engine.WithAgent(ctx, engine.AgentInfo{ID: a.ID, Role: string(a.role), TaskID: task.ID})
+			result, err := executor(taskCtx, fullTask)
 			if err != nil {
 				// Task failed (logging removed to avoid TUI contamination)
 
 				// Extract learnings from error if possible
 				learnings := a.extractLearnings(err.Error())
 				blockers := a.extractBlockers(err.Error())
+				var changes *ChangesRequestedError
+				if errors.As(err, &changes) && len(changes.Issues) > 0 {
+					blockers = changes.Issues
+				}
 
 				opts := []coordinator.TaskOption{}
 				if learnings != "" {
@@ -185,8 +198,7 @@ func (a *BaseAgent) StartLoop(ctx context.Context, executor func(context.Context
 			}
 
 			// Extract learnings from result
-			learnings := a.extractLearnings(result)
-			approaches := a.extractApproaches(result)
+			learnings, approaches := a.outcome(taskCtx, fullTask, result)
 
 			// Complete the task with learnings
 			// Task completed successfully (logging removed to avoid TUI contamination)
@@ -204,6 +216,31 @@ func (a *BaseAgent) StartLoop(ctx context.Context, executor func(context.Context
 	}
 }
 
+// ChangesRequestedError fails a task whose review found issues
+type ChangesRequestedError struct {
+	Issues []string
+}
+
+func (e *ChangesRequestedError) Error() string {
+	if len(e.Issues) == 0 {
+		return "changes requested"
+	}
+	return "changes requested: " + strings.Join(e.Issues, "; ")
+}
+
+// outcome returns the learnings and tried approaches of a finished task:
+// from the model's structured report when there is an engine, otherwise
+// from patterns in the result
+func (a *BaseAgent) outcome(ctx context.Context, task *coordinator.Task, result string) (string, []string) {
+	if a.engine != nil {
+		report, err := engine.Report[engine.TaskOutcome](ctx, a.engine, string(a.role), task.Title, result, engine.TaskOutcomeSchema)
+		if err == nil {
+			return report.Learnings, report.Approaches
+		}
+	}
+	return a.extractLearnings(result), a.extractApproaches(result)
+}
+
 // extractLearnings parses learnings from task result/error
 // Looks for patterns like "Learning:", "Learned:", "Key insight:"
 func (a *BaseAgent) extractLearnings(text string) string {
@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -468,6 +469,45 @@ func TestReviewAgent(t *testing.T) {
 	}
 }
 
+// TestReviewTestEvidence tests that review sees test runs recorded on dependencies
+func TestReviewTestEvidence(t *testing.T) {
+	coord, err := coordinator.NewBolt(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create coordinator: %v", err)
+	}
+	defer func() { _ = coord.Close() }()
+
+	testID, err := coord.CreateTask("Test auth", "Write tests", string(eventbus.RoleTesting))
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	if err := coord.RecordTestRun(testID, coordinator.TestRun{
+		Command: "go test -json ./...",
+		Passed:  3,
+		Failed:  1,
+		Tests:   []coordinator.TestCaseResult{{Name: "TestLogin", Status: "fail", Location: "auth_test.go:42"}},
+	}); err != nil {
+		t.Fatalf("RecordTestRun failed: %v", err)
+	}
+
+	reviewID, err := coord.CreateTask("Review auth", "Review it", string(eventbus.RoleReview), coordinator.WithDependencies(testID))
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	review, err := coord.GetTask(reviewID)
+	if err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+
+	reviewAgent := NewReviewAgent(Config{AgentID: "agent-review-001", Coordinator: coord})
+	evidence := reviewAgent.testEvidence(review)
+	for _, want := range []string{"3 passed, 1 failed", "FAIL TestLogin auth_test.go:42"} {
+		if !strings.Contains(evidence, want) {
+			t.Errorf("expected evidence to contain %q, got:\n%s", want, evidence)
+		}
+	}
+}
+
 // TestAllAgentTypes tests all five agent types working together
 func TestAllAgentTypes(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -560,3 +600,69 @@ func TestAllAgentTypes(t *testing.T) {
 		}
 	}
 }
+
+// TestAgentOutOfProcess runs an agent through a second coordinator on the same
+// project, the way `smith agents run` shares the database, and stops it with a
+// stop request instead of cancelling its context
+func TestAgentOutOfProcess(t *testing.T) {
+	tmpDir := t.TempDir()
+	coord, err := coordinator.NewBolt(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create coordinator: %v", err)
+	}
+	defer func() { _ = coord.Close() }()
+
+	worker, err := coordinator.NewBolt(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to open second coordinator: %v", err)
+	}
+	defer func() { _ = worker.Close() }()
+
+	// Legacy role names reach the matching agent
+	taskID, err := coord.CreateTask("Add endpoint", "GET /health", "implementation")
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	agent := NewImplementationAgent(Config{
+		AgentID:      "keymaker-worker-1",
+		Coordinator:  worker,
+		Registry:     worker.GetRegistry(),
+		PollInterval: 20 * time.Millisecond,
+	})
+	done := make(chan error, 1)
+	go func() { done <- agent.Start(context.Background()) }()
+
+	deadline := time.After(3 * time.Second)
+	for {
+		task, err := coord.GetTask(taskID)
+		if err == nil && task.Status == "done" {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for worker to complete task")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	registered, err := coord.Registry().Get(context.Background(), "keymaker-worker-1")
+	if err != nil || registered.PID != os.Getpid() {
+		t.Fatalf("expected agent registered with its PID, got %+v (%v)", registered, err)
+	}
+
+	if err := coord.Registry().RequestStop(context.Background(), "keymaker-worker-1"); err != nil {
+		t.Fatalf("RequestStop failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected clean stop, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("agent did not stop")
+	}
+	if _, err := coord.Registry().Get(context.Background(), "keymaker-worker-1"); err == nil {
+		t.Error("expected stopped agent to be unregistered")
+	}
+}
@@ -30,15 +30,19 @@ func NewBolt(projectPath string) (*BoltCoordinator, error) {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
-	coord := &BoltCoordinator{
+	return NewWithStore(projectPath, store), nil
+}
+
+// NewWithStore creates a coordinator on an existing store, such as a
+// storage.MemoryStore for runs that should leave no files behind
+func NewWithStore(projectPath string, store storage.Store) *BoltCoordinator {
+	return &BoltCoordinator{
 		projectPath: projectPath,
 		db:          store,
 		eventBus:    eventbus.New(store),
 		lockMgr:     NewLockManager(store),
 		registry:    registry.New(store),
 	}
-
-	return coord, nil
 }
 
 // Registry returns the agent registry (legacy method - use GetRegistry instead)
@@ -80,7 +84,7 @@ func (a *eventBusAdapter) Publish(ctx context.Context, event Event) error {
 
 func (a *eventBusAdapter) Query(ctx context.Context, filter EventFilter) ([]Event, error) {
 	// Convert interface EventFilter to eventbus.EventFilter
-	ebFilter := eventbus.EventFilter{}
+	ebFilter := eventbus.EventFilter{SinceID: filter.SinceID, TaskID: filter.TaskID}
 	for _, et := range filter.EventTypes {
 		ebFilter.EventTypes = append(ebFilter.EventTypes, eventbus.EventType(et))
 	}
@@ -94,6 +98,8 @@ func (a *eventBusAdapter) Query(ctx context.Context, filter EventFilter) ([]Even
 	result := make([]Event, len(events))
 	for i, e := range events {
 		result[i] = Event{
+			ID:        e.ID,
+			Timestamp: e.Timestamp,
 			AgentID:   e.AgentID,
 			AgentRole: AgentRole(e.AgentRole),
 			Type:      EventType(e.Type),
@@ -147,6 +153,10 @@ func (a *registryAdapter) GetActiveAgents(ctx context.Context) ([]Agent, error)
 	return activeAgents, nil
 }
 
+func (a *registryAdapter) StopRequested(ctx context.Context, agentID string) (bool, error) {
+	return a.Registry.StopRequested(ctx, agentID)
+}
+
 // EnsureDirectories creates the .smith directory structure
 // For BoltCoordinator, this just ensures the database is initialized
 func (c *BoltCoordinator) EnsureDirectories() error {
@@ -326,14 +336,23 @@ func (c *BoltCoordinator) GetAvailableTasks() ([]Task, error) {
 		return nil, fmt.Errorf("failed to list available tasks: %w", err)
 	}
 
+	// Load every dependency in one read
+	var depIDs []string
+	for _, st := range storageTasks {
+		depIDs = append(depIDs, st.DependsOn...)
+	}
+	deps, err := c.db.GetTasks(ctx, depIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load task dependencies: %w", err)
+	}
+
 	var tasks []Task
 	for _, st := range storageTasks {
 		// Check if dependencies are satisfied
 		if len(st.DependsOn) > 0 {
 			allDependenciesMet := true
 			for _, depID := range st.DependsOn {
-				depTask, err := c.db.GetTask(ctx, depID)
-				if err != nil || depTask.Status != "done" {
+				if dep, ok := deps[depID]; !ok || dep.Status != "done" {
 					allDependenciesMet = false
 					break
 				}
@@ -344,25 +363,7 @@ func (c *BoltCoordinator) GetAvailableTasks() ([]Task, error) {
 			}
 		}
 
-		task := Task{
-			ID:              st.TaskID,
-			Title:           st.Title,
-			Description:     st.Description,
-			Role:            st.AgentRole,
-			Status:          st.Status,
-			Priority:        st.Priority,
-			DependsOn:       st.DependsOn,
-			AgentID:         st.AgentID,
-			Result:          st.Result,
-			Error:           st.Error,
-			StartedAt:       st.StartedAt,
-			UpdatedAt:       st.UpdatedAt,
-			CompletedAt:     st.CompletedAt,
-			Learnings:       st.Learnings,
-			TriedApproaches: st.TriedApproaches,
-			Blockers:        st.Blockers,
-			Notes:           st.Notes,
-		}
+		task := toTask(st)
 		tasks = append(tasks, task)
 	}
 
@@ -391,25 +392,7 @@ func (c *BoltCoordinator) GetTasksByStatus(status string) ([]Task, error) {
 
 	var tasks []Task
 	for _, st := range storageTasks {
-		task := Task{
-			ID:              st.TaskID,
-			Title:           st.Title,
-			Description:     st.Description,
-			Role:            st.AgentRole,
-			Status:          st.Status,
-			AgentID:         st.AgentID,
-			Result:          st.Result,
-			Error:           st.Error,
-			Priority:        st.Priority,
-			DependsOn:       st.DependsOn,
-			StartedAt:       st.StartedAt,
-			UpdatedAt:       st.UpdatedAt,
-			CompletedAt:     st.CompletedAt,
-			Learnings:       st.Learnings,
-			TriedApproaches: st.TriedApproaches,
-			Blockers:        st.Blockers,
-			Notes:           st.Notes,
-		}
+		task := toTask(st)
 		tasks = append(tasks, task)
 	}
 
@@ -626,6 +609,10 @@ func (c *BoltCoordinator) CompleteTask(taskID, result string, opts ...TaskOption
 		return fmt.Errorf("failed to get task: %w", err)
 	}
 
+	if task.Status == "cancelled" {
+		return fmt.Errorf("task %s was cancelled", taskID)
+	}
+
 	task.Status = "done"
 	task.Result = result
 	now := time.Now()
@@ -678,6 +665,10 @@ func (c *BoltCoordinator) FailTask(taskID, errorMsg string, opts ...TaskOption)
 		return fmt.Errorf("failed to get task: %w", err)
 	}
 
+	if task.Status == "cancelled" {
+		return fmt.Errorf("task %s was cancelled", taskID)
+	}
+
 	task.Status = "backlog"
 	task.Error = errorMsg
 	task.AgentID = ""
@@ -731,46 +722,40 @@ func (c *BoltCoordinator) GetTask(taskID string) (*Task, error) {
 	}
 
 	// Convert storage.Task to coordinator.Task
-	task := &Task{
-		ID:              storageTask.TaskID,
-		Title:           storageTask.Title,
-		Description:     storageTask.Description,
-		Status:          storageTask.Status,
-		Role:            storageTask.AgentRole,
-		AgentID:         storageTask.AgentID,
-		Result:          storageTask.Result,
-		Error:           storageTask.Error,
-		Priority:        storageTask.Priority,
-		DependsOn:       storageTask.DependsOn,
-		StartedAt:       storageTask.StartedAt,
-		UpdatedAt:       storageTask.UpdatedAt,
-		CompletedAt:     storageTask.CompletedAt,
-		Learnings:       storageTask.Learnings,
-		TriedApproaches: storageTask.TriedApproaches,
-		Blockers:        storageTask.Blockers,
-		Notes:           storageTask.Notes,
-	}
-
-	return task, nil
+	task := toTask(storageTask)
+
+	return &task, nil
+}
+
+// RecordTestRun adds a test run to a task so review can check its results.
+// Tasks keep the newest storage.MaxTestRuns runs and a summary of all of them.
+func (c *BoltCoordinator) RecordTestRun(taskID string, run TestRun) error {
+	if run.RanAt.IsZero() {
+		run.RanAt = time.Now()
+	}
+
+	if err := c.db.AddTestRun(context.Background(), taskID, run); err != nil {
+		return fmt.Errorf("failed to record test run: %w", err)
+	}
+
+	return nil
 }
 
 // GetRecentTasks retrieves recent tasks, optionally filtered by agent role
 // This is the "memory query" API - agents use this to learn from past work
 func (c *BoltCoordinator) GetRecentTasks(ctx context.Context, role string, limit int) ([]*Task, error) {
-	// Get all tasks (pass nil to get all statuses)
-	storageTasks, err := c.db.ListTasks(ctx, nil)
+	// Get tasks for the role, or all tasks if no role is specified
+	var filtered []*storage.Task
+	var err error
+	if role != "" {
+		filtered, err = c.db.ListTasksByRole(ctx, role)
+	} else {
+		filtered, err = c.db.ListTasks(ctx, nil)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tasks: %w", err)
 	}
 
-	// Filter by role if specified
-	var filtered []*storage.Task
-	for _, st := range storageTasks {
-		if role == "" || st.AgentRole == role {
-			filtered = append(filtered, st)
-		}
-	}
-
 	// Sort by UpdatedAt descending (most recent first)
 	sort.Slice(filtered, func(i, j int) bool {
 		return filtered[i].UpdatedAt.After(filtered[j].UpdatedAt)
@@ -784,25 +769,8 @@ func (c *BoltCoordinator) GetRecentTasks(ctx context.Context, role string, limit
 	// Convert to coordinator.Task
 	tasks := make([]*Task, len(filtered))
 	for i, st := range filtered {
-		tasks[i] = &Task{
-			ID:              st.TaskID,
-			Title:           st.Title,
-			Description:     st.Description,
-			Status:          st.Status,
-			Role:            st.AgentRole,
-			AgentID:         st.AgentID,
-			Result:          st.Result,
-			Error:           st.Error,
-			Priority:        st.Priority,
-			DependsOn:       st.DependsOn,
-			StartedAt:       st.StartedAt,
-			UpdatedAt:       st.UpdatedAt,
-			CompletedAt:     st.CompletedAt,
-			Learnings:       st.Learnings,
-			TriedApproaches: st.TriedApproaches,
-			Blockers:        st.Blockers,
-			Notes:           st.Notes,
-		}
+		task := toTask(st)
+		tasks[i] = &task
 	}
 
 	return tasks, nil
@@ -848,9 +816,246 @@ func (c *BoltCoordinator) GetSessionUsage(ctx context.Context, sessionID string)
 		TotalTokens:      usage.TotalTokens,
 		PromptTokens:     usage.PromptTokens,
 		CompletionTokens: usage.CompletionTokens,
+		CachedTokens:     usage.CachedTokens,
+		ReasoningTokens:  usage.ReasoningTokens,
+		CacheHits:        usage.CacheHits,
+		CacheMisses:      usage.CacheMisses,
 	}, nil
 }
 
+// RecordUsage adds the tokens of an LLM call to its task, in the current
+// session unless usage names one. Calls outside a task (the main chat) are
+// kept per session.
+func (c *BoltCoordinator) RecordUsage(ctx context.Context, usage LLMUsage) error {
+	if usage.SessionID == "" {
+		sessionID, err := c.GetOrCreateSession(ctx)
+		if err != nil {
+			return err
+		}
+		usage.SessionID = sessionID
+	}
+
+	// Usage is stored as one running total per task
+	key := usage.TaskID
+	if key == "" {
+		key = "chat-" + usage.SessionID
+	}
+	total, err := c.db.GetUsage(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get usage: %w", err)
+	}
+	if total == nil {
+		total = &storage.LLMUsage{TaskID: key, SessionID: usage.SessionID}
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.CachedTokens += usage.CachedTokens
+	total.ReasoningTokens += usage.ReasoningTokens
+	total.CacheHits += usage.CacheHits
+	total.CacheMisses += usage.CacheMisses
+	if usage.TotalTokens > 0 {
+		// Response cache lookups spend no tokens and name no model
+		total.Provider, total.Model = usage.Provider, usage.Model
+	}
+	total.Timestamp = time.Now()
+
+	if err := c.db.SaveUsage(ctx, total); err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	return nil
+}
+
+// GetCachedResponse returns the response cached under key, or nil if there
+// is none or it has expired
+func (c *BoltCoordinator) GetCachedResponse(ctx context.Context, key string) ([]byte, error) {
+	entry, err := c.db.GetCachedResponse(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached response: %w", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	return entry.Data, nil
+}
+
+// CacheResponse caches a response for ttl, keeping at most maxEntries
+// responses (0 means no limit)
+func (c *BoltCoordinator) CacheResponse(ctx context.Context, key string, data []byte, ttl time.Duration, maxEntries int) error {
+	now := time.Now()
+	entry := &storage.CachedResponse{Key: key, Data: data, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	if err := c.db.PutCachedResponse(ctx, entry, maxEntries); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	return nil
+}
+
+// ListTasks returns tasks matching the filter, ordered by task ID
+func (c *BoltCoordinator) ListTasks(ctx context.Context, filter TaskFilter) ([]Task, error) {
+	var statusFilter *string
+	if filter.Status != "" {
+		statusFilter = &filter.Status
+	}
+
+	storageTasks, err := c.db.ListTasks(ctx, statusFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	var tasks []Task
+	for _, st := range storageTasks {
+		if filter.Role != "" && st.AgentRole != filter.Role {
+			continue
+		}
+		if filter.SessionID != "" && st.SessionID != filter.SessionID {
+			continue
+		}
+		tasks = append(tasks, toTask(st))
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].ID < tasks[j].ID
+	})
+
+	return tasks, nil
+}
+
+// CancelTask stops a task from being picked up or completed
+func (c *BoltCoordinator) CancelTask(taskID, reason string) error {
+	ctx := context.Background()
+
+	task, err := c.db.GetTask(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	switch task.Status {
+	case "done":
+		return fmt.Errorf("task %s is already done", taskID)
+	case "cancelled":
+		return fmt.Errorf("task %s is already cancelled", taskID)
+	}
+
+	task.Status = "cancelled"
+	task.Error = reason
+	task.UpdatedAt = time.Now()
+
+	if err := c.db.UpdateTask(ctx, task); err != nil {
+		return fmt.Errorf("failed to cancel task: %w", err)
+	}
+
+	// Release any files the task still holds (best effort)
+	if task.AgentID != "" {
+		_ = c.lockMgr.ReleaseAll(ctx, task.AgentID)
+	}
+
+	return c.eventBus.PublishWithData(
+		ctx,
+		"coordinator",
+		eventbus.RoleCoordinator,
+		eventbus.EventTaskCancelled,
+		&taskID,
+		nil,
+		map[string]string{"task_id": taskID, "reason": reason},
+	)
+}
+
+// RetryTask moves a failed, cancelled or finished task back to the backlog
+func (c *BoltCoordinator) RetryTask(taskID string) error {
+	ctx := context.Background()
+
+	task, err := c.db.GetTask(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	switch {
+	case task.Status == "wip" || task.Status == "review":
+		return fmt.Errorf("task %s is in progress", taskID)
+	case task.Status == "backlog" && task.Error == "":
+		return fmt.Errorf("task %s is already waiting in the backlog", taskID)
+	}
+
+	// Keep learnings, tried approaches and blockers so the next attempt can use them
+	task.Status = "backlog"
+	task.AgentID = ""
+	task.Result = ""
+	task.Error = ""
+	task.CompletedAt = nil
+	task.UpdatedAt = time.Now()
+
+	if err := c.db.UpdateTask(ctx, task); err != nil {
+		return fmt.Errorf("failed to retry task: %w", err)
+	}
+
+	return c.eventBus.PublishWithData(
+		ctx,
+		"coordinator",
+		eventbus.RoleCoordinator,
+		eventbus.EventTaskUpdated,
+		&taskID,
+		nil,
+		map[string]string{"task_id": taskID, "status": "backlog", "retry": "true"},
+	)
+}
+
+// SetTaskPriority changes a task's priority (0=low, 1=medium, 2=high)
+func (c *BoltCoordinator) SetTaskPriority(taskID string, priority int) error {
+	ctx := context.Background()
+
+	if priority < 0 || priority > 2 {
+		return fmt.Errorf("invalid priority: %d", priority)
+	}
+
+	task, err := c.db.GetTask(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	task.Priority = priority
+	task.UpdatedAt = time.Now()
+
+	if err := c.db.UpdateTask(ctx, task); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	return c.eventBus.PublishWithData(
+		ctx,
+		"coordinator",
+		eventbus.RoleCoordinator,
+		eventbus.EventTaskUpdated,
+		&taskID,
+		nil,
+		map[string]string{"task_id": taskID, "priority": fmt.Sprint(priority)},
+	)
+}
+
+// toTask converts a storage.Task to a coordinator.Task
+func toTask(st *storage.Task) Task {
+	return Task{
+		ID:              st.TaskID,
+		Title:           st.Title,
+		Description:     st.Description,
+		Role:            st.AgentRole,
+		Status:          st.Status,
+		SessionID:       st.SessionID,
+		AgentID:         st.AgentID,
+		Result:          st.Result,
+		Error:           st.Error,
+		Priority:        st.Priority,
+		DependsOn:       st.DependsOn,
+		StartedAt:       st.StartedAt,
+		UpdatedAt:       st.UpdatedAt,
+		CompletedAt:     st.CompletedAt,
+		Learnings:       st.Learnings,
+		TriedApproaches: st.TriedApproaches,
+		Blockers:        st.Blockers,
+		Notes:           st.Notes,
+		TestRuns:        st.TestRuns,
+		TestSummary:     st.TestSummary,
+	}
+}
+
 // Close closes the database connection
 func (c *BoltCoordinator) Close() error {
 	return c.db.Close()
@@ -139,3 +139,93 @@ func TestBoltCoordinatorLockConflict(t *testing.T) {
 		t.Error("agent-2 should not be able to lock file already locked by agent-1")
 	}
 }
+
+func TestTaskManagement(t *testing.T) {
+	coord := NewWithStore(t.TempDir(), storage.NewMemoryStore())
+	ctx := context.Background()
+
+	first, err := coord.CreateTask("Build API", "REST endpoints", "implementation")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	second, _ := coord.CreateTask("Test API", "Integration tests", "testing", WithDependencies(first))
+
+	tasks, err := coord.ListTasks(ctx, TaskFilter{Role: "testing"})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != second || tasks[0].SessionID == "" {
+		t.Fatalf("unexpected tasks for role filter: %+v", tasks)
+	}
+	if tasks, _ := coord.ListTasks(ctx, TaskFilter{SessionID: "other"}); len(tasks) != 0 {
+		t.Errorf("expected no tasks for another session, got %d", len(tasks))
+	}
+
+	// Prioritize
+	if err := coord.SetTaskPriority(second, 2); err != nil {
+		t.Fatalf("SetTaskPriority failed: %v", err)
+	}
+	if err := coord.SetTaskPriority(second, 5); err == nil {
+		t.Error("expected error for invalid priority")
+	}
+
+	// Cancel: agents can no longer complete the task
+	if err := coord.CancelTask(first, "out of scope"); err != nil {
+		t.Fatalf("CancelTask failed: %v", err)
+	}
+	if err := coord.CompleteTask(first, "done anyway"); err == nil {
+		t.Error("expected error completing a cancelled task")
+	}
+	if tasks, _ := coord.ListTasks(ctx, TaskFilter{Status: "cancelled"}); len(tasks) != 1 || tasks[0].Error != "out of scope" {
+		t.Errorf("expected cancelled task with reason, got %+v", tasks)
+	}
+
+	// Retry: back to the backlog with the error cleared
+	if err := coord.RetryTask(second); err == nil {
+		t.Error("expected error retrying a task waiting in the backlog")
+	}
+	if err := coord.RetryTask(first); err != nil {
+		t.Fatalf("RetryTask failed: %v", err)
+	}
+	task, _ := coord.GetTask(first)
+	if task.Status != "backlog" || task.Error != "" {
+		t.Errorf("expected clean backlog task, got %+v", task)
+	}
+
+	events, err := coord.GetEventBus().Query(ctx, EventFilter{TaskID: &first})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(events) != 3 || events[1].Type != EventType(eventbus.EventTaskCancelled) {
+		t.Errorf("expected created, cancelled and retried events, got %+v", events)
+	}
+}
+
+func TestRecordUsage(t *testing.T) {
+	coord := NewWithStore(t.TempDir(), storage.NewMemoryStore())
+	ctx := context.Background()
+
+	taskID, _ := coord.CreateTask("Build API", "REST endpoints", "implementation")
+	for _, usage := range []LLMUsage{
+		{TaskID: taskID, PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100},
+		{TaskID: taskID, PromptTokens: 40, CompletionTokens: 10, TotalTokens: 50, ReasoningTokens: 6, Model: "o1"},
+		{PromptTokens: 5, CompletionTokens: 5, TotalTokens: 10}, // Main chat
+		{TaskID: taskID, CacheHits: 1},
+	} {
+		if err := coord.RecordUsage(ctx, usage); err != nil {
+			t.Fatalf("RecordUsage failed: %v", err)
+		}
+	}
+
+	session, err := coord.GetCurrentSession(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, err := coord.GetSessionUsage(ctx, session.SessionID)
+	if err != nil {
+		t.Fatalf("GetSessionUsage failed: %v", err)
+	}
+	if total.TotalTokens != 160 || total.PromptTokens != 125 || total.ReasoningTokens != 6 || total.CacheHits != 1 {
+		t.Errorf("expected 160 tokens (125 prompt, 6 reasoning) and a cache hit in the session, got %+v", total)
+	}
+}
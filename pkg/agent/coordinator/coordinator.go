@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 )
 
 // TaskOptions holds optional parameters for task creation
@@ -79,6 +80,13 @@ type Coordinator interface {
 	FailTask(taskID, errorMsg string, opts ...TaskOption) error
 	GetTask(taskID string) (*Task, error)
 	GetRecentTasks(ctx context.Context, role string, limit int) ([]*Task, error)
+	RecordTestRun(taskID string, run TestRun) error
+
+	// Task management from outside the agent loop (CLI, scripts)
+	ListTasks(ctx context.Context, filter TaskFilter) ([]Task, error)
+	CancelTask(taskID, reason string) error
+	RetryTask(taskID string) error
+	SetTaskPriority(taskID string, priority int) error
 
 	// File coordination
 	LockFiles(taskID, agent string, files []string) error
@@ -95,6 +103,11 @@ type Coordinator interface {
 
 	// Token usage tracking
 	GetSessionUsage(ctx context.Context, sessionID string) (*LLMUsage, error)
+	RecordUsage(ctx context.Context, usage LLMUsage) error
+
+	// Response cache of idempotent LLM requests
+	GetCachedResponse(ctx context.Context, key string) ([]byte, error)
+	CacheResponse(ctx context.Context, key string, data []byte, ttl time.Duration, maxEntries int) error
 }
 
 // EventBus defines the interface for event publishing and querying
@@ -109,10 +122,13 @@ type Registry interface {
 	Heartbeat(ctx context.Context, agentID string) error
 	Unregister(ctx context.Context, agentID string) error
 	GetActiveAgents(ctx context.Context) ([]Agent, error)
+	StopRequested(ctx context.Context, agentID string) (bool, error)
 }
 
 // Event represents a system event (simplified for interface)
 type Event struct {
+	ID        int64
+	Timestamp time.Time
 	AgentID   string
 	AgentRole AgentRole
 	Type      EventType
@@ -122,6 +138,8 @@ type Event struct {
 
 // EventFilter defines criteria for querying events
 type EventFilter struct {
+	SinceID    int64   // Only events with ID > SinceID
+	TaskID     *string // Only events for this task
 	EventTypes []EventType
 }
 
@@ -1,6 +1,10 @@
 package coordinator
 
-import "time"
+import (
+	"time"
+
+	"github.com/speier/smith/pkg/agent/storage"
+)
 
 // TaskStats represents statistics about tasks in different states
 type TaskStats struct {
@@ -15,8 +19,9 @@ type Task struct {
 	ID          string
 	Title       string
 	Description string
-	Status      string // backlog, wip, review, done
+	Status      string // backlog, wip, review, done, cancelled
 	Role        string // planning, implementation, testing, review
+	SessionID   string
 	AgentID     string
 	Result      string   // Output/result from task execution
 	Error       string   // Error message if task failed
@@ -31,8 +36,27 @@ type Task struct {
 	TriedApproaches []string          // Approaches attempted
 	Blockers        []string          // What didn't work
 	Notes           map[string]string // Freeform agent notes
+
+	TestRuns    []TestRun   // Newest test results recorded by run_tests
+	TestSummary TestSummary // Totals of every recorded run
+}
+
+// TaskFilter selects tasks for ListTasks; empty fields match everything
+type TaskFilter struct {
+	Status    string
+	Role      string
+	SessionID string
 }
 
+// TestRun records a run_tests execution against a task
+type TestRun = storage.TestRun
+
+// TestCaseResult is the outcome of a single test in a TestRun
+type TestCaseResult = storage.TestCaseResult
+
+// TestSummary totals the test runs recorded on a task
+type TestSummary = storage.TestSummary
+
 // Lock represents a file lock held by an agent
 type Lock struct {
 	Agent  string
@@ -53,9 +77,16 @@ type Session struct {
 // LLMUsage represents token usage for a session or task
 type LLMUsage struct {
 	SessionID        string
+	TaskID           string // Empty for the main chat
+	Provider         string // Provider and model of the last call
+	Model            string
 	TotalTokens      int
 	PromptTokens     int
 	CompletionTokens int
+	CachedTokens     int // Prompt tokens the provider read from its prompt cache
+	ReasoningTokens  int // Completion tokens the model spent thinking
+	CacheHits        int // Requests answered from the response cache
+	CacheMisses      int // Cacheable requests sent to the provider
 }
 
 // Message represents a message between agents
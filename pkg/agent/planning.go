@@ -3,9 +3,11 @@ package agent
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/speier/smith/pkg/agent/coordinator"
+	"github.com/speier/smith/internal/engine"
 	"github.com/speier/smith/internal/eventbus"
 )
 
@@ -26,7 +28,19 @@ func NewPlanningAgent(cfg Config) *PlanningAgent {
 func (a *PlanningAgent) Execute(ctx context.Context, task *coordinator.Task) (string, error) {
 	// If engine is available, use it for LLM-powered planning
 	if a.Engine() != nil {
-		return a.Engine().ExecuteTask(ctx, "planning", task.Title, task.Description)
+		result, err := a.Engine().ExecuteTask(ctx, "planning", task.Title, task.Description)
+		if err != nil {
+			return "", err
+		}
+		plan, err := engine.Report[engine.PlanResult](ctx, a.Engine(), "planning", task.Title, result, engine.PlanResultSchema)
+		if err != nil {
+			return result, nil // The plan stays readable as written
+		}
+		created, err := a.createTasks(plan)
+		if err != nil {
+			return "", err
+		}
+		return result + "\n\nCreated tasks:\n" + strings.Join(created, "\n"), nil
 	}
 
 	// Fallback: Simulate planning work
@@ -42,6 +56,39 @@ func (a *PlanningAgent) Execute(ctx context.Context, task *coordinator.Task) (st
 	return result, nil
 }
 
+// createTasks adds the tasks of a plan to the backlog and returns a line
+// for each
+func (a *PlanningAgent) createTasks(plan *engine.PlanResult) ([]string, error) {
+	ids := make([]string, len(plan.Tasks))
+	var created []string
+	for i, planned := range plan.Tasks {
+		var opts []coordinator.TaskOption
+		switch strings.ToLower(planned.Priority) {
+		case "high":
+			opts = append(opts, coordinator.WithPriority(2))
+		case "low":
+			opts = append(opts, coordinator.WithPriority(0))
+		}
+		var dependsOn []string
+		for _, n := range planned.DependsOn {
+			if n >= 1 && n <= i { // Only earlier tasks exist yet
+				dependsOn = append(dependsOn, ids[n-1])
+			}
+		}
+		if len(dependsOn) > 0 {
+			opts = append(opts, coordinator.WithDependencies(dependsOn...))
+		}
+
+		id, err := a.coord.CreateTask(planned.Title, planned.Description, engine.CanonicalRole(planned.Role), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating planned task %q: %w", planned.Title, err)
+		}
+		ids[i] = id
+		created = append(created, fmt.Sprintf("- %s: %s (%s)", id, planned.Title, planned.Role))
+	}
+	return created, nil
+}
+
 // Start begins the planning agent work loop
 func (a *PlanningAgent) Start(ctx context.Context) error {
 	return a.StartLoop(ctx, a.Execute)
@@ -3,9 +3,11 @@ package agent
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/speier/smith/pkg/agent/coordinator"
+	"github.com/speier/smith/internal/engine"
 	"github.com/speier/smith/internal/eventbus"
 )
 
@@ -26,7 +28,22 @@ func NewReviewAgent(cfg Config) *ReviewAgent {
 func (a *ReviewAgent) Execute(ctx context.Context, task *coordinator.Task) (string, error) {
 	// If engine is available, use it for LLM-powered code review
 	if a.Engine() != nil {
-		return a.Engine().ExecuteTask(ctx, "review", task.Title, task.Description)
+		description := task.Description
+		if evidence := a.testEvidence(task); evidence != "" {
+			description += "\n\n" + evidence
+		}
+		result, err := a.Engine().ExecuteTask(ctx, "review", task.Title, description)
+		if err != nil {
+			return "", err
+		}
+		verdict, err := engine.Report[engine.ReviewVerdict](ctx, a.Engine(), "review", task.Title, result, engine.ReviewVerdictSchema)
+		if err != nil {
+			return result, nil // Without a verdict the review stands as written
+		}
+		if !verdict.Approved {
+			return "", &ChangesRequestedError{Issues: verdict.Issues}
+		}
+		return result, nil
 	}
 
 	// Fallback: Simulate review work
@@ -46,3 +63,48 @@ func (a *ReviewAgent) Execute(ctx context.Context, task *coordinator.Task) (stri
 func (a *ReviewAgent) Start(ctx context.Context) error {
 	return a.StartLoop(ctx, a.Execute)
 }
+
+// testEvidence summarizes test runs recorded on the task and the tasks it depends on,
+// so the review can check that tests were added and pass
+func (a *ReviewAgent) testEvidence(task *coordinator.Task) string {
+	tasks := []*coordinator.Task{task}
+	for _, depID := range task.DependsOn {
+		if dep, err := a.coord.GetTask(depID); err == nil {
+			tasks = append(tasks, dep)
+		}
+	}
+
+	var sb strings.Builder
+	for _, t := range tasks {
+		if len(t.TestRuns) == 0 {
+			continue
+		}
+		// The latest run reflects the final state of the work
+		run := t.TestRuns[len(t.TestRuns)-1]
+		fmt.Fprintf(&sb, "- %s (%s): %d passed, %d failed, %d skipped via `%s` (%d runs recorded)\n",
+			t.ID, t.Title, run.Passed, run.Failed, run.Skipped, run.Command, t.TestSummary.Runs)
+		for _, tc := range run.Tests {
+			if tc.Status == "fail" {
+				fmt.Fprintf(&sb, "  - FAIL %s %s\n", tc.Name, tc.Location)
+			}
+		}
+		for _, e := range run.Errors {
+			fmt.Fprintf(&sb, "  - ERROR %s\n", firstLine(e))
+		}
+		if total, ok := run.Coverage["total"]; ok {
+			fmt.Fprintf(&sb, "  - coverage %.1f%%\n", total)
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "Test results: none recorded. Check whether tests were added and run."
+	}
+	return "Test results:\n" + strings.TrimRight(sb.String(), "\n")
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
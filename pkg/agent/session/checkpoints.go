@@ -0,0 +1,38 @@
+package session
+
+import (
+	"os"
+	"time"
+)
+
+// Checkpoints restores files a session's tools changed
+type Checkpoints interface {
+	// Undo restores the most recent checkpoint that hasn't been restored yet
+	Undo() (*Checkpoint, []string, error)
+
+	// Restore restores a checkpoint by ID, returning the restored paths
+	Restore(id string) (*Checkpoint, []string, error)
+
+	// List returns all checkpoints, oldest first
+	List() ([]*Checkpoint, error)
+}
+
+// Checkpoint records the contents of every file touched within a task or chat turn,
+// as they were before the first change
+type Checkpoint struct {
+	ID         string         `json:"id"`
+	TaskID     string         `json:"task_id,omitempty"`
+	Turn       int            `json:"turn,omitempty"`
+	Files      []FileSnapshot `json:"files"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	RestoredAt *time.Time     `json:"restored_at,omitempty"`
+}
+
+// FileSnapshot is the previous state of a single file
+type FileSnapshot struct {
+	Path    string      `json:"path"`           // Relative to project root
+	Existed bool        `json:"existed"`        // False if the file was created by the change
+	Hash    string      `json:"hash,omitempty"` // SHA-256 of the previous contents
+	Mode    os.FileMode `json:"mode,omitempty"`
+}
@@ -1,5 +1,9 @@
 package session
 
+import (
+	"github.com/speier/smith/pkg/llm"
+)
+
 // Session interface - represents an interactive coding session
 // Backed by the agent system (Planning, Implementation, Testing, Review)
 type Session interface {
@@ -9,10 +13,32 @@ type Session interface {
 	// GetHistory returns all messages in the conversation
 	GetHistory() []Message
 
+	// Attach adds a file to the next message; see llm.Attachment for the kinds of files
+	Attach(path string) (llm.Part, error)
+
+	// Approvals delivers commands of background agents and the chat waiting
+	// for the user's decision; nil if the session runs no commands
+	Approvals() <-chan Approval
+
+	// Checkpoints returns the checkpoints the session's tools snapshot
+	// files into before changing them; nil if the session has none
+	Checkpoints() Checkpoints
+
 	// Reset clears the conversation history
 	Reset()
 }
 
+// Approval is a blocked agent command waiting for the user
+type Approval struct {
+	Agent   string // ID of the agent asking; empty for the chat
+	Command string
+	Reason  string // Why the command needs approval
+
+	// Respond answers the request; addToAllowlist approves the command for
+	// the rest of the session
+	Respond func(approved, addToAllowlist bool)
+}
+
 // Message represents a chat message
 type Message struct {
 	Role    string // "user" or "assistant"
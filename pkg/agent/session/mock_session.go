@@ -2,12 +2,15 @@ package session
 
 import (
 	"time"
+
+	"github.com/speier/smith/pkg/llm"
 )
 
 // MockSession is a simple session for testing/demo
 // In production, this would be your actual agent system
 type MockSession struct {
-	history []Message
+	history     []Message
+	attachments []llm.Part
 }
 
 func NewMockSession() *MockSession {
@@ -22,6 +25,7 @@ func (m *MockSession) SendMessage(message string) (<-chan string, error) {
 		Role:    "user",
 		Content: message,
 	})
+	m.attachments = nil
 
 	// Create response channel
 	ch := make(chan string)
@@ -149,8 +153,29 @@ func (m *MockSession) GetHistory() []Message {
 	return m.history
 }
 
+// Attach reads a file for the next message
+func (m *MockSession) Attach(path string) (llm.Part, error) {
+	part, err := llm.Attachment(path)
+	if err != nil {
+		return llm.Part{}, err
+	}
+	m.attachments = append(m.attachments, part)
+	return part, nil
+}
+
+// Approvals returns nil: the mock session runs no agents
+func (m *MockSession) Approvals() <-chan Approval {
+	return nil
+}
+
+// Checkpoints returns nil: the mock session changes no files
+func (m *MockSession) Checkpoints() Checkpoints {
+	return nil
+}
+
 func (m *MockSession) Reset() {
 	m.history = []Message{}
+	m.attachments = nil
 }
 
 func splitWords(text string) []string {
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,29 +9,34 @@ import (
 
 	"github.com/speier/smith/internal/config"
 	"go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
 )
 
 // BoltDB wraps the BBolt database connection
 type BoltDB struct {
 	*bbolt.DB
-	path string
+	path      string
+	migration *MigrationResult // Schema migrations applied at open
 }
 
 // Bucket names
 var (
-	EventsBucket    = []byte("events")
-	FileLocksBucket = []byte("file_locks")
-	TasksBucket     = []byte("tasks")
-	AgentsBucket    = []byte("agents")
-	SessionsBucket  = []byte("sessions")
-	SequenceBucket  = []byte("sequences")
-	LLMUsageBucket  = []byte("llm_usage")
+	EventsBucket        = []byte("events")
+	FileLocksBucket     = []byte("file_locks")
+	TasksBucket         = []byte("tasks")
+	AgentsBucket        = []byte("agents")
+	SessionsBucket      = []byte("sessions")
+	SequenceBucket      = []byte("sequences")
+	LLMUsageBucket      = []byte("llm_usage")
+	IndexesBucket       = []byte("indexes") // Secondary indexes, see index.go
+	ResponseCacheBucket = []byte("response_cache")
 )
 
 // Note: Task, Agent, Event, FileLock types are now defined in interfaces.go
 
 // InitProjectStorage initializes the .smith directory and BBolt database.
 // This is the primary storage backend using BBolt for lock-free concurrent access.
+// Several smith processes can open the same project; see SharedStore.
 func InitProjectStorage(projectRoot string) (Store, error) {
 	smithDir := filepath.Join(projectRoot, ".smith")
 
@@ -39,61 +45,77 @@ func InitProjectStorage(projectRoot string) (Store, error) {
 		return nil, fmt.Errorf("failed to create .smith directory: %w", err)
 	}
 
-	// Initialize database
-	dbPath := filepath.Join(smithDir, "smith.db")
-	db, err := initBoltDatabase(dbPath)
+	// Open the database, or connect to the smith process that has it open
+	store, err := OpenSharedStore(projectRoot)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
 	// Create default project files (config, .gitignore)
 	if err := config.InitProjectFiles(smithDir); err != nil {
+		_ = store.Close()
 		return nil, err
 	}
 
-	// Return wrapped Store interface
-	return NewBoltStore(db.DB), nil
+	return store, nil
 }
 
-// initBoltDatabase creates and initializes the BBolt database with buckets
+// initBoltDatabase opens the BBolt database and migrates it to the current schema
 func initBoltDatabase(dbPath string) (*BoltDB, error) {
+	return openBoltDatabase(dbPath, 5*time.Second)
+}
+
+// openBoltDatabase is initBoltDatabase with a custom wait for the file lock
+func openBoltDatabase(dbPath string, timeout time.Duration) (*BoltDB, error) {
 	// Open database
-	boltDB, err := bbolt.Open(dbPath, 0600, &bbolt.Options{
-		Timeout: 5 * time.Second,
+	boltDB, err := openBolt(dbPath, &bbolt.Options{
+		Timeout: timeout,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Create buckets
-	err = boltDB.Update(func(tx *bbolt.Tx) error {
-		buckets := [][]byte{
-			EventsBucket,
-			FileLocksBucket,
-			TasksBucket,
-			AgentsBucket,
-			SessionsBucket,
-			SequenceBucket,
-			LLMUsageBucket,
-		}
-		for _, bucket := range buckets {
-			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
-				return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
-			}
-		}
-		return nil
-	})
+	// Create or upgrade the schema
+	result, err := migrate(boltDB, dbPath)
 	if err != nil {
 		_ = boltDB.Close()
 		return nil, err
 	}
 
 	return &BoltDB{
-		DB:   boltDB,
-		path: dbPath,
+		DB:        boltDB,
+		path:      dbPath,
+		migration: result,
 	}, nil
 }
 
+// isLockTimeout reports whether opening the database failed because another
+// process holds its file lock
+func isLockTimeout(err error) bool {
+	return errors.Is(err, berrors.ErrTimeout)
+}
+
+// openBolt opens the database file at dbPath. Compaction replaces the file
+// while holding the lock on the old one, so a process that was waiting for
+// that lock gets it on a file no longer at dbPath; it then opens again.
+func openBolt(dbPath string, options *bbolt.Options) (*bbolt.DB, error) {
+	for attempt := 0; ; attempt++ {
+		before, _ := os.Stat(dbPath)
+		db, err := bbolt.Open(dbPath, 0600, options)
+		if err != nil {
+			return nil, err
+		}
+		// The file opened is the one at dbPath before and after the wait
+		if after, err := os.Stat(dbPath); err == nil && before != nil && os.SameFile(before, after) {
+			return db, nil
+		}
+		_ = db.Close()
+		if attempt == 10 {
+			return nil, fmt.Errorf("%s keeps being replaced while opening it", dbPath)
+		}
+	}
+}
+
 // Path returns the file path of the database
 func (db *BoltDB) Path() string {
 	return db.path
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"go.etcd.io/bbolt"
 )
 
+// defaultEventLimit caps QueryEvents results when the filter has no limit
+const defaultEventLimit = 1000
+
 // BoltStore implements the Store interface using BBolt
 type BoltStore struct {
 	db *bbolt.DB
@@ -23,11 +27,6 @@ func NewBoltStore(db *bbolt.DB) *BoltStore {
 
 func (s *BoltStore) SaveEvent(ctx context.Context, event *Event) error {
 	return s.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(EventsBucket)
-		if b == nil {
-			return fmt.Errorf("events bucket not found")
-		}
-
 		// Get next sequence ID
 		seq := tx.Bucket(SequenceBucket)
 		if seq == nil {
@@ -45,70 +44,110 @@ func (s *BoltStore) SaveEvent(ctx context.Context, event *Event) error {
 			event.Timestamp = time.Now()
 		}
 
-		// Encode event
-		data, err := json.Marshal(event)
-		if err != nil {
-			return fmt.Errorf("failed to encode event: %w", err)
-		}
-
-		// Store by ID
-		key := []byte(fmt.Sprintf("%d", event.ID))
-		if err := b.Put(key, data); err != nil {
-			return fmt.Errorf("failed to store event: %w", err)
-		}
-
-		return nil
+		// Store by ID, along with its index entries
+		return putEvent(tx, event)
 	})
 }
 
 func (s *BoltStore) QueryEvents(ctx context.Context, filter EventFilter) ([]*Event, error) {
 	var events []*Event
-
 	err := s.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(EventsBucket)
-		if b == nil {
-			return fmt.Errorf("events bucket not found")
-		}
+		var err error
+		events, _, err = queryEvents(tx, filter)
+		return err
+	})
+	return events, err
+}
 
-		// Iterate all events (BBolt doesn't support queries, so we filter in Go)
-		c := b.Cursor()
-		for k, v := c.Last(); k != nil && len(events) < 1000; k, v = c.Prev() {
-			var event Event
-			if err := json.Unmarshal(v, &event); err != nil {
-				return fmt.Errorf("failed to decode event: %w", err)
-			}
+// queryEvents returns the events matching filter, newest first, and the
+// number of index entries it read to find them
+func queryEvents(tx *bbolt.Tx, filter EventFilter) ([]*Event, int, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultEventLimit
+	}
 
-			// Apply filters
-			if len(filter.EventTypes) > 0 {
-				found := false
-				for _, et := range filter.EventTypes {
-					if event.EventType == et {
-						found = true
-						break
-					}
-				}
-				if !found {
-					continue
-				}
-			}
+	b := tx.Bucket(EventsBucket)
+	if b == nil {
+		return nil, 0, fmt.Errorf("events bucket not found")
+	}
 
-			if filter.AgentID != nil && event.AgentID != *filter.AgentID {
-				continue
-			}
+	// Seek the most selective index; remaining filters are applied to each event
+	var streams []*eventStream
+	byTime := false
+	switch {
+	case filter.TaskID != nil:
+		idx, err := indexBucket(tx, EventsByTaskIndex)
+		if err != nil {
+			return nil, 0, err
+		}
+		streams = append(streams, newEventStream(idx, indexPrefix(*filter.TaskID), nil))
+	case filter.AgentID != nil:
+		idx, err := indexBucket(tx, EventsByAgentIndex)
+		if err != nil {
+			return nil, 0, err
+		}
+		streams = append(streams, newEventStream(idx, indexPrefix(*filter.AgentID), nil))
+	case len(filter.EventTypes) > 0:
+		idx, err := indexBucket(tx, EventsByTypeIndex)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, et := range filter.EventTypes {
+			streams = append(streams, newEventStream(idx, indexPrefix(et), nil))
+		}
+	case filter.SinceID > 0:
+		// Polling for new events: walk IDs down to the cursor
+		idx, err := indexBucket(tx, EventsByIDIndex)
+		if err != nil {
+			return nil, 0, err
+		}
+		streams = append(streams, newEventStream(idx, nil, nil))
+	default:
+		idx, err := indexBucket(tx, EventsByTimeIndex)
+		if err != nil {
+			return nil, 0, err
+		}
+		streams = append(streams, newEventStream(idx, nil, timeUpper(filter.Until)))
+		byTime = true
+	}
 
-			if filter.TaskID != nil {
-				if event.TaskID == nil || *event.TaskID != *filter.TaskID {
-					continue
-				}
+	var events []*Event
+	scanned := 0
+	for len(events) < limit {
+		stream := mergeNewest(streams)
+		if stream == nil {
+			break
+		}
+		id := stream.id()
+
+		if byTime {
+			// Time-ordered: stop once past the start of the range
+			if !filter.Since.IsZero() && stream.timestamp().Before(filter.Since) {
+				break
 			}
+		} else if id <= filter.SinceID {
+			// ID-ordered: everything after this is older
+			break
+		}
+		stream.next()
+		scanned++
 
-			events = append(events, &event)
+		data := b.Get(eventKey(id))
+		if data == nil {
+			continue // Index entry for a removed event
+		}
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, scanned, fmt.Errorf("failed to decode event: %w", err)
 		}
 
-		return nil
-	})
+		if filter.matches(&event) {
+			events = append(events, &event)
+		}
+	}
 
-	return events, err
+	return events, scanned, nil
 }
 
 // === AgentStore Implementation ===
@@ -283,11 +322,6 @@ func (s *BoltStore) MarkAgentDead(ctx context.Context, timeout time.Duration) (i
 
 func (s *BoltStore) CreateTask(ctx context.Context, task *Task) error {
 	return s.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(TasksBucket)
-		if b == nil {
-			return fmt.Errorf("tasks bucket not found")
-		}
-
 		// Set timestamps if needed
 		if task.StartedAt.IsZero() {
 			task.StartedAt = time.Now()
@@ -296,16 +330,7 @@ func (s *BoltStore) CreateTask(ctx context.Context, task *Task) error {
 			task.UpdatedAt = time.Now()
 		}
 
-		data, err := json.Marshal(task)
-		if err != nil {
-			return fmt.Errorf("failed to encode task: %w", err)
-		}
-
-		if err := b.Put([]byte(task.TaskID), data); err != nil {
-			return fmt.Errorf("failed to store task: %w", err)
-		}
-
-		return nil
+		return putTask(tx, task)
 	})
 }
 
@@ -336,27 +361,48 @@ func (s *BoltStore) GetTask(ctx context.Context, taskID string) (*Task, error) {
 
 func (s *BoltStore) UpdateTask(ctx context.Context, task *Task) error {
 	return s.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(TasksBucket)
-		if b == nil {
-			return fmt.Errorf("tasks bucket not found")
+		task.UpdatedAt = time.Now()
+
+		if err := putTask(tx, task); err != nil {
+			return fmt.Errorf("failed to update task: %w", err)
 		}
 
-		task.UpdatedAt = time.Now()
+		return nil
+	})
+}
 
-		data, err := json.Marshal(task)
-		if err != nil {
-			return fmt.Errorf("failed to encode task: %w", err)
+func (s *BoltStore) GetTasks(ctx context.Context, taskIDs []string) (map[string]*Task, error) {
+	tasks := make(map[string]*Task, len(taskIDs))
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(TasksBucket)
+		if b == nil {
+			return fmt.Errorf("tasks bucket not found")
 		}
 
-		if err := b.Put([]byte(task.TaskID), data); err != nil {
-			return fmt.Errorf("failed to update task: %w", err)
+		for _, id := range taskIDs {
+			data := b.Get([]byte(id))
+			if data == nil {
+				continue // Missing tasks are left out of the result
+			}
+			var task Task
+			if err := json.Unmarshal(data, &task); err != nil {
+				return fmt.Errorf("failed to decode task: %w", err)
+			}
+			tasks[id] = &task
 		}
 
 		return nil
 	})
+
+	return tasks, err
 }
 
 func (s *BoltStore) ListTasks(ctx context.Context, status *string) ([]*Task, error) {
+	if status != nil {
+		return s.tasksByIndex(TasksByStatusIndex, *status)
+	}
+
 	var tasks []*Task
 
 	err := s.db.View(func(tx *bbolt.Tx) error {
@@ -371,12 +417,6 @@ func (s *BoltStore) ListTasks(ctx context.Context, status *string) ([]*Task, err
 			if err := json.Unmarshal(v, &task); err != nil {
 				return fmt.Errorf("failed to decode task: %w", err)
 			}
-
-			// Filter by status if specified
-			if status != nil && task.Status != *status {
-				continue
-			}
-
 			tasks = append(tasks, &task)
 		}
 
@@ -386,6 +426,41 @@ func (s *BoltStore) ListTasks(ctx context.Context, status *string) ([]*Task, err
 	return tasks, err
 }
 
+func (s *BoltStore) ListTasksByRole(ctx context.Context, role string) ([]*Task, error) {
+	return s.tasksByIndex(TasksByRoleIndex, role)
+}
+
+// tasksByIndex loads the tasks whose index entry matches value
+func (s *BoltStore) tasksByIndex(index []byte, value string) ([]*Task, error) {
+	var tasks []*Task
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(TasksBucket)
+		if b == nil {
+			return fmt.Errorf("tasks bucket not found")
+		}
+		idx, err := indexBucket(tx, index)
+		if err != nil {
+			return err
+		}
+
+		return forEachPrefix(idx, value, func(key []byte) error {
+			data := b.Get(key)
+			if data == nil {
+				return nil // Stale entry
+			}
+			var task Task
+			if err := json.Unmarshal(data, &task); err != nil {
+				return fmt.Errorf("failed to decode task: %w", err)
+			}
+			tasks = append(tasks, &task)
+			return nil
+		})
+	})
+
+	return tasks, err
+}
+
 func (s *BoltStore) ClaimTask(ctx context.Context, taskID, agentID string) error {
 	return s.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(TasksBucket)
@@ -412,12 +487,34 @@ func (s *BoltStore) ClaimTask(ctx context.Context, taskID, agentID string) error
 		task.Status = "wip"
 		task.UpdatedAt = time.Now()
 
-		newData, err := json.Marshal(task)
-		if err != nil {
-			return fmt.Errorf("failed to encode task: %w", err)
+		if err := putTask(tx, &task); err != nil {
+			return fmt.Errorf("failed to update task: %w", err)
 		}
 
-		if err := b.Put([]byte(taskID), newData); err != nil {
+		return nil
+	})
+}
+
+func (s *BoltStore) AddTestRun(ctx context.Context, taskID string, run TestRun) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(TasksBucket)
+		if b == nil {
+			return fmt.Errorf("tasks bucket not found")
+		}
+
+		data := b.Get([]byte(taskID))
+		if data == nil {
+			return fmt.Errorf("task not found: %s", taskID)
+		}
+
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return fmt.Errorf("failed to decode task: %w", err)
+		}
+
+		task.addTestRun(run)
+
+		if err := putTask(tx, &task); err != nil {
 			return fmt.Errorf("failed to update task: %w", err)
 		}
 
@@ -429,27 +526,25 @@ func (s *BoltStore) GetTaskStats(ctx context.Context) (*TaskStats, error) {
 	stats := &TaskStats{}
 
 	err := s.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(TasksBucket)
-		if b == nil {
-			return fmt.Errorf("tasks bucket not found")
+		idx, err := indexBucket(tx, TasksByStatusIndex)
+		if err != nil {
+			return err
 		}
 
-		c := b.Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var task Task
-			if err := json.Unmarshal(v, &task); err != nil {
-				return fmt.Errorf("failed to decode task: %w", err)
-			}
-
-			switch task.Status {
-			case "backlog":
-				stats.Backlog++
-			case "wip":
-				stats.WIP++
-			case "review":
-				stats.Review++
-			case "done":
-				stats.Done++
+		// Count index entries per status without decoding tasks
+		counts := map[string]*int{
+			"backlog": &stats.Backlog,
+			"wip":     &stats.WIP,
+			"review":  &stats.Review,
+			"done":    &stats.Done,
+		}
+		for status, count := range counts {
+			err := forEachPrefix(idx, status, func([]byte) error {
+				*count++
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 		}
 
@@ -482,13 +577,8 @@ func (s *BoltStore) AcquireLocks(ctx context.Context, locks []*FileLock) error {
 				lock.LockedAt = time.Now()
 			}
 
-			data, err := json.Marshal(lock)
-			if err != nil {
-				return fmt.Errorf("failed to encode lock: %w", err)
-			}
-
-			if err := b.Put([]byte(lock.FilePath), data); err != nil {
-				return fmt.Errorf("failed to store lock: %w", err)
+			if err := putLock(tx, lock); err != nil {
+				return err
 			}
 		}
 
@@ -505,37 +595,32 @@ func (s *BoltStore) ReleaseLocks(ctx context.Context, agentID string, files []st
 
 		if len(files) == 0 {
 			// Release all locks for this agent
-			c := b.Cursor()
-			var toDelete [][]byte
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				var lock FileLock
-				if err := json.Unmarshal(v, &lock); err != nil {
-					return fmt.Errorf("failed to decode lock: %w", err)
-				}
-				if lock.AgentID == agentID {
-					toDelete = append(toDelete, append([]byte(nil), k...)) // Copy key
-				}
+			idx, err := indexBucket(tx, LocksByAgentIndex)
+			if err != nil {
+				return err
 			}
-			for _, key := range toDelete {
-				if err := b.Delete(key); err != nil {
-					return fmt.Errorf("failed to delete lock: %w", err)
-				}
+			err = forEachPrefix(idx, agentID, func(key []byte) error {
+				files = append(files, string(key))
+				return nil
+			})
+			if err != nil {
+				return err
 			}
-		} else {
-			// Release specific files
-			for _, file := range files {
-				data := b.Get([]byte(file))
-				if data != nil {
-					var lock FileLock
-					if err := json.Unmarshal(data, &lock); err != nil {
-						return fmt.Errorf("failed to decode lock: %w", err)
-					}
-					// Only delete if owned by this agent
-					if lock.AgentID == agentID {
-						if err := b.Delete([]byte(file)); err != nil {
-							return fmt.Errorf("failed to delete lock: %w", err)
-						}
-					}
+		}
+
+		for _, file := range files {
+			data := b.Get([]byte(file))
+			if data == nil {
+				continue
+			}
+			var lock FileLock
+			if err := json.Unmarshal(data, &lock); err != nil {
+				return fmt.Errorf("failed to decode lock: %w", err)
+			}
+			// Only delete if owned by this agent
+			if lock.AgentID == agentID {
+				if err := deleteLock(tx, &lock); err != nil {
+					return err
 				}
 			}
 		}
@@ -576,19 +661,23 @@ func (s *BoltStore) GetLocksForAgent(ctx context.Context, agentID string) ([]*Fi
 		if b == nil {
 			return fmt.Errorf("file_locks bucket not found")
 		}
+		idx, err := indexBucket(tx, LocksByAgentIndex)
+		if err != nil {
+			return err
+		}
 
-		c := b.Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
+		return forEachPrefix(idx, agentID, func(key []byte) error {
+			data := b.Get(key)
+			if data == nil {
+				return nil // Stale entry
+			}
 			var lock FileLock
-			if err := json.Unmarshal(v, &lock); err != nil {
+			if err := json.Unmarshal(data, &lock); err != nil {
 				return fmt.Errorf("failed to decode lock: %w", err)
 			}
-			if lock.AgentID == agentID {
-				locks = append(locks, &lock)
-			}
-		}
-
-		return nil
+			locks = append(locks, &lock)
+			return nil
+		})
 	})
 
 	return locks, err
@@ -665,15 +754,7 @@ func (s *BoltStore) ListSessions(ctx context.Context, limit int) ([]*Session, er
 		return nil, err
 	}
 
-	// Sort by LastActive (most recent first)
-	// Simple bubble sort for small lists
-	for i := 0; i < len(sessions)-1; i++ {
-		for j := i + 1; j < len(sessions); j++ {
-			if sessions[j].LastActive.After(sessions[i].LastActive) {
-				sessions[i], sessions[j] = sessions[j], sessions[i]
-			}
-		}
-	}
+	sortSessionsByActivity(sessions)
 
 	// Apply limit
 	if limit > 0 && len(sessions) > limit {
@@ -715,32 +796,7 @@ func (s *BoltStore) ArchiveSession(ctx context.Context, sessionID string) error
 }
 
 func (s *BoltStore) GetSessionTasks(ctx context.Context, sessionID string) ([]*Task, error) {
-	var tasks []*Task
-
-	err := s.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(TasksBucket)
-		if b == nil {
-			return fmt.Errorf("tasks bucket not found")
-		}
-
-		// Iterate through all tasks
-		c := b.Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var task Task
-			if err := json.Unmarshal(v, &task); err != nil {
-				continue // Skip corrupted entries
-			}
-
-			// Filter by session
-			if task.SessionID == sessionID {
-				tasks = append(tasks, &task)
-			}
-		}
-
-		return nil
-	})
-
-	return tasks, err
+	return s.tasksByIndex(TasksBySessionIndex, sessionID)
 }
 
 // === LLMUsageStore Implementation ===
@@ -818,9 +874,7 @@ func (s *BoltStore) GetSessionUsage(ctx context.Context, sessionID string) (*LLM
 
 			// Filter by session
 			if usage.SessionID == sessionID {
-				totalUsage.PromptTokens += usage.PromptTokens
-				totalUsage.CompletionTokens += usage.CompletionTokens
-				totalUsage.TotalTokens += usage.TotalTokens
+				totalUsage.add(&usage)
 			}
 		}
 
@@ -847,9 +901,7 @@ func (s *BoltStore) GetTotalUsage(ctx context.Context) (*LLMUsage, error) {
 				continue // Skip corrupted entries
 			}
 
-			totalUsage.PromptTokens += usage.PromptTokens
-			totalUsage.CompletionTokens += usage.CompletionTokens
-			totalUsage.TotalTokens += usage.TotalTokens
+			totalUsage.add(&usage)
 		}
 
 		return nil
@@ -858,7 +910,80 @@ func (s *BoltStore) GetTotalUsage(ctx context.Context) (*LLMUsage, error) {
 	return &totalUsage, err
 }
 
+// === ResponseCacheStore Implementation ===
+
+func (s *BoltStore) GetCachedResponse(ctx context.Context, key string) (*CachedResponse, error) {
+	var entry *CachedResponse
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ResponseCacheBucket)
+		if b == nil {
+			return fmt.Errorf("response_cache bucket not found")
+		}
+
+		data := b.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		entry = &CachedResponse{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return fmt.Errorf("failed to decode cached response: %w", err)
+		}
+		return nil
+	})
+	if err != nil || entry == nil || entry.expired(time.Now()) {
+		return nil, err // Expired entries are dropped by the next put
+	}
+	return entry, nil
+}
+
+func (s *BoltStore) PutCachedResponse(ctx context.Context, entry *CachedResponse, maxEntries int) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ResponseCacheBucket)
+		if b == nil {
+			return fmt.Errorf("response_cache bucket not found")
+		}
+
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode cached response: %w", err)
+		}
+		if err := b.Put([]byte(entry.Key), data); err != nil {
+			return fmt.Errorf("failed to store cached response: %w", err)
+		}
+
+		// Only the timestamps are needed to pick entries to drop
+		var entries []*CachedResponse
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var stamps struct{ CreatedAt, ExpiresAt time.Time }
+			if err := json.Unmarshal(v, &stamps); err != nil {
+				stamps.ExpiresAt = time.Unix(0, 0) // Drop corrupted entries
+			}
+			entries = append(entries, &CachedResponse{Key: string(k), CreatedAt: stamps.CreatedAt, ExpiresAt: stamps.ExpiresAt})
+		}
+		for _, key := range cacheEvictions(entries, time.Now(), maxEntries) {
+			if err := b.Delete([]byte(key)); err != nil {
+				return fmt.Errorf("failed to evict cached response: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
 // Close closes the database
 func (s *BoltStore) Close() error {
 	return s.db.Close()
 }
+
+// sortSessionsByActivity orders sessions by LastActive, most recent first.
+// Sessions active at the same time keep their order (by session ID).
+func sortSessionsByActivity(sessions []*Session) {
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastActive.After(sessions[j].LastActive)
+	})
+}
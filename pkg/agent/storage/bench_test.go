@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const benchEvents = 100_000
+
+// seedBenchStore fills a store with benchEvents events spread over 500 tasks,
+// 20 agents and 10 event types, plus 2,000 tasks across the statuses
+func seedBenchStore(b *testing.B) *BoltStore {
+	b.Helper()
+	store := newTestStore(b)
+
+	base := time.Now().Add(-24 * time.Hour)
+	const batch = 5_000
+	for start := 0; start < benchEvents; start += batch {
+		err := store.db.Update(func(tx *bbolt.Tx) error {
+			seq := tx.Bucket(SequenceBucket)
+			for i := start; i < start+batch; i++ {
+				id, err := seq.NextSequence()
+				if err != nil {
+					return err
+				}
+				taskID := fmt.Sprintf("task-%d", i%500)
+				event := &Event{
+					ID:        int64(id),
+					Timestamp: base.Add(time.Duration(i) * time.Second),
+					AgentID:   fmt.Sprintf("agent-%d", i%20),
+					EventType: fmt.Sprintf("type_%d", i%10),
+					TaskID:    &taskID,
+					Data:      `{"file":"main.go"}`,
+				}
+				if err := putEvent(tx, event); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatalf("failed to seed events: %v", err)
+		}
+	}
+
+	statuses := []string{"backlog", "todo", "wip", "review", "done"}
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		for i := 0; i < 2_000; i++ {
+			task := &Task{
+				TaskID:    fmt.Sprintf("task-%d", i),
+				Status:    statuses[i%len(statuses)],
+				AgentRole: "keymaker",
+			}
+			if err := putTask(tx, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("failed to seed tasks: %v", err)
+	}
+
+	return store
+}
+
+// scanEvents is the unindexed baseline: walk every event newest first and filter in Go
+func scanEvents(store *BoltStore, filter EventFilter) ([]*Event, error) {
+	var events []*Event
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultEventLimit
+	}
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(EventsBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(events) < limit; k, v = c.Prev() {
+			var event Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			if filter.matches(&event) {
+				events = append(events, &event)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+func BenchmarkQueryEvents(b *testing.B) {
+	store := seedBenchStore(b)
+	ctx := context.Background()
+
+	filters := []struct {
+		name   string
+		filter EventFilter
+	}{
+		{"latest", EventFilter{Limit: 50}},
+		{"by_task", EventFilter{TaskID: strPtr("task-42")}},
+		{"by_agent", EventFilter{AgentID: strPtr("agent-7"), Limit: 100}},
+		{"by_types", EventFilter{EventTypes: []string{"type_3", "type_8"}, Limit: 100}},
+		{"since_id", EventFilter{TaskID: strPtr("task-42"), SinceID: benchEvents - 1_000}},
+		{"poll", EventFilter{SinceID: benchEvents}},
+	}
+
+	for _, f := range filters {
+		b.Run(f.name+"/indexed", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := store.QueryEvents(ctx, f.filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(f.name+"/scan", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := scanEvents(store, f.filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListTasksByStatus(b *testing.B) {
+	store := seedBenchStore(b)
+	ctx := context.Background()
+	status := "review"
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.ListTasks(ctx, &status); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var tasks []*Task
+			err := store.db.View(func(tx *bbolt.Tx) error {
+				return tx.Bucket(TasksBucket).ForEach(func(k, v []byte) error {
+					var task Task
+					if err := json.Unmarshal(v, &task); err != nil {
+						return err
+					}
+					if task.Status == status {
+						tasks = append(tasks, &task)
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
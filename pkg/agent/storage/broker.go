@@ -0,0 +1,562 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// BBolt lets one process at a time hold the database file lock. To let
+// headless workers, the TUI and CLI commands share a project, the first
+// process to open the database becomes the broker: it keeps the file open
+// and serves the Store on a unix socket next to it. Later processes forward
+// every call over the socket. If the broker exits, the next call from a
+// client opens the database itself and takes over.
+//
+// The socket sits in a directory only the user can enter, so other local
+// users can't reach the Store through it.
+
+const (
+	// brokerOpenTimeout bounds how long opening a project store waits for
+	// the database file lock or a broker socket
+	brokerOpenTimeout = 5 * time.Second
+
+	// brokerLockAttempt is how long each attempt waits for the file lock
+	// before checking for a broker again
+	brokerLockAttempt = 200 * time.Millisecond
+)
+
+// BrokerSocketPath returns the socket a project's broker listens on
+func BrokerSocketPath(projectRoot string) string {
+	return filepath.Join(projectRoot, ".smith", "broker", "smith.sock")
+}
+
+// SharedStore is a Store that can be opened by several processes at once.
+// It either owns the database and serves it to other processes (broker) or
+// forwards calls to the process that does (client).
+type SharedStore struct {
+	dbPath string
+	socket string
+
+	mu       sync.RWMutex
+	local    *BoltStore   // Set while this process owns the database
+	listener net.Listener // Broker socket, nil if it couldn't be created
+	conns    map[net.Conn]struct{}
+	client   *rpc.Client // Set while another process owns the database
+	closed   bool
+}
+
+var _ Store = (*SharedStore)(nil)
+
+// OpenSharedStore opens a project database as broker or client
+func OpenSharedStore(projectRoot string) (*SharedStore, error) {
+	s := &SharedStore{
+		dbPath: ProjectDatabasePath(projectRoot),
+		socket: BrokerSocketPath(projectRoot),
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsBroker reports whether this process owns the database
+func (s *SharedStore) IsBroker() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.local != nil
+}
+
+// connect dials the broker or, if there is none, becomes it.
+// Callers must hold s.mu or have exclusive access to s.
+func (s *SharedStore) connect() error {
+	deadline := time.Now().Add(brokerOpenTimeout)
+	for {
+		if conn, err := net.DialTimeout("unix", s.socket, time.Second); err == nil {
+			s.client = rpc.NewClient(conn)
+			return nil
+		}
+
+		db, err := openBoltDatabase(s.dbPath, brokerLockAttempt)
+		if err == nil {
+			s.local = NewBoltStore(db.DB)
+			s.serve()
+			return nil
+		}
+		if !isLockTimeout(err) || time.Now().After(deadline) {
+			return err
+		}
+		// Another process holds the lock but isn't serving yet; retry
+	}
+}
+
+// serve starts the broker socket. Failing to create it (e.g. a path too
+// long for a unix socket) leaves the store usable by this process only.
+func (s *SharedStore) serve() {
+	dir := filepath.Dir(s.socket)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return
+	}
+	_ = os.Remove(s.socket) // Left behind by a broker that crashed
+	l, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return
+	}
+	_ = os.Chmod(s.socket, 0600)
+	s.listener = l
+	s.conns = make(map[net.Conn]struct{})
+
+	server := rpc.NewServer()
+	_ = server.RegisterName("Store", &storeService{store: s.local, db: s.local.db, dbPath: s.dbPath})
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return // Listener closed
+			}
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			go func() {
+				server.ServeConn(conn)
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+}
+
+// reconnect replaces a client connection whose broker went away
+func (s *SharedStore) reconnect(broken *rpc.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("store is closed")
+	}
+	if s.client != broken {
+		return nil // Another call already reconnected
+	}
+	_ = broken.Close()
+	s.client = nil
+	return s.connect()
+}
+
+// Close stops serving other processes and releases the database
+func (s *SharedStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if s.client != nil {
+		return s.client.Close()
+	}
+
+	if s.listener != nil {
+		_ = s.listener.Close()
+		_ = os.Remove(s.socket)
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+	}
+	return s.local.Close()
+}
+
+// readMethods are the Store methods that change nothing, so repeating one
+// after the broker went away mid-call is safe
+var readMethods = map[string]bool{
+	"QueryEvents":       true,
+	"GetAgent":          true,
+	"ListAgents":        true,
+	"GetTask":           true,
+	"GetTasks":          true,
+	"ListTasks":         true,
+	"ListTasksByRole":   true,
+	"GetTaskStats":      true,
+	"GetLocks":          true,
+	"GetLocksForAgent":  true,
+	"GetSession":        true,
+	"ListSessions":      true,
+	"GetSessionTasks":   true,
+	"GetUsage":          true,
+	"GetSessionUsage":   true,
+	"GetTotalUsage":     true,
+	"GetCachedResponse": true,
+}
+
+// call runs a Store method locally or on the broker. args exclude the
+// context; results point at variables receiving the non-error results.
+// When the broker goes away, reads are retried; writes only if they were
+// never sent, since the broker may have committed one before it exited.
+func (s *SharedStore) call(ctx context.Context, method string, args []interface{}, results ...interface{}) error {
+	for attempt := 0; ; attempt++ {
+		s.mu.RLock()
+		local, client, closed := s.local, s.client, s.closed
+		s.mu.RUnlock()
+
+		if closed {
+			return fmt.Errorf("store is closed")
+		}
+		if local != nil {
+			return invokeLocal(ctx, local, method, args, results)
+		}
+
+		err := invokeRemote(ctx, client, method, args, results)
+		var remote remoteError
+		if err == nil || errors.As(err, &remote) || attempt > 0 {
+			return err
+		}
+
+		// The broker went away: reconnect, possibly becoming the broker
+		if err := s.reconnect(client); err != nil {
+			return fmt.Errorf("reconnecting to database broker: %w", err)
+		}
+		if !readMethods[method] && !errors.Is(err, rpc.ErrShutdown) {
+			return fmt.Errorf("database broker exited during %s, which may or may not have been applied: %w", method, err)
+		}
+	}
+}
+
+// BrokerRequest is a Store method call sent to the broker
+type BrokerRequest struct {
+	Method string
+	Args   [][]byte // JSON-encoded arguments, without the context
+}
+
+// BrokerResponse is the broker's reply to a BrokerRequest
+type BrokerResponse struct {
+	Results [][]byte // JSON-encoded results, without the error
+	Args    [][]byte // Arguments after the call, for methods that fill them in (e.g. SaveEvent)
+	Err     string
+}
+
+// remoteError is an error returned by the Store on the broker
+type remoteError string
+
+func (e remoteError) Error() string { return string(e) }
+
+// storeService exposes a Store over net/rpc
+type storeService struct {
+	store  Store
+	db     *bbolt.DB // Database behind store, for Stats
+	dbPath string
+}
+
+// Stats reports on the database for clients that can't open it themselves
+func (svc *storeService) Stats(_ struct{}, stats *DatabaseStats) error {
+	info, err := os.Stat(svc.dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat database: %w", err)
+	}
+	result, err := readDatabaseStats(svc.db, svc.dbPath, info.Size())
+	if err != nil {
+		return err
+	}
+	*stats = *result
+	return nil
+}
+
+// Call runs one Store method
+func (svc *storeService) Call(req BrokerRequest, resp *BrokerResponse) error {
+	m, err := storeMethod(svc.store, req.Method)
+	if err != nil {
+		return err
+	}
+	mt := m.Type()
+	if len(req.Args) != mt.NumIn()-1 {
+		return fmt.Errorf("%s: expected %d arguments, got %d", req.Method, mt.NumIn()-1, len(req.Args))
+	}
+
+	in := []reflect.Value{reflect.ValueOf(context.Background())}
+	for i, raw := range req.Args {
+		v := reflect.New(mt.In(i + 1))
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return fmt.Errorf("%s: decoding argument %d: %w", req.Method, i+1, err)
+		}
+		in = append(in, v.Elem())
+	}
+
+	out := m.Call(in)
+	if errVal := out[len(out)-1]; !errVal.IsNil() {
+		resp.Err = errVal.Interface().(error).Error()
+		return nil
+	}
+	for _, v := range out[:len(out)-1] {
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Errorf("%s: encoding result: %w", req.Method, err)
+		}
+		resp.Results = append(resp.Results, data)
+	}
+	for _, v := range in[1:] {
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Errorf("%s: encoding argument: %w", req.Method, err)
+		}
+		resp.Args = append(resp.Args, data)
+	}
+	return nil
+}
+
+// storeMethod looks up an exported Store method other than Close
+func storeMethod(store Store, name string) (reflect.Value, error) {
+	if _, ok := reflect.TypeOf((*Store)(nil)).Elem().MethodByName(name); !ok || name == "Close" {
+		return reflect.Value{}, fmt.Errorf("unknown store method: %q", name)
+	}
+	return reflect.ValueOf(store).MethodByName(name), nil
+}
+
+// invokeLocal calls a Store method in this process
+func invokeLocal(ctx context.Context, store Store, method string, args, results []interface{}) error {
+	m, err := storeMethod(store, method)
+	if err != nil {
+		return err
+	}
+	mt := m.Type()
+
+	in := []reflect.Value{reflect.ValueOf(ctx)}
+	for i, arg := range args {
+		if arg == nil {
+			in = append(in, reflect.Zero(mt.In(i+1)))
+		} else {
+			in = append(in, reflect.ValueOf(arg))
+		}
+	}
+
+	out := m.Call(in)
+	if errVal := out[len(out)-1]; !errVal.IsNil() {
+		return errVal.Interface().(error)
+	}
+	for i, v := range out[:len(out)-1] {
+		reflect.ValueOf(results[i]).Elem().Set(v)
+	}
+	return nil
+}
+
+// invokeRemote calls a Store method on the broker. Errors from the store
+// itself are returned as remoteError; anything else means the connection failed.
+func invokeRemote(ctx context.Context, client *rpc.Client, method string, args, results []interface{}) error {
+	req := BrokerRequest{Method: method}
+	for _, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return remoteError(fmt.Sprintf("%s: encoding argument: %v", method, err))
+		}
+		req.Args = append(req.Args, data)
+	}
+
+	var resp BrokerResponse
+	done := client.Go("Store.Call", req, &resp, make(chan *rpc.Call, 1)).Done
+	select {
+	case c := <-done:
+		if _, ok := c.Error.(rpc.ServerError); ok {
+			return remoteError(c.Error.Error())
+		}
+		if c.Error != nil {
+			return c.Error // Connection to the broker failed
+		}
+	case <-ctx.Done():
+		return remoteError(ctx.Err().Error())
+	}
+
+	if resp.Err != "" {
+		return remoteError(resp.Err)
+	}
+	for i, data := range resp.Results {
+		if err := json.Unmarshal(data, results[i]); err != nil {
+			return remoteError(fmt.Sprintf("%s: decoding result: %v", method, err))
+		}
+	}
+	// Copy back arguments the broker filled in, such as a saved event's ID
+	for i, data := range resp.Args {
+		if v := reflect.ValueOf(args[i]); v.Kind() == reflect.Ptr && !v.IsNil() {
+			if err := json.Unmarshal(data, args[i]); err != nil {
+				return remoteError(fmt.Sprintf("%s: decoding argument: %v", method, err))
+			}
+		}
+	}
+	return nil
+}
+
+// EventStore
+
+func (s *SharedStore) SaveEvent(ctx context.Context, event *Event) error {
+	return s.call(ctx, "SaveEvent", []interface{}{event})
+}
+
+func (s *SharedStore) QueryEvents(ctx context.Context, filter EventFilter) (events []*Event, err error) {
+	err = s.call(ctx, "QueryEvents", []interface{}{filter}, &events)
+	return events, err
+}
+
+// AgentStore
+
+func (s *SharedStore) RegisterAgent(ctx context.Context, agent *Agent) error {
+	return s.call(ctx, "RegisterAgent", []interface{}{agent})
+}
+
+func (s *SharedStore) UpdateHeartbeat(ctx context.Context, agentID string) error {
+	return s.call(ctx, "UpdateHeartbeat", []interface{}{agentID})
+}
+
+func (s *SharedStore) UnregisterAgent(ctx context.Context, agentID string) error {
+	return s.call(ctx, "UnregisterAgent", []interface{}{agentID})
+}
+
+func (s *SharedStore) GetAgent(ctx context.Context, agentID string) (agent *Agent, err error) {
+	err = s.call(ctx, "GetAgent", []interface{}{agentID}, &agent)
+	return agent, err
+}
+
+func (s *SharedStore) ListAgents(ctx context.Context, role *string) (agents []*Agent, err error) {
+	err = s.call(ctx, "ListAgents", []interface{}{role}, &agents)
+	return agents, err
+}
+
+func (s *SharedStore) MarkAgentDead(ctx context.Context, timeout time.Duration) (n int, err error) {
+	err = s.call(ctx, "MarkAgentDead", []interface{}{timeout}, &n)
+	return n, err
+}
+
+// TaskStore
+
+func (s *SharedStore) CreateTask(ctx context.Context, task *Task) error {
+	return s.call(ctx, "CreateTask", []interface{}{task})
+}
+
+func (s *SharedStore) GetTask(ctx context.Context, taskID string) (task *Task, err error) {
+	err = s.call(ctx, "GetTask", []interface{}{taskID}, &task)
+	return task, err
+}
+
+func (s *SharedStore) UpdateTask(ctx context.Context, task *Task) error {
+	return s.call(ctx, "UpdateTask", []interface{}{task})
+}
+
+func (s *SharedStore) GetTasks(ctx context.Context, taskIDs []string) (tasks map[string]*Task, err error) {
+	err = s.call(ctx, "GetTasks", []interface{}{taskIDs}, &tasks)
+	return tasks, err
+}
+
+func (s *SharedStore) ListTasks(ctx context.Context, status *string) (tasks []*Task, err error) {
+	err = s.call(ctx, "ListTasks", []interface{}{status}, &tasks)
+	return tasks, err
+}
+
+func (s *SharedStore) ListTasksByRole(ctx context.Context, role string) (tasks []*Task, err error) {
+	err = s.call(ctx, "ListTasksByRole", []interface{}{role}, &tasks)
+	return tasks, err
+}
+
+func (s *SharedStore) ClaimTask(ctx context.Context, taskID, agentID string) error {
+	return s.call(ctx, "ClaimTask", []interface{}{taskID, agentID})
+}
+
+func (s *SharedStore) AddTestRun(ctx context.Context, taskID string, run TestRun) error {
+	return s.call(ctx, "AddTestRun", []interface{}{taskID, run})
+}
+
+func (s *SharedStore) GetTaskStats(ctx context.Context) (stats *TaskStats, err error) {
+	err = s.call(ctx, "GetTaskStats", nil, &stats)
+	return stats, err
+}
+
+// LockStore
+
+func (s *SharedStore) AcquireLocks(ctx context.Context, locks []*FileLock) error {
+	return s.call(ctx, "AcquireLocks", []interface{}{locks})
+}
+
+func (s *SharedStore) ReleaseLocks(ctx context.Context, agentID string, files []string) error {
+	return s.call(ctx, "ReleaseLocks", []interface{}{agentID, files})
+}
+
+func (s *SharedStore) GetLocks(ctx context.Context) (locks []*FileLock, err error) {
+	err = s.call(ctx, "GetLocks", nil, &locks)
+	return locks, err
+}
+
+func (s *SharedStore) GetLocksForAgent(ctx context.Context, agentID string) (locks []*FileLock, err error) {
+	err = s.call(ctx, "GetLocksForAgent", []interface{}{agentID}, &locks)
+	return locks, err
+}
+
+// SessionStore
+
+func (s *SharedStore) CreateSession(ctx context.Context, session *Session) error {
+	return s.call(ctx, "CreateSession", []interface{}{session})
+}
+
+func (s *SharedStore) GetSession(ctx context.Context, sessionID string) (session *Session, err error) {
+	err = s.call(ctx, "GetSession", []interface{}{sessionID}, &session)
+	return session, err
+}
+
+func (s *SharedStore) UpdateSession(ctx context.Context, session *Session) error {
+	return s.call(ctx, "UpdateSession", []interface{}{session})
+}
+
+func (s *SharedStore) ListSessions(ctx context.Context, limit int) (sessions []*Session, err error) {
+	err = s.call(ctx, "ListSessions", []interface{}{limit}, &sessions)
+	return sessions, err
+}
+
+func (s *SharedStore) ArchiveSession(ctx context.Context, sessionID string) error {
+	return s.call(ctx, "ArchiveSession", []interface{}{sessionID})
+}
+
+func (s *SharedStore) GetSessionTasks(ctx context.Context, sessionID string) (tasks []*Task, err error) {
+	err = s.call(ctx, "GetSessionTasks", []interface{}{sessionID}, &tasks)
+	return tasks, err
+}
+
+// UsageStore
+
+func (s *SharedStore) SaveUsage(ctx context.Context, usage *LLMUsage) error {
+	return s.call(ctx, "SaveUsage", []interface{}{usage})
+}
+
+func (s *SharedStore) GetUsage(ctx context.Context, taskID string) (usage *LLMUsage, err error) {
+	err = s.call(ctx, "GetUsage", []interface{}{taskID}, &usage)
+	return usage, err
+}
+
+func (s *SharedStore) GetSessionUsage(ctx context.Context, sessionID string) (usage *LLMUsage, err error) {
+	err = s.call(ctx, "GetSessionUsage", []interface{}{sessionID}, &usage)
+	return usage, err
+}
+
+func (s *SharedStore) GetTotalUsage(ctx context.Context) (usage *LLMUsage, err error) {
+	err = s.call(ctx, "GetTotalUsage", nil, &usage)
+	return usage, err
+}
+
+// ResponseCacheStore
+
+func (s *SharedStore) GetCachedResponse(ctx context.Context, key string) (entry *CachedResponse, err error) {
+	err = s.call(ctx, "GetCachedResponse", []interface{}{key}, &entry)
+	return entry, err
+}
+
+func (s *SharedStore) PutCachedResponse(ctx context.Context, entry *CachedResponse, maxEntries int) error {
+	return s.call(ctx, "PutCachedResponse", []interface{}{entry, maxEntries})
+}
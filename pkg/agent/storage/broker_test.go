@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newTestClient opens a project store twice: the first becomes the broker and
+// the returned second one forwards every call to it
+func newTestClient(t *testing.T) *SharedStore {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".smith"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	broker, err := OpenSharedStore(root)
+	if err != nil {
+		t.Fatalf("OpenSharedStore failed: %v", err)
+	}
+	t.Cleanup(func() { _ = broker.Close() })
+
+	client, err := OpenSharedStore(root)
+	if err != nil {
+		t.Fatalf("OpenSharedStore failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if !broker.IsBroker() || client.IsBroker() {
+		t.Fatal("expected the first store to be the broker and the second a client")
+	}
+	return client
+}
+
+func TestSharedStoreFailover(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	broker, err := InitProjectStorage(root)
+	if err != nil {
+		t.Fatalf("InitProjectStorage failed: %v", err)
+	}
+	client, err := InitProjectStorage(root)
+	if err != nil {
+		t.Fatalf("InitProjectStorage failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// Arguments filled in by the broker come back to the caller
+	event := &Event{AgentID: "agent-1", EventType: "task_created"}
+	if err := client.SaveEvent(ctx, event); err != nil {
+		t.Fatalf("SaveEvent failed: %v", err)
+	}
+	if event.ID != 1 || event.Timestamp.IsZero() {
+		t.Errorf("expected event ID and timestamp from the broker, got %+v", event)
+	}
+
+	// Store errors pass through unchanged
+	if _, err := client.GetTask(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	// When the broker exits, the client takes over the database
+	if err := broker.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	events, err := client.QueryEvents(ctx, EventFilter{})
+	if err != nil || len(events) != 1 {
+		t.Errorf("expected the event saved through the old broker, got %v (%v)", events, err)
+	}
+	if !client.(*SharedStore).IsBroker() {
+		t.Error("expected client to become the broker")
+	}
+	if err := client.CreateTask(ctx, &Task{TaskID: "task-001", Title: "After failover", Status: "backlog"}); err != nil {
+		t.Fatalf("CreateTask after broker exit failed: %v", err)
+	}
+}
+
+// hangUpBroker listens on a project's broker socket and, for the first
+// connection, reads part of a request and hangs up without replying, as a
+// broker that exits mid-call would
+func hangUpBroker(t *testing.T, root string) {
+	t.Helper()
+	socket := BrokerSocketPath(root)
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		_ = l.Close()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Read(make([]byte, 1))
+		_ = conn.Close()
+	}()
+	t.Cleanup(func() { _ = l.Close() })
+}
+
+func TestSharedStoreBrokerExitsMidCall(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("read is retried", func(t *testing.T) {
+		root := t.TempDir()
+		hangUpBroker(t, root)
+		client, err := OpenSharedStore(root)
+		if err != nil {
+			t.Fatalf("OpenSharedStore failed: %v", err)
+		}
+		defer func() { _ = client.Close() }()
+
+		if _, err := client.ListTasks(ctx, nil); err != nil {
+			t.Errorf("expected the read to be retried on the new broker, got %v", err)
+		}
+	})
+
+	t.Run("write is not retried", func(t *testing.T) {
+		root := t.TempDir()
+		hangUpBroker(t, root)
+		client, err := OpenSharedStore(root)
+		if err != nil {
+			t.Fatalf("OpenSharedStore failed: %v", err)
+		}
+		defer func() { _ = client.Close() }()
+
+		if err := client.SaveEvent(ctx, &Event{AgentID: "agent-1", EventType: "task_created"}); err == nil {
+			t.Fatal("expected a write cut off by the broker exiting to fail")
+		}
+		if !client.IsBroker() {
+			t.Fatal("expected the client to take over the database")
+		}
+		if events, _ := client.QueryEvents(ctx, EventFilter{}); len(events) != 0 {
+			t.Errorf("expected the write not to be repeated, got %d events", len(events))
+		}
+		// Later writes go to the new broker
+		if err := client.SaveEvent(ctx, &Event{AgentID: "agent-1", EventType: "task_created"}); err != nil {
+			t.Errorf("SaveEvent after takeover failed: %v", err)
+		}
+	})
+}
+
+func TestReadMethodsAreStoreMethods(t *testing.T) {
+	store := reflect.TypeOf((*Store)(nil)).Elem()
+	for name := range readMethods {
+		if _, ok := store.MethodByName(name); !ok {
+			t.Errorf("readMethods lists %s, which isn't a Store method", name)
+		}
+	}
+}
+
+func TestBrokerSocketPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes don't restrict access on Windows")
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".smith"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenSharedStore(root)
+	if err != nil {
+		t.Fatalf("OpenSharedStore failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	socket := BrokerSocketPath(root)
+	for path, want := range map[string]os.FileMode{filepath.Dir(socket): 0700, socket: 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("broker socket not created: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != want {
+			t.Errorf("%s has mode %o, want %o", filepath.Base(path), perm, want)
+		}
+	}
+}
+
+func TestProjectDatabaseStatsWithBroker(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store, err := InitProjectStorage(root)
+	if err != nil {
+		t.Fatalf("InitProjectStorage failed: %v", err)
+	}
+	if err := store.CreateTask(ctx, &Task{TaskID: "task-001", Title: "Counted", Status: "backlog"}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	// The broker holds the database, so the stats come from it
+	stats, err := ProjectDatabaseStats(root)
+	if err != nil {
+		t.Fatalf("ProjectDatabaseStats with a broker failed: %v", err)
+	}
+	if stats.SchemaVersion != SchemaVersion || stats.Path != ProjectDatabasePath(root) {
+		t.Errorf("stats = v%d %s, want v%d %s", stats.SchemaVersion, stats.Path, SchemaVersion, ProjectDatabasePath(root))
+	}
+	if records := bucketRecords(stats, "tasks"); records != 1 {
+		t.Errorf("tasks has %d records, want 1", records)
+	}
+
+	// Without one, the database is opened directly
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	stats, err = ProjectDatabaseStats(root)
+	if err != nil {
+		t.Fatalf("ProjectDatabaseStats without a broker failed: %v", err)
+	}
+	if records := bucketRecords(stats, "tasks"); records != 1 {
+		t.Errorf("tasks has %d records without a broker, want 1", records)
+	}
+}
+
+func bucketRecords(stats *DatabaseStats, name string) int {
+	for _, b := range stats.Buckets {
+		if b.Name == name {
+			return b.Records
+		}
+	}
+	return -1
+}
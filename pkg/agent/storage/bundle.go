@@ -0,0 +1,638 @@
+package storage
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BundleVersion is the bundle format written by this build
+const BundleVersion = 1
+
+// Bundle is a portable snapshot of Smith state: a session (or the whole
+// store) with its tasks, events and LLM usage
+type Bundle struct {
+	Version    int
+	ExportedAt time.Time
+	SessionID  string // Exported session, empty for the whole store
+	Sessions   []*Session
+	Tasks      []*Task
+	Events     []*Event // Oldest first
+	Usage      []*LLMUsage
+}
+
+// Bundle formats
+const (
+	BundleJSON  = "json"
+	BundleTar   = "tar"
+	BundleTarGz = "tar.gz"
+)
+
+// Export collects a session's state from store, or everything if sessionID is empty
+func Export(ctx context.Context, store Store, sessionID string) (*Bundle, error) {
+	bundle := &Bundle{
+		Version:    BundleVersion,
+		ExportedAt: time.Now(),
+		SessionID:  sessionID,
+	}
+
+	var err error
+	if sessionID == "" {
+		if bundle.Sessions, err = store.ListSessions(ctx, 0); err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if bundle.Tasks, err = store.ListTasks(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to list tasks: %w", err)
+		}
+	} else {
+		session, err := store.GetSession(ctx, sessionID)
+		if err == nil {
+			bundle.Sessions = []*Session{session}
+		}
+		if bundle.Tasks, err = store.GetSessionTasks(ctx, sessionID); err != nil {
+			return nil, fmt.Errorf("failed to list session tasks: %w", err)
+		}
+		if len(bundle.Sessions) == 0 && len(bundle.Tasks) == 0 {
+			return nil, fmt.Errorf("session not found: %s", sessionID)
+		}
+	}
+
+	inScope := make(map[string]bool, len(bundle.Tasks))
+	for _, task := range bundle.Tasks {
+		inScope[task.TaskID] = true
+
+		usage, err := store.GetUsage(ctx, task.TaskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get usage: %w", err)
+		}
+		if usage != nil {
+			bundle.Usage = append(bundle.Usage, usage)
+		}
+	}
+
+	events, err := store.QueryEvents(ctx, EventFilter{Limit: math.MaxInt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	for _, e := range events {
+		if sessionID == "" || e.SessionID == sessionID || (e.TaskID != nil && inScope[*e.TaskID]) {
+			bundle.Events = append(bundle.Events, e)
+		}
+	}
+	sort.SliceStable(bundle.Events, func(i, j int) bool { return bundle.Events[i].ID < bundle.Events[j].ID })
+
+	return bundle, nil
+}
+
+// WriteBundle writes a bundle as indented JSON, or as a tar archive with one
+// file per record type plus a SUMMARY.md
+func WriteBundle(w io.Writer, bundle *Bundle, format string) error {
+	switch format {
+	case BundleJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(bundle)
+	case BundleTar:
+		return writeBundleTar(w, bundle)
+	case BundleTarGz:
+		gz := gzip.NewWriter(w)
+		if err := writeBundleTar(gz, bundle); err != nil {
+			return err
+		}
+		return gz.Close()
+	default:
+		return fmt.Errorf("unsupported bundle format: %s", format)
+	}
+}
+
+// bundleManifest is manifest.json in a tar bundle
+type bundleManifest struct {
+	Version    int
+	ExportedAt time.Time
+	SessionID  string
+}
+
+func writeBundleTar(w io.Writer, bundle *Bundle) error {
+	tw := tar.NewWriter(w)
+
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"manifest.json", bundleManifest{bundle.Version, bundle.ExportedAt, bundle.SessionID}},
+		{"sessions.json", bundle.Sessions},
+		{"tasks.json", bundle.Tasks},
+		{"usage.json", bundle.Usage},
+	}
+
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: bundle.ExportedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	for _, f := range files {
+		data, err := json.MarshalIndent(f.value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", f.name, err)
+		}
+		if err := add(f.name, data); err != nil {
+			return err
+		}
+	}
+
+	var events bytes.Buffer
+	enc := json.NewEncoder(&events)
+	for _, e := range bundle.Events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode events: %w", err)
+		}
+	}
+	if err := add("events.ndjson", events.Bytes()); err != nil {
+		return err
+	}
+	if err := add("SUMMARY.md", []byte(bundle.Markdown())); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// ReadBundle reads a bundle written by WriteBundle in any format
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	br := bufio.NewReader(r)
+
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		br = bufio.NewReader(gz)
+	}
+
+	var bundle *Bundle
+	if header, _ := br.Peek(262); len(header) == 262 && string(header[257:262]) == "ustar" {
+		b, err := readBundleTar(br)
+		if err != nil {
+			return nil, err
+		}
+		bundle = b
+	} else {
+		bundle = &Bundle{}
+		if err := json.NewDecoder(br).Decode(bundle); err != nil {
+			return nil, fmt.Errorf("failed to decode bundle: %w", err)
+		}
+	}
+
+	if bundle.Version == 0 || bundle.Version > BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d (this smith reads up to %d)", bundle.Version, BundleVersion)
+	}
+	return bundle, nil
+}
+
+func readBundleTar(r io.Reader) (*Bundle, error) {
+	bundle := &Bundle{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		var target interface{}
+		switch hdr.Name {
+		case "manifest.json":
+			var m bundleManifest
+			if err := json.NewDecoder(tr).Decode(&m); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", hdr.Name, err)
+			}
+			bundle.Version, bundle.ExportedAt, bundle.SessionID = m.Version, m.ExportedAt, m.SessionID
+			continue
+		case "sessions.json":
+			target = &bundle.Sessions
+		case "tasks.json":
+			target = &bundle.Tasks
+		case "usage.json":
+			target = &bundle.Usage
+		case "events.ndjson":
+			dec := json.NewDecoder(tr)
+			for dec.More() {
+				var e Event
+				if err := dec.Decode(&e); err != nil {
+					return nil, fmt.Errorf("failed to decode %s: %w", hdr.Name, err)
+				}
+				bundle.Events = append(bundle.Events, &e)
+			}
+			continue
+		default:
+			continue // SUMMARY.md and unknown files
+		}
+		if err := json.NewDecoder(tr).Decode(target); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", hdr.Name, err)
+		}
+	}
+	return bundle, nil
+}
+
+// Conflict handling for Import
+const (
+	ConflictRename = "rename" // Import under a new ID and remap references
+	ConflictSkip   = "skip"   // Keep the existing record, drop the imported one
+	ConflictFail   = "fail"   // Abort before writing anything
+)
+
+// ImportOptions controls how a bundle is merged into a store
+type ImportOptions struct {
+	OnConflict string // ConflictRename (default), ConflictSkip or ConflictFail
+	DryRun     bool   // Plan only, write nothing
+}
+
+// ImportConflict is a bundle record whose ID already exists with different content
+type ImportConflict struct {
+	Kind  string // "task" or "session"
+	ID    string
+	NewID string // ID used for the import, empty if skipped
+}
+
+// ImportResult describes what Import wrote (or would write, for a dry run)
+type ImportResult struct {
+	Sessions   int
+	Tasks      int
+	Events     int
+	Usage      int
+	Duplicates int               // Records already present with identical content
+	Conflicts  []ImportConflict  // IDs taken by different records
+	TaskIDs    map[string]string // Bundle task ID -> stored ID, for remapped tasks
+	SessionIDs map[string]string // Bundle session ID -> stored ID, for remapped sessions
+	EventIDs   map[int64]int64   // Bundle event ID -> stored ID
+}
+
+// sameRecord reports whether two records encode identically
+func sameRecord(a, b interface{}) bool {
+	da, errA := json.Marshal(a)
+	db, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(da, db)
+}
+
+var numberedTaskID = regexp.MustCompile(`^task-(\d+)$`)
+
+// Import merges a bundle into store. Records whose ID is free are imported
+// as-is; identical records are treated as already imported and skipped along
+// with their events. Conflicting IDs are handled per opts.OnConflict, and every
+// reference (dependencies, events, usage) follows a renamed record.
+// Event IDs are always reassigned by the store.
+func Import(ctx context.Context, store Store, bundle *Bundle, opts ImportOptions) (*ImportResult, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictRename
+	}
+	switch opts.OnConflict {
+	case ConflictRename, ConflictSkip, ConflictFail:
+	default:
+		return nil, fmt.Errorf("unknown conflict mode: %s", opts.OnConflict)
+	}
+
+	result := &ImportResult{
+		TaskIDs:    make(map[string]string),
+		SessionIDs: make(map[string]string),
+		EventIDs:   make(map[int64]int64),
+	}
+
+	// Plan: decide the stored ID of every session and task before writing
+	sessionIDs := make(map[string]string) // Bundle ID -> stored ID, "" to drop
+	var sessions []*Session
+	takenSessions := make(map[string]bool)
+	for _, s := range bundle.Sessions {
+		existing, err := store.GetSession(ctx, s.SessionID)
+		switch {
+		case err != nil:
+			sessionIDs[s.SessionID] = s.SessionID
+			sessions = append(sessions, s)
+		case sameRecord(existing, s):
+			sessionIDs[s.SessionID] = ""
+			result.Duplicates++
+		default:
+			newID := ""
+			if opts.OnConflict == ConflictRename {
+				newID = freeSessionID(ctx, store, s.SessionID, takenSessions)
+				takenSessions[newID] = true
+				sessions = append(sessions, s)
+			}
+			sessionIDs[s.SessionID] = newID
+			result.Conflicts = append(result.Conflicts, ImportConflict{Kind: "session", ID: s.SessionID, NewID: newID})
+		}
+	}
+
+	existingTasks, err := store.ListTasks(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	taken := make(map[string]*Task, len(existingTasks))
+	maxTask := 0
+	for _, t := range existingTasks {
+		taken[t.TaskID] = t
+		if m := numberedTaskID.FindStringSubmatch(t.TaskID); m != nil {
+			if n, _ := strconv.Atoi(m[1]); n > maxTask {
+				maxTask = n
+			}
+		}
+	}
+	for _, t := range bundle.Tasks {
+		if m := numberedTaskID.FindStringSubmatch(t.TaskID); m != nil {
+			if n, _ := strconv.Atoi(m[1]); n > maxTask {
+				maxTask = n
+			}
+		}
+	}
+
+	taskIDs := make(map[string]string) // Bundle ID -> stored ID, "" to drop
+	var tasks []*Task
+	for _, t := range bundle.Tasks {
+		existing, ok := taken[t.TaskID]
+		switch {
+		case !ok:
+			taskIDs[t.TaskID] = t.TaskID
+			tasks = append(tasks, t)
+		case sameRecord(existing, t):
+			taskIDs[t.TaskID] = ""
+			result.Duplicates++
+		default:
+			newID := ""
+			if opts.OnConflict == ConflictRename {
+				if numberedTaskID.MatchString(t.TaskID) {
+					maxTask++
+					newID = fmt.Sprintf("task-%03d", maxTask)
+				} else {
+					for n := 2; newID == "" || taken[newID] != nil; n++ {
+						newID = fmt.Sprintf("%s-%d", t.TaskID, n)
+					}
+				}
+				taken[newID] = t
+				tasks = append(tasks, t)
+			}
+			taskIDs[t.TaskID] = newID
+			result.Conflicts = append(result.Conflicts, ImportConflict{Kind: "task", ID: t.TaskID, NewID: newID})
+		}
+	}
+
+	if opts.OnConflict == ConflictFail && len(result.Conflicts) > 0 {
+		return result, fmt.Errorf("%d conflicting record(s), nothing imported", len(result.Conflicts))
+	}
+
+	for old, id := range sessionIDs {
+		if id != "" && id != old {
+			result.SessionIDs[old] = id
+		}
+	}
+	for old, id := range taskIDs {
+		if id != "" && id != old {
+			result.TaskIDs[old] = id
+		}
+	}
+
+	// mapSession returns the stored session ID for a reference; unknown IDs pass through
+	mapSession := func(id string) (string, bool) {
+		if mapped, ok := sessionIDs[id]; ok {
+			return mapped, mapped != ""
+		}
+		return id, true
+	}
+	mapTask := func(id string) (string, bool) {
+		if mapped, ok := taskIDs[id]; ok {
+			return mapped, mapped != ""
+		}
+		return id, true
+	}
+
+	// Events belong to their task if it's in the bundle, otherwise to their session
+	var events []*Event
+	for _, e := range bundle.Events {
+		keep := true
+		if e.TaskID != nil {
+			if _, inBundle := taskIDs[*e.TaskID]; inBundle {
+				_, keep = mapTask(*e.TaskID)
+			} else if e.SessionID != "" {
+				_, keep = mapSession(e.SessionID)
+			}
+		} else if e.SessionID != "" {
+			_, keep = mapSession(e.SessionID)
+		}
+		if keep {
+			events = append(events, e)
+		}
+	}
+
+	result.Sessions = len(sessions)
+	result.Tasks = len(tasks)
+	result.Events = len(events)
+	for _, u := range bundle.Usage {
+		if _, ok := mapTask(u.TaskID); ok {
+			result.Usage++
+		}
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	// Apply, copying records so the bundle stays unchanged
+	for _, s := range sessions {
+		c, err := clone(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy session: %w", err)
+		}
+		c.SessionID = sessionIDs[s.SessionID]
+		if err := store.CreateSession(ctx, c); err != nil {
+			return nil, fmt.Errorf("failed to import session %s: %w", s.SessionID, err)
+		}
+	}
+
+	for _, t := range tasks {
+		c, err := clone(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy task: %w", err)
+		}
+		c.TaskID = taskIDs[t.TaskID]
+		c.SessionID, _ = mapSession(t.SessionID)
+		var deps []string
+		for _, dep := range t.DependsOn {
+			if id, ok := mapTask(dep); ok {
+				deps = append(deps, id)
+			} else {
+				deps = append(deps, dep) // Not imported: the existing task with this ID stands in
+			}
+		}
+		c.DependsOn = deps
+		if err := store.CreateTask(ctx, c); err != nil {
+			return nil, fmt.Errorf("failed to import task %s: %w", t.TaskID, err)
+		}
+	}
+
+	for _, e := range events {
+		c, err := clone(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy event: %w", err)
+		}
+		if c.TaskID != nil {
+			if id, ok := mapTask(*c.TaskID); ok {
+				c.TaskID = &id
+			}
+		}
+		if c.SessionID != "" {
+			c.SessionID, _ = mapSession(c.SessionID)
+		}
+		if err := store.SaveEvent(ctx, c); err != nil {
+			return nil, fmt.Errorf("failed to import event %d: %w", e.ID, err)
+		}
+		result.EventIDs[e.ID] = c.ID
+	}
+
+	for _, u := range bundle.Usage {
+		taskID, ok := mapTask(u.TaskID)
+		if !ok {
+			continue
+		}
+		c, err := clone(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy usage: %w", err)
+		}
+		c.TaskID = taskID
+		if c.SessionID != "" {
+			c.SessionID, _ = mapSession(c.SessionID)
+		}
+		if err := store.SaveUsage(ctx, c); err != nil {
+			return nil, fmt.Errorf("failed to import usage for %s: %w", u.TaskID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// freeSessionID returns id with the first numeric suffix not used in store or taken
+func freeSessionID(ctx context.Context, store Store, id string, taken map[string]bool) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", id, n)
+		if taken[candidate] {
+			continue
+		}
+		if _, err := store.GetSession(ctx, candidate); err != nil {
+			return candidate
+		}
+	}
+}
+
+// Markdown renders a readable summary of the bundle, e.g. for a bug report
+func (b *Bundle) Markdown() string {
+	var sb strings.Builder
+
+	title := "All sessions"
+	if b.SessionID != "" {
+		title = "Session " + b.SessionID
+		for _, s := range b.Sessions {
+			if s.SessionID == b.SessionID && s.Title != "" {
+				title += ": " + s.Title
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+
+	var tokens int
+	for _, u := range b.Usage {
+		tokens += u.TotalTokens
+	}
+	fmt.Fprintf(&sb, "Exported %s · %d tasks · %d events · %d tokens\n\n",
+		b.ExportedAt.Format("2006-01-02 15:04"), len(b.Tasks), len(b.Events), tokens)
+
+	if len(b.Tasks) > 0 {
+		sb.WriteString("## Tasks\n\n| ID | Title | Role | Status | Agent |\n|---|---|---|---|---|\n")
+		for _, t := range b.Tasks {
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", t.TaskID, mdCell(t.Title), t.AgentRole, t.Status, t.AgentID)
+		}
+		sb.WriteString("\n")
+
+		for _, t := range b.Tasks {
+			fmt.Fprintf(&sb, "### %s: %s\n\n", t.TaskID, t.Title)
+			if t.Description != "" {
+				fmt.Fprintf(&sb, "%s\n\n", strings.TrimSpace(t.Description))
+			}
+			if len(t.DependsOn) > 0 {
+				fmt.Fprintf(&sb, "**Depends on:** %s\n\n", strings.Join(t.DependsOn, ", "))
+			}
+			if t.Learnings != "" {
+				fmt.Fprintf(&sb, "**Learnings:** %s\n\n", strings.TrimSpace(t.Learnings))
+			}
+			mdList(&sb, "Tried", t.TriedApproaches)
+			mdList(&sb, "Blockers", t.Blockers)
+			if n := len(t.TestRuns); n > 0 {
+				run := t.TestRuns[n-1]
+				fmt.Fprintf(&sb, "**Tests (%s):** %d passed, %d failed, %d skipped\n\n", run.Framework, run.Passed, run.Failed, run.Skipped)
+			}
+			if t.Result != "" {
+				fmt.Fprintf(&sb, "**Result:** %s\n\n", strings.TrimSpace(t.Result))
+			}
+			if t.Error != "" {
+				fmt.Fprintf(&sb, "**Error:** %s\n\n", strings.TrimSpace(t.Error))
+			}
+		}
+	}
+
+	if len(b.Events) > 0 {
+		const maxTimeline = 50
+		sb.WriteString("## Timeline\n\n")
+		events := b.Events
+		if len(events) > maxTimeline {
+			fmt.Fprintf(&sb, "_Last %d of %d events._\n\n", maxTimeline, len(events))
+			events = events[len(events)-maxTimeline:]
+		}
+		for _, e := range events {
+			task := ""
+			if e.TaskID != nil {
+				task = " " + *e.TaskID
+			}
+			fmt.Fprintf(&sb, "- %s `%s` %s%s\n", e.Timestamp.Format("2006-01-02 15:04:05"), e.EventType, e.AgentID, task)
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(b.Usage) > 0 {
+		sb.WriteString("## Usage\n\n| Task | Model | Prompt | Completion | Total |\n|---|---|---|---|---|\n")
+		for _, u := range b.Usage {
+			fmt.Fprintf(&sb, "| %s | %s | %d | %d | %d |\n", u.TaskID, u.Model, u.PromptTokens, u.CompletionTokens, u.TotalTokens)
+		}
+	}
+
+	return sb.String()
+}
+
+// mdCell escapes a value for a Markdown table cell
+func mdCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+// mdList writes a bold-labelled bullet list, if items is non-empty
+func mdList(sb *strings.Builder, label string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "**%s:**\n\n", label)
+	for _, item := range items {
+		fmt.Fprintf(sb, "- %s\n", item)
+	}
+	sb.WriteString("\n")
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedBundleStore creates a session with two dependent tasks, events and usage
+func seedBundleStore(t *testing.T, store Store, sessionID string) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := store.CreateSession(ctx, &Session{SessionID: sessionID, Title: "Login page", Status: "active"}); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	tasks := []*Task{
+		{TaskID: "task-001", Title: "Design form", Status: "done", AgentRole: "architect", SessionID: sessionID, Learnings: "Reuse the auth layout"},
+		{TaskID: "task-002", Title: "Build form", Status: "wip", AgentRole: "keymaker", SessionID: sessionID, DependsOn: []string{"task-001"}},
+	}
+	for _, task := range tasks {
+		if err := store.CreateTask(ctx, task); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+		taskID := task.TaskID
+		if err := store.SaveEvent(ctx, &Event{AgentID: "agent-1", EventType: "task_started", TaskID: &taskID, SessionID: sessionID}); err != nil {
+			t.Fatalf("SaveEvent failed: %v", err)
+		}
+		if err := store.SaveUsage(ctx, &LLMUsage{TaskID: taskID, SessionID: sessionID, TotalTokens: 100}); err != nil {
+			t.Fatalf("SaveUsage failed: %v", err)
+		}
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	seedBundleStore(t, store, "session-a")
+	seedBundleStore(t, store, "session-b") // Same task IDs: only the latest copy survives
+
+	bundle, err := Export(ctx, store, "session-b")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(bundle.Tasks) != 2 || len(bundle.Events) != 4 || len(bundle.Usage) != 2 {
+		t.Fatalf("unexpected bundle contents: %d tasks, %d events, %d usage", len(bundle.Tasks), len(bundle.Events), len(bundle.Usage))
+	}
+
+	for _, format := range []string{BundleJSON, BundleTar, BundleTarGz} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteBundle(&buf, bundle, format); err != nil {
+				t.Fatalf("WriteBundle failed: %v", err)
+			}
+			got, err := ReadBundle(&buf)
+			if err != nil {
+				t.Fatalf("ReadBundle failed: %v", err)
+			}
+			if !sameRecord(got, bundle) {
+				t.Error("bundle changed in round trip")
+			}
+		})
+	}
+
+	if _, err := ReadBundle(strings.NewReader(`{"Version": 99}`)); err == nil {
+		t.Error("expected error for a newer bundle version")
+	}
+	if _, err := Export(ctx, store, "missing"); err == nil {
+		t.Error("expected error exporting a missing session")
+	}
+}
+
+func TestImportRemapsConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	source := NewMemoryStore()
+	seedBundleStore(t, source, "session-a")
+	bundle, err := Export(ctx, source, "session-a")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	// The target already has a different task-001 and session-a
+	target := NewMemoryStore()
+	_ = target.CreateSession(ctx, &Session{SessionID: "session-a", Title: "Other work"})
+	_ = target.CreateTask(ctx, &Task{TaskID: "task-001", Title: "Unrelated", Status: "backlog"})
+	_ = target.SaveEvent(ctx, &Event{AgentID: "agent-9", EventType: "task_created"})
+
+	// Fail mode writes nothing
+	if _, err := Import(ctx, target, bundle, ImportOptions{OnConflict: ConflictFail}); err == nil {
+		t.Fatal("expected conflict error")
+	}
+	if tasks, _ := target.ListTasks(ctx, nil); len(tasks) != 1 {
+		t.Fatalf("fail mode should not import anything, got %d tasks", len(tasks))
+	}
+
+	result, err := Import(ctx, target, bundle, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(result.Conflicts) != 2 || result.Tasks != 2 || result.Events != 2 || result.Usage != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.TaskIDs["task-001"] != "task-003" || result.SessionIDs["session-a"] != "session-a-2" {
+		t.Fatalf("unexpected remapping: %v %v", result.TaskIDs, result.SessionIDs)
+	}
+
+	// References follow the renamed records
+	built, err := target.GetTask(ctx, "task-002")
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if built.DependsOn[0] != "task-003" || built.SessionID != "session-a-2" {
+		t.Errorf("references not remapped: %+v", built)
+	}
+	events, _ := target.QueryEvents(ctx, EventFilter{TaskID: strPtr("task-003")})
+	if len(events) != 1 || events[0].SessionID != "session-a-2" || result.EventIDs[bundle.Events[0].ID] != events[0].ID {
+		t.Errorf("event not remapped: %v", events)
+	}
+	if usage, _ := target.GetUsage(ctx, "task-003"); usage == nil || usage.TotalTokens != 100 {
+		t.Errorf("usage not remapped: %v", usage)
+	}
+	if original, _ := target.GetTask(ctx, "task-001"); original.Title != "Unrelated" {
+		t.Error("existing task was overwritten")
+	}
+
+	// Importing into the source again finds everything already there
+	result, err = Import(ctx, source, bundle, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Duplicates != 3 || result.Tasks != 0 || result.Events != 0 || len(result.Conflicts) != 0 {
+		t.Errorf("expected only duplicates, got %+v", result)
+	}
+}
+
+func TestBundleMarkdown(t *testing.T) {
+	store := NewMemoryStore()
+	seedBundleStore(t, store, "session-a")
+	bundle, err := Export(context.Background(), store, "session-a")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	bundle.ExportedAt = time.Date(2025, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	md := bundle.Markdown()
+	for _, want := range []string{
+		"# Session session-a: Login page",
+		"2 tasks · 2 events · 200 tokens",
+		"| task-002 | Build form | keymaker | wip |",
+		"**Learnings:** Reuse the auth layout",
+		"**Depends on:** task-001",
+		"## Usage",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("summary missing %q:\n%s", want, md)
+		}
+	}
+}
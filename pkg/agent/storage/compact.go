@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// compactTxMaxSize bounds the size of each copy transaction during compaction
+const compactTxMaxSize = 64 << 20
+
+// CompactResult describes a CompactProject run
+type CompactResult struct {
+	PruneResult
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// BucketStats holds the size of one bucket. Nested buckets are reported as "parent/child".
+type BucketStats struct {
+	Name    string
+	Records int
+	Bytes   int // Bytes in use, including nested buckets
+}
+
+// DatabaseStats summarizes a database file
+type DatabaseStats struct {
+	Path          string
+	FileSize      int64
+	FreeBytes     int // Allocated but unused pages that compaction would reclaim
+	SchemaVersion int
+	Buckets       []BucketStats
+}
+
+// openExclusive opens the database file, failing fast if another process holds it
+func openExclusive(dbPath string, readOnly bool) (*bbolt.DB, error) {
+	db, err := openBolt(dbPath, &bbolt.Options{
+		Timeout:         5 * time.Second,
+		ReadOnly:        readOnly,
+		PreLoadFreelist: true,
+	})
+	if isLockTimeout(err) {
+		return nil, fmt.Errorf("database %s is in use (stop running smith processes first)", dbPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// CompactProject prunes events outside policy into .smith/archive and then
+// rewrites the database file to reclaim free space. It needs exclusive access
+// to the database, so no other smith process may be running in the project.
+func CompactProject(projectRoot string, policy RetentionPolicy) (*CompactResult, error) {
+	dbPath := ProjectDatabasePath(projectRoot)
+	result := &CompactResult{}
+
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database: %w", err)
+	}
+	result.SizeBefore = info.Size()
+
+	if !policy.IsZero() {
+		db, err := initBoltDatabase(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		store := NewBoltStore(db.DB)
+		pruned, err := store.PruneEvents(context.Background(), policy, filepath.Join(projectRoot, ".smith", "archive"))
+		_ = store.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune events: %w", err)
+		}
+		result.PruneResult = *pruned
+	}
+
+	if err := CompactDatabase(dbPath); err != nil {
+		return nil, err
+	}
+
+	info, err = os.Stat(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database: %w", err)
+	}
+	result.SizeAfter = info.Size()
+
+	return result, nil
+}
+
+// CompactDatabase rewrites the database at dbPath into a new file without
+// free pages and atomically replaces the original. Processes waiting for the
+// old file's lock notice the replacement and open the new file (see openBolt).
+func CompactDatabase(dbPath string) error {
+	src, err := openExclusive(dbPath, false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	tmpPath := dbPath + ".compact"
+	_ = os.Remove(tmpPath)
+	dst, err := bbolt.Open(tmpPath, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to create compacted database: %w", err)
+	}
+
+	if err := bbolt.Compact(dst, src, compactTxMaxSize); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact database: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact database: %w", err)
+	}
+
+	// Keep holding the source lock until the compacted file is in place
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+// GetDatabaseStats reports per-bucket record counts and sizes for the database at dbPath
+func GetDatabaseStats(dbPath string) (*DatabaseStats, error) {
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database: %w", err)
+	}
+
+	db, err := openExclusive(dbPath, true)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+
+	return readDatabaseStats(db, dbPath, info.Size())
+}
+
+// ProjectDatabaseStats reports on a project's database. While a smith
+// process serves the database to others, the stats come from that process,
+// since the database can't be opened alongside it.
+func ProjectDatabaseStats(projectRoot string) (*DatabaseStats, error) {
+	conn, err := net.DialTimeout("unix", BrokerSocketPath(projectRoot), time.Second)
+	if err != nil {
+		return GetDatabaseStats(ProjectDatabasePath(projectRoot))
+	}
+	client := rpc.NewClient(conn)
+	defer func() { _ = client.Close() }()
+
+	var stats DatabaseStats
+	if err := client.Call("Store.Stats", struct{}{}, &stats); err != nil {
+		return nil, fmt.Errorf("failed to read database stats from the broker: %w", err)
+	}
+	return &stats, nil
+}
+
+// readDatabaseStats reports on an open database
+func readDatabaseStats(db *bbolt.DB, dbPath string, fileSize int64) (*DatabaseStats, error) {
+	stats := &DatabaseStats{Path: dbPath, FileSize: fileSize}
+	err := db.View(func(tx *bbolt.Tx) error {
+		var err error
+		stats.SchemaVersion, err = schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		stats.FreeBytes = db.Stats().FreePageN * db.Info().PageSize
+
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			stats.Buckets = append(stats.Buckets, bucketStats(string(name), b)...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database stats: %w", err)
+	}
+
+	sort.Slice(stats.Buckets, func(i, j int) bool { return stats.Buckets[i].Name < stats.Buckets[j].Name })
+	return stats, nil
+}
+
+// bucketStats returns stats for a bucket and, separately, each of its nested buckets
+func bucketStats(name string, b *bbolt.Bucket) []BucketStats {
+	s := b.Stats()
+	entry := BucketStats{Name: name, Bytes: s.BranchInuse + s.LeafInuse}
+	if entry.Bytes == 0 {
+		entry.Bytes = s.InlineBucketInuse // Small buckets are stored inline in their parent
+	}
+
+	var nested []BucketStats
+	_ = b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			if child := b.Bucket(k); child != nil {
+				nested = append(nested, bucketStats(name+"/"+string(k), child)...)
+				return nil
+			}
+		}
+		entry.Records++
+		return nil
+	})
+
+	return append([]BucketStats{entry}, nested...)
+}
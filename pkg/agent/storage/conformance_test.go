@@ -0,0 +1,459 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestStoreConformance runs the same behavioral checks against every Store backend
+func TestStoreConformance(t *testing.T) {
+	backends := []struct {
+		name     string
+		newStore func(t *testing.T) Store
+	}{
+		{"bolt", func(t *testing.T) Store { return newTestStore(t) }},
+		{"memory", func(t *testing.T) Store { return NewMemoryStore() }},
+		{"shared", func(t *testing.T) Store { return newTestClient(t) }},
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store Store)
+	}{
+		{"EventSequence", testEventSequence},
+		{"EventQueries", testEventQueries},
+		{"TaskLifecycle", testTaskLifecycle},
+		{"ConcurrentClaim", testConcurrentClaim},
+		{"TestRuns", testTestRuns},
+		{"LockConflicts", testLockConflicts},
+		{"Agents", testAgents},
+		{"Sessions", testSessions},
+		{"Usage", testUsage},
+		{"ResponseCache", testResponseCache},
+		{"RecordsAreCopies", testRecordsAreCopies},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, backend.newStore(t))
+				})
+			}
+		})
+	}
+}
+
+func testEventSequence(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.SaveEvent(ctx, &Event{AgentID: "agent-1", EventType: "file_read"}); err != nil {
+				t.Errorf("SaveEvent failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	events, err := store.QueryEvents(ctx, EventFilter{AgentID: strPtr("agent-1")})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if len(events) != 20 {
+		t.Fatalf("expected 20 events, got %d", len(events))
+	}
+	for i, e := range events {
+		if want := int64(20 - i); e.ID != want {
+			t.Fatalf("expected sequential IDs newest first, got %d at %d", e.ID, i)
+		}
+		if e.Timestamp.IsZero() {
+			t.Error("expected timestamp to be set")
+		}
+	}
+}
+
+func testEventQueries(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 12; i++ {
+		event := &Event{
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			AgentID:   fmt.Sprintf("agent-%d", i%2),
+			EventType: []string{"task_started", "file_edited", "task_completed"}[i%3],
+			TaskID:    strPtr(fmt.Sprintf("task-%d", i%3)),
+		}
+		if err := store.SaveEvent(ctx, event); err != nil {
+			t.Fatalf("SaveEvent failed: %v", err)
+		}
+	}
+	// An out-of-order timestamp: newest by ID, oldest by time
+	if err := store.SaveEvent(ctx, &Event{Timestamp: base.Add(-time.Minute), AgentID: "agent-0", EventType: "file_read"}); err != nil {
+		t.Fatalf("SaveEvent failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   []int64
+	}{
+		{"latest_by_time", EventFilter{Limit: 2}, []int64{12, 11}},
+		{"oldest_by_time", EventFilter{Until: base}, []int64{1, 13}},
+		{"task", EventFilter{TaskID: strPtr("task-2")}, []int64{12, 9, 6, 3}},
+		{"agent", EventFilter{AgentID: strPtr("agent-0"), Limit: 3}, []int64{13, 11, 9}},
+		{"types", EventFilter{EventTypes: []string{"file_read", "task_completed"}}, []int64{13, 12, 9, 6, 3}},
+		{"since_id", EventFilter{AgentID: strPtr("agent-1"), SinceID: 8}, []int64{12, 10}},
+		{"since", EventFilter{Since: base.Add(10 * time.Minute)}, []int64{12, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := store.QueryEvents(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("QueryEvents failed: %v", err)
+			}
+			var got []int64
+			for _, e := range events {
+				got = append(got, e.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got IDs %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testTaskLifecycle(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if _, err := store.GetTask(ctx, "missing"); err == nil {
+		t.Error("expected error for missing task")
+	}
+
+	for _, id := range []string{"task-b", "task-a", "task-c"} {
+		task := &Task{TaskID: id, Title: id, Status: "backlog", AgentRole: "keymaker", SessionID: "s1"}
+		if err := store.CreateTask(ctx, task); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+		if task.StartedAt.IsZero() || task.UpdatedAt.IsZero() {
+			t.Error("expected CreateTask to set timestamps")
+		}
+	}
+
+	if err := store.ClaimTask(ctx, "task-a", "agent-1"); err != nil {
+		t.Fatalf("ClaimTask failed: %v", err)
+	}
+	if err := store.ClaimTask(ctx, "task-a", "agent-2"); err == nil {
+		t.Error("expected error claiming a task that is not in backlog")
+	}
+	if err := store.ClaimTask(ctx, "missing", "agent-2"); err == nil {
+		t.Error("expected error claiming a missing task")
+	}
+
+	task, err := store.GetTask(ctx, "task-c")
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	task.Status = "done"
+	task.AgentRole = "oracle"
+	task.Notes = map[string]string{"k": "v"}
+	if err := store.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+
+	all, _ := store.ListTasks(ctx, nil)
+	if len(all) != 3 || all[0].TaskID != "task-a" || all[2].TaskID != "task-c" {
+		t.Errorf("expected tasks ordered by ID, got %d", len(all))
+	}
+	if wip, _ := store.ListTasks(ctx, strPtr("wip")); len(wip) != 1 || wip[0].AgentID != "agent-1" {
+		t.Errorf("expected task-a in wip for agent-1, got %v", wip)
+	}
+	if byRole, _ := store.ListTasksByRole(ctx, "keymaker"); len(byRole) != 2 {
+		t.Errorf("expected 2 keymaker tasks, got %d", len(byRole))
+	}
+	if session, _ := store.GetSessionTasks(ctx, "s1"); len(session) != 3 {
+		t.Errorf("expected 3 session tasks, got %d", len(session))
+	}
+
+	got, _ := store.GetTasks(ctx, []string{"task-c", "missing"})
+	if len(got) != 1 || got["task-c"].Notes["k"] != "v" {
+		t.Errorf("unexpected GetTasks result: %v", got)
+	}
+
+	stats, _ := store.GetTaskStats(ctx)
+	if *stats != (TaskStats{Backlog: 1, WIP: 1, Done: 1}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func testTestRuns(t *testing.T, store Store) {
+	ctx := context.Background()
+	if err := store.CreateTask(ctx, &Task{TaskID: "task-1", Title: "t", Status: "wip"}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	// Concurrent runs all count; only the newest are kept in full
+	const runs = MaxTestRuns + 3
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run := TestRun{Command: fmt.Sprintf("run-%d", i), Passed: 1, Failed: i % 2}
+			if err := store.AddTestRun(ctx, "task-1", run); err != nil {
+				t.Errorf("AddTestRun failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	task, err := store.GetTask(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if len(task.TestRuns) != MaxTestRuns {
+		t.Errorf("expected %d runs kept, got %d", MaxTestRuns, len(task.TestRuns))
+	}
+	if task.TestSummary != (TestSummary{Runs: runs, FailedRuns: runs / 2}) {
+		t.Errorf("unexpected summary %+v", task.TestSummary)
+	}
+	if err := store.AddTestRun(ctx, "missing", TestRun{}); err == nil {
+		t.Error("expected error adding a run to a missing task")
+	}
+}
+
+func testConcurrentClaim(t *testing.T, store Store) {
+	ctx := context.Background()
+	if err := store.CreateTask(ctx, &Task{TaskID: "task-1", Status: "backlog"}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if store.ClaimTask(ctx, "task-1", fmt.Sprintf("agent-%d", i)) == nil {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if claimed != 1 {
+		t.Errorf("expected exactly one successful claim, got %d", claimed)
+	}
+}
+
+func testLockConflicts(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if err := store.AcquireLocks(ctx, []*FileLock{{FilePath: "b.go", AgentID: "agent-1"}}); err != nil {
+		t.Fatalf("AcquireLocks failed: %v", err)
+	}
+
+	// A conflicting batch acquires nothing
+	err := store.AcquireLocks(ctx, []*FileLock{
+		{FilePath: "a.go", AgentID: "agent-2"},
+		{FilePath: "b.go", AgentID: "agent-2"},
+	})
+	if err == nil {
+		t.Fatal("expected conflict on b.go")
+	}
+	if locks, _ := store.GetLocksForAgent(ctx, "agent-2"); len(locks) != 0 {
+		t.Errorf("expected no partial acquisition, got %d locks", len(locks))
+	}
+
+	if err := store.AcquireLocks(ctx, []*FileLock{{FilePath: "a.go", AgentID: "agent-2"}}); err != nil {
+		t.Fatalf("AcquireLocks failed: %v", err)
+	}
+
+	// Agents can't release each other's locks
+	if err := store.ReleaseLocks(ctx, "agent-2", []string{"a.go", "b.go"}); err != nil {
+		t.Fatalf("ReleaseLocks failed: %v", err)
+	}
+	locks, _ := store.GetLocks(ctx)
+	if len(locks) != 1 || locks[0].FilePath != "b.go" || locks[0].LockedAt.IsZero() {
+		t.Errorf("expected only agent-1's lock on b.go to remain, got %v", locks)
+	}
+
+	if err := store.ReleaseLocks(ctx, "agent-1", nil); err != nil {
+		t.Fatalf("ReleaseLocks failed: %v", err)
+	}
+	if locks, _ := store.GetLocks(ctx); len(locks) != 0 {
+		t.Errorf("expected all locks released, got %d", len(locks))
+	}
+}
+
+func testAgents(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	stale := time.Now().Add(-time.Hour)
+	agents := []*Agent{
+		{ID: "agent-2", Role: "keymaker", Status: "active", LastHeartbeat: stale},
+		{ID: "agent-1", Role: "keymaker", Status: "active"},
+		{ID: "agent-3", Role: "oracle", Status: "idle", LastHeartbeat: stale},
+	}
+	for _, a := range agents {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent failed: %v", err)
+		}
+	}
+
+	if err := store.UpdateHeartbeat(ctx, "missing"); err == nil {
+		t.Error("expected error updating heartbeat of a missing agent")
+	}
+
+	dead, err := store.MarkAgentDead(ctx, time.Minute)
+	if err != nil || dead != 1 {
+		t.Fatalf("expected 1 agent marked dead, got %d (%v)", dead, err)
+	}
+	if a, _ := store.GetAgent(ctx, "agent-2"); a.Status != "dead" {
+		t.Errorf("expected agent-2 dead, got %s", a.Status)
+	}
+
+	keymakers, _ := store.ListAgents(ctx, strPtr("keymaker"))
+	if len(keymakers) != 2 || keymakers[0].ID != "agent-1" {
+		t.Errorf("expected keymakers ordered by ID, got %v", keymakers)
+	}
+
+	if err := store.UnregisterAgent(ctx, "agent-1"); err != nil {
+		t.Fatalf("UnregisterAgent failed: %v", err)
+	}
+	if _, err := store.GetAgent(ctx, "agent-1"); err == nil {
+		t.Error("expected error for unregistered agent")
+	}
+}
+
+func testSessions(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, id := range []string{"s1", "s2", "s3"} {
+		session := &Session{SessionID: id, Status: "active", LastActive: now.Add(time.Duration(i%2) * time.Minute)}
+		if err := store.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+	}
+
+	sessions, err := store.ListSessions(ctx, 0)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	var ids []string
+	for _, s := range sessions {
+		ids = append(ids, s.SessionID)
+	}
+	if fmt.Sprint(ids) != "[s2 s1 s3]" {
+		t.Errorf("expected most recent first, then by ID, got %v", ids)
+	}
+	if limited, _ := store.ListSessions(ctx, 1); len(limited) != 1 {
+		t.Errorf("expected limit to apply, got %d", len(limited))
+	}
+
+	if err := store.ArchiveSession(ctx, "s1"); err != nil {
+		t.Fatalf("ArchiveSession failed: %v", err)
+	}
+	if s, _ := store.GetSession(ctx, "s1"); s.Status != "archived" {
+		t.Errorf("expected archived session, got %s", s.Status)
+	}
+	if err := store.ArchiveSession(ctx, "missing"); err == nil {
+		t.Error("expected error archiving a missing session")
+	}
+}
+
+func testUsage(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if usage, err := store.GetUsage(ctx, "task-1"); usage != nil || err != nil {
+		t.Errorf("expected no usage for unknown task, got %v (%v)", usage, err)
+	}
+
+	records := []*LLMUsage{
+		{TaskID: "task-1", SessionID: "s1", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		{TaskID: "task-2", SessionID: "s1", PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2, CacheHits: 3, CacheMisses: 1},
+		{TaskID: "task-3", SessionID: "s2", PromptTokens: 100, CompletionTokens: 0, TotalTokens: 100},
+		// Replaces the first record: usage is stored per task
+		{TaskID: "task-1", SessionID: "s1", PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25},
+	}
+	for _, u := range records {
+		if err := store.SaveUsage(ctx, u); err != nil {
+			t.Fatalf("SaveUsage failed: %v", err)
+		}
+	}
+
+	if session, _ := store.GetSessionUsage(ctx, "s1"); session.TotalTokens != 27 || session.CacheHits != 3 || session.SessionID != "s1" {
+		t.Errorf("unexpected session usage: %+v", session)
+	}
+	if total, _ := store.GetTotalUsage(ctx); total.PromptTokens != 121 || total.TotalTokens != 127 {
+		t.Errorf("unexpected total usage: %+v", total)
+	}
+}
+
+func testResponseCache(t *testing.T, store Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	if entry, err := store.GetCachedResponse(ctx, "k1"); entry != nil || err != nil {
+		t.Errorf("expected no entry for unknown key, got %v (%v)", entry, err)
+	}
+
+	entries := []*CachedResponse{
+		{Key: "k1", Data: []byte("one"), CreatedAt: now.Add(-3 * time.Minute), ExpiresAt: now.Add(time.Hour)},
+		{Key: "k2", Data: []byte("two"), CreatedAt: now.Add(-2 * time.Minute), ExpiresAt: now.Add(time.Hour)},
+		{Key: "old", Data: []byte("stale"), CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	}
+	for _, entry := range entries {
+		if err := store.PutCachedResponse(ctx, entry, 0); err != nil {
+			t.Fatalf("PutCachedResponse failed: %v", err)
+		}
+	}
+	if entry, _ := store.GetCachedResponse(ctx, "k2"); entry == nil || string(entry.Data) != "two" {
+		t.Errorf("unexpected entry for k2: %+v", entry)
+	}
+	if entry, _ := store.GetCachedResponse(ctx, "old"); entry != nil {
+		t.Errorf("expired entry was served: %+v", entry)
+	}
+
+	// Beyond the limit the oldest entries go
+	if err := store.PutCachedResponse(ctx, &CachedResponse{Key: "k3", Data: []byte("three"), ExpiresAt: now.Add(time.Hour)}, 2); err != nil {
+		t.Fatalf("PutCachedResponse failed: %v", err)
+	}
+	for key, want := range map[string]bool{"k1": false, "k2": true, "k3": true} {
+		if entry, _ := store.GetCachedResponse(ctx, key); (entry != nil) != want {
+			t.Errorf("entry %s cached = %v, want %v", key, entry != nil, want)
+		}
+	}
+}
+
+func testRecordsAreCopies(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	task := &Task{TaskID: "task-1", Status: "backlog", Blockers: []string{"x"}}
+	if err := store.CreateTask(ctx, task); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	task.Status = "done"
+	task.Blockers[0] = "changed"
+
+	got, _ := store.GetTask(ctx, "task-1")
+	if got.Status != "backlog" || got.Blockers[0] != "x" {
+		t.Errorf("store kept a reference to the caller's task: %+v", got)
+	}
+
+	got.Status = "wip"
+	if again, _ := store.GetTask(ctx, "task-1"); again.Status != "backlog" {
+		t.Error("store returned a reference to its own task")
+	}
+}
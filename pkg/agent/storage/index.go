@@ -0,0 +1,416 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Secondary index buckets, nested under IndexesBucket.
+//
+// Index keys are "<value>\x00<primary key>" so a lookup is a prefix seek.
+// Event keys end in the big-endian event ID, so they sort in insertion order.
+var (
+	TasksByStatusIndex  = []byte("tasks_by_status")
+	TasksBySessionIndex = []byte("tasks_by_session")
+	TasksByRoleIndex    = []byte("tasks_by_role")
+	EventsByTaskIndex   = []byte("events_by_task")
+	EventsByAgentIndex  = []byte("events_by_agent")
+	EventsByTypeIndex   = []byte("events_by_type")
+	EventsByTimeIndex   = []byte("events_by_time") // Key: big-endian unix nanos + event ID
+	EventsByIDIndex     = []byte("events_by_id")   // Key: big-endian event ID
+	LocksByAgentIndex   = []byte("locks_by_agent")
+)
+
+var indexBuckets = [][]byte{
+	TasksByStatusIndex,
+	TasksBySessionIndex,
+	TasksByRoleIndex,
+	EventsByTaskIndex,
+	EventsByAgentIndex,
+	EventsByTypeIndex,
+	EventsByTimeIndex,
+	EventsByIDIndex,
+	LocksByAgentIndex,
+}
+
+// indexPrefix returns the seek prefix for all entries with value
+func indexPrefix(value string) []byte {
+	prefix := make([]byte, 0, len(value)+1)
+	prefix = append(prefix, value...)
+	return append(prefix, 0)
+}
+
+// indexKey returns the index entry for value pointing at key
+func indexKey(value string, key []byte) []byte {
+	return append(indexPrefix(value), key...)
+}
+
+// eventIDBytes encodes an event ID so index entries sort numerically
+func eventIDBytes(id int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+// eventTimeKey returns the events_by_time key for an event
+func eventTimeKey(ts time.Time, id int64) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(ts.UnixNano()))
+	binary.BigEndian.PutUint64(b[8:], uint64(id))
+	return b
+}
+
+// eventKey returns the primary key of an event in EventsBucket
+func eventKey(id int64) []byte {
+	return []byte(fmt.Sprintf("%d", id))
+}
+
+// indexBucket returns a nested index bucket
+func indexBucket(tx *bbolt.Tx, name []byte) (*bbolt.Bucket, error) {
+	root := tx.Bucket(IndexesBucket)
+	if root == nil {
+		return nil, fmt.Errorf("indexes bucket not found")
+	}
+	b := root.Bucket(name)
+	if b == nil {
+		return nil, fmt.Errorf("index %s not found", name)
+	}
+	return b, nil
+}
+
+// createIndexBuckets creates the index buckets if they don't exist
+func createIndexBuckets(tx *bbolt.Tx) error {
+	root, err := tx.CreateBucketIfNotExists(IndexesBucket)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", IndexesBucket, err)
+	}
+	for _, name := range indexBuckets {
+		if _, err := root.CreateBucketIfNotExists(name); err != nil {
+			return fmt.Errorf("failed to create index %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// taskIndexKeys returns the index entries for a task, by index bucket
+func taskIndexKeys(task *Task) map[string][]byte {
+	id := []byte(task.TaskID)
+	return map[string][]byte{
+		string(TasksByStatusIndex):  indexKey(task.Status, id),
+		string(TasksBySessionIndex): indexKey(task.SessionID, id),
+		string(TasksByRoleIndex):    indexKey(task.AgentRole, id),
+	}
+}
+
+// putTask stores a task and moves its index entries from the previous version
+func putTask(tx *bbolt.Tx, task *Task) error {
+	b := tx.Bucket(TasksBucket)
+	if b == nil {
+		return fmt.Errorf("tasks bucket not found")
+	}
+
+	var oldKeys map[string][]byte
+	if data := b.Get([]byte(task.TaskID)); data != nil {
+		var old Task
+		if err := json.Unmarshal(data, &old); err == nil {
+			oldKeys = taskIndexKeys(&old)
+		}
+	}
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+	if err := b.Put([]byte(task.TaskID), data); err != nil {
+		return fmt.Errorf("failed to store task: %w", err)
+	}
+
+	for name, key := range taskIndexKeys(task) {
+		idx, err := indexBucket(tx, []byte(name))
+		if err != nil {
+			return err
+		}
+		if old := oldKeys[name]; old != nil && !bytes.Equal(old, key) {
+			if err := idx.Delete(old); err != nil {
+				return fmt.Errorf("failed to update index %s: %w", name, err)
+			}
+		}
+		if err := idx.Put(key, nil); err != nil {
+			return fmt.Errorf("failed to update index %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// putEvent stores a new event and its index entries
+func putEvent(tx *bbolt.Tx, event *Event) error {
+	b := tx.Bucket(EventsBucket)
+	if b == nil {
+		return fmt.Errorf("events bucket not found")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if err := b.Put(eventKey(event.ID), data); err != nil {
+		return fmt.Errorf("failed to store event: %w", err)
+	}
+
+	return indexEvent(tx, event)
+}
+
+// indexEntry is a key in one of the index buckets
+type indexEntry struct {
+	index []byte
+	key   []byte
+}
+
+// eventIndexEntries returns the index entries for an event
+func eventIndexEntries(event *Event) []indexEntry {
+	id := eventIDBytes(event.ID)
+	entries := []indexEntry{
+		{EventsByAgentIndex, indexKey(event.AgentID, id)},
+		{EventsByTypeIndex, indexKey(event.EventType, id)},
+		{EventsByTimeIndex, eventTimeKey(event.Timestamp, event.ID)},
+		{EventsByIDIndex, id},
+	}
+	if event.TaskID != nil {
+		entries = append(entries, indexEntry{EventsByTaskIndex, indexKey(*event.TaskID, id)})
+	}
+	return entries
+}
+
+// indexEvent adds an event's index entries
+func indexEvent(tx *bbolt.Tx, event *Event) error {
+	for _, e := range eventIndexEntries(event) {
+		idx, err := indexBucket(tx, e.index)
+		if err != nil {
+			return err
+		}
+		if err := idx.Put(e.key, nil); err != nil {
+			return fmt.Errorf("failed to update index %s: %w", e.index, err)
+		}
+	}
+	return nil
+}
+
+// deleteEvent removes an event and its index entries
+func deleteEvent(tx *bbolt.Tx, event *Event) error {
+	b := tx.Bucket(EventsBucket)
+	if b == nil {
+		return fmt.Errorf("events bucket not found")
+	}
+	if err := b.Delete(eventKey(event.ID)); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+
+	for _, e := range eventIndexEntries(event) {
+		idx, err := indexBucket(tx, e.index)
+		if err != nil {
+			return err
+		}
+		if err := idx.Delete(e.key); err != nil {
+			return fmt.Errorf("failed to update index %s: %w", e.index, err)
+		}
+	}
+	return nil
+}
+
+// putLock stores a lock and indexes it by agent
+func putLock(tx *bbolt.Tx, lock *FileLock) error {
+	b := tx.Bucket(FileLocksBucket)
+	if b == nil {
+		return fmt.Errorf("file_locks bucket not found")
+	}
+
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock: %w", err)
+	}
+	if err := b.Put([]byte(lock.FilePath), data); err != nil {
+		return fmt.Errorf("failed to store lock: %w", err)
+	}
+
+	idx, err := indexBucket(tx, LocksByAgentIndex)
+	if err != nil {
+		return err
+	}
+	return idx.Put(indexKey(lock.AgentID, []byte(lock.FilePath)), nil)
+}
+
+// deleteLock removes a lock and its index entry
+func deleteLock(tx *bbolt.Tx, lock *FileLock) error {
+	b := tx.Bucket(FileLocksBucket)
+	if b == nil {
+		return fmt.Errorf("file_locks bucket not found")
+	}
+	if err := b.Delete([]byte(lock.FilePath)); err != nil {
+		return fmt.Errorf("failed to delete lock: %w", err)
+	}
+
+	idx, err := indexBucket(tx, LocksByAgentIndex)
+	if err != nil {
+		return err
+	}
+	return idx.Delete(indexKey(lock.AgentID, []byte(lock.FilePath)))
+}
+
+// forEachPrefix calls fn with the primary key of each index entry with value, in key order
+func forEachPrefix(idx *bbolt.Bucket, value string, fn func(key []byte) error) error {
+	prefix := indexPrefix(value)
+	c := idx.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if err := fn(k[len(prefix):]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventStream walks an event index backwards (newest first)
+type eventStream struct {
+	c      *bbolt.Cursor
+	prefix []byte
+	key    []byte
+}
+
+// newEventStream positions a stream at the newest entry with prefix,
+// skipping entries whose suffix sorts after upper (if set)
+func newEventStream(idx *bbolt.Bucket, prefix, upper []byte) *eventStream {
+	s := &eventStream{c: idx.Cursor(), prefix: prefix}
+
+	seek := append(append([]byte(nil), prefix...), upper...)
+	if upper == nil {
+		// Past every suffix: IDs and timestamps fit in 63 bits
+		seek = append(seek, bytes.Repeat([]byte{0xFF}, 16)...)
+	}
+
+	k, _ := s.c.Seek(seek)
+	switch {
+	case k == nil:
+		k, _ = s.c.Last()
+	case bytes.Compare(k, seek) > 0:
+		k, _ = s.c.Prev()
+	}
+	s.set(k)
+	return s
+}
+
+func (s *eventStream) set(k []byte) {
+	if k != nil && bytes.HasPrefix(k, s.prefix) {
+		s.key = k
+	} else {
+		s.key = nil
+	}
+}
+
+// id returns the event ID at the stream's position, or -1 when exhausted
+func (s *eventStream) id() int64 {
+	if s.key == nil {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint64(s.key[len(s.key)-8:]))
+}
+
+// timestamp returns the timestamp of a events_by_time entry
+func (s *eventStream) timestamp() time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(s.key[len(s.prefix):])))
+}
+
+func (s *eventStream) next() {
+	k, _ := s.c.Prev()
+	s.set(k)
+}
+
+// mergeNewest returns the stream with the highest event ID
+func mergeNewest(streams []*eventStream) *eventStream {
+	var best *eventStream
+	for _, s := range streams {
+		if s.key != nil && (best == nil || s.id() > best.id()) {
+			best = s
+		}
+	}
+	return best
+}
+
+// timeUpper returns the events_by_time seek bound for until (inclusive)
+func timeUpper(until time.Time) []byte {
+	if until.IsZero() {
+		return nil
+	}
+	return eventTimeKey(until, math.MaxInt64)
+}
+
+// rebuildIndexes recreates every index from the primary buckets
+func rebuildIndexes(tx *bbolt.Tx) error {
+	if tx.Bucket(IndexesBucket) != nil {
+		if err := tx.DeleteBucket(IndexesBucket); err != nil {
+			return fmt.Errorf("failed to drop indexes: %w", err)
+		}
+	}
+	if err := createIndexBuckets(tx); err != nil {
+		return err
+	}
+
+	if b := tx.Bucket(TasksBucket); b != nil {
+		err := b.ForEach(func(k, v []byte) error {
+			var task Task
+			if err := json.Unmarshal(v, &task); err != nil {
+				return nil // Skip corrupted entries
+			}
+			for name, key := range taskIndexKeys(&task) {
+				idx, err := indexBucket(tx, []byte(name))
+				if err != nil {
+					return err
+				}
+				if err := idx.Put(key, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to index tasks: %w", err)
+		}
+	}
+
+	if b := tx.Bucket(EventsBucket); b != nil {
+		err := b.ForEach(func(k, v []byte) error {
+			var event Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return nil // Skip corrupted entries
+			}
+			return indexEvent(tx, &event)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to index events: %w", err)
+		}
+	}
+
+	if b := tx.Bucket(FileLocksBucket); b != nil {
+		idx, err := indexBucket(tx, LocksByAgentIndex)
+		if err != nil {
+			return err
+		}
+		err = b.ForEach(func(k, v []byte) error {
+			var lock FileLock
+			if err := json.Unmarshal(v, &lock); err != nil {
+				return nil // Skip corrupted entries
+			}
+			return idx.Put(indexKey(lock.AgentID, k), nil)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to index locks: %w", err)
+		}
+	}
+
+	return nil
+}
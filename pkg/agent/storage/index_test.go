@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func newTestStore(t testing.TB) *BoltStore {
+	t.Helper()
+	db, err := initBoltDatabase(filepath.Join(t.TempDir(), "smith.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	store := NewBoltStore(db.DB)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestTaskIndexes(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for i, role := range []string{"keymaker", "sentinel", "keymaker"} {
+		task := &Task{
+			TaskID:    fmt.Sprintf("task-%d", i),
+			Status:    "backlog",
+			AgentRole: role,
+			SessionID: "session-1",
+		}
+		if err := store.CreateTask(ctx, task); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+	}
+
+	if err := store.ClaimTask(ctx, "task-0", "agent-1"); err != nil {
+		t.Fatalf("ClaimTask failed: %v", err)
+	}
+	task, _ := store.GetTask(ctx, "task-1")
+	task.Status = "done"
+	task.SessionID = "session-2"
+	if err := store.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+
+	// Status changes move the index entry
+	for status, want := range map[string]int{"backlog": 1, "wip": 1, "done": 1} {
+		tasks, err := store.ListTasks(ctx, strPtr(status))
+		if err != nil {
+			t.Fatalf("ListTasks failed: %v", err)
+		}
+		if len(tasks) != want {
+			t.Errorf("ListTasks(%s) = %d tasks, want %d", status, len(tasks), want)
+		}
+	}
+
+	stats, err := store.GetTaskStats(ctx)
+	if err != nil {
+		t.Fatalf("GetTaskStats failed: %v", err)
+	}
+	if stats.Backlog != 1 || stats.WIP != 1 || stats.Done != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	keymakers, err := store.ListTasksByRole(ctx, "keymaker")
+	if err != nil || len(keymakers) != 2 {
+		t.Errorf("ListTasksByRole(keymaker) = %d tasks (%v), want 2", len(keymakers), err)
+	}
+
+	session, err := store.GetSessionTasks(ctx, "session-1")
+	if err != nil || len(session) != 2 {
+		t.Errorf("GetSessionTasks(session-1) = %d tasks (%v), want 2", len(session), err)
+	}
+
+	got, err := store.GetTasks(ctx, []string{"task-0", "task-2", "missing"})
+	if err != nil || len(got) != 2 || got["task-0"].Status != "wip" {
+		t.Errorf("GetTasks returned %v (%v)", got, err)
+	}
+}
+
+func TestEventIndexes(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 25; i++ {
+		event := &Event{
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			AgentID:   fmt.Sprintf("agent-%d", i%2),
+			EventType: []string{"task_started", "file_edited", "task_completed"}[i%3],
+			TaskID:    strPtr(fmt.Sprintf("task-%d", i%5)),
+		}
+		if err := store.SaveEvent(ctx, event); err != nil {
+			t.Fatalf("SaveEvent failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   []int64 // Event IDs, newest first
+	}{
+		{"latest", EventFilter{Limit: 3}, []int64{25, 24, 23}},
+		{"task", EventFilter{TaskID: strPtr("task-1")}, []int64{22, 17, 12, 7, 2}},
+		{"agent_and_type", EventFilter{AgentID: strPtr("agent-0"), EventTypes: []string{"task_started"}}, []int64{25, 19, 13, 7, 1}},
+		{"types", EventFilter{EventTypes: []string{"file_edited", "task_completed"}, Limit: 4}, []int64{24, 23, 21, 20}},
+		{"since_id", EventFilter{TaskID: strPtr("task-1"), SinceID: 7}, []int64{22, 17, 12}},
+		{"since_id_only", EventFilter{SinceID: 22}, []int64{25, 24, 23}},
+		{"time_range", EventFilter{Since: base.Add(10 * time.Minute), Until: base.Add(12 * time.Minute)}, []int64{13, 12, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := store.QueryEvents(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("QueryEvents failed: %v", err)
+			}
+			var got []int64
+			for _, e := range events {
+				got = append(got, e.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got IDs %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryEventsSinceIDScansNewEvents(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for i := 0; i < 200; i++ {
+		// Timestamps out of ID order, so the time index can't stand in for IDs
+		event := &Event{Timestamp: time.Now().Add(-time.Duration(i%7) * time.Minute), AgentID: "agent-1", EventType: "file_read"}
+		if err := store.SaveEvent(ctx, event); err != nil {
+			t.Fatalf("SaveEvent failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filter  EventFilter
+		want    int
+		scanned int
+	}{
+		{"empty poll", EventFilter{SinceID: 200}, 0, 0},
+		{"new events", EventFilter{SinceID: 195}, 5, 5},
+		{"limited", EventFilter{SinceID: 100, Limit: 3}, 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.db.View(func(tx *bbolt.Tx) error {
+				events, scanned, err := queryEvents(tx, tt.filter)
+				if err != nil {
+					return err
+				}
+				if len(events) != tt.want {
+					t.Errorf("got %d events, want %d", len(events), tt.want)
+				}
+				if scanned != tt.scanned {
+					t.Errorf("scanned %d index entries, want %d", scanned, tt.scanned)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("queryEvents failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestLockIndex(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	err := store.AcquireLocks(ctx, []*FileLock{
+		{FilePath: "a.go", AgentID: "agent-1"},
+		{FilePath: "b.go", AgentID: "agent-1"},
+		{FilePath: "c.go", AgentID: "agent-2"},
+	})
+	if err != nil {
+		t.Fatalf("AcquireLocks failed: %v", err)
+	}
+
+	locks, err := store.GetLocksForAgent(ctx, "agent-1")
+	if err != nil || len(locks) != 2 {
+		t.Fatalf("GetLocksForAgent = %d locks (%v), want 2", len(locks), err)
+	}
+
+	if err := store.ReleaseLocks(ctx, "agent-1", nil); err != nil {
+		t.Fatalf("ReleaseLocks failed: %v", err)
+	}
+	if locks, _ := store.GetLocksForAgent(ctx, "agent-1"); len(locks) != 0 {
+		t.Errorf("expected agent-1 locks released, got %d", len(locks))
+	}
+	if locks, _ := store.GetLocks(ctx); len(locks) != 1 {
+		t.Errorf("expected agent-2 lock to remain, got %d", len(locks))
+	}
+}
+
+func TestIndexesBuiltForExistingDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "smith.db")
+
+	db, err := initBoltDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	store := NewBoltStore(db.DB)
+	if err := store.CreateTask(ctx, &Task{TaskID: "task-1", Status: "review", AgentRole: "oracle"}); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if err := store.SaveEvent(ctx, &Event{AgentID: "agent-1", EventType: "task_started"}); err != nil {
+		t.Fatalf("SaveEvent failed: %v", err)
+	}
+
+	// Simulate a database from before indexes existed
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(IndexesBucket); err != nil {
+			return err
+		}
+		return setSchemaVersion(tx, 1)
+	})
+	if err != nil {
+		t.Fatalf("failed to drop indexes: %v", err)
+	}
+	_ = store.Close()
+
+	db, err = initBoltDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	store = NewBoltStore(db.DB)
+	defer func() { _ = store.Close() }()
+
+	if tasks, _ := store.ListTasks(ctx, strPtr("review")); len(tasks) != 1 {
+		t.Errorf("expected existing task to be indexed, got %d", len(tasks))
+	}
+	if events, _ := store.QueryEvents(ctx, EventFilter{AgentID: strPtr("agent-1")}); len(events) != 1 {
+		t.Errorf("expected existing event to be indexed, got %d", len(events))
+	}
+}
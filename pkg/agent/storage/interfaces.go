@@ -11,6 +11,12 @@
 //   - Pure Go, no CGo dependencies
 //   - Battle-tested in production systems
 //
+// InitProjectStorage returns a SharedStore: the first smith process to open a
+// project owns the database and serves it to the others over a unix socket.
+//
+// MemoryStore is an in-memory backend with the same semantics, used by tests
+// and ephemeral runs. conformance_test.go checks both backends behave alike.
+//
 // Why not SQLite?
 //   - SQLITE_BUSY errors with concurrent agents (4+ agents hitting lock timeouts)
 //   - CGo dependency complicates cross-compilation
@@ -21,6 +27,7 @@ package storage
 
 import (
 	"context"
+	"sort"
 	"time"
 )
 
@@ -29,7 +36,7 @@ type EventStore interface {
 	// SaveEvent stores a new event
 	SaveEvent(ctx context.Context, event *Event) error
 
-	// QueryEvents retrieves events matching the filter
+	// QueryEvents retrieves events matching the filter, newest first
 	QueryEvents(ctx context.Context, filter EventFilter) ([]*Event, error)
 }
 
@@ -51,6 +58,47 @@ type EventFilter struct {
 	EventTypes []string
 	AgentID    *string
 	TaskID     *string
+	SinceID    int64     // Only events with ID > SinceID
+	Since      time.Time // Only events at or after Since
+	Until      time.Time // Only events at or before Until
+	Limit      int       // Maximum events to return (default: 1000)
+}
+
+// matches reports whether an event satisfies every criterion of the filter
+func (f EventFilter) matches(event *Event) bool {
+	if len(f.EventTypes) > 0 {
+		found := false
+		for _, et := range f.EventTypes {
+			if event.EventType == et {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.AgentID != nil && event.AgentID != *f.AgentID {
+		return false
+	}
+
+	if f.TaskID != nil && (event.TaskID == nil || *event.TaskID != *f.TaskID) {
+		return false
+	}
+
+	if event.ID <= f.SinceID {
+		return false
+	}
+
+	if !f.Since.IsZero() && event.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && event.Timestamp.After(f.Until) {
+		return false
+	}
+
+	return true
 }
 
 // AgentStore defines the interface for agent registry operations
@@ -96,12 +144,22 @@ type TaskStore interface {
 	// UpdateTask updates an existing task
 	UpdateTask(ctx context.Context, task *Task) error
 
+	// GetTasks retrieves several tasks by ID in one read; missing tasks are omitted
+	GetTasks(ctx context.Context, taskIDs []string) (map[string]*Task, error)
+
 	// ListTasks retrieves tasks, optionally filtered by status
 	ListTasks(ctx context.Context, status *string) ([]*Task, error)
 
+	// ListTasksByRole retrieves tasks assigned to an agent role
+	ListTasksByRole(ctx context.Context, role string) ([]*Task, error)
+
 	// ClaimTask atomically claims a task for an agent
 	ClaimTask(ctx context.Context, taskID, agentID string) error
 
+	// AddTestRun atomically records a test run on a task, keeping the
+	// newest MaxTestRuns runs and a summary of all of them
+	AddTestRun(ctx context.Context, taskID string, run TestRun) error
+
 	// GetTaskStats returns statistics about tasks by status
 	GetTaskStats(ctx context.Context) (*TaskStats, error)
 }
@@ -128,6 +186,56 @@ type Task struct {
 	TriedApproaches []string          // Approaches attempted ("Used strategy A", "Tried pattern B")
 	Blockers        []string          // What didn't work or blocked progress
 	Notes           map[string]string // Freeform key-value notes from agents
+
+	TestRuns    []TestRun   // Newest results of run_tests executed for this task
+	TestSummary TestSummary // Totals of every run, including ones dropped from TestRuns
+}
+
+// MaxTestRuns is how many test runs a task keeps in full
+const MaxTestRuns = 5
+
+// TestSummary totals the test runs recorded on a task
+type TestSummary struct {
+	Runs       int // Runs recorded
+	FailedRuns int // Runs with failing tests or errors
+}
+
+// addTestRun appends run, dropping the oldest runs beyond MaxTestRuns
+func (t *Task) addTestRun(run TestRun) {
+	t.TestSummary.Runs++
+	if run.Failed > 0 || len(run.Errors) > 0 {
+		t.TestSummary.FailedRuns++
+	}
+	t.TestRuns = append(t.TestRuns, run)
+	if extra := len(t.TestRuns) - MaxTestRuns; extra > 0 {
+		t.TestRuns = append([]TestRun(nil), t.TestRuns[extra:]...)
+	}
+	t.UpdatedAt = time.Now()
+}
+
+// TestRun records a run_tests execution against a task
+type TestRun struct {
+	Framework string             // go, pytest, jest
+	Command   string             // Command that was executed
+	AgentID   string             // Agent that ran the tests
+	Passed    int                // Passing tests
+	Failed    int                // Failing tests
+	Skipped   int                // Skipped tests
+	Duration  float64            // Seconds
+	Coverage  map[string]float64 // Percent by package or file
+	Errors    []string           // Build or collection errors
+	Tests     []TestCaseResult   // Per-test results
+	RanAt     time.Time
+}
+
+// TestCaseResult is the outcome of a single test in a TestRun
+type TestCaseResult struct {
+	Name     string
+	Suite    string
+	Status   string // pass, fail, skip
+	Duration float64
+	Message  string
+	Location string // file:line of the failure
 }
 
 // Session represents a work session
@@ -215,10 +323,71 @@ type LLMUsage struct {
 	PromptTokens     int       // Tokens in the prompt
 	CompletionTokens int       // Tokens in the completion
 	TotalTokens      int       // Total tokens used
+	CachedTokens     int       // Prompt tokens the provider read from its prompt cache
+	ReasoningTokens  int       // Completion tokens the model spent thinking
+	CacheHits        int       // Requests answered from the response cache
+	CacheMisses      int       // Cacheable requests sent to the provider
 	Provider         string    // Provider name (copilot, openrouter)
 	Model            string    // Model used (gpt-4o, claude-3.5-sonnet, etc.)
 }
 
+// add sums the counts of other into u
+func (u *LLMUsage) add(other *LLMUsage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.CachedTokens += other.CachedTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.CacheHits += other.CacheHits
+	u.CacheMisses += other.CacheMisses
+}
+
+// ResponseCacheStore defines the interface for the cache of LLM responses
+type ResponseCacheStore interface {
+	// GetCachedResponse retrieves the response cached under key, or nil if
+	// there is none or it has expired
+	GetCachedResponse(ctx context.Context, key string) (*CachedResponse, error)
+
+	// PutCachedResponse caches a response, then drops expired entries and
+	// the oldest ones beyond maxEntries (0 means no limit)
+	PutCachedResponse(ctx context.Context, entry *CachedResponse, maxEntries int) error
+}
+
+// CachedResponse is an LLM response kept for identical requests
+type CachedResponse struct {
+	Key       string    // Hash of the request
+	Data      []byte    // Encoded response
+	CreatedAt time.Time // When the response was cached
+	ExpiresAt time.Time // When the entry stops being served
+}
+
+// expired reports whether the entry is past its expiry at now
+func (c *CachedResponse) expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// cacheEvictions returns the keys of entries to drop from a response cache:
+// the expired ones, then the oldest beyond maxEntries
+func cacheEvictions(entries []*CachedResponse, now time.Time, maxEntries int) []string {
+	var evict []string
+	live := make([]*CachedResponse, 0, len(entries))
+	for _, entry := range entries {
+		if entry.expired(now) {
+			evict = append(evict, entry.Key)
+		} else {
+			live = append(live, entry)
+		}
+	}
+
+	if maxEntries > 0 && len(live) > maxEntries {
+		sort.SliceStable(live, func(i, j int) bool { return live[i].CreatedAt.Before(live[j].CreatedAt) })
+		for _, entry := range live[:len(live)-maxEntries] {
+			evict = append(evict, entry.Key)
+		}
+	}
+	return evict
+}
+
 // Store combines all storage interfaces
 type Store interface {
 	EventStore
@@ -227,6 +396,7 @@ type Store interface {
 	LockStore
 	SessionStore
 	LLMUsageStore
+	ResponseCacheStore
 
 	// Close closes the storage backend
 	Close() error
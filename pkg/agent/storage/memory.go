@@ -0,0 +1,582 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements the Store interface in memory.
+// It has the same semantics as BoltStore (records are copied in and out,
+// sequence IDs, ordering and errors match) and is meant for tests and
+// ephemeral runs that should not write .smith/ into the project.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	events   []*Event // In ID order
+	lastID   int64
+	agents   map[string]*Agent
+	tasks    map[string]*Task
+	locks    map[string]*FileLock
+	sessions map[string]*Session
+	usage    map[string]*LLMUsage
+	cache    map[string]*CachedResponse
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		agents:   make(map[string]*Agent),
+		tasks:    make(map[string]*Task),
+		locks:    make(map[string]*FileLock),
+		sessions: make(map[string]*Session),
+		usage:    make(map[string]*LLMUsage),
+		cache:    make(map[string]*CachedResponse),
+	}
+}
+
+// clone deep-copies a record through JSON, like a BoltStore round trip
+func clone[T any](v *T) (*T, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out T
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// cloneAll deep-copies a list of records
+func cloneAll[T any](values []*T) ([]*T, error) {
+	var out []*T
+	for _, v := range values {
+		c, err := clone(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// === EventStore Implementation ===
+
+func (s *MemoryStore) SaveEvent(ctx context.Context, event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.ID = s.lastID + 1
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	stored, err := clone(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	s.lastID = event.ID
+	s.events = append(s.events, stored)
+	return nil
+}
+
+func (s *MemoryStore) QueryEvents(ctx context.Context, filter EventFilter) ([]*Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultEventLimit
+	}
+
+	var events []*Event
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if filter.matches(s.events[i]) {
+			events = append(events, s.events[i])
+		}
+	}
+
+	// Without task, agent or type criteria BoltStore walks the time index
+	if filter.TaskID == nil && filter.AgentID == nil && len(filter.EventTypes) == 0 {
+		sort.SliceStable(events, func(i, j int) bool {
+			if !events[i].Timestamp.Equal(events[j].Timestamp) {
+				return events[i].Timestamp.After(events[j].Timestamp)
+			}
+			return events[i].ID > events[j].ID
+		})
+	}
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	out, err := cloneAll(events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+	return out, nil
+}
+
+// === AgentStore Implementation ===
+
+func (s *MemoryStore) RegisterAgent(ctx context.Context, agent *Agent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if agent.StartedAt.IsZero() {
+		agent.StartedAt = time.Now()
+	}
+	if agent.LastHeartbeat.IsZero() {
+		agent.LastHeartbeat = time.Now()
+	}
+
+	stored, err := clone(agent)
+	if err != nil {
+		return fmt.Errorf("failed to encode agent: %w", err)
+	}
+	s.agents[agent.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) UpdateHeartbeat(ctx context.Context, agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agent, ok := s.agents[agentID]
+	if !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	agent.LastHeartbeat = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) UnregisterAgent(ctx context.Context, agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.agents, agentID)
+	return nil
+}
+
+func (s *MemoryStore) GetAgent(ctx context.Context, agentID string) (*Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agent, ok := s.agents[agentID]
+	if !ok {
+		return nil, fmt.Errorf("agent not found: %s", agentID)
+	}
+	return clone(agent)
+}
+
+func (s *MemoryStore) ListAgents(ctx context.Context, role *string) ([]*Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var agents []*Agent
+	for _, agent := range s.agents {
+		if role != nil && agent.Role != *role {
+			continue
+		}
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+	return cloneAll(agents)
+}
+
+func (s *MemoryStore) MarkAgentDead(ctx context.Context, timeout time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	deadline := time.Now().Add(-timeout)
+	for _, agent := range s.agents {
+		if agent.Status == "active" && agent.LastHeartbeat.Before(deadline) {
+			agent.Status = "dead"
+			count++
+		}
+	}
+	return count, nil
+}
+
+// === TaskStore Implementation ===
+
+func (s *MemoryStore) CreateTask(ctx context.Context, task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task.StartedAt.IsZero() {
+		task.StartedAt = time.Now()
+	}
+	if task.UpdatedAt.IsZero() {
+		task.UpdatedAt = time.Now()
+	}
+	return s.putTask(task)
+}
+
+// putTask stores a copy of task; the caller holds the write lock
+func (s *MemoryStore) putTask(task *Task) error {
+	stored, err := clone(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+	s.tasks[task.TaskID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetTask(ctx context.Context, taskID string) (*Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok {
+		return nil, fmt.Errorf("task not found: %s", taskID)
+	}
+	return clone(task)
+}
+
+func (s *MemoryStore) UpdateTask(ctx context.Context, task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task.UpdatedAt = time.Now()
+	if err := s.putTask(task); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetTasks(ctx context.Context, taskIDs []string) (map[string]*Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks := make(map[string]*Task, len(taskIDs))
+	for _, id := range taskIDs {
+		task, ok := s.tasks[id]
+		if !ok {
+			continue // Missing tasks are left out of the result
+		}
+		c, err := clone(task)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode task: %w", err)
+		}
+		tasks[id] = c
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) ListTasks(ctx context.Context, status *string) ([]*Task, error) {
+	return s.filterTasks(func(t *Task) bool { return status == nil || t.Status == *status })
+}
+
+func (s *MemoryStore) ListTasksByRole(ctx context.Context, role string) ([]*Task, error) {
+	return s.filterTasks(func(t *Task) bool { return t.AgentRole == role })
+}
+
+// filterTasks returns copies of the tasks matching keep, ordered by task ID
+func (s *MemoryStore) filterTasks(keep func(*Task) bool) ([]*Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tasks []*Task
+	for _, task := range s.tasks {
+		if keep(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].TaskID < tasks[j].TaskID })
+	return cloneAll(tasks)
+}
+
+func (s *MemoryStore) ClaimTask(ctx context.Context, taskID, agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+
+	// Only claim if in backlog state
+	if task.Status != "backlog" {
+		return fmt.Errorf("task %s is not claimable", taskID)
+	}
+
+	task.AgentID = agentID
+	task.Status = "wip"
+	task.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) AddTestRun(ctx context.Context, taskID string, run TestRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+
+	task, err := clone(stored)
+	if err != nil {
+		return fmt.Errorf("failed to decode task: %w", err)
+	}
+	task.addTestRun(run)
+	return s.putTask(task)
+}
+
+func (s *MemoryStore) GetTaskStats(ctx context.Context) (*TaskStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &TaskStats{}
+	for _, task := range s.tasks {
+		switch task.Status {
+		case "backlog":
+			stats.Backlog++
+		case "wip":
+			stats.WIP++
+		case "review":
+			stats.Review++
+		case "done":
+			stats.Done++
+		}
+	}
+	return stats, nil
+}
+
+// === LockStore Implementation ===
+
+func (s *MemoryStore) AcquireLocks(ctx context.Context, locks []*FileLock) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check for existing locks first
+	for _, lock := range locks {
+		if _, ok := s.locks[lock.FilePath]; ok {
+			return fmt.Errorf("file %s is already locked", lock.FilePath)
+		}
+	}
+
+	// Acquire all locks atomically
+	stored := make([]*FileLock, 0, len(locks))
+	for _, lock := range locks {
+		if lock.LockedAt.IsZero() {
+			lock.LockedAt = time.Now()
+		}
+		c, err := clone(lock)
+		if err != nil {
+			return fmt.Errorf("failed to encode lock: %w", err)
+		}
+		stored = append(stored, c)
+	}
+	for _, lock := range stored {
+		s.locks[lock.FilePath] = lock
+	}
+	return nil
+}
+
+func (s *MemoryStore) ReleaseLocks(ctx context.Context, agentID string, files []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(files) == 0 {
+		// Release all locks for this agent
+		for path, lock := range s.locks {
+			if lock.AgentID == agentID {
+				delete(s.locks, path)
+			}
+		}
+		return nil
+	}
+
+	for _, file := range files {
+		// Only delete if owned by this agent
+		if lock, ok := s.locks[file]; ok && lock.AgentID == agentID {
+			delete(s.locks, file)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetLocks(ctx context.Context) ([]*FileLock, error) {
+	return s.filterLocks(func(*FileLock) bool { return true })
+}
+
+func (s *MemoryStore) GetLocksForAgent(ctx context.Context, agentID string) ([]*FileLock, error) {
+	return s.filterLocks(func(l *FileLock) bool { return l.AgentID == agentID })
+}
+
+// filterLocks returns copies of the locks matching keep, ordered by file path
+func (s *MemoryStore) filterLocks(keep func(*FileLock) bool) ([]*FileLock, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var locks []*FileLock
+	for _, lock := range s.locks {
+		if keep(lock) {
+			locks = append(locks, lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].FilePath < locks[j].FilePath })
+	return cloneAll(locks)
+}
+
+// === SessionStore Implementation ===
+
+func (s *MemoryStore) CreateSession(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := clone(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	s.sessions[session.SessionID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	return clone(session)
+}
+
+func (s *MemoryStore) UpdateSession(ctx context.Context, session *Session) error {
+	return s.CreateSession(ctx, session) // Same as create - upsert
+}
+
+func (s *MemoryStore) ListSessions(ctx context.Context, limit int) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].SessionID < sessions[j].SessionID })
+	sortSessionsByActivity(sessions)
+
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return cloneAll(sessions)
+}
+
+func (s *MemoryStore) ArchiveSession(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	session.Status = "archived"
+	return nil
+}
+
+func (s *MemoryStore) GetSessionTasks(ctx context.Context, sessionID string) ([]*Task, error) {
+	return s.filterTasks(func(t *Task) bool { return t.SessionID == sessionID })
+}
+
+// === LLMUsageStore Implementation ===
+
+func (s *MemoryStore) SaveUsage(ctx context.Context, usage *LLMUsage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if usage.Timestamp.IsZero() {
+		usage.Timestamp = time.Now()
+	}
+
+	// One entry per task
+	stored, err := clone(usage)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage: %w", err)
+	}
+	s.usage[usage.TaskID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetUsage(ctx context.Context, taskID string) (*LLMUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usage, ok := s.usage[taskID]
+	if !ok {
+		return nil, nil // No usage recorded for this task
+	}
+	return clone(usage)
+}
+
+func (s *MemoryStore) GetSessionUsage(ctx context.Context, sessionID string) (*LLMUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := &LLMUsage{SessionID: sessionID}
+	for _, usage := range s.usage {
+		if usage.SessionID == sessionID {
+			total.add(usage)
+		}
+	}
+	return total, nil
+}
+
+func (s *MemoryStore) GetTotalUsage(ctx context.Context) (*LLMUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := &LLMUsage{}
+	for _, usage := range s.usage {
+		total.add(usage)
+	}
+	return total, nil
+}
+
+// === ResponseCacheStore Implementation ===
+
+func (s *MemoryStore) GetCachedResponse(ctx context.Context, key string) (*CachedResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.cache[key]
+	if !ok || entry.expired(time.Now()) {
+		return nil, nil
+	}
+	return clone(entry)
+}
+
+func (s *MemoryStore) PutCachedResponse(ctx context.Context, entry *CachedResponse, maxEntries int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	stored, err := clone(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached response: %w", err)
+	}
+	s.cache[entry.Key] = stored
+
+	entries := make([]*CachedResponse, 0, len(s.cache))
+	for _, e := range s.cache {
+		entries = append(entries, e)
+	}
+	for _, key := range cacheEvictions(entries, time.Now(), maxEntries) {
+		delete(s.cache, key)
+	}
+	return nil
+}
+
+// Close is a no-op; the data lives as long as the store
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// MetaBucket holds database metadata such as the schema version
+var MetaBucket = []byte("meta")
+
+var schemaVersionKey = []byte("schema_version")
+
+// Migration upgrades the database schema by one version
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(tx *bbolt.Tx) error
+}
+
+// migrations lists every schema change in order.
+// Append new migrations to the end; never edit or reorder released ones.
+var migrations = []Migration{
+	{Version: 1, Description: "create core buckets", Apply: createCoreBuckets},
+	{Version: 2, Description: "build secondary indexes for tasks, events and locks", Apply: rebuildIndexes},
+	{Version: 3, Description: "create the LLM response cache bucket", Apply: createResponseCacheBucket},
+	{Version: 4, Description: "index events by ID", Apply: rebuildIndexes},
+}
+
+// SchemaVersion is the schema version this build reads and writes
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// MigrationResult describes the migrations applied (or pending, for a dry run) to a database
+type MigrationResult struct {
+	Path    string
+	From    int
+	To      int
+	Applied []Migration
+	Backup  string // Copy of the database taken before migrating, empty if none was needed
+}
+
+// createCoreBuckets creates the primary data buckets
+func createCoreBuckets(tx *bbolt.Tx) error {
+	buckets := [][]byte{
+		EventsBucket,
+		FileLocksBucket,
+		TasksBucket,
+		AgentsBucket,
+		SessionsBucket,
+		SequenceBucket,
+		LLMUsageBucket,
+	}
+	for _, bucket := range buckets {
+		if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+// createResponseCacheBucket creates the bucket of cached LLM responses
+func createResponseCacheBucket(tx *bbolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists(ResponseCacheBucket); err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", ResponseCacheBucket, err)
+	}
+	return nil
+}
+
+// schemaVersion returns the recorded schema version, 0 for databases that predate versioning
+func schemaVersion(tx *bbolt.Tx) (int, error) {
+	b := tx.Bucket(MetaBucket)
+	if b == nil {
+		return 0, nil
+	}
+	data := b.Get(schemaVersionKey)
+	if data == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", data, err)
+	}
+	return version, nil
+}
+
+// setSchemaVersion records the schema version
+func setSchemaVersion(tx *bbolt.Tx, version int) error {
+	b, err := tx.CreateBucketIfNotExists(MetaBucket)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", MetaBucket, err)
+	}
+	return b.Put(schemaVersionKey, []byte(strconv.Itoa(version)))
+}
+
+// pendingMigrations returns the migrations needed to bring version up to date
+func pendingMigrations(version int) ([]Migration, error) {
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("database schema version %d is newer than supported version %d (upgrade smith)", version, SchemaVersion)
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// isEmpty reports whether the database has no buckets yet
+func isEmpty(tx *bbolt.Tx) bool {
+	empty := true
+	_ = tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+		empty = false
+		return errors.New("stop")
+	})
+	return empty
+}
+
+// migrate brings an open database up to SchemaVersion. Existing databases are
+// backed up next to dbPath first; all pending migrations run in one transaction.
+func migrate(db *bbolt.DB, dbPath string) (*MigrationResult, error) {
+	result := &MigrationResult{Path: dbPath, To: SchemaVersion}
+
+	var fresh bool
+	err := db.View(func(tx *bbolt.Tx) error {
+		var err error
+		fresh = isEmpty(tx)
+		result.From, err = schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		result.Applied, err = pendingMigrations(result.From)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+
+	if !fresh {
+		result.Backup, err = backupDatabase(db, dbPath, result.From)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, m := range result.Applied {
+			if err := m.Apply(tx); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+			}
+		}
+		return setSchemaVersion(tx, SchemaVersion)
+	})
+	if err != nil {
+		if result.Backup != "" {
+			return nil, fmt.Errorf("%w (database unchanged, backup at %s)", err, result.Backup)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// backupDatabase writes a consistent copy of the database before migrating it
+func backupDatabase(db *bbolt.DB, dbPath string, version int) (string, error) {
+	backup := fmt.Sprintf("%s.v%d-%s.bak", dbPath, version, time.Now().Format("20060102-150405"))
+	err := db.View(func(tx *bbolt.Tx) error {
+		return tx.CopyFile(backup, 0600)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
+	return backup, nil
+}
+
+// MigrateDatabase migrates the database at dbPath to SchemaVersion.
+// With dryRun, it only reports the pending migrations and leaves the file untouched.
+func MigrateDatabase(dbPath string, dryRun bool) (*MigrationResult, error) {
+	if !dryRun {
+		db, err := initBoltDatabase(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = db.Close() }()
+		return db.migration, nil
+	}
+
+	result := &MigrationResult{Path: dbPath, To: SchemaVersion}
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		result.Applied = migrations
+		return result, nil
+	}
+
+	db, err := openBolt(dbPath, &bbolt.Options{
+		Timeout:  5 * time.Second,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		var err error
+		result.From, err = schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		result.Applied, err = pendingMigrations(result.From)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ProjectDatabasePath returns the database path for a project root
+func ProjectDatabasePath(projectRoot string) string {
+	return filepath.Join(projectRoot, ".smith", "smith.db")
+}
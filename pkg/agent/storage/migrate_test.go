@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// createLegacyDatabase writes a database as created before schema versioning
+func createLegacyDatabase(t *testing.T, path string) {
+	t.Helper()
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucket(TasksBucket)
+		if err != nil {
+			return err
+		}
+		data, _ := json.Marshal(&Task{TaskID: "task-1", Status: "todo", AgentRole: "architect"})
+		return b.Put([]byte("task-1"), data)
+	})
+	if err != nil {
+		t.Fatalf("failed to seed database: %v", err)
+	}
+}
+
+func TestMigrate_NewDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smith.db")
+	db, err := initBoltDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if db.migration.Backup != "" {
+		t.Errorf("expected no backup for a new database, got %s", db.migration.Backup)
+	}
+	_ = db.View(func(tx *bbolt.Tx) error {
+		if version, _ := schemaVersion(tx); version != SchemaVersion {
+			t.Errorf("expected schema version %d, got %d", SchemaVersion, version)
+		}
+		return nil
+	})
+}
+
+func TestMigrate_LegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smith.db")
+	createLegacyDatabase(t, path)
+
+	// Dry run reports everything pending and changes nothing
+	plan, err := MigrateDatabase(path, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if plan.From != 0 || len(plan.Applied) != len(migrations) {
+		t.Fatalf("expected all migrations pending from v0, got %+v", plan)
+	}
+	if backups, _ := filepath.Glob(path + ".*.bak"); len(backups) != 0 {
+		t.Errorf("dry run should not create a backup, got %v", backups)
+	}
+
+	result, err := MigrateDatabase(path, false)
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if result.To != SchemaVersion || len(result.Applied) != len(migrations) {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !strings.HasPrefix(result.Backup, path+".v0-") {
+		t.Errorf("unexpected backup path %q", result.Backup)
+	}
+	if _, err := os.Stat(result.Backup); err != nil {
+		t.Errorf("expected backup file: %v", err)
+	}
+
+	// Migrated data is queryable through the indexes
+	db, err := initBoltDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	store := NewBoltStore(db.DB)
+	defer func() { _ = store.Close() }()
+
+	if len(db.migration.Applied) != 0 {
+		t.Errorf("expected no pending migrations after migrating, got %d", len(db.migration.Applied))
+	}
+	tasks, err := store.ListTasksByRole(context.Background(), "architect")
+	if err != nil || len(tasks) != 1 {
+		t.Errorf("expected migrated task to be indexed, got %d (%v)", len(tasks), err)
+	}
+}
+
+func TestMigrate_NewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "smith.db")
+	db, err := initBoltDatabase(path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	_ = db.Update(func(tx *bbolt.Tx) error {
+		return setSchemaVersion(tx, SchemaVersion+1)
+	})
+	_ = db.Close()
+
+	if _, err := initBoltDatabase(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected error opening a newer schema, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// RetentionPolicy controls which events are kept in the database.
+// An event is pruned if either limit excludes it; zero values keep everything.
+type RetentionPolicy struct {
+	MaxAge        time.Duration // Prune events older than this
+	EventsPerTask int           // Keep only the newest N events of each task
+}
+
+// IsZero reports whether the policy keeps every event
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxAge <= 0 && p.EventsPerTask <= 0
+}
+
+// PruneResult describes the events removed by PruneEvents
+type PruneResult struct {
+	Pruned  int
+	Archive string // NDJSON.gz file holding the pruned events, empty if none were pruned
+}
+
+// PruneEvents deletes events outside the retention policy. Pruned events are
+// first written to a gzipped NDJSON file in archiveDir, one event per line.
+func (s *BoltStore) PruneEvents(ctx context.Context, policy RetentionPolicy, archiveDir string) (*PruneResult, error) {
+	result := &PruneResult{}
+	if policy.IsZero() {
+		return result, nil
+	}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		ids, err := expiredEventIDs(tx, policy, time.Now())
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		b := tx.Bucket(EventsBucket)
+		events := make([]*Event, 0, len(ids))
+		for _, id := range ids {
+			data := b.Get(eventKey(id))
+			if data == nil {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				return fmt.Errorf("failed to decode event %d: %w", id, err)
+			}
+			events = append(events, &event)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		// Archive before deleting; the archive is removed again if the transaction fails
+		result.Archive, err = writeEventArchive(archiveDir, events)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := deleteEvent(tx, event); err != nil {
+				return err
+			}
+		}
+		result.Pruned = len(events)
+		return nil
+	})
+	if err != nil {
+		if result.Archive != "" {
+			_ = os.Remove(result.Archive)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// expiredEventIDs returns the IDs of events outside the policy, in ascending order
+func expiredEventIDs(tx *bbolt.Tx, policy RetentionPolicy, now time.Time) ([]int64, error) {
+	expired := make(map[int64]bool)
+
+	if policy.MaxAge > 0 {
+		idx, err := indexBucket(tx, EventsByTimeIndex)
+		if err != nil {
+			return nil, err
+		}
+		cutoff := eventTimeKey(now.Add(-policy.MaxAge), 0)
+		c := idx.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+			expired[int64(binary.BigEndian.Uint64(k[8:]))] = true
+		}
+	}
+
+	if policy.EventsPerTask > 0 {
+		idx, err := indexBucket(tx, EventsByTaskIndex)
+		if err != nil {
+			return nil, err
+		}
+		// Entries are grouped by task and ordered by ID within each group
+		var task []byte
+		var ids []int64
+		flush := func() {
+			if len(ids) > policy.EventsPerTask {
+				for _, id := range ids[:len(ids)-policy.EventsPerTask] {
+					expired[id] = true
+				}
+			}
+			ids = ids[:0]
+		}
+		err = idx.ForEach(func(k, _ []byte) error {
+			sep := bytes.IndexByte(k, 0)
+			if sep < 0 {
+				return nil
+			}
+			if !bytes.Equal(k[:sep], task) {
+				flush()
+				task = append(task[:0], k[:sep]...)
+			}
+			ids = append(ids, int64(binary.BigEndian.Uint64(k[sep+1:])))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		flush()
+	}
+
+	ids := make([]int64, 0, len(expired))
+	for id := range expired {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// writeEventArchive writes events to archiveDir/events-<first>-<last>.ndjson.gz
+func writeEventArchive(archiveDir string, events []*Event) (string, error) {
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(archiveDir, fmt.Sprintf("events-%d-%d.ndjson.gz", events[0].ID, events[len(events)-1].ID))
+	tmp, err := os.CreateTemp(archiveDir, ".events-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	gz := gzip.NewWriter(tmp)
+	w := bufio.NewWriter(gz)
+	enc := json.NewEncoder(w)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			_ = tmp.Close()
+			return "", fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	return path, nil
+}
+
+// ReadEventArchive reads the events in an archive written by PruneEvents
+func ReadEventArchive(path string) ([]*Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	var events []*Event
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var event Event
+		if err := dec.Decode(&event); err != nil {
+			return nil, fmt.Errorf("failed to decode archived event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
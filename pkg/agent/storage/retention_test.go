@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestPruneEvents(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+
+	// 2 tasks x 5 events; the first 4 events are 10 days old
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		ts := now.Add(-time.Duration(10-i) * time.Minute)
+		if i < 4 {
+			ts = now.Add(-10 * 24 * time.Hour)
+		}
+		event := &Event{
+			Timestamp: ts,
+			AgentID:   "agent-1",
+			EventType: "file_edited",
+			TaskID:    strPtr(fmt.Sprintf("task-%d", i%2)),
+		}
+		if err := store.SaveEvent(ctx, event); err != nil {
+			t.Fatalf("SaveEvent failed: %v", err)
+		}
+	}
+
+	result, err := store.PruneEvents(ctx, RetentionPolicy{MaxAge: 7 * 24 * time.Hour, EventsPerTask: 2}, archiveDir)
+	if err != nil {
+		t.Fatalf("PruneEvents failed: %v", err)
+	}
+	// Age prunes 1-4, per-task keeps 9,7 (task-0) and 10,8 (task-1)
+	if result.Pruned != 6 {
+		t.Fatalf("expected 6 pruned events, got %d", result.Pruned)
+	}
+	if filepath.Base(result.Archive) != "events-1-6.ndjson.gz" {
+		t.Errorf("unexpected archive name %q", result.Archive)
+	}
+
+	archived, err := ReadEventArchive(result.Archive)
+	if err != nil {
+		t.Fatalf("ReadEventArchive failed: %v", err)
+	}
+	if len(archived) != 6 || archived[0].ID != 1 || *archived[5].TaskID != "task-1" {
+		t.Errorf("unexpected archived events: %d", len(archived))
+	}
+
+	// Remaining events are still queryable and the indexes no longer point at pruned ones
+	events, err := store.QueryEvents(ctx, EventFilter{AgentID: strPtr("agent-1")})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if len(events) != 4 || events[0].ID != 10 || events[3].ID != 7 {
+		t.Errorf("expected events 10..7 to remain, got %d", len(events))
+	}
+
+	// Nothing left to prune
+	result, err = store.PruneEvents(ctx, RetentionPolicy{EventsPerTask: 2}, archiveDir)
+	if err != nil || result.Pruned != 0 || result.Archive != "" {
+		t.Errorf("expected nothing to prune, got %+v (%v)", result, err)
+	}
+}
+
+func TestCompactProject(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	s, err := InitProjectStorage(root)
+	if err != nil {
+		t.Fatalf("InitProjectStorage failed: %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for i := 0; i < 2000; i++ {
+		event := &Event{Timestamp: old, AgentID: "agent-1", EventType: "file_edited", Data: fmt.Sprintf("%0200d", i)}
+		if err := s.SaveEvent(ctx, event); err != nil {
+			t.Fatalf("SaveEvent failed: %v", err)
+		}
+	}
+	_ = s.Close()
+
+	result, err := CompactProject(root, RetentionPolicy{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("CompactProject failed: %v", err)
+	}
+	if result.Pruned != 2000 {
+		t.Errorf("expected 2000 pruned events, got %d", result.Pruned)
+	}
+	if result.SizeAfter >= result.SizeBefore {
+		t.Errorf("expected database to shrink, %d -> %d", result.SizeBefore, result.SizeAfter)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".smith", "archive", "events-1-2000.ndjson.gz")); err != nil {
+		t.Errorf("expected archive file: %v", err)
+	}
+
+	stats, err := GetDatabaseStats(ProjectDatabasePath(root))
+	if err != nil {
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.SchemaVersion != SchemaVersion {
+		t.Errorf("expected schema v%d after compaction, got v%d", SchemaVersion, stats.SchemaVersion)
+	}
+	for _, b := range stats.Buckets {
+		if b.Name == "events" && b.Records != 0 {
+			t.Errorf("expected empty events bucket, got %d records", b.Records)
+		}
+	}
+}
+
+func TestOpenBoltAfterReplace(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "smith.db")
+	held, err := initBoltDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("initBoltDatabase failed: %v", err)
+	}
+
+	// Wait for the lock while the file is replaced, as compaction does
+	opened := make(chan *BoltDB, 1)
+	go func() {
+		db, err := initBoltDatabase(dbPath)
+		if err != nil {
+			t.Errorf("initBoltDatabase while held failed: %v", err)
+		}
+		opened <- db
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	replacement, err := initBoltDatabase(dbPath + ".compact")
+	if err != nil {
+		t.Fatalf("initBoltDatabase failed: %v", err)
+	}
+	_ = replacement.Close()
+	if err := os.Rename(dbPath+".compact", dbPath); err != nil {
+		t.Fatal(err)
+	}
+	_ = held.Close()
+
+	db := <-opened
+	if db == nil {
+		return
+	}
+	defer func() { _ = db.Close() }()
+
+	// Only the replacement has the marker
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("marker"))
+		return err
+	}); err != nil {
+		t.Fatalf("writing to the reopened database failed: %v", err)
+	}
+	_ = db.Close()
+	check, err := bbolt.Open(dbPath, 0600, &bbolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = check.Close() }()
+	if err := check.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte("marker")) == nil {
+			return fmt.Errorf("write went to the replaced file")
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,790 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// goLoadMode loads syntax and type information for the requested packages.
+// Dependencies come from export data but share one type universe, so objects
+// can be compared across packages.
+const goLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports |
+	packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo
+
+// GoSymbol is a declared Go identifier
+type GoSymbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"` // func, method, type, interface, var, const, field
+	Package   string `json:"package"`
+	Receiver  string `json:"receiver,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Exported  bool   `json:"exported"`
+	Position  string `json:"position"` // file:line
+}
+
+// GoReference is a use of an identifier
+type GoReference struct {
+	Position string `json:"position"` // file:line
+	Kind     string `json:"kind"`     // definition or reference
+	Context  string `json:"context"`  // Enclosing function (pkg.Func or pkg.Type.Method)
+}
+
+// GoCall is a call site of a function
+type GoCall struct {
+	Caller   string `json:"caller"`
+	Position string `json:"position"` // file:line
+}
+
+// goCode holds the loaded packages for a single tool call
+type goCode struct {
+	workDir string
+	fset    *token.FileSet
+	pkgs    []*packages.Package
+}
+
+// loadGoCode loads Go packages matching pattern (default ./...) from workDir
+func loadGoCode(ctx context.Context, workDir string, params map[string]interface{}) (*goCode, error) {
+	pattern, _ := params["package"].(string)
+	if pattern == "" {
+		pattern = "./..."
+	}
+
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     workDir,
+		Mode:    goLoadMode,
+		Fset:    fset,
+	}
+
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("loading packages: %w", err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages match %s", pattern)
+	}
+
+	// Type errors are tolerated (partial info is still useful), but a package
+	// that failed to load entirely is reported
+	for _, pkg := range pkgs {
+		if pkg.Types == nil || len(pkg.Syntax) == 0 {
+			if len(pkg.Errors) > 0 {
+				return nil, fmt.Errorf("loading %s: %v", pkg.PkgPath, pkg.Errors[0])
+			}
+		}
+	}
+
+	return &goCode{workDir: workDir, fset: fset, pkgs: pkgs}, nil
+}
+
+// position formats a token position as file:line, relative to workDir when possible
+func (g *goCode) position(pos token.Pos) string {
+	p := g.fset.Position(pos)
+	if !p.IsValid() {
+		return ""
+	}
+	file := p.Filename
+	if rel, err := filepath.Rel(g.workDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+	return fmt.Sprintf("%s:%d", file, p.Line)
+}
+
+// symbol describes a types.Object
+func (g *goCode) symbol(obj types.Object) GoSymbol {
+	sym := GoSymbol{
+		Name:     obj.Name(),
+		Kind:     objectKind(obj),
+		Exported: obj.Exported(),
+		Position: g.position(obj.Pos()),
+	}
+	if obj.Pkg() != nil {
+		sym.Package = obj.Pkg().Path()
+	}
+
+	qualifier := types.RelativeTo(obj.Pkg())
+	switch o := obj.(type) {
+	case *types.Func:
+		sig := o.Type().(*types.Signature)
+		if recv := sig.Recv(); recv != nil {
+			sym.Receiver = types.TypeString(recv.Type(), qualifier)
+		}
+		sym.Signature = types.TypeString(sig, qualifier)
+	case *types.TypeName:
+		sym.Signature = types.TypeString(o.Type().Underlying(), qualifier)
+	default:
+		sym.Signature = types.TypeString(obj.Type(), qualifier)
+	}
+
+	return sym
+}
+
+func objectKind(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Func:
+		if o.Type().(*types.Signature).Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.TypeName:
+		if types.IsInterface(o.Type()) {
+			return "interface"
+		}
+		return "type"
+	case *types.Const:
+		return "const"
+	case *types.Var:
+		if o.IsField() {
+			return "field"
+		}
+		return "var"
+	case *types.PkgName:
+		return "package"
+	default:
+		return "object"
+	}
+}
+
+// origin returns the generic origin of instantiated functions and fields
+func origin(obj types.Object) types.Object {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin()
+	case *types.Var:
+		return o.Origin()
+	}
+	return obj
+}
+
+// resolve finds the object named by the "symbol" parameter, or by the
+// identifier at "file"/"line" (and optional "column")
+func (g *goCode) resolve(params map[string]interface{}) (types.Object, error) {
+	if file, ok := params["file"].(string); ok && file != "" {
+		line := intParam(params, "line")
+		if line <= 0 {
+			return nil, fmt.Errorf("line parameter is required with file")
+		}
+		return g.resolveAt(file, line, intParam(params, "column"), stringParam(params, "symbol"))
+	}
+
+	symbol := stringParam(params, "symbol")
+	if symbol == "" {
+		return nil, fmt.Errorf("symbol or file/line parameter is required")
+	}
+
+	objs := g.lookup(symbol)
+	switch len(objs) {
+	case 0:
+		return nil, fmt.Errorf("symbol not found: %s", symbol)
+	case 1:
+		return objs[0], nil
+	default:
+		var matches []string
+		for _, obj := range objs {
+			matches = append(matches, fmt.Sprintf("%s.%s (%s)", obj.Pkg().Path(), obj.Name(), g.position(obj.Pos())))
+		}
+		return nil, fmt.Errorf("symbol %s is ambiguous, qualify it with the package: %s", symbol, strings.Join(matches, ", "))
+	}
+}
+
+// lookup finds objects by name: Name, pkg.Name, Type.Member, pkg.Type.Member,
+// where pkg is a package name or import path
+func (g *goCode) lookup(symbol string) []types.Object {
+	prefix := ""
+	if idx := strings.LastIndex(symbol, "/"); idx >= 0 {
+		prefix, symbol = symbol[:idx+1], symbol[idx+1:]
+	}
+	parts := strings.Split(symbol, ".")
+	parts[0] = prefix + parts[0]
+
+	seen := make(map[types.Object]bool)
+	var objs []types.Object
+	add := func(obj types.Object) {
+		if obj != nil && !seen[obj] {
+			seen[obj] = true
+			objs = append(objs, obj)
+		}
+	}
+
+	for _, pkg := range g.pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		matchesPkg := pkg.Name == parts[0] || pkg.PkgPath == parts[0] || strings.HasSuffix(pkg.PkgPath, "/"+parts[0])
+
+		switch len(parts) {
+		case 1:
+			if prefix == "" {
+				add(scope.Lookup(parts[0]))
+			}
+		case 2:
+			if matchesPkg {
+				add(scope.Lookup(parts[1]))
+			}
+			if prefix == "" {
+				add(lookupMember(pkg.Types, scope.Lookup(parts[0]), parts[1]))
+			}
+		case 3:
+			if matchesPkg {
+				add(lookupMember(pkg.Types, scope.Lookup(parts[1]), parts[2]))
+			}
+		}
+	}
+
+	return objs
+}
+
+// lookupMember finds a method or field of a named type
+func lookupMember(pkg *types.Package, obj types.Object, name string) types.Object {
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	member, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg, name)
+	return member
+}
+
+// resolveAt finds the object for the identifier at file:line[:column]
+func (g *goCode) resolveAt(file string, line, column int, name string) (types.Object, error) {
+	absFile := resolvePath(g.workDir, file)
+
+	for _, pkg := range g.pkgs {
+		for _, f := range pkg.Syntax {
+			if g.fset.Position(f.Pos()).Filename != absFile {
+				continue
+			}
+
+			var found types.Object
+			ast.Inspect(f, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok || found != nil {
+					return found == nil
+				}
+				start, end := g.fset.Position(ident.Pos()), g.fset.Position(ident.End())
+				if start.Line != line {
+					return true
+				}
+				if column > 0 && (column < start.Column || column >= end.Column) {
+					return true
+				}
+				if name != "" && ident.Name != name {
+					return true
+				}
+				if obj := pkg.TypesInfo.Defs[ident]; obj != nil {
+					found = obj
+				} else if obj := pkg.TypesInfo.Uses[ident]; obj != nil {
+					found = obj
+				}
+				return true
+			})
+
+			if found == nil {
+				return nil, fmt.Errorf("no identifier found at %s:%d", file, line)
+			}
+			return found, nil
+		}
+	}
+
+	return nil, fmt.Errorf("file not found in loaded packages: %s", file)
+}
+
+// enclosingFunc names the function declaration containing pos
+func enclosingFunc(pkg *packages.Package, file *ast.File, pos token.Pos) string {
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || pos < fn.Pos() || pos > fn.End() {
+			continue
+		}
+		if fn.Recv != nil && len(fn.Recv.List) > 0 {
+			return fmt.Sprintf("%s.%s.%s", pkg.Name, receiverName(fn.Recv.List[0].Type), fn.Name.Name)
+		}
+		return fmt.Sprintf("%s.%s", pkg.Name, fn.Name.Name)
+	}
+	return pkg.Name + ".<package>"
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+func stringParam(params map[string]interface{}, name string) string {
+	s, _ := params[name].(string)
+	return s
+}
+
+func intParam(params map[string]interface{}, name string) int {
+	switch v := params[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// jsonResult wraps data as a successful ToolResult with JSON output
+func jsonResult(data interface{}) (*ToolResult, error) {
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to encode result: %v", err),
+		}, err
+	}
+	return &ToolResult{Success: true, Output: string(out), Data: data}, nil
+}
+
+func failedResult(err error) (*ToolResult, error) {
+	return &ToolResult{Success: false, Error: err.Error()}, err
+}
+
+// validateGoTarget checks the symbol or file/line parameters
+func validateGoTarget(workDir string, params map[string]interface{}) error {
+	if file := stringParam(params, "file"); file != "" {
+		if intParam(params, "line") <= 0 {
+			return fmt.Errorf("line parameter is required with file")
+		}
+		return validatePath(workDir, file)
+	}
+	if stringParam(params, "symbol") == "" {
+		return fmt.Errorf("symbol or file/line parameter is required")
+	}
+	return nil
+}
+
+// GoSymbolsTool lists the declarations in Go packages
+type GoSymbolsTool struct {
+	workDir string
+}
+
+// NewGoSymbolsTool creates a new GoSymbolsTool
+func NewGoSymbolsTool(workDir string) *GoSymbolsTool {
+	return &GoSymbolsTool{workDir: workDir}
+}
+
+func (t *GoSymbolsTool) Name() string {
+	return "go_symbols"
+}
+
+func (t *GoSymbolsTool) Description() string {
+	return "List functions, types, methods, vars and consts declared in Go packages"
+}
+
+func (t *GoSymbolsTool) Validate(params map[string]interface{}) error {
+	return nil // All parameters are optional
+}
+
+func (t *GoSymbolsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	g, err := loadGoCode(ctx, t.workDir, params)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	query := strings.ToLower(stringParam(params, "query"))
+	exportedOnly, _ := params["exported_only"].(bool)
+
+	var symbols []GoSymbol
+	for _, pkg := range g.pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			var objs []types.Object
+			objs = append(objs, obj)
+
+			// Include methods with their type
+			if tn, ok := obj.(*types.TypeName); ok && !tn.IsAlias() {
+				if named, ok := tn.Type().(*types.Named); ok {
+					for i := 0; i < named.NumMethods(); i++ {
+						objs = append(objs, named.Method(i))
+					}
+				}
+			}
+
+			for _, o := range objs {
+				if exportedOnly && !o.Exported() {
+					continue
+				}
+				if query != "" && !strings.Contains(strings.ToLower(o.Name()), query) {
+					continue
+				}
+				symbols = append(symbols, g.symbol(o))
+			}
+		}
+	}
+
+	return jsonResult(map[string]interface{}{
+		"symbols": symbols,
+		"count":   len(symbols),
+	})
+}
+
+func (t *GoSymbolsTool) RequiresConfirmation(level SafetyLevel) bool {
+	return false // Reading only, no confirmation needed
+}
+
+// GoDefinitionTool finds where a Go identifier is declared
+type GoDefinitionTool struct {
+	workDir string
+}
+
+// NewGoDefinitionTool creates a new GoDefinitionTool
+func NewGoDefinitionTool(workDir string) *GoDefinitionTool {
+	return &GoDefinitionTool{workDir: workDir}
+}
+
+func (t *GoDefinitionTool) Name() string {
+	return "go_definition"
+}
+
+func (t *GoDefinitionTool) Description() string {
+	return "Find the definition of a Go symbol (by name like pkg.Type.Method, or by file/line)"
+}
+
+func (t *GoDefinitionTool) Validate(params map[string]interface{}) error {
+	return validateGoTarget(t.workDir, params)
+}
+
+func (t *GoDefinitionTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	g, err := loadGoCode(ctx, t.workDir, params)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	obj, err := g.resolve(params)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	return jsonResult(map[string]interface{}{
+		"definition": g.symbol(obj),
+	})
+}
+
+func (t *GoDefinitionTool) RequiresConfirmation(level SafetyLevel) bool {
+	return false // Reading only, no confirmation needed
+}
+
+// GoReferencesTool finds every use of a Go identifier
+type GoReferencesTool struct {
+	workDir string
+}
+
+// NewGoReferencesTool creates a new GoReferencesTool
+func NewGoReferencesTool(workDir string) *GoReferencesTool {
+	return &GoReferencesTool{workDir: workDir}
+}
+
+func (t *GoReferencesTool) Name() string {
+	return "go_references"
+}
+
+func (t *GoReferencesTool) Description() string {
+	return "Find all references to a Go symbol across the loaded packages"
+}
+
+func (t *GoReferencesTool) Validate(params map[string]interface{}) error {
+	return validateGoTarget(t.workDir, params)
+}
+
+func (t *GoReferencesTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	g, err := loadGoCode(ctx, t.workDir, params)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	target, err := g.resolve(params)
+	if err != nil {
+		return failedResult(err)
+	}
+	target = origin(target)
+
+	var refs []GoReference
+	for _, pkg := range g.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				kind := "reference"
+				obj := pkg.TypesInfo.Uses[ident]
+				if obj == nil {
+					obj = pkg.TypesInfo.Defs[ident]
+					kind = "definition"
+				}
+				if obj == nil || origin(obj) != target {
+					return true
+				}
+				refs = append(refs, GoReference{
+					Position: g.position(ident.Pos()),
+					Kind:     kind,
+					Context:  enclosingFunc(pkg, file, ident.Pos()),
+				})
+				return true
+			})
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Position < refs[j].Position })
+
+	return jsonResult(map[string]interface{}{
+		"symbol":     g.symbol(target),
+		"references": refs,
+		"count":      len(refs),
+	})
+}
+
+func (t *GoReferencesTool) RequiresConfirmation(level SafetyLevel) bool {
+	return false // Reading only, no confirmation needed
+}
+
+// GoImplementationsTool shows a type's method set and the interfaces it implements,
+// or the types implementing an interface
+type GoImplementationsTool struct {
+	workDir string
+}
+
+// NewGoImplementationsTool creates a new GoImplementationsTool
+func NewGoImplementationsTool(workDir string) *GoImplementationsTool {
+	return &GoImplementationsTool{workDir: workDir}
+}
+
+func (t *GoImplementationsTool) Name() string {
+	return "go_implementations"
+}
+
+func (t *GoImplementationsTool) Description() string {
+	return "Show a Go type's method set and the interfaces it implements, or the types that implement an interface"
+}
+
+func (t *GoImplementationsTool) Validate(params map[string]interface{}) error {
+	return validateGoTarget(t.workDir, params)
+}
+
+func (t *GoImplementationsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	g, err := loadGoCode(ctx, t.workDir, params)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	obj, err := g.resolve(params)
+	if err != nil {
+		return failedResult(err)
+	}
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		err := fmt.Errorf("%s is a %s, not a type", obj.Name(), objectKind(obj))
+		return failedResult(err)
+	}
+
+	typ := tn.Type()
+	result := map[string]interface{}{
+		"type": g.symbol(tn),
+	}
+
+	// Method set, including promoted methods and pointer receivers
+	var methods []GoSymbol
+	mset := types.NewMethodSet(typ)
+	if !types.IsInterface(typ) {
+		mset = types.NewMethodSet(types.NewPointer(typ))
+	}
+	for i := 0; i < mset.Len(); i++ {
+		methods = append(methods, g.symbol(mset.At(i).Obj()))
+	}
+	result["methods"] = methods
+
+	var matches []GoSymbol
+	for _, candidate := range g.namedTypes() {
+		if candidate == tn {
+			continue
+		}
+		ctyp := candidate.Type()
+
+		if types.IsInterface(typ) {
+			// Concrete types implementing the interface
+			iface := typ.Underlying().(*types.Interface)
+			if types.IsInterface(ctyp) {
+				continue
+			}
+			if types.Implements(ctyp, iface) || types.Implements(types.NewPointer(ctyp), iface) {
+				matches = append(matches, g.symbol(candidate))
+			}
+		} else {
+			// Non-empty interfaces the type implements
+			iface, ok := ctyp.Underlying().(*types.Interface)
+			if !ok || iface.NumMethods() == 0 {
+				continue
+			}
+			if types.Implements(typ, iface) || types.Implements(types.NewPointer(typ), iface) {
+				matches = append(matches, g.symbol(candidate))
+			}
+		}
+	}
+
+	if types.IsInterface(typ) {
+		result["implemented_by"] = matches
+	} else {
+		result["implements"] = matches
+	}
+
+	return jsonResult(result)
+}
+
+// namedTypes returns the non-generic named types declared in the loaded
+// packages and their direct imports
+func (g *goCode) namedTypes() []*types.TypeName {
+	seen := make(map[*types.Package]bool)
+	var pkgs []*types.Package
+	for _, pkg := range g.pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		for _, p := range append([]*types.Package{pkg.Types}, pkg.Types.Imports()...) {
+			if !seen[p] {
+				seen[p] = true
+				pkgs = append(pkgs, p)
+			}
+		}
+	}
+
+	var names []*types.TypeName
+	for _, p := range pkgs {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() == 0 {
+				names = append(names, tn)
+			}
+		}
+	}
+	return names
+}
+
+func (t *GoImplementationsTool) RequiresConfirmation(level SafetyLevel) bool {
+	return false // Reading only, no confirmation needed
+}
+
+// GoCallersTool lists the call sites of a Go function or method
+type GoCallersTool struct {
+	workDir string
+}
+
+// NewGoCallersTool creates a new GoCallersTool
+func NewGoCallersTool(workDir string) *GoCallersTool {
+	return &GoCallersTool{workDir: workDir}
+}
+
+func (t *GoCallersTool) Name() string {
+	return "go_callers"
+}
+
+func (t *GoCallersTool) Description() string {
+	return "List the functions that call a Go function or method, with call site positions"
+}
+
+func (t *GoCallersTool) Validate(params map[string]interface{}) error {
+	return validateGoTarget(t.workDir, params)
+}
+
+func (t *GoCallersTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	g, err := loadGoCode(ctx, t.workDir, params)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	obj, err := g.resolve(params)
+	if err != nil {
+		return failedResult(err)
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		err := fmt.Errorf("%s is a %s, not a function", obj.Name(), objectKind(obj))
+		return failedResult(err)
+	}
+	target := fn.Origin()
+
+	var calls []GoCall
+	for _, pkg := range g.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				ident := calleeIdent(call.Fun)
+				if ident == nil {
+					return true
+				}
+				if callee, ok := pkg.TypesInfo.Uses[ident].(*types.Func); ok && callee.Origin() == target {
+					calls = append(calls, GoCall{
+						Caller:   enclosingFunc(pkg, file, call.Pos()),
+						Position: g.position(call.Pos()),
+					})
+				}
+				return true
+			})
+		}
+	}
+
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Position < calls[j].Position })
+
+	return jsonResult(map[string]interface{}{
+		"function": g.symbol(fn),
+		"callers":  calls,
+		"count":    len(calls),
+	})
+}
+
+// calleeIdent returns the identifier naming the called function
+func calleeIdent(expr ast.Expr) *ast.Ident {
+	switch f := expr.(type) {
+	case *ast.Ident:
+		return f
+	case *ast.SelectorExpr:
+		return f.Sel
+	case *ast.IndexExpr:
+		return calleeIdent(f.X)
+	case *ast.IndexListExpr:
+		return calleeIdent(f.X)
+	case *ast.ParenExpr:
+		return calleeIdent(f.X)
+	}
+	return nil
+}
+
+func (t *GoCallersTool) RequiresConfirmation(level SafetyLevel) bool {
+	return false // Reading only, no confirmation needed
+}
@@ -0,0 +1,247 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupGoModule creates a small Go module for the code intelligence tools
+func setupGoModule(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod": "module example.com/demo\n\ngo 1.21\n",
+		"shapes/shapes.go": `package shapes
+
+// Shape has an area
+type Shape interface {
+	Area() float64
+}
+
+// Square is a Shape
+type Square struct {
+	Side float64
+}
+
+// NewSquare creates a Square
+func NewSquare(side float64) *Square {
+	return &Square{Side: side}
+}
+
+func (s *Square) Area() float64 {
+	return s.Side * s.Side
+}
+
+func (s Square) String() string {
+	return "square"
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/demo/shapes"
+)
+
+func total(items []shapes.Shape) float64 {
+	sum := 0.0
+	for _, s := range items {
+		sum += s.Area()
+	}
+	return sum
+}
+
+func main() {
+	sq := shapes.NewSquare(2)
+	fmt.Println(total([]shapes.Shape{sq}), sq.Area())
+}
+`,
+	}
+
+	for path, content := range files {
+		full := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	return tempDir
+}
+
+func executeGoTool(t *testing.T, tool Tool, params map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	if err := tool.Validate(params); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("%s failed: %v", tool.Name(), err)
+	}
+	if !result.Success {
+		t.Fatalf("%s not successful: %s", tool.Name(), result.Error)
+	}
+	return result.Data.(map[string]interface{})
+}
+
+func TestGoSymbolsTool(t *testing.T) {
+	dir := setupGoModule(t)
+
+	data := executeGoTool(t, NewGoSymbolsTool(dir), map[string]interface{}{
+		"package": "./shapes",
+	})
+
+	symbols := data["symbols"].([]GoSymbol)
+	byName := make(map[string]GoSymbol)
+	for _, s := range symbols {
+		byName[s.Name] = s
+	}
+
+	if s := byName["Shape"]; s.Kind != "interface" || s.Position != "shapes/shapes.go:4" {
+		t.Errorf("unexpected Shape symbol: %+v", s)
+	}
+	if s := byName["NewSquare"]; s.Kind != "func" || s.Signature != "func(side float64) *Square" {
+		t.Errorf("unexpected NewSquare symbol: %+v", s)
+	}
+	if s := byName["Area"]; s.Kind != "method" || s.Receiver != "*Square" {
+		t.Errorf("unexpected Area symbol: %+v", s)
+	}
+
+	// Query filter
+	data = executeGoTool(t, NewGoSymbolsTool(dir), map[string]interface{}{
+		"package": "./shapes",
+		"query":   "square",
+	})
+	if got := data["count"].(int); got != 2 {
+		t.Errorf("expected 2 symbols matching 'square', got %d", got)
+	}
+}
+
+func TestGoDefinitionTool(t *testing.T) {
+	dir := setupGoModule(t)
+	tool := NewGoDefinitionTool(dir)
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   string
+	}{
+		{"qualified", map[string]interface{}{"symbol": "shapes.NewSquare"}, "shapes/shapes.go:14"},
+		{"method", map[string]interface{}{"symbol": "Square.Area"}, "shapes/shapes.go:18"},
+		{"import_path", map[string]interface{}{"symbol": "example.com/demo/shapes.Shape"}, "shapes/shapes.go:4"},
+		{"position", map[string]interface{}{"file": "main.go", "line": float64(18), "symbol": "NewSquare"}, "shapes/shapes.go:14"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := executeGoTool(t, tool, tt.params)
+			def := data["definition"].(GoSymbol)
+			if def.Position != tt.want {
+				t.Errorf("definition at %s, want %s", def.Position, tt.want)
+			}
+		})
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"symbol": "Missing"}); err == nil {
+		t.Error("expected error for unknown symbol")
+	}
+}
+
+func TestGoReferencesTool(t *testing.T) {
+	dir := setupGoModule(t)
+
+	data := executeGoTool(t, NewGoReferencesTool(dir), map[string]interface{}{
+		"symbol": "shapes.Shape",
+	})
+
+	refs := data["references"].([]GoReference)
+	if len(refs) != 3 {
+		t.Fatalf("expected 3 references (1 definition, 2 uses), got %+v", refs)
+	}
+
+	var uses int
+	for _, r := range refs {
+		if r.Kind == "reference" {
+			uses++
+			if r.Context != "main.total" && r.Context != "main.main" {
+				t.Errorf("unexpected reference context: %s", r.Context)
+			}
+		}
+	}
+	if uses != 2 {
+		t.Errorf("expected 2 uses, got %d", uses)
+	}
+}
+
+func TestGoImplementationsTool(t *testing.T) {
+	dir := setupGoModule(t)
+	tool := NewGoImplementationsTool(dir)
+
+	data := executeGoTool(t, tool, map[string]interface{}{"symbol": "shapes.Shape"})
+	impls := data["implemented_by"].([]GoSymbol)
+	if len(impls) != 1 || impls[0].Name != "Square" {
+		t.Errorf("expected Square to implement Shape, got %+v", impls)
+	}
+
+	data = executeGoTool(t, tool, map[string]interface{}{"symbol": "shapes.Square"})
+	methods := data["methods"].([]GoSymbol)
+	if len(methods) != 2 {
+		t.Errorf("expected *Square method set of 2, got %+v", methods)
+	}
+	var implementsShape, implementsStringer bool
+	for _, s := range data["implements"].([]GoSymbol) {
+		switch s.Name {
+		case "Shape":
+			implementsShape = true
+		case "Stringer":
+			implementsStringer = true
+		}
+	}
+	if !implementsShape || !implementsStringer {
+		t.Errorf("expected Square to implement Shape and fmt.Stringer, got %+v", data["implements"])
+	}
+}
+
+func TestGoCallersTool(t *testing.T) {
+	dir := setupGoModule(t)
+
+	data := executeGoTool(t, NewGoCallersTool(dir), map[string]interface{}{
+		"symbol": "shapes.Square.Area",
+	})
+
+	calls := data["callers"].([]GoCall)
+	if len(calls) != 1 || calls[0].Caller != "main.main" || calls[0].Position != "main.go:19" {
+		t.Errorf("unexpected callers: %+v", calls)
+	}
+
+	if _, err := NewGoCallersTool(dir).Execute(context.Background(), map[string]interface{}{"symbol": "shapes.Square"}); err == nil {
+		t.Error("expected error for non-function symbol")
+	}
+}
+
+func TestGoCodeTools_Metadata(t *testing.T) {
+	for _, tool := range []Tool{
+		NewGoSymbolsTool("/tmp"),
+		NewGoDefinitionTool("/tmp"),
+		NewGoReferencesTool("/tmp"),
+		NewGoImplementationsTool("/tmp"),
+		NewGoCallersTool("/tmp"),
+	} {
+		if tool.Description() == "" {
+			t.Errorf("%s: expected non-empty description", tool.Name())
+		}
+		if tool.RequiresConfirmation(SafetyHigh) {
+			t.Errorf("%s: read-only tool should not require confirmation", tool.Name())
+		}
+	}
+
+	if err := NewGoDefinitionTool("/tmp").Validate(map[string]interface{}{}); err == nil {
+		t.Error("expected error for missing symbol")
+	}
+}
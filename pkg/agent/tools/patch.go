@@ -0,0 +1,857 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultPatchFuzz is how many context lines may be ignored at each end of a hunk
+const defaultPatchFuzz = 2
+
+// FilePatch is the part of a unified diff that applies to one file
+type FilePatch struct {
+	OldPath  string // Empty for new files
+	NewPath  string // Empty for deleted files
+	IsNew    bool
+	IsDelete bool
+	IsRename bool
+	Hunks    []*Hunk
+}
+
+// Path returns the path the patch writes to (or deletes)
+func (fp *FilePatch) Path() string {
+	if fp.IsDelete {
+		return fp.OldPath
+	}
+	return fp.NewPath
+}
+
+// Paths returns every path the patch touches
+func (fp *FilePatch) Paths() []string {
+	var paths []string
+	if fp.OldPath != "" {
+		paths = append(paths, fp.OldPath)
+	}
+	if fp.NewPath != "" && fp.NewPath != fp.OldPath {
+		paths = append(paths, fp.NewPath)
+	}
+	return paths
+}
+
+// Hunk is a single @@ section of a unified diff
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []HunkLine
+
+	oldNoEOL bool // "\ No newline at end of file" after an old-side line
+	newNoEOL bool // "\ No newline at end of file" after a new-side line
+}
+
+// HunkLine is one line of a hunk: ' ' context, '-' removed, '+' added
+type HunkLine struct {
+	Op   byte
+	Text string
+}
+
+// HunkResult reports how a hunk was applied
+type HunkResult struct {
+	File       string `json:"file"`
+	Hunk       int    `json:"hunk"` // 1-based index within the file
+	Applied    bool   `json:"applied"`
+	Line       int    `json:"line,omitempty"`       // 1-based line where the hunk was applied
+	Offset     int    `json:"offset,omitempty"`     // Lines away from the position in the header
+	Fuzz       int    `json:"fuzz,omitempty"`       // Context lines ignored at each end
+	Whitespace bool   `json:"whitespace,omitempty"` // Matched ignoring whitespace
+	Error      string `json:"error,omitempty"`
+
+	// Nearest matching context for failed hunks
+	NearestLine    int    `json:"nearest_line,omitempty"`
+	NearestMatch   string `json:"nearest_match,omitempty"` // e.g. "83% similar"
+	NearestContext string `json:"nearest_context,omitempty"`
+}
+
+// ParsePatch parses a unified diff (plain or git-style) into per-file patches
+func ParsePatch(patch string) ([]*FilePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+
+	var (
+		files   []*FilePatch
+		current *FilePatch
+		hunk    *Hunk
+		gitDiff bool
+	)
+
+	finishFile := func() {
+		if current != nil {
+			files = append(files, current)
+		}
+		current = nil
+		hunk = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			finishFile()
+			gitDiff = true
+			current = &FilePatch{}
+			if oldPath, newPath, ok := parseGitHeader(line); ok {
+				current.OldPath, current.NewPath = oldPath, newPath
+			}
+
+		case current != nil && hunk == nil && strings.HasPrefix(line, "new file mode"):
+			current.IsNew = true
+			current.OldPath = ""
+
+		case current != nil && hunk == nil && strings.HasPrefix(line, "deleted file mode"):
+			current.IsDelete = true
+			current.NewPath = ""
+
+		case current != nil && hunk == nil && strings.HasPrefix(line, "rename from "):
+			current.IsRename = true
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+
+		case current != nil && hunk == nil && strings.HasPrefix(line, "rename to "):
+			current.IsRename = true
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") && (hunk == nil || hunk.complete()):
+			if current == nil || len(current.Hunks) > 0 {
+				finishFile()
+				current = &FilePatch{}
+				gitDiff = false
+			}
+			oldPath := parsePatchPath(strings.TrimPrefix(line, "--- "), "a/", gitDiff)
+			newPath := parsePatchPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/", gitDiff)
+			if !gitDiff && strings.HasPrefix(oldPath, "a/") && strings.HasPrefix(newPath, "b/") {
+				oldPath, newPath = oldPath[2:], newPath[2:]
+			}
+			current.OldPath, current.NewPath = oldPath, newPath
+			current.IsNew = current.IsNew || oldPath == ""
+			current.IsDelete = current.IsDelete || newPath == ""
+			hunk = nil
+			i++
+
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			hunk = h
+			current.Hunks = append(current.Hunks, hunk)
+
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" applies to the previous line
+			if n := len(hunk.Lines); n > 0 {
+				if hunk.Lines[n-1].Op == '-' {
+					hunk.oldNoEOL = true
+				} else {
+					hunk.newNoEOL = true
+					if hunk.Lines[n-1].Op == ' ' {
+						hunk.oldNoEOL = true
+					}
+				}
+			}
+
+		case hunk != nil && len(line) > 0 && (line[0] == ' ' || line[0] == '-' || line[0] == '+'):
+			if hunk.complete() {
+				return nil, fmt.Errorf("line %d: hunk has more lines than its header declares (-%d +%d)", i+1, hunk.OldLines, hunk.NewLines)
+			}
+			hunk.Lines = append(hunk.Lines, HunkLine{Op: line[0], Text: line[1:]})
+
+		case hunk != nil && line == "" && i < len(lines)-1 && !hunk.complete():
+			// Some tools strip the leading space from empty context lines
+			hunk.Lines = append(hunk.Lines, HunkLine{Op: ' ', Text: ""})
+
+		default:
+			// Anything else (index lines, commit messages) ends the current hunk
+			hunk = nil
+		}
+	}
+	finishFile()
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+
+	for _, fp := range files {
+		if fp.OldPath == "" && fp.NewPath == "" {
+			return nil, fmt.Errorf("patch is missing file paths")
+		}
+		if fp.IsRename && (fp.OldPath == "" || fp.NewPath == "") {
+			return nil, fmt.Errorf("rename is missing source or destination")
+		}
+	}
+
+	return files, nil
+}
+
+// parseGitHeader extracts paths from "diff --git a/old b/new"
+func parseGitHeader(line string) (string, string, bool) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	idx := strings.Index(rest, " b/")
+	if !strings.HasPrefix(rest, "a/") || idx < 0 {
+		return "", "", false
+	}
+	return rest[2:idx], rest[idx+3:], true
+}
+
+// parsePatchPath cleans a ---/+++ path, returning "" for /dev/null
+func parsePatchPath(path, prefix string, stripPrefix bool) string {
+	// Drop timestamps ("--- file.go\t2024-01-01 ...")
+	if idx := strings.Index(path, "\t"); idx >= 0 {
+		path = path[:idx]
+	}
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	if stripPrefix {
+		path = strings.TrimPrefix(path, prefix)
+	}
+	return path
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@"
+func parseHunkHeader(line string) (*Hunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return nil, fmt.Errorf("invalid hunk header: %s", line)
+	}
+
+	oldStart, oldLines, err := parseRange(fields[1][1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid hunk header: %s", line)
+	}
+	newStart, newLines, err := parseRange(fields[2][1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid hunk header: %s", line)
+	}
+
+	return &Hunk{OldStart: oldStart, OldLines: oldLines, NewStart: newStart, NewLines: newLines}, nil
+}
+
+func parseRange(s string) (int, int, error) {
+	start, count := s, "1"
+	if idx := strings.Index(s, ","); idx >= 0 {
+		start, count = s[:idx], s[idx+1:]
+	}
+	a, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, err
+	}
+	return a, b, nil
+}
+
+// complete reports whether the hunk already has as many lines as its header says
+func (h *Hunk) complete() bool {
+	oldCount, newCount := 0, 0
+	for _, l := range h.Lines {
+		if l.Op != '+' {
+			oldCount++
+		}
+		if l.Op != '-' {
+			newCount++
+		}
+	}
+	return oldCount >= h.OldLines && newCount >= h.NewLines
+}
+
+// sides returns the old and new lines of the hunk, with up to fuzz context
+// lines dropped from each end
+func (h *Hunk) sides(fuzz int) (oldSide, newSide []string, dropped int) {
+	lead, trail := 0, 0
+	for lead < len(h.Lines) && h.Lines[lead].Op == ' ' {
+		lead++
+	}
+	for trail < len(h.Lines)-lead && h.Lines[len(h.Lines)-1-trail].Op == ' ' {
+		trail++
+	}
+
+	dropLead, dropTrail := min(fuzz, lead), min(fuzz, trail)
+	for _, l := range h.Lines[dropLead : len(h.Lines)-dropTrail] {
+		if l.Op != '+' {
+			oldSide = append(oldSide, l.Text)
+		}
+		if l.Op != '-' {
+			newSide = append(newSide, l.Text)
+		}
+	}
+	return oldSide, newSide, dropLead
+}
+
+// fileContent is a file split into lines
+type fileContent struct {
+	lines           []string
+	trailingNewline bool
+}
+
+func splitContent(data string) fileContent {
+	if data == "" {
+		return fileContent{trailingNewline: true}
+	}
+	trailing := strings.HasSuffix(data, "\n")
+	data = strings.TrimSuffix(data, "\n")
+	return fileContent{lines: strings.Split(data, "\n"), trailingNewline: trailing}
+}
+
+func (c fileContent) String() string {
+	if len(c.lines) == 0 {
+		return ""
+	}
+	s := strings.Join(c.lines, "\n")
+	if c.trailingNewline {
+		s += "\n"
+	}
+	return s
+}
+
+// applyHunks applies hunks in order, returning the new content and a result per hunk
+func applyHunks(path string, content fileContent, hunks []*Hunk, maxFuzz int) (fileContent, []HunkResult, bool) {
+	lines := append([]string{}, content.lines...)
+	trailing := content.trailingNewline
+	results := make([]HunkResult, 0, len(hunks))
+	ok := true
+
+	delta := 0    // Line count change and drift from previous hunks
+	minStart := 0 // Hunks can't overlap or go backwards
+
+	for i, h := range hunks {
+		result := HunkResult{File: path, Hunk: i + 1}
+
+		found := false
+		for fuzz := 0; fuzz <= maxFuzz && !found; fuzz++ {
+			oldSide, newSide, dropped := h.sides(fuzz)
+			expected := max(h.OldStart-1, 0) + delta + dropped
+			if h.OldLines == 0 {
+				expected = h.OldStart + delta // Pure insertion after line OldStart
+			}
+
+			for _, ignoreSpace := range []bool{false, true} {
+				pos, matched := findLines(lines, oldSide, expected, minStart, ignoreSpace)
+				if !matched {
+					continue
+				}
+
+				lines = append(lines[:pos], append(append([]string{}, newSide...), lines[pos+len(oldSide):]...)...)
+
+				result.Applied = true
+				result.Line = pos + 1
+				result.Offset = pos - expected
+				result.Fuzz = fuzz
+				result.Whitespace = ignoreSpace
+
+				delta += len(newSide) - len(oldSide) + (pos - expected)
+				minStart = pos + len(newSide)
+				found = true
+				break
+			}
+			if len(oldSide) == 0 {
+				break // No context left to drop
+			}
+		}
+
+		if !found {
+			ok = false
+			oldSide, _, _ := h.sides(0)
+			result.Error = "hunk context not found"
+			result.NearestLine, result.NearestMatch, result.NearestContext = nearestContext(lines, oldSide, max(h.OldStart-1, 0)+delta)
+		} else {
+			if h.newNoEOL {
+				trailing = false
+			} else if h.oldNoEOL {
+				trailing = true
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return fileContent{lines: lines, trailingNewline: trailing}, results, ok
+}
+
+// findLines looks for want in lines, starting nearest to expected
+func findLines(lines, want []string, expected, minStart int, ignoreSpace bool) (int, bool) {
+	last := len(lines) - len(want)
+	if last < minStart {
+		return 0, false
+	}
+	if len(want) == 0 {
+		return min(max(expected, minStart), len(lines)), true
+	}
+
+	expected = min(max(expected, minStart), last)
+	for dist := 0; ; dist++ {
+		before, after := expected-dist, expected+dist
+		if before < minStart && after > last {
+			return 0, false
+		}
+		if before >= minStart && linesMatch(lines[before:before+len(want)], want, ignoreSpace) {
+			return before, true
+		}
+		if dist > 0 && after <= last && linesMatch(lines[after:after+len(want)], want, ignoreSpace) {
+			return after, true
+		}
+	}
+}
+
+func linesMatch(got, want []string, ignoreSpace bool) bool {
+	for i := range want {
+		if ignoreSpace {
+			if strings.Join(strings.Fields(got[i]), " ") != strings.Join(strings.Fields(want[i]), " ") {
+				return false
+			}
+		} else if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// nearestContext finds the window of lines most similar to want,
+// preferring windows closer to the expected position on ties
+func nearestContext(lines, want []string, expected int) (int, string, string) {
+	if len(want) == 0 || len(lines) == 0 {
+		return 0, "", ""
+	}
+
+	bestPos, bestScore := 0, -1.0
+	for pos := 0; pos <= max(len(lines)-len(want), 0); pos++ {
+		score := 0.0
+		for i := 0; i < len(want) && pos+i < len(lines); i++ {
+			score += lineSimilarity(lines[pos+i], want[i])
+		}
+		if score > bestScore || (score == bestScore && abs(pos-expected) < abs(bestPos-expected)) {
+			bestPos, bestScore = pos, score
+		}
+	}
+
+	end := min(bestPos+len(want), len(lines))
+	similarity := int(100 * bestScore / float64(len(want)))
+	return bestPos + 1, fmt.Sprintf("%d%% similar", similarity), strings.Join(lines[bestPos:end], "\n")
+}
+
+// lineSimilarity scores two lines from 0 to 1 by shared prefix and suffix, ignoring indentation
+func lineSimilarity(a, b string) float64 {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return 1
+	}
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return float64(prefix+suffix) / float64(longest)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// ApplyPatchTool applies a unified diff to one or more files
+type ApplyPatchTool struct {
+	workDir string
+}
+
+// NewApplyPatchTool creates a new ApplyPatchTool
+func NewApplyPatchTool(workDir string) *ApplyPatchTool {
+	return &ApplyPatchTool{workDir: workDir}
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "Apply a unified diff to one or more files (create, delete, rename supported; all-or-nothing)"
+}
+
+func (t *ApplyPatchTool) Validate(params map[string]interface{}) error {
+	patch, ok := params["patch"].(string)
+	if !ok || patch == "" {
+		return fmt.Errorf("patch parameter is required and must be a non-empty string")
+	}
+
+	files, err := ParsePatch(patch)
+	if err != nil {
+		return err
+	}
+
+	for _, fp := range files {
+		for _, path := range fp.Paths() {
+			if err := validatePath(t.workDir, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// patchWrite is a pending change to a single path
+type patchWrite struct {
+	path    string
+	content *string     // nil means delete
+	mode    os.FileMode // Permissions for a new file; 0 for the default
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	patch, ok := params["patch"].(string)
+	if !ok || patch == "" {
+		return &ToolResult{
+			Success: false,
+			Error:   "patch parameter is required",
+		}, fmt.Errorf("patch parameter is required")
+	}
+
+	fuzz := defaultPatchFuzz
+	if f, ok := params["fuzz"].(float64); ok {
+		fuzz = int(f)
+	} else if f, ok := params["fuzz"].(int); ok {
+		fuzz = f
+	}
+
+	dryRun, _ := params["dry_run"].(bool)
+
+	files, err := ParsePatch(patch)
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to parse patch: %v", err),
+		}, err
+	}
+
+	// Compute every change in memory first so nothing is written unless all hunks apply
+	var (
+		results []HunkResult
+		errs    []string
+		summary []string
+	)
+	tree := newPatchTree()
+	for _, fp := range files {
+		hunkResults, err := t.prepare(tree, fp, fuzz)
+		results = append(results, hunkResults...)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		switch {
+		case fp.IsNew:
+			summary = append(summary, "created "+fp.NewPath)
+		case fp.IsDelete:
+			summary = append(summary, "deleted "+fp.OldPath)
+		case fp.IsRename:
+			summary = append(summary, fmt.Sprintf("renamed %s -> %s", fp.OldPath, fp.NewPath))
+		default:
+			summary = append(summary, "modified "+fp.NewPath)
+		}
+	}
+
+	data := map[string]interface{}{
+		"hunks": results,
+		"files": summary,
+	}
+
+	if len(errs) > 0 {
+		return &ToolResult{
+			Success: false,
+			Output:  formatHunkResults(results),
+			Error:   "patch not applied: " + strings.Join(errs, "; "),
+			Data:    data,
+		}, fmt.Errorf("patch not applied: %s", strings.Join(errs, "; "))
+	}
+
+	if dryRun {
+		return &ToolResult{
+			Success: true,
+			Output:  "Patch applies cleanly (dry run)\n" + formatHunkResults(results),
+			Data:    data,
+		}, nil
+	}
+
+	if err := commitWrites(tree.writes()); err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to write patch: %v", err),
+			Data:    data,
+		}, err
+	}
+
+	return &ToolResult{
+		Success: true,
+		Output:  fmt.Sprintf("Applied patch: %s\n%s", strings.Join(summary, ", "), formatHunkResults(results)),
+		Data:    data,
+	}, nil
+}
+
+// patchTree is the work tree as the patch leaves it. Files are read from
+// disk on first touch, so later sections see what earlier ones did to a path.
+type patchTree struct {
+	files map[string]*string     // nil once deleted
+	modes map[string]os.FileMode // Permissions of files moved by a rename
+	order []string               // Paths in the order they were first changed
+}
+
+func newPatchTree() *patchTree {
+	return &patchTree{files: make(map[string]*string), modes: make(map[string]os.FileMode)}
+}
+
+// read returns the content of path and whether it exists
+func (pt *patchTree) read(path string) (string, bool, error) {
+	if content, ok := pt.files[path]; ok {
+		if content == nil {
+			return "", false, nil
+		}
+		return *content, true, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// mode returns the permissions of path, or 0 if it has none yet
+func (pt *patchTree) mode(path string) os.FileMode {
+	if mode, ok := pt.modes[path]; ok {
+		return mode
+	}
+	if content, ok := pt.files[path]; ok && content == nil {
+		return 0
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	return 0
+}
+
+// write records new content for path; nil deletes it
+func (pt *patchTree) write(path string, content *string) {
+	if _, ok := pt.files[path]; !ok {
+		pt.order = append(pt.order, path)
+	}
+	if content == nil {
+		delete(pt.modes, path)
+	}
+	pt.files[path] = content
+}
+
+// writes returns the changes to make on disk
+func (pt *patchTree) writes() []patchWrite {
+	writes := make([]patchWrite, len(pt.order))
+	for i, path := range pt.order {
+		writes[i] = patchWrite{path: path, content: pt.files[path], mode: pt.modes[path]}
+	}
+	return writes
+}
+
+// prepare applies a single file patch to the tree; the tree is left as it
+// was if the patch fails
+func (t *ApplyPatchTool) prepare(tree *patchTree, fp *FilePatch, fuzz int) ([]HunkResult, error) {
+	if fp.IsNew {
+		newPath := resolvePath(t.workDir, fp.NewPath)
+		if _, exists, err := tree.read(newPath); err != nil {
+			return nil, fmt.Errorf("%s: %w", fp.NewPath, err)
+		} else if exists {
+			return nil, fmt.Errorf("%s: file already exists", fp.NewPath)
+		}
+		content, results, ok := applyHunks(fp.NewPath, fileContent{trailingNewline: true}, fp.Hunks, 0)
+		if !ok {
+			return results, fmt.Errorf("%s: hunks failed", fp.NewPath)
+		}
+		s := content.String()
+		tree.write(newPath, &s)
+		return results, nil
+	}
+
+	oldPath := resolvePath(t.workDir, fp.OldPath)
+	data, exists, err := tree.read(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fp.OldPath, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", fp.OldPath, os.ErrNotExist)
+	}
+
+	content, results, ok := applyHunks(fp.OldPath, splitContent(data), fp.Hunks, fuzz)
+	if !ok {
+		return results, fmt.Errorf("%s: hunks failed", fp.OldPath)
+	}
+
+	if fp.IsDelete {
+		if len(fp.Hunks) > 0 && len(content.lines) > 0 {
+			return results, fmt.Errorf("%s: file not empty after removing hunks", fp.OldPath)
+		}
+		tree.write(oldPath, nil)
+		return results, nil
+	}
+
+	s := content.String()
+	if fp.IsRename && fp.NewPath != fp.OldPath {
+		newPath := resolvePath(t.workDir, fp.NewPath)
+		if _, exists, err := tree.read(newPath); err != nil {
+			return results, fmt.Errorf("%s: %w", fp.NewPath, err)
+		} else if exists {
+			return results, fmt.Errorf("%s: rename target already exists", fp.NewPath)
+		}
+		// The file keeps its permissions under the new name
+		if mode := tree.mode(oldPath); mode != 0 {
+			tree.modes[newPath] = mode
+		}
+		tree.write(newPath, &s)
+		tree.write(oldPath, nil)
+		return results, nil
+	}
+
+	tree.write(oldPath, &s)
+	return results, nil
+}
+
+// commitWrites applies writes, restoring the original files and removing
+// the directories it created if any write fails
+func commitWrites(writes []patchWrite) error {
+	type backup struct {
+		path    string
+		data    []byte
+		existed bool
+		mode    os.FileMode
+	}
+
+	var (
+		done []backup
+		dirs []string // Created, parents first
+	)
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			b := done[i]
+			if b.existed {
+				_ = os.WriteFile(b.path, b.data, b.mode)
+			} else {
+				_ = os.Remove(b.path)
+			}
+		}
+		for i := len(dirs) - 1; i >= 0; i-- {
+			_ = os.Remove(dirs[i])
+		}
+	}
+
+	for _, w := range writes {
+		b := backup{path: w.path, mode: 0644}
+		if w.mode != 0 {
+			b.mode = w.mode
+		}
+		if info, err := os.Stat(w.path); err == nil {
+			data, err := os.ReadFile(w.path)
+			if err != nil {
+				rollback()
+				return err
+			}
+			b.data, b.existed, b.mode = data, true, info.Mode().Perm()
+		}
+
+		var err error
+		switch {
+		case w.content == nil && !b.existed:
+			// Created and deleted again within the patch
+		case w.content == nil:
+			err = os.Remove(w.path)
+		default:
+			missing := missingDirs(filepath.Dir(w.path))
+			err = os.MkdirAll(filepath.Dir(w.path), 0755)
+			if err == nil {
+				dirs = append(dirs, missing...)
+				err = os.WriteFile(w.path, []byte(*w.content), b.mode)
+			}
+		}
+		if err != nil {
+			rollback()
+			return err
+		}
+		done = append(done, b)
+	}
+
+	return nil
+}
+
+// missingDirs returns dir and its ancestors that don't exist yet, parents first
+func missingDirs(dir string) []string {
+	var missing []string
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return missing
+}
+
+// formatHunkResults renders a per-hunk report
+func formatHunkResults(results []HunkResult) string {
+	var sb strings.Builder
+	for _, r := range results {
+		if r.Applied {
+			sb.WriteString(fmt.Sprintf("  ✓ %s hunk %d at line %d", r.File, r.Hunk, r.Line))
+			if r.Offset != 0 {
+				sb.WriteString(fmt.Sprintf(" (offset %d)", r.Offset))
+			}
+			if r.Fuzz > 0 {
+				sb.WriteString(fmt.Sprintf(" (fuzz %d)", r.Fuzz))
+			}
+			if r.Whitespace {
+				sb.WriteString(" (ignoring whitespace)")
+			}
+			sb.WriteString("\n")
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("  ✗ %s hunk %d: %s\n", r.File, r.Hunk, r.Error))
+		if r.NearestLine > 0 {
+			sb.WriteString(fmt.Sprintf("    nearest match at line %d (%s):\n", r.NearestLine, r.NearestMatch))
+			for _, l := range strings.Split(r.NearestContext, "\n") {
+				sb.WriteString("    | " + l + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (t *ApplyPatchTool) RequiresConfirmation(level SafetyLevel) bool {
+	return level >= SafetyMedium // Modifying multiple files requires confirmation
+}
@@ -0,0 +1,351 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const patchTestFile = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello, World!")
+}
+
+func helper() string {
+	return "help"
+}
+`
+
+func TestApplyPatchTool(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		patch       string
+		shouldError bool
+		expect      map[string]string // path -> expected content ("" means must not exist)
+	}{
+		{
+			name:  "modify_file",
+			files: map[string]string{"main.go": patchTestFile},
+			patch: `--- a/main.go
++++ b/main.go
+@@ -5,3 +5,4 @@
+ func main() {
+-	fmt.Println("Hello, World!")
++	fmt.Println("Hello, Smith!")
++	fmt.Println("Welcome to the Matrix.")
+ }
+`,
+			expect: map[string]string{"main.go": strings.Replace(patchTestFile,
+				"\tfmt.Println(\"Hello, World!\")\n",
+				"\tfmt.Println(\"Hello, Smith!\")\n\tfmt.Println(\"Welcome to the Matrix.\")\n", 1)},
+		},
+		{
+			name:  "two_sections_same_file",
+			files: map[string]string{"main.go": patchTestFile},
+			patch: `--- a/main.go
++++ b/main.go
+@@ -5,3 +5,3 @@
+ func main() {
+-	fmt.Println("Hello, World!")
++	fmt.Println("Hello, Smith!")
+ }
+--- a/main.go
++++ b/main.go
+@@ -9,3 +9,3 @@
+ func helper() string {
+-	return "help"
++	return "helped"
+ }
+`,
+			expect: map[string]string{"main.go": strings.NewReplacer(
+				"Hello, World!", "Hello, Smith!",
+				`"help"`, `"helped"`).Replace(patchTestFile)},
+		},
+		{
+			name:   "create_then_modify",
+			files:  map[string]string{},
+			patch:  "--- /dev/null\n+++ new.txt\n@@ -0,0 +1 @@\n+one\n--- new.txt\n+++ new.txt\n@@ -1 +1,2 @@\n one\n+two\n",
+			expect: map[string]string{"new.txt": "one\ntwo\n"},
+		},
+		{
+			name:  "offset_hunk",
+			files: map[string]string{"main.go": patchTestFile},
+			patch: `--- main.go
++++ main.go
+@@ -1,3 +1,3 @@
+ func helper() string {
+-	return "help"
++	return "helped"
+ }
+`,
+			expect: map[string]string{"main.go": strings.Replace(patchTestFile, `"help"`, `"helped"`, 1)},
+		},
+		{
+			name:  "fuzzy_context",
+			files: map[string]string{"main.go": patchTestFile},
+			patch: `--- a/main.go
++++ b/main.go
+@@ -8,4 +8,4 @@
+ // stale comment that is no longer there
+ func helper() string {
+-	return "help"
++	return "fuzzy"
+ }
+`,
+			expect: map[string]string{"main.go": strings.Replace(patchTestFile, `"help"`, `"fuzzy"`, 1)},
+		},
+		{
+			name:  "create_delete_rename",
+			files: map[string]string{"old.txt": "one\ntwo\n", "gone.txt": "bye\n"},
+			patch: `diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old.txt b/renamed.txt
+similarity index 80%
+rename from old.txt
+rename to renamed.txt
+--- a/old.txt
++++ b/renamed.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++three
+`,
+			expect: map[string]string{
+				"new.txt":     "hello\nworld\n",
+				"gone.txt":    "",
+				"old.txt":     "",
+				"renamed.txt": "one\nthree\n",
+			},
+		},
+		{
+			name:  "failed_hunk_is_atomic",
+			files: map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-alpha
++ALPHA
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-gamma
++GAMMA
+`,
+			shouldError: true,
+			expect:      map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n"},
+		},
+		{
+			name:  "no_newline_at_end",
+			files: map[string]string{"a.txt": "x\ny"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ x
+-y
+\ No newline at end of file
++z
+`,
+			expect: map[string]string{"a.txt": "x\nz\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			for path, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tempDir, path), []byte(content), 0644); err != nil {
+					t.Fatalf("failed to create test file: %v", err)
+				}
+			}
+
+			tool := NewApplyPatchTool(tempDir)
+			params := map[string]interface{}{"patch": tt.patch}
+
+			if err := tool.Validate(params); err != nil {
+				t.Fatalf("validation failed: %v", err)
+			}
+
+			result, err := tool.Execute(context.Background(), params)
+			if tt.shouldError {
+				if err == nil || result.Success {
+					t.Fatalf("expected error, got success: %s", result.Output)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, result.Output)
+			}
+
+			for path, want := range tt.expect {
+				data, err := os.ReadFile(filepath.Join(tempDir, path))
+				if want == "" {
+					if !os.IsNotExist(err) {
+						t.Errorf("expected %s to not exist", path)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("failed to read %s: %v", path, err)
+				}
+				if string(data) != want {
+					t.Errorf("%s content mismatch:\ngot:\n%q\nwant:\n%q", path, string(data), want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPatchTool_HunkReport(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte(patchTestFile), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	tool := NewApplyPatchTool(tempDir)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"patch": `--- a/main.go
++++ b/main.go
+@@ -9,3 +9,3 @@
+ func helper() int {
+-	return 42
++	return 43
+ }
+`,
+	})
+	if err == nil {
+		t.Fatal("expected hunk to fail")
+	}
+
+	data := result.Data.(map[string]interface{})
+	hunks := data["hunks"].([]HunkResult)
+	if len(hunks) != 1 || hunks[0].Applied {
+		t.Fatalf("expected one failed hunk, got %+v", hunks)
+	}
+	if hunks[0].NearestLine != 9 {
+		t.Errorf("expected nearest context at line 9, got %d", hunks[0].NearestLine)
+	}
+	if !strings.Contains(hunks[0].NearestContext, "func helper() string") {
+		t.Errorf("expected nearest context to show helper, got %q", hunks[0].NearestContext)
+	}
+	if !strings.Contains(result.Output, "nearest match at line 9") {
+		t.Errorf("expected report in output, got %q", result.Output)
+	}
+}
+
+func TestApplyPatchTool_DryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "a.txt")
+	if err := os.WriteFile(path, []byte("alpha\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	tool := NewApplyPatchTool(tempDir)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"patch":   "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-alpha\n+beta\n",
+		"dry_run": true,
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("dry run failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "alpha\n" {
+		t.Errorf("dry run modified file: %q", string(data))
+	}
+}
+
+func TestCommitWritesRollback(t *testing.T) {
+	tempDir := t.TempDir()
+	blocker := filepath.Join(tempDir, "blocker")
+	if err := os.WriteFile(blocker, []byte("file\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	// The second write can't create its directory under a file
+	content := "new\n"
+	err := commitWrites([]patchWrite{
+		{path: filepath.Join(tempDir, "a", "b", "new.txt"), content: &content},
+		{path: filepath.Join(blocker, "x.txt"), content: &content},
+	})
+	if err == nil {
+		t.Fatal("expected the write under a file to fail")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "a")); !os.IsNotExist(err) {
+		t.Errorf("expected created directories to be removed, got %v", err)
+	}
+}
+
+func TestApplyPatchTool_Validate(t *testing.T) {
+	tool := NewApplyPatchTool(t.TempDir())
+
+	if err := tool.Validate(map[string]interface{}{}); err == nil {
+		t.Error("expected error for missing patch")
+	}
+	if err := tool.Validate(map[string]interface{}{"patch": "not a diff"}); err == nil {
+		t.Error("expected error for patch without file changes")
+	}
+	traversal := "--- a/../etc/passwd\n+++ b/../etc/passwd\n@@ -1 +1 @@\n-x\n+y\n"
+	if err := tool.Validate(map[string]interface{}{"patch": traversal}); err == nil {
+		t.Error("expected error for path traversal")
+	}
+	extra := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-alpha\n+beta\n+gamma\n"
+	if err := tool.Validate(map[string]interface{}{"patch": extra}); err == nil || !strings.Contains(err.Error(), "more lines than its header") {
+		t.Errorf("expected a hunk longer than its header to be rejected, got %v", err)
+	}
+}
+
+func TestApplyPatchTool_RenameKeepsMode(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "run.sh"), []byte("echo hi\n"), 0755); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	patch := "diff --git a/run.sh b/start.sh\nrename from run.sh\nrename to start.sh\n" +
+		"--- a/run.sh\n+++ b/start.sh\n@@ -1 +1 @@\n-echo hi\n+echo hello\n"
+	if _, err := NewApplyPatchTool(tempDir).Execute(context.Background(), map[string]interface{}{"patch": patch}); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(tempDir, "start.sh"))
+	if err != nil {
+		t.Fatalf("renamed file missing: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0755 {
+		t.Errorf("renamed file mode = %v, want -rwxr-xr-x", mode)
+	}
+}
+
+func TestApplyPatchTool_Metadata(t *testing.T) {
+	tool := NewApplyPatchTool("/tmp")
+
+	if tool.Name() != "apply_patch" {
+		t.Errorf("expected name 'apply_patch', got '%s'", tool.Name())
+	}
+
+	if tool.Description() == "" {
+		t.Error("expected non-empty description")
+	}
+
+	if !tool.RequiresConfirmation(SafetyMedium) {
+		t.Error("apply_patch should require confirmation at medium safety")
+	}
+
+	if tool.RequiresConfirmation(SafetyLow) {
+		t.Error("apply_patch should not require confirmation at low safety")
+	}
+}
@@ -0,0 +1,674 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOutputBufferSize = 256 * 1024
+	defaultStopGrace        = 5 * time.Second
+	defaultStartWait        = 2 * time.Second
+	maxStartWait            = 60 * time.Second
+)
+
+var (
+	// ErrProcessNotFound is returned when a process ID is unknown to the manager
+	ErrProcessNotFound = errors.New("process not found")
+
+	// ErrProcessExited is returned when writing to a process that has exited
+	ErrProcessExited = errors.New("process has exited")
+)
+
+// outputBuffer keeps the most recent output of a process.
+// Offsets count every byte ever written, so readers can resume where they left off.
+type outputBuffer struct {
+	mu      sync.Mutex
+	buf     []byte
+	size    int
+	written int64
+}
+
+func newOutputBuffer(size int) *outputBuffer {
+	return &outputBuffer{size: size}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.size; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	b.written += int64(len(p))
+	return len(p), nil
+}
+
+// ReadFrom returns output written since offset, the offset to resume from,
+// and whether output before the buffer's start was dropped
+func (b *outputBuffer) ReadFrom(offset int64) ([]byte, int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := b.written - int64(len(b.buf))
+	dropped := false
+	if offset < start {
+		dropped = offset >= 0 && start > 0
+		offset = start
+	}
+	if offset > b.written {
+		offset = b.written
+	}
+
+	data := make([]byte, b.written-offset)
+	copy(data, b.buf[offset-start:])
+	return data, b.written, dropped
+}
+
+// Process is a background process started by an agent
+type Process struct {
+	ID        string
+	Owner     string // Task or session that started the process
+	Command   string
+	PID       int
+	StartedAt time.Time
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	output *outputBuffer
+	done   chan struct{}
+
+	stopMu sync.Mutex  // Serializes stopProcess
+	guard  *groupGuard // Kills the group if smith dies; nil once released
+
+	mu       sync.Mutex
+	exitCode int
+	cursor   int64 // Offset of the last read_process_output
+}
+
+// Running reports whether the process is still running
+func (p *Process) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// ExitCode returns the exit code, or -1 while the process is running
+func (p *Process) ExitCode() int {
+	if p.Running() {
+		return -1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exitCode
+}
+
+// Done is closed when the process exits
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Output returns output since offset; a negative offset continues from the last read
+func (p *Process) Output(offset int64) (string, int64, bool) {
+	p.mu.Lock()
+	if offset < 0 {
+		offset = p.cursor
+	}
+	p.mu.Unlock()
+
+	data, next, dropped := p.output.ReadFrom(offset)
+
+	p.mu.Lock()
+	if next > p.cursor {
+		p.cursor = next
+	}
+	p.mu.Unlock()
+
+	return string(data), next, dropped
+}
+
+// WriteInput writes to the process's stdin
+func (p *Process) WriteInput(input string) error {
+	if !p.Running() {
+		return ErrProcessExited
+	}
+	_, err := io.WriteString(p.stdin, input)
+	return err
+}
+
+// CloseInput closes the process's stdin (sends EOF)
+func (p *Process) CloseInput() error {
+	return p.stdin.Close()
+}
+
+// ProcessInfo is a snapshot of a process for listing
+type ProcessInfo struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Command   string    `json:"command"`
+	PID       int       `json:"pid"`
+	Running   bool      `json:"running"`
+	ExitCode  int       `json:"exit_code"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// ProcessManager tracks background processes by owner.
+// Each process runs in its own process group so stopping it also stops its children.
+// Groups are killed when their owner stops or the manager is closed, and by
+// a guard process in each group if smith itself dies.
+type ProcessManager struct {
+	mu         sync.Mutex
+	procs      map[string]*Process
+	nextID     int
+	bufferSize int
+}
+
+// NewProcessManager creates a new ProcessManager
+func NewProcessManager() *ProcessManager {
+	return &ProcessManager{
+		procs:      make(map[string]*Process),
+		bufferSize: defaultOutputBufferSize,
+	}
+}
+
+// Start runs a shell command in the background
+func (m *ProcessManager) Start(owner, workDir, command string) (*Process, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = workDir
+	cmd.SysProcAttr = processSysProcAttr()
+	cmd.WaitDelay = time.Second // Don't hang on pipes held open by orphaned children
+
+	output := newOutputBuffer(m.bufferSize)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start process: %w", err)
+	}
+	guard, err := startGroupGuard(cmd.Process)
+	if err != nil {
+		_ = killProcessGroup(cmd.Process)
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("failed to start process group guard: %w", err)
+	}
+
+	m.mu.Lock()
+	m.nextID++
+	proc := &Process{
+		ID:        fmt.Sprintf("proc-%d", m.nextID),
+		Owner:     owner,
+		Command:   command,
+		PID:       cmd.Process.Pid,
+		StartedAt: time.Now(),
+		cmd:       cmd,
+		stdin:     stdin,
+		output:    output,
+		done:      make(chan struct{}),
+		guard:     guard,
+	}
+	m.procs[proc.ID] = proc
+	m.mu.Unlock()
+
+	go func() {
+		_ = cmd.Wait()
+		proc.mu.Lock()
+		proc.exitCode = cmd.ProcessState.ExitCode()
+		proc.mu.Unlock()
+		close(proc.done)
+	}()
+
+	return proc, nil
+}
+
+// Get returns a process owned by owner
+func (m *ProcessManager) Get(owner, id string) (*Process, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	proc, ok := m.procs[id]
+	if !ok || proc.Owner != owner {
+		return nil, fmt.Errorf("%w: %s", ErrProcessNotFound, id)
+	}
+	return proc, nil
+}
+
+// List returns the processes started by owner, or all processes if owner is empty
+func (m *ProcessManager) List(owner string) []ProcessInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var infos []ProcessInfo
+	for _, p := range m.procs {
+		if owner != "" && p.Owner != owner {
+			continue
+		}
+		infos = append(infos, ProcessInfo{
+			ID:        p.ID,
+			Owner:     p.Owner,
+			Command:   p.Command,
+			PID:       p.PID,
+			Running:   p.Running(),
+			ExitCode:  p.ExitCode(),
+			StartedAt: p.StartedAt,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartedAt.Before(infos[j].StartedAt) })
+	return infos
+}
+
+// Stop terminates a process group, escalating to a kill after grace
+func (m *ProcessManager) Stop(owner, id string, grace time.Duration) (*Process, error) {
+	proc, err := m.Get(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	stopProcess(proc, grace)
+	return proc, nil
+}
+
+// StopOwner stops every process started by owner and forgets them.
+// It's called when a task ends so servers don't outlive the agent that started them.
+func (m *ProcessManager) StopOwner(owner string) int {
+	m.mu.Lock()
+	var procs []*Process
+	for id, p := range m.procs {
+		if p.Owner == owner {
+			procs = append(procs, p)
+			delete(m.procs, id)
+		}
+	}
+	m.mu.Unlock()
+
+	stopAll(procs)
+	return len(procs)
+}
+
+// StopAll stops every tracked process
+func (m *ProcessManager) StopAll() {
+	m.mu.Lock()
+	procs := make([]*Process, 0, len(m.procs))
+	for id, p := range m.procs {
+		procs = append(procs, p)
+		delete(m.procs, id)
+	}
+	m.mu.Unlock()
+
+	stopAll(procs)
+}
+
+func stopAll(procs []*Process) {
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		wg.Add(1)
+		go func(p *Process) {
+			defer wg.Done()
+			stopProcess(p, defaultStopGrace)
+		}(p)
+	}
+	wg.Wait()
+}
+
+// stopProcess interrupts the process group and kills it if it doesn't exit
+// within grace. The group is only signalled while its guard or leader is
+// unreaped; after that its ID may belong to another group.
+func stopProcess(p *Process, grace time.Duration) {
+	p.stopMu.Lock()
+	defer p.stopMu.Unlock()
+
+	_ = p.stdin.Close()
+	guard := p.guard
+	p.guard = nil
+
+	if p.Running() {
+		_ = interruptProcessGroup(p.cmd.Process)
+		select {
+		case <-p.done:
+		case <-time.After(grace):
+		}
+	}
+
+	// Kill whatever is left, including children that outlived the group leader
+	if guard != nil || p.Running() {
+		_ = killProcessGroup(p.cmd.Process)
+	}
+	guard.release()
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// processStatus describes a process for tool output
+func processStatus(p *Process) string {
+	if p.Running() {
+		return fmt.Sprintf("%s (pid %d) running", p.ID, p.PID)
+	}
+	return fmt.Sprintf("%s (pid %d) exited with code %d", p.ID, p.PID, p.ExitCode())
+}
+
+// StartProcessTool starts a long-running command in the background
+type StartProcessTool struct {
+	workDir string
+	manager *ProcessManager
+	owner   string
+}
+
+// NewStartProcessTool creates a new StartProcessTool
+func NewStartProcessTool(workDir string, manager *ProcessManager, owner string) *StartProcessTool {
+	return &StartProcessTool{workDir: workDir, manager: manager, owner: owner}
+}
+
+func (t *StartProcessTool) Name() string {
+	return "start_process"
+}
+
+func (t *StartProcessTool) Description() string {
+	return "Start a long-running command (e.g., a dev server) in the background and return its process ID"
+}
+
+func (t *StartProcessTool) Validate(params map[string]interface{}) error {
+	if stringParam(params, "command") == "" {
+		return fmt.Errorf("command parameter is required and must be a string")
+	}
+	if dir := stringParam(params, "working_dir"); dir != "" {
+		if err := validatePath(t.workDir, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *StartProcessTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	command := stringParam(params, "command")
+	workDir := t.workDir
+	if dir := stringParam(params, "working_dir"); dir != "" {
+		workDir = resolvePath(t.workDir, dir)
+	}
+
+	proc, err := t.manager.Start(t.owner, workDir, command)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	// Wait for a readiness marker (or a short settle time) so early output and crashes are visible
+	waitFor := stringParam(params, "wait_for")
+	wait := defaultStartWait
+	if seconds := intParam(params, "wait_seconds"); seconds > 0 {
+		wait = time.Duration(seconds) * time.Second
+		if wait > maxStartWait {
+			wait = maxStartWait
+		}
+	} else if waitFor != "" {
+		wait = 30 * time.Second
+	}
+	ready := waitForOutput(ctx, proc, waitFor, wait)
+
+	output, cursor, _ := proc.Output(0)
+	data := map[string]interface{}{
+		"id":      proc.ID,
+		"pid":     proc.PID,
+		"running": proc.Running(),
+		"cursor":  cursor,
+	}
+
+	status := processStatus(proc)
+	if waitFor != "" {
+		data["ready"] = ready
+		if !ready {
+			status += fmt.Sprintf(" (%q not seen yet)", waitFor)
+		}
+	}
+	if !proc.Running() {
+		data["exit_code"] = proc.ExitCode()
+	}
+
+	result := &ToolResult{
+		Success: proc.Running() || proc.ExitCode() == 0,
+		Output:  fmt.Sprintf("%s\n%s", status, tail(output, 4000)),
+		Data:    data,
+	}
+	if !result.Success {
+		result.Error = status
+		return result, ErrCommandFailed
+	}
+	return result, nil
+}
+
+// waitForOutput waits until marker appears in the output, the process exits, or wait elapses.
+// Without a marker it waits the full duration unless the process exits first.
+func waitForOutput(ctx context.Context, proc *Process, marker string, wait time.Duration) bool {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if marker != "" {
+			data, _, _ := proc.output.ReadFrom(0)
+			if strings.Contains(string(data), marker) {
+				return true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-proc.Done():
+			if marker == "" {
+				return false
+			}
+			data, _, _ := proc.output.ReadFrom(0)
+			return strings.Contains(string(data), marker)
+		case <-deadline.C:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *StartProcessTool) RequiresConfirmation(level SafetyLevel) bool {
+	return level >= SafetyMedium // Runs arbitrary commands
+}
+
+// ReadProcessOutputTool reads buffered output from a background process
+type ReadProcessOutputTool struct {
+	manager *ProcessManager
+	owner   string
+}
+
+// NewReadProcessOutputTool creates a new ReadProcessOutputTool
+func NewReadProcessOutputTool(manager *ProcessManager, owner string) *ReadProcessOutputTool {
+	return &ReadProcessOutputTool{manager: manager, owner: owner}
+}
+
+func (t *ReadProcessOutputTool) Name() string {
+	return "read_process_output"
+}
+
+func (t *ReadProcessOutputTool) Description() string {
+	return "Read new output from a background process, along with whether it is still running"
+}
+
+func (t *ReadProcessOutputTool) Validate(params map[string]interface{}) error {
+	if stringParam(params, "id") == "" {
+		return fmt.Errorf("id parameter is required")
+	}
+	return nil
+}
+
+func (t *ReadProcessOutputTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	proc, err := t.manager.Get(t.owner, stringParam(params, "id"))
+	if err != nil {
+		return failedResult(err)
+	}
+
+	// Optionally wait for output to settle (e.g., after sending a request to a server)
+	if wait := intParam(params, "wait_seconds"); wait > 0 {
+		if wait > int(maxStartWait/time.Second) {
+			wait = int(maxStartWait / time.Second)
+		}
+		waitForOutput(ctx, proc, stringParam(params, "wait_for"), time.Duration(wait)*time.Second)
+	}
+
+	since := int64(-1)
+	if v, ok := params["since"].(float64); ok {
+		since = int64(v)
+	}
+	output, cursor, dropped := proc.Output(since)
+
+	if n := intParam(params, "tail"); n > 0 {
+		lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+		if len(lines) > n {
+			output = strings.Join(lines[len(lines)-n:], "\n") + "\n"
+		}
+	}
+
+	header := processStatus(proc)
+	if dropped {
+		header += " (older output was dropped)"
+	}
+
+	return &ToolResult{
+		Success: true,
+		Output:  header + "\n" + output,
+		Data: map[string]interface{}{
+			"id":        proc.ID,
+			"running":   proc.Running(),
+			"exit_code": proc.ExitCode(),
+			"cursor":    cursor,
+			"truncated": dropped,
+			"output":    output,
+		},
+	}, nil
+}
+
+func (t *ReadProcessOutputTool) RequiresConfirmation(level SafetyLevel) bool {
+	return false // Read-only
+}
+
+// SendProcessInputTool writes to a background process's stdin
+type SendProcessInputTool struct {
+	manager *ProcessManager
+	owner   string
+}
+
+// NewSendProcessInputTool creates a new SendProcessInputTool
+func NewSendProcessInputTool(manager *ProcessManager, owner string) *SendProcessInputTool {
+	return &SendProcessInputTool{manager: manager, owner: owner}
+}
+
+func (t *SendProcessInputTool) Name() string {
+	return "send_process_input"
+}
+
+func (t *SendProcessInputTool) Description() string {
+	return "Write text to a background process's stdin, optionally closing it"
+}
+
+func (t *SendProcessInputTool) Validate(params map[string]interface{}) error {
+	if stringParam(params, "id") == "" {
+		return fmt.Errorf("id parameter is required")
+	}
+	closeInput, _ := params["close"].(bool)
+	if _, ok := params["input"].(string); !ok && !closeInput {
+		return fmt.Errorf("input parameter is required unless close is true")
+	}
+	return nil
+}
+
+func (t *SendProcessInputTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	proc, err := t.manager.Get(t.owner, stringParam(params, "id"))
+	if err != nil {
+		return failedResult(err)
+	}
+
+	input, _ := params["input"].(string)
+	if input != "" {
+		if err := proc.WriteInput(input); err != nil {
+			return failedResult(fmt.Errorf("failed to write to %s: %w", proc.ID, err))
+		}
+	}
+
+	if closeInput, _ := params["close"].(bool); closeInput {
+		if err := proc.CloseInput(); err != nil {
+			return failedResult(fmt.Errorf("failed to close stdin of %s: %w", proc.ID, err))
+		}
+	}
+
+	return &ToolResult{
+		Success: true,
+		Output:  fmt.Sprintf("Sent %d bytes to %s", len(input), proc.ID),
+		Data:    map[string]interface{}{"id": proc.ID, "bytes": len(input)},
+	}, nil
+}
+
+func (t *SendProcessInputTool) RequiresConfirmation(level SafetyLevel) bool {
+	return level >= SafetyMedium // Input can drive the process to do anything
+}
+
+// StopProcessTool stops a background process and its children
+type StopProcessTool struct {
+	manager *ProcessManager
+	owner   string
+}
+
+// NewStopProcessTool creates a new StopProcessTool
+func NewStopProcessTool(manager *ProcessManager, owner string) *StopProcessTool {
+	return &StopProcessTool{manager: manager, owner: owner}
+}
+
+func (t *StopProcessTool) Name() string {
+	return "stop_process"
+}
+
+func (t *StopProcessTool) Description() string {
+	return "Stop a background process and all of its child processes"
+}
+
+func (t *StopProcessTool) Validate(params map[string]interface{}) error {
+	if stringParam(params, "id") == "" {
+		return fmt.Errorf("id parameter is required")
+	}
+	return nil
+}
+
+func (t *StopProcessTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	grace := defaultStopGrace
+	if seconds := intParam(params, "grace_seconds"); seconds > 0 {
+		grace = time.Duration(seconds) * time.Second
+	}
+
+	proc, err := t.manager.Stop(t.owner, stringParam(params, "id"), grace)
+	if err != nil {
+		return failedResult(err)
+	}
+
+	output, _, _ := proc.Output(-1)
+	return &ToolResult{
+		Success: true,
+		Output:  processStatus(proc) + "\n" + tail(output, 2000),
+		Data: map[string]interface{}{
+			"id":        proc.ID,
+			"exit_code": proc.ExitCode(),
+		},
+	}, nil
+}
+
+func (t *StopProcessTool) RequiresConfirmation(level SafetyLevel) bool {
+	return false // Stopping is always safe
+}
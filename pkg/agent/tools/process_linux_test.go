@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// processAlive reports whether pid exists and isn't a zombie waiting to be reaped
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// readPID waits for a process to write its pid to path
+func readPID(t *testing.T, path string) int {
+	t.Helper()
+	var pid int
+	for i := 0; i < 100 && pid == 0; i++ {
+		data, _ := os.ReadFile(path)
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		time.Sleep(20 * time.Millisecond)
+	}
+	if pid == 0 {
+		t.Fatal("child never started")
+	}
+	return pid
+}
+
+func TestProcessManager_StopOwnerKillsGroup(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "child.pid")
+	manager := NewProcessManager()
+
+	// The shell starts a child that ignores stdin and would outlive the shell
+	proc, err := manager.Start("task-1", dir, "sleep 60 & echo $! > child.pid; wait")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	childPID := readPID(t, pidFile)
+
+	if n := manager.StopOwner("task-1"); n != 1 {
+		t.Errorf("expected 1 process stopped, got %d", n)
+	}
+	if proc.Running() {
+		t.Error("expected shell to be stopped")
+	}
+	for i := 0; i < 50 && processAlive(childPID); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if processAlive(childPID) {
+		t.Error("expected child process to be killed with its group")
+	}
+	if len(manager.List("")) != 0 {
+		t.Error("expected stopped owner's processes to be forgotten")
+	}
+}
+
+func TestProcessManager_StopOwnerKillsOrphans(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewProcessManager()
+
+	// The shell exits at once, leaving its child behind in the group
+	proc, err := manager.Start("task-1", dir, "sleep 60 > /dev/null 2>&1 & echo $! > child.pid")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	childPID := readPID(t, filepath.Join(dir, "child.pid"))
+	select {
+	case <-proc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shell never exited")
+	}
+	if !processAlive(childPID) {
+		t.Fatal("child exited with the shell")
+	}
+
+	manager.StopOwner("task-1")
+	for i := 0; i < 50 && processAlive(childPID); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if processAlive(childPID) {
+		t.Error("expected orphaned child to be killed with its group")
+	}
+}
+
+func TestProcessManager_GroupDiesWithOwner(t *testing.T) {
+	if dir := os.Getenv("SMITH_TEST_PROCESS_OWNER"); dir != "" {
+		// The owner: start a shell with a child, then wait to be killed
+		if _, err := NewProcessManager().Start("task-1", dir, "sleep 60 & echo $! > child.pid; wait"); err != nil {
+			os.Exit(1)
+		}
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+
+	dir := t.TempDir()
+	owner := exec.Command(os.Args[0], "-test.run=^TestProcessManager_GroupDiesWithOwner$")
+	owner.Env = append(os.Environ(), "SMITH_TEST_PROCESS_OWNER="+dir)
+	if err := owner.Start(); err != nil {
+		t.Fatalf("starting owner: %v", err)
+	}
+	childPID := readPID(t, filepath.Join(dir, "child.pid"))
+
+	// Killed outright, the owner gets no chance to stop its processes
+	_ = owner.Process.Kill()
+	_ = owner.Wait()
+
+	for i := 0; i < 100 && processAlive(childPID); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if processAlive(childPID) {
+		_ = syscall.Kill(childPID, syscall.SIGKILL)
+		t.Error("expected the group to be killed when its owner died")
+	}
+}
+
+func TestProcessManager_StopTwice(t *testing.T) {
+	manager := NewProcessManager()
+	proc, err := manager.Start("task-1", t.TempDir(), "sleep 60")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := manager.Stop("task-1", proc.ID, time.Second); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if proc.guard != nil {
+		t.Error("expected the guard to be released once the group is stopped")
+	}
+	// Stopping again must not signal the group, whose ID may be reused
+	manager.StopOwner("task-1")
+}
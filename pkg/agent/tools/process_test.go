@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	b := newOutputBuffer(8)
+	_, _ = b.Write([]byte("hello "))
+
+	data, next, dropped := b.ReadFrom(0)
+	if string(data) != "hello " || next != 6 || dropped {
+		t.Fatalf("unexpected read: %q %d %v", data, next, dropped)
+	}
+
+	_, _ = b.Write([]byte("world"))
+	data, next, _ = b.ReadFrom(next)
+	if string(data) != "world" || next != 11 {
+		t.Errorf("expected resumed read of 'world', got %q %d", data, next)
+	}
+
+	// Only the last 8 bytes are kept
+	data, _, dropped = b.ReadFrom(0)
+	if string(data) != "lo world" || !dropped {
+		t.Errorf("expected ring-buffered 'lo world' with dropped output, got %q %v", data, dropped)
+	}
+}
+
+func TestProcessTools(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewProcessManager()
+	defer manager.StopAll()
+
+	// A line-echoing "server" that announces readiness
+	start := NewStartProcessTool(dir, manager, "task-1")
+	params := map[string]interface{}{
+		"command":  `echo ready; while read line; do echo "got $line"; done`,
+		"wait_for": "ready",
+	}
+	if err := start.Validate(params); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	result, err := start.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("start_process failed: %v", err)
+	}
+	data := result.Data.(map[string]interface{})
+	if data["ready"] != true {
+		t.Fatalf("expected process to be ready: %s", result.Output)
+	}
+	id := data["id"].(string)
+
+	send := NewSendProcessInputTool(manager, "task-1")
+	if _, err := send.Execute(context.Background(), map[string]interface{}{"id": id, "input": "ping\n"}); err != nil {
+		t.Fatalf("send_process_input failed: %v", err)
+	}
+
+	read := NewReadProcessOutputTool(manager, "task-1")
+	result, err = read.Execute(context.Background(), map[string]interface{}{
+		"id":           id,
+		"wait_for":     "got ping",
+		"wait_seconds": float64(5),
+	})
+	if err != nil {
+		t.Fatalf("read_process_output failed: %v", err)
+	}
+	if out := result.Data.(map[string]interface{})["output"].(string); !strings.Contains(out, "got ping") || strings.Contains(out, "ready") {
+		t.Errorf("expected only new output since start, got %q", out)
+	}
+
+	// Other tasks can't see the process
+	if _, err := NewReadProcessOutputTool(manager, "task-2").Execute(context.Background(), map[string]interface{}{"id": id}); err == nil {
+		t.Error("expected error reading another task's process")
+	}
+
+	stop := NewStopProcessTool(manager, "task-1")
+	if _, err := stop.Execute(context.Background(), map[string]interface{}{"id": id}); err != nil {
+		t.Fatalf("stop_process failed: %v", err)
+	}
+	proc, _ := manager.Get("task-1", id)
+	if proc.Running() {
+		t.Error("expected process to be stopped")
+	}
+}
+
+func TestStartProcessTool_ImmediateFailure(t *testing.T) {
+	manager := NewProcessManager()
+	defer manager.StopAll()
+
+	result, err := NewStartProcessTool(t.TempDir(), manager, "chat").Execute(context.Background(), map[string]interface{}{
+		"command":      "echo boom; exit 3",
+		"wait_seconds": float64(2),
+	})
+	if err == nil || result.Success {
+		t.Fatal("expected failure for process that exits non-zero")
+	}
+	if data := result.Data.(map[string]interface{}); data["exit_code"] != 3 {
+		t.Errorf("expected exit code 3, got %v", data["exit_code"])
+	}
+	if !strings.Contains(result.Output, "boom") {
+		t.Errorf("expected output in result, got %q", result.Output)
+	}
+}
+
+func TestProcessTools_Metadata(t *testing.T) {
+	manager := NewProcessManager()
+	for _, tool := range []Tool{
+		NewStartProcessTool("/tmp", manager, "chat"),
+		NewReadProcessOutputTool(manager, "chat"),
+		NewSendProcessInputTool(manager, "chat"),
+		NewStopProcessTool(manager, "chat"),
+	} {
+		if tool.Description() == "" {
+			t.Errorf("%s: expected non-empty description", tool.Name())
+		}
+		if err := tool.Validate(map[string]interface{}{}); err == nil {
+			t.Errorf("%s: expected validation error for missing params", tool.Name())
+		}
+	}
+
+	if !NewStartProcessTool("/tmp", manager, "chat").RequiresConfirmation(SafetyMedium) {
+		t.Error("start_process should require confirmation at medium safety")
+	}
+	if NewStopProcessTool(manager, "chat").RequiresConfirmation(SafetyHigh) {
+		t.Error("stop_process should never require confirmation")
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processSysProcAttr puts the process in its own group so signals reach its children
+func processSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interruptProcessGroup asks every process in the group to terminate
+func interruptProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGTERM)
+}
+
+// killProcessGroup kills every process in the group
+func killProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
+
+// groupGuard is a shell in a started process's group that waits on a pipe
+// only smith holds open. However smith exits, even when killed outright, the
+// pipe closes and the guard kills the group. Until the guard is reaped the
+// group's ID can't be reused, so signalling the group can't hit anything else.
+type groupGuard struct {
+	cmd  *exec.Cmd
+	pipe *os.File // Write end; never written to
+}
+
+// startGroupGuard starts a guard in the process group led by p
+func startGroupGuard(p *os.Process) (*groupGuard, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	// Signals meant for the group's processes leave the guard waiting
+	cmd := exec.Command("sh", "-c", "trap '' HUP INT TERM; read _; kill -s KILL 0")
+	cmd.Stdin = r
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: p.Pid}
+	if err := cmd.Start(); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	return &groupGuard{cmd: cmd, pipe: w}, nil
+}
+
+// release kills and reaps the guard, after which the group must not be signalled
+func (g *groupGuard) release() {
+	if g == nil {
+		return
+	}
+	_ = g.cmd.Process.Kill()
+	_ = g.cmd.Wait()
+	_ = g.pipe.Close()
+}
@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"os"
+	"syscall"
+)
+
+// processSysProcAttr uses default attributes; Windows has no process groups to signal
+func processSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// interruptProcessGroup kills the process; Windows has no SIGTERM
+func interruptProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+// killProcessGroup kills the process
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+// groupGuard is unused; Windows has no process groups to guard
+type groupGuard struct{}
+
+// startGroupGuard returns no guard
+func startGroupGuard(p *os.Process) (*groupGuard, error) {
+	return nil, nil
+}
+
+// release does nothing
+func (g *groupGuard) release() {}
@@ -0,0 +1,915 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Supported test frameworks
+const (
+	FrameworkGo     = "go"
+	FrameworkPytest = "pytest"
+	FrameworkJest   = "jest"
+)
+
+// Test statuses
+const (
+	TestPass = "pass"
+	TestFail = "fail"
+	TestSkip = "skip"
+)
+
+// maxReportedTests is how many tests are listed in tool output before passing tests are omitted
+const maxReportedTests = 100
+
+var (
+	goCoverageRe  = regexp.MustCompile(`coverage: ([\d.]+)% of statements`)
+	goLocationRe  = regexp.MustCompile(`^\s*([\w./\\-]+\.go):(\d+):`)
+	pyLocationRe  = regexp.MustCompile(`([\w./\\-]+\.py):(\d+):`)
+	jestLocatonRe = regexp.MustCompile(`([^\s()]+\.[jt]sx?):(\d+):\d+`)
+)
+
+// TestCase is the result of a single test
+type TestCase struct {
+	Name     string  `json:"name"`
+	Suite    string  `json:"suite,omitempty"` // Go package, pytest class/module or jest file
+	Status   string  `json:"status"`          // pass, fail, skip
+	Duration float64 `json:"duration"`        // Seconds
+	Message  string  `json:"message,omitempty"`
+	Location string  `json:"location,omitempty"` // file:line of the failure
+}
+
+// TestReport is the structured result of a test run
+type TestReport struct {
+	Framework string             `json:"framework"`
+	Command   string             `json:"command"`
+	Passed    int                `json:"passed"`
+	Failed    int                `json:"failed"`
+	Skipped   int                `json:"skipped"`
+	Duration  float64            `json:"duration"`           // Seconds
+	Coverage  map[string]float64 `json:"coverage,omitempty"` // Percent by package or file; "total" when reported
+	Errors    []string           `json:"errors,omitempty"`   // Build or collection errors
+	Affected  []string           `json:"affected,omitempty"` // Targets selected from changed files
+	Tests     []TestCase         `json:"tests"`
+}
+
+// OK reports whether every test passed and nothing failed to build
+func (r *TestReport) OK() bool {
+	return r.Failed == 0 && len(r.Errors) == 0
+}
+
+// TestPlan is the command a test run will execute
+type TestPlan struct {
+	Framework string
+	Args      []string
+	Affected  []string // Targets selected from changed files
+	Empty     bool     // Affected mode found nothing to run
+
+	reportFile string // junit XML or jest JSON output
+	coverFile  string // Coverage summary output
+	tempDir    string
+}
+
+// Cleanup removes the plan's temporary report files
+func (p *TestPlan) Cleanup() {
+	if p.tempDir != "" {
+		_ = os.RemoveAll(p.tempDir)
+	}
+}
+
+// Command returns the plan as a shell-style command line
+func (p *TestPlan) Command() string {
+	return strings.Join(p.Args, " ")
+}
+
+// RunTestsTool runs the project's tests and returns structured results
+type RunTestsTool struct {
+	workDir string
+	timeout time.Duration
+}
+
+// NewRunTestsTool creates a new RunTestsTool
+func NewRunTestsTool(workDir string, timeout time.Duration) *RunTestsTool {
+	if timeout == 0 {
+		timeout = 10 * time.Minute // Test suites take longer than single commands
+	}
+	return &RunTestsTool{workDir: workDir, timeout: timeout}
+}
+
+func (t *RunTestsTool) Name() string {
+	return "run_tests"
+}
+
+func (t *RunTestsTool) Description() string {
+	return "Run tests (go test, pytest, jest) and return per-test status, durations, failures with file:line and coverage"
+}
+
+func (t *RunTestsTool) Validate(params map[string]interface{}) error {
+	if framework := stringParam(params, "framework"); framework != "" {
+		switch framework {
+		case FrameworkGo, FrameworkPytest, FrameworkJest:
+		default:
+			return fmt.Errorf("unsupported framework: %s (use go, pytest or jest)", framework)
+		}
+	}
+
+	for _, target := range stringsParam(params, "targets") {
+		if strings.HasPrefix(target, "-") {
+			return fmt.Errorf("targets must be paths or packages, not flags: %s", target)
+		}
+	}
+
+	for _, path := range stringsParam(params, "changed_files") {
+		if err := validatePath(t.workDir, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Plan detects the framework and builds the test command without running it
+func (t *RunTestsTool) Plan(ctx context.Context, params map[string]interface{}) (*TestPlan, error) {
+	framework := stringParam(params, "framework")
+	if framework == "" {
+		framework = DetectTestFramework(t.workDir)
+		if framework == "" {
+			return nil, fmt.Errorf("no supported test framework found (go.mod, package.json with jest, or pytest project)")
+		}
+	}
+
+	targets := stringsParam(params, "targets")
+	run := stringParam(params, "run")
+	coverage, _ := params["coverage"].(bool)
+	affected, _ := params["affected"].(bool)
+
+	plan := &TestPlan{Framework: framework}
+
+	if affected {
+		changed := stringsParam(params, "changed_files")
+		if len(changed) == 0 {
+			var err error
+			changed, err = gitChangedFiles(ctx, t.workDir)
+			if err != nil {
+				return nil, fmt.Errorf("affected mode needs changed_files (git unavailable: %w)", err)
+			}
+		}
+
+		selected, err := t.affectedTargets(ctx, framework, changed)
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) == 0 {
+			plan.Empty = true
+			return plan, nil
+		}
+		plan.Affected = selected
+		targets = selected
+	}
+
+	switch framework {
+	case FrameworkGo:
+		plan.Args = []string{"go", "test", "-json"}
+		if coverage {
+			plan.Args = append(plan.Args, "-cover")
+		}
+		if run != "" {
+			plan.Args = append(plan.Args, "-run", run)
+		}
+		if len(targets) == 0 {
+			targets = []string{"./..."}
+		}
+		plan.Args = append(plan.Args, targets...)
+
+	case FrameworkPytest:
+		tempDir, err := os.MkdirTemp("", "smith-tests-*")
+		if err != nil {
+			return nil, err
+		}
+		plan.tempDir = tempDir
+		plan.reportFile = filepath.Join(tempDir, "junit.xml")
+		plan.Args = []string{pythonExecutable(), "-m", "pytest", "-q", "--junitxml=" + plan.reportFile}
+		if coverage {
+			plan.coverFile = filepath.Join(tempDir, "coverage.json")
+			plan.Args = append(plan.Args, "--cov", "--cov-report=json:"+plan.coverFile)
+		}
+		if run != "" {
+			plan.Args = append(plan.Args, "-k", run)
+		}
+		plan.Args = append(plan.Args, targets...)
+
+	case FrameworkJest:
+		tempDir, err := os.MkdirTemp("", "smith-tests-*")
+		if err != nil {
+			return nil, err
+		}
+		plan.tempDir = tempDir
+		plan.reportFile = filepath.Join(tempDir, "jest.json")
+		plan.Args = append([]string{"npx", "jest"}, "--json", "--outputFile="+plan.reportFile)
+		if coverage {
+			plan.coverFile = filepath.Join(tempDir, "coverage-summary.json")
+			plan.Args = append(plan.Args, "--coverage", "--coverageReporters=json-summary", "--coverageDirectory="+tempDir)
+		}
+		if run != "" {
+			plan.Args = append(plan.Args, "-t", run)
+		}
+		if affected {
+			plan.Args = append(plan.Args, "--findRelatedTests")
+		}
+		plan.Args = append(plan.Args, targets...)
+	}
+
+	return plan, nil
+}
+
+func (t *RunTestsTool) Execute(ctx context.Context, params map[string]interface{}) (*ToolResult, error) {
+	plan, err := t.Plan(ctx, params)
+	if err != nil {
+		return failedResult(err)
+	}
+	return t.ExecutePlan(ctx, plan)
+}
+
+// ExecutePlan runs a plan from Plan and parses its results.
+// Failing tests are reported in the result; an error means the tests couldn't run.
+func (t *RunTestsTool) ExecutePlan(ctx context.Context, plan *TestPlan) (*ToolResult, error) {
+	defer plan.Cleanup()
+
+	report := &TestReport{Framework: plan.Framework, Command: plan.Command(), Tests: []TestCase{}}
+	if plan.Empty {
+		return t.result(report, "No tests affected by the changed files")
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	start := time.Now()
+	cmd := exec.CommandContext(execCtx, plan.Args[0], plan.Args[1:]...)
+	cmd.Dir = t.workDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	report.Duration = time.Since(start).Seconds()
+	report.Affected = plan.Affected
+
+	if execCtx.Err() == context.DeadlineExceeded {
+		err := fmt.Errorf("tests timed out after %v", t.timeout)
+		return &ToolResult{Success: false, Output: tail(stdout.String()+stderr.String(), 4000), Error: err.Error()}, err
+	}
+	if runErr != nil {
+		if _, ok := runErr.(*exec.ExitError); !ok {
+			// Runner couldn't start (e.g., toolchain not installed)
+			err := fmt.Errorf("running %s: %w", plan.Args[0], runErr)
+			return failedResult(err)
+		}
+	}
+
+	switch plan.Framework {
+	case FrameworkGo:
+		parseGoTestJSON(&stdout, stderr.String(), report)
+	case FrameworkPytest:
+		if err := parseJUnitXML(plan.reportFile, t.workDir, report); err != nil {
+			report.Errors = append(report.Errors, tail(stdout.String()+stderr.String(), 4000))
+		}
+		parsePytestCoverage(plan.coverFile, report)
+	case FrameworkJest:
+		if err := parseJestJSON(plan.reportFile, t.workDir, report); err != nil {
+			report.Errors = append(report.Errors, tail(stdout.String()+stderr.String(), 4000))
+		}
+		parseJestCoverage(plan.coverFile, t.workDir, report)
+	}
+
+	// A failing exit without any failing test means the run itself broke
+	if runErr != nil && report.Failed == 0 && len(report.Errors) == 0 {
+		report.Errors = append(report.Errors, tail(stdout.String()+stderr.String(), 4000))
+	}
+
+	summary := fmt.Sprintf("%d passed, %d failed, %d skipped", report.Passed, report.Failed, report.Skipped)
+	return t.result(report, summary)
+}
+
+// result wraps a report as a ToolResult, omitting passing tests from large outputs
+func (t *RunTestsTool) result(report *TestReport, summary string) (*ToolResult, error) {
+	shown := *report
+	if len(report.Tests) > maxReportedTests {
+		shown.Tests = nil
+		for _, tc := range report.Tests {
+			if tc.Status != TestPass {
+				shown.Tests = append(shown.Tests, tc)
+			}
+		}
+	}
+
+	out, err := json.MarshalIndent(shown, "", "  ")
+	if err != nil {
+		return failedResult(err)
+	}
+
+	result := &ToolResult{
+		Success: report.OK(),
+		Output:  summary + "\n" + string(out),
+		Data:    report,
+	}
+	if !report.OK() {
+		result.Error = summary
+	}
+	return result, nil
+}
+
+func (t *RunTestsTool) RequiresConfirmation(level SafetyLevel) bool {
+	return level >= SafetyMedium // Runs project code, same as command execution
+}
+
+// DetectTestFramework guesses the test framework from project files
+func DetectTestFramework(dir string) string {
+	if fileExistsAt(dir, "go.mod") {
+		return FrameworkGo
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil && bytes.Contains(data, []byte(`"jest"`)) {
+		return FrameworkJest
+	}
+
+	for _, name := range []string{"pytest.ini", "conftest.py", "pyproject.toml", "setup.cfg", "tox.ini"} {
+		if fileExistsAt(dir, name) {
+			return FrameworkPytest
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "test_*.py")); len(matches) > 0 {
+		return FrameworkPytest
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "tests", "test_*.py")); len(matches) > 0 {
+		return FrameworkPytest
+	}
+
+	return ""
+}
+
+// affectedTargets maps changed files to the tests that cover them
+func (t *RunTestsTool) affectedTargets(ctx context.Context, framework string, changed []string) ([]string, error) {
+	switch framework {
+	case FrameworkGo:
+		return goAffectedPackages(ctx, t.workDir, changed)
+	case FrameworkPytest:
+		return pytestAffectedFiles(t.workDir, changed), nil
+	case FrameworkJest:
+		// jest --findRelatedTests resolves the dependency graph itself
+		var files []string
+		for _, f := range changed {
+			if isSourceFile(f, ".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs") {
+				files = append(files, f)
+			}
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("unsupported framework: %s", framework)
+}
+
+// goAffectedPackages returns the packages containing changed files and every
+// package in the module that depends on them
+func goAffectedPackages(ctx context.Context, dir string, changed []string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-f", `{{.ImportPath}}|{{.Dir}}|{{join .Deps ","}}`, "./...")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing packages: %w", err)
+	}
+
+	type goPkg struct {
+		importPath string
+		deps       []string
+	}
+	byDir := make(map[string]string)
+	var pkgs []goPkg
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.SplitN(line, "|", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		byDir[filepath.Clean(parts[1])] = parts[0]
+		pkgs = append(pkgs, goPkg{importPath: parts[0], deps: strings.Split(parts[2], ",")})
+	}
+
+	changedPkgs := make(map[string]bool)
+	for _, f := range changed {
+		if !isSourceFile(f, ".go") && filepath.Base(f) != "go.mod" {
+			continue
+		}
+		abs := resolvePath(dir, f)
+		if absDir, err := filepath.Abs(filepath.Dir(abs)); err == nil {
+			abs = filepath.Join(absDir, filepath.Base(abs))
+		}
+		if importPath, ok := byDir[filepath.Dir(abs)]; ok {
+			changedPkgs[importPath] = true
+		}
+	}
+
+	var affected []string
+	for _, p := range pkgs {
+		if changedPkgs[p.importPath] {
+			affected = append(affected, p.importPath)
+			continue
+		}
+		for _, dep := range p.deps {
+			if changedPkgs[dep] {
+				affected = append(affected, p.importPath)
+				break
+			}
+		}
+	}
+	return affected, nil
+}
+
+// pytestAffectedFiles returns changed test files and the test files named
+// after changed modules (test_x.py / x_test.py for x.py)
+func pytestAffectedFiles(dir string, changed []string) []string {
+	selected := make(map[string]bool)
+	wanted := make(map[string]bool)
+
+	for _, f := range changed {
+		if !isSourceFile(f, ".py") {
+			continue
+		}
+		base := filepath.Base(f)
+		if strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py") {
+			selected[filepath.ToSlash(f)] = true
+			continue
+		}
+		module := strings.TrimSuffix(base, ".py")
+		wanted["test_"+module+".py"] = true
+		wanted[module+"_test.py"] = true
+	}
+
+	if len(wanted) > 0 {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if path != dir && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "venv" || name == "__pycache__") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if wanted[d.Name()] {
+				if rel, err := filepath.Rel(dir, path); err == nil {
+					selected[filepath.ToSlash(rel)] = true
+				}
+			}
+			return nil
+		})
+	}
+
+	var files []string
+	for f := range selected {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// gitChangedFiles lists modified and untracked files
+func gitChangedFiles(ctx context.Context, dir string) ([]string, error) {
+	var files []string
+	for _, args := range [][]string{
+		{"diff", "--name-only", "HEAD"},
+		{"ls-files", "--others", "--exclude-standard"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if line != "" {
+				files = append(files, line)
+			}
+		}
+	}
+	return files, nil
+}
+
+// goTestEvent is a line of go test -json output
+type goTestEvent struct {
+	Action      string
+	Package     string
+	Test        string
+	Elapsed     float64
+	Output      string
+	ImportPath  string // build-output events
+	FailedBuild string // Build whose failure failed the package
+}
+
+// parseGoTestJSON parses go test -json output into the report
+func parseGoTestJSON(stdout *bytes.Buffer, stderr string, report *TestReport) {
+	outputs := make(map[string][]string)  // package/test -> output lines
+	failedPkgs := make(map[string]string) // package -> failed build
+
+	pkgHasFailedTest := make(map[string]bool)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var ev goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		key := ev.Package + "\x00" + ev.Test
+
+		switch ev.Action {
+		case "output":
+			outputs[key] = append(outputs[key], ev.Output)
+			if ev.Test == "" {
+				if m := goCoverageRe.FindStringSubmatch(ev.Output); m != nil {
+					var pct float64
+					_, _ = fmt.Sscanf(m[1], "%g", &pct)
+					if report.Coverage == nil {
+						report.Coverage = make(map[string]float64)
+					}
+					report.Coverage[ev.Package] = pct
+				}
+			}
+		case "build-output":
+			outputs["build\x00"+ev.ImportPath] = append(outputs["build\x00"+ev.ImportPath], ev.Output)
+		case "pass", "fail", "skip":
+			if ev.Test == "" {
+				if ev.Action == "fail" {
+					failedPkgs[ev.Package] = ev.FailedBuild
+				}
+				continue
+			}
+			tc := TestCase{
+				Name:     ev.Test,
+				Suite:    ev.Package,
+				Status:   map[string]string{"pass": TestPass, "fail": TestFail, "skip": TestSkip}[ev.Action],
+				Duration: ev.Elapsed,
+			}
+			if ev.Action == "fail" {
+				pkgHasFailedTest[ev.Package] = true
+				tc.Message, tc.Location = goFailureDetails(outputs[key])
+			}
+			report.Tests = append(report.Tests, tc)
+		}
+	}
+
+	// Packages that failed without a failing test didn't build or panicked in init
+	for pkg, build := range failedPkgs {
+		if pkgHasFailedTest[pkg] {
+			continue
+		}
+		var lines []string
+		if build != "" {
+			lines = append(lines, outputs["build\x00"+build]...)
+		}
+		lines = append(lines, outputs[pkg+"\x00"]...)
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", pkg, strings.TrimSpace(strings.Join(lines, ""))))
+	}
+	if len(report.Errors) == 0 && len(report.Tests) == 0 && strings.TrimSpace(stderr) != "" {
+		report.Errors = append(report.Errors, tail(stderr, 4000))
+	}
+	sort.Strings(report.Errors)
+
+	countTests(report)
+}
+
+// goFailureDetails extracts the failure message and first file:line from test output
+func goFailureDetails(lines []string) (string, string) {
+	var msg []string
+	location := ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") || trimmed == "" {
+			continue
+		}
+		if location == "" {
+			if m := goLocationRe.FindStringSubmatch(line); m != nil {
+				location = m[1] + ":" + m[2]
+			}
+		}
+		msg = append(msg, trimmed)
+	}
+	return truncate(strings.Join(msg, "\n"), 2000), location
+}
+
+// junitSuite matches both <testsuites> and <testsuite> roots
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// parseJUnitXML parses a pytest --junitxml report
+func parseJUnitXML(path, workDir string, report *TestReport) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("parsing junit report: %w", err)
+	}
+
+	var walk func(s junitSuite)
+	walk = func(s junitSuite) {
+		for _, c := range s.Cases {
+			tc := TestCase{Name: c.Name, Suite: c.ClassName, Status: TestPass, Duration: c.Time}
+			failure := c.Failure
+			if failure == nil {
+				failure = c.Error
+			}
+			switch {
+			case failure != nil:
+				tc.Status = TestFail
+				tc.Message = truncate(strings.TrimSpace(firstNonEmpty(failure.Message, failure.Text)), 2000)
+				if m := pyLocationRe.FindAllStringSubmatch(failure.Text, -1); len(m) > 0 {
+					last := m[len(m)-1]
+					tc.Location = relativeTo(workDir, last[1]) + ":" + last[2]
+				} else if c.File != "" {
+					tc.Location = fmt.Sprintf("%s:%d", c.File, c.Line+1)
+				}
+				// Errors without a test name are collection failures
+				if c.Name == "" {
+					report.Errors = append(report.Errors, tc.Message)
+					continue
+				}
+			case c.Skipped != nil:
+				tc.Status = TestSkip
+				tc.Message = strings.TrimSpace(c.Skipped.Message)
+			}
+			report.Tests = append(report.Tests, tc)
+		}
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	countTests(report)
+	return nil
+}
+
+// parsePytestCoverage reads a pytest-cov JSON report
+func parsePytestCoverage(path string, report *TestReport) {
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var cov struct {
+		Totals struct {
+			PercentCovered float64 `json:"percent_covered"`
+		} `json:"totals"`
+		Files map[string]struct {
+			Summary struct {
+				PercentCovered float64 `json:"percent_covered"`
+			} `json:"summary"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &cov); err != nil {
+		return
+	}
+
+	report.Coverage = map[string]float64{"total": round1(cov.Totals.PercentCovered)}
+	for file, f := range cov.Files {
+		report.Coverage[file] = round1(f.Summary.PercentCovered)
+	}
+}
+
+// jestReport is the subset of jest --json output we use
+type jestReport struct {
+	TestResults []struct {
+		Name             string `json:"name"`
+		Status           string `json:"status"`
+		Message          string `json:"message"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Status          string   `json:"status"`
+			Duration        float64  `json:"duration"` // Milliseconds
+			FailureMessages []string `json:"failureMessages"`
+			Location        *struct {
+				Line int `json:"line"`
+			} `json:"location"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// parseJestJSON parses a jest --json report
+func parseJestJSON(path, workDir string, report *TestReport) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var jr jestReport
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return fmt.Errorf("parsing jest report: %w", err)
+	}
+
+	for _, file := range jr.TestResults {
+		suite := relativeTo(workDir, file.Name)
+		if file.Status == "failed" && len(file.AssertionResults) == 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", suite, truncate(strings.TrimSpace(file.Message), 2000)))
+			continue
+		}
+
+		for _, a := range file.AssertionResults {
+			tc := TestCase{Name: a.FullName, Suite: suite, Duration: a.Duration / 1000}
+			switch a.Status {
+			case "passed":
+				tc.Status = TestPass
+			case "failed":
+				tc.Status = TestFail
+				msg := strings.Join(a.FailureMessages, "\n")
+				tc.Message = truncate(strings.TrimSpace(msg), 2000)
+				tc.Location = jestFailureLocation(msg, file.Name, workDir)
+				if tc.Location == "" && a.Location != nil {
+					tc.Location = fmt.Sprintf("%s:%d", suite, a.Location.Line)
+				}
+			default:
+				tc.Status = TestSkip // pending, skipped, todo, disabled
+			}
+			report.Tests = append(report.Tests, tc)
+		}
+	}
+
+	countTests(report)
+	return nil
+}
+
+// jestFailureLocation finds the stack frame in the test file, falling back to the first frame
+func jestFailureLocation(msg, testFile, workDir string) string {
+	matches := jestLocatonRe.FindAllStringSubmatch(msg, -1)
+	for _, m := range matches {
+		if m[1] == testFile || strings.HasSuffix(testFile, m[1]) {
+			return relativeTo(workDir, m[1]) + ":" + m[2]
+		}
+	}
+	for _, m := range matches {
+		if !strings.Contains(m[1], "node_modules") {
+			return relativeTo(workDir, m[1]) + ":" + m[2]
+		}
+	}
+	return ""
+}
+
+// parseJestCoverage reads a jest json-summary coverage report
+func parseJestCoverage(path, workDir string, report *TestReport) {
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var summary map[string]struct {
+		Lines struct {
+			Pct float64 `json:"pct"`
+		} `json:"lines"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return
+	}
+
+	report.Coverage = make(map[string]float64)
+	for file, s := range summary {
+		if file != "total" {
+			file = relativeTo(workDir, file)
+		}
+		report.Coverage[file] = round1(s.Lines.Pct)
+	}
+}
+
+func countTests(report *TestReport) {
+	report.Passed, report.Failed, report.Skipped = 0, 0, 0
+	for _, tc := range report.Tests {
+		switch tc.Status {
+		case TestPass:
+			report.Passed++
+		case TestFail:
+			report.Failed++
+		case TestSkip:
+			report.Skipped++
+		}
+	}
+}
+
+func pythonExecutable() string {
+	if _, err := exec.LookPath("python3"); err == nil {
+		return "python3"
+	}
+	return "python"
+}
+
+// stringsParam reads a string or list-of-strings parameter
+func stringsParam(params map[string]interface{}, name string) []string {
+	switch v := params[name].(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func isSourceFile(path string, exts ...string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExistsAt(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+func relativeTo(dir, path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(absDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
+
+func round1(f float64) float64 {
+	return float64(int(f*10+0.5)) / 10
+}
@@ -0,0 +1,295 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupTestModule creates a Go module with passing, failing and skipped tests
+func setupTestModule(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod": "module example.com/calc\n\ngo 1.21\n",
+		"calc/calc.go": `package calc
+
+func Add(a, b int) int { return a + b }
+
+func Sub(a, b int) int { return a + b }
+`,
+		"calc/calc_test.go": `package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Fatal("bad add")
+	}
+}
+
+func TestSub(t *testing.T) {
+	if got := Sub(3, 1); got != 2 {
+		t.Errorf("Sub(3, 1) = %d, want 2", got)
+	}
+}
+
+func TestSlow(t *testing.T) {
+	t.Skip("too slow")
+}
+`,
+		"util/util.go": "package util\n\nfunc Double(n int) int { return n * 2 }\n",
+		"util/util_test.go": `package util
+
+import "testing"
+
+func TestDouble(t *testing.T) {
+	if Double(2) != 4 {
+		t.Fatal("bad double")
+	}
+}
+`,
+	}
+
+	for path, content := range files {
+		full := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	return tempDir
+}
+
+func TestRunTestsTool_Go(t *testing.T) {
+	dir := setupTestModule(t)
+	tool := NewRunTestsTool(dir, 0)
+
+	params := map[string]interface{}{"coverage": true}
+	if err := tool.Validate(params); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("run_tests failed: %v", err)
+	}
+	if result.Success {
+		t.Error("expected failure with a failing test")
+	}
+
+	report := result.Data.(*TestReport)
+	if report.Framework != FrameworkGo {
+		t.Errorf("expected go framework, got %s", report.Framework)
+	}
+	if report.Passed != 2 || report.Failed != 1 || report.Skipped != 1 {
+		t.Fatalf("expected 2 passed, 1 failed, 1 skipped, got %+v", report)
+	}
+
+	for _, tc := range report.Tests {
+		if tc.Name != "TestSub" {
+			continue
+		}
+		if tc.Suite != "example.com/calc/calc" {
+			t.Errorf("unexpected suite: %s", tc.Suite)
+		}
+		if tc.Location != "calc_test.go:13" {
+			t.Errorf("expected failure at calc_test.go:13, got %q", tc.Location)
+		}
+		if !strings.Contains(tc.Message, "Sub(3, 1) = 4, want 2") {
+			t.Errorf("unexpected failure message: %q", tc.Message)
+		}
+	}
+
+	if _, ok := report.Coverage["example.com/calc/util"]; !ok {
+		t.Errorf("expected coverage for util package, got %v", report.Coverage)
+	}
+}
+
+func TestRunTestsTool_Affected(t *testing.T) {
+	dir := setupTestModule(t)
+	tool := NewRunTestsTool(dir, 0)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"affected":      true,
+		"changed_files": []interface{}{"util/util.go"},
+	})
+	if err != nil {
+		t.Fatalf("run_tests failed: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("expected util tests to pass: %s", result.Output)
+	}
+
+	report := result.Data.(*TestReport)
+	if len(report.Affected) != 1 || report.Affected[0] != "example.com/calc/util" {
+		t.Errorf("expected only util to be affected, got %v", report.Affected)
+	}
+	if report.Passed != 1 || len(report.Tests) != 1 {
+		t.Errorf("expected only TestDouble to run, got %+v", report.Tests)
+	}
+
+	// Files outside any package select nothing
+	result, err = tool.Execute(context.Background(), map[string]interface{}{
+		"affected":      true,
+		"changed_files": []interface{}{"README.md"},
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("expected empty run to succeed: %v", err)
+	}
+	if n := len(result.Data.(*TestReport).Tests); n != 0 {
+		t.Errorf("expected no tests, got %d", n)
+	}
+}
+
+func TestRunTestsTool_BuildFailure(t *testing.T) {
+	dir := setupTestModule(t)
+	if err := os.WriteFile(filepath.Join(dir, "util", "util.go"), []byte("package util\n\nfunc Double(n int) int { return n * }\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	result, err := NewRunTestsTool(dir, 0).Execute(context.Background(), map[string]interface{}{
+		"targets": []interface{}{"./util"},
+	})
+	if err != nil {
+		t.Fatalf("run_tests failed: %v", err)
+	}
+	report := result.Data.(*TestReport)
+	if result.Success || len(report.Errors) == 0 {
+		t.Fatalf("expected build error to be reported, got %+v", report)
+	}
+	if !strings.Contains(report.Errors[0], "util.go:3") {
+		t.Errorf("expected error to point at util.go:3, got %q", report.Errors[0])
+	}
+}
+
+func TestParseJUnitXML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "junit.xml")
+	xml := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" tests="3">
+<testcase classname="tests.test_calc" name="test_add" file="tests/test_calc.py" line="3" time="0.001"/>
+<testcase classname="tests.test_calc" name="test_sub" file="tests/test_calc.py" line="7" time="0.002">
+<failure message="assert 4 == 2">def test_sub():
+&gt;       assert sub(3, 1) == 2
+E       assert 4 == 2
+
+tests/test_calc.py:9: AssertionError</failure></testcase>
+<testcase classname="tests.test_calc" name="test_slow" time="0"><skipped message="too slow"/></testcase>
+</testsuite></testsuites>`
+	if err := os.WriteFile(path, []byte(xml), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	report := &TestReport{}
+	if err := parseJUnitXML(path, dir, report); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if report.Passed != 1 || report.Failed != 1 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	failed := report.Tests[1]
+	if failed.Location != "tests/test_calc.py:9" || failed.Message != "assert 4 == 2" {
+		t.Errorf("unexpected failure: %+v", failed)
+	}
+}
+
+func TestParseJestJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jest.json")
+	testFile := filepath.Join(dir, "src", "calc.test.js")
+	report := `{"testResults":[{"name":"` + testFile + `","status":"failed","assertionResults":[
+{"fullName":"calc adds","status":"passed","duration":3},
+{"fullName":"calc subtracts","status":"failed","duration":5,"failureMessages":["Error: expect(received).toBe(expected)\n    at Object.<anonymous> (` + testFile + `:12:20)"]},
+{"fullName":"calc divides","status":"pending"}]}]}`
+	if err := os.WriteFile(path, []byte(report), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	r := &TestReport{}
+	if err := parseJestJSON(path, dir, r); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if r.Passed != 1 || r.Failed != 1 || r.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", r)
+	}
+	if got := r.Tests[1].Location; got != "src/calc.test.js:12" {
+		t.Errorf("expected failure at src/calc.test.js:12, got %q", got)
+	}
+	if r.Tests[1].Suite != "src/calc.test.js" {
+		t.Errorf("expected relative suite, got %q", r.Tests[1].Suite)
+	}
+}
+
+func TestDetectTestFramework(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		want    string
+	}{
+		{"go.mod", "module x\n", FrameworkGo},
+		{"package.json", `{"devDependencies":{"jest":"^29"}}`, FrameworkJest},
+		{"pytest.ini", "[pytest]\n", FrameworkPytest},
+		{"test_x.py", "def test_x(): pass\n", FrameworkPytest},
+		{"README.md", "hello\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if got := DetectTestFramework(dir); got != tt.want {
+				t.Errorf("DetectTestFramework() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPytestAffectedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"calc.py", "tests/test_calc.py", "tests/test_other.py"} {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(""), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	got := pytestAffectedFiles(dir, []string{"calc.py", "README.md"})
+	if len(got) != 1 || got[0] != "tests/test_calc.py" {
+		t.Errorf("expected tests/test_calc.py, got %v", got)
+	}
+}
+
+func TestRunTestsTool_Metadata(t *testing.T) {
+	tool := NewRunTestsTool("/tmp", 0)
+
+	if tool.Name() != "run_tests" {
+		t.Errorf("expected name 'run_tests', got '%s'", tool.Name())
+	}
+
+	if tool.Description() == "" {
+		t.Error("expected non-empty description")
+	}
+
+	if !tool.RequiresConfirmation(SafetyMedium) {
+		t.Error("run_tests should require confirmation at medium safety")
+	}
+
+	if err := tool.Validate(map[string]interface{}{"framework": "rspec"}); err == nil {
+		t.Error("expected error for unsupported framework")
+	}
+	if err := tool.Validate(map[string]interface{}{"targets": []interface{}{"-exec=rm"}}); err == nil {
+		t.Error("expected error for flag target")
+	}
+}
@@ -0,0 +1,229 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// Two kinds of caching cut the cost of resending the same context:
+//
+// Prompt caching happens at the provider, which reuses its work on a prompt
+// prefix it has seen recently. OpenAI models do this for any repeated prefix;
+// Anthropic models only for prefixes marked with cache_control, which
+// withPromptCache adds. Either way prompts should start with what changes
+// least, the system prompt, and reported cached tokens show up in
+// Response.CachedTokens.
+//
+// Response caching happens here: a CachingProvider answers idempotent
+// requests (see WithCacheable) it has answered before from a ResponseCache.
+
+// promptCacheControl marks the end of a cacheable prompt prefix
+var promptCacheControl = map[string]string{"type": "ephemeral"}
+
+// needsCacheControl reports whether a model only caches prompt prefixes
+// marked with cache_control
+func needsCacheControl(model string) bool {
+	return strings.HasPrefix(model, "anthropic/")
+}
+
+// withPromptCache adds prompt caching hints to messages for models that need
+// them: the system prompt and the conversation up to the newest user message
+// are marked as prefixes to cache. The next request extends the
+// conversation, so it reads all of that from the cache.
+func withPromptCache(model string, messages []wireMessage) []wireMessage {
+	if !needsCacheControl(model) {
+		return messages
+	}
+
+	lastSystem, lastUser := -1, -1
+	for i, msg := range messages {
+		if msg.Content == "" {
+			continue
+		}
+		switch msg.Role {
+		case "system":
+			lastSystem = i
+		case "user":
+			lastUser = i
+		}
+	}
+
+	marked := append([]wireMessage{}, messages...)
+	for _, i := range []int{lastSystem, lastUser} {
+		if i < 0 {
+			continue
+		}
+		// The mark goes on the last part of the content
+		var parts []contentPart
+		switch content := marked[i].Content.(type) {
+		case string:
+			parts = []contentPart{{Type: "text", Text: content}}
+		case []contentPart:
+			parts = append(parts, content...)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		parts[len(parts)-1].CacheControl = promptCacheControl
+		marked[i].Content = parts
+	}
+	return marked
+}
+
+// ResponseCache keeps the responses of idempotent requests
+type ResponseCache interface {
+	// Get returns the data cached under key, or nil if there is none
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put caches data under key
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+type cacheableKey struct{}
+
+// WithCacheable marks the requests made with ctx as idempotent, so a
+// CachingProvider may answer them from its cache. Use it for requests whose
+// answer only depends on the messages, like summaries and classification.
+func WithCacheable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheableKey{}, true)
+}
+
+// IsCacheable reports whether requests made with ctx are idempotent
+func IsCacheable(ctx context.Context) bool {
+	cacheable, _ := ctx.Value(cacheableKey{}).(bool)
+	return cacheable
+}
+
+// CacheOptions configures a CachingProvider
+type CacheOptions struct {
+	Model    string                              // Model the requests go to; part of the cache key
+	OnLookup func(ctx context.Context, hit bool) // Called for each cacheable request
+}
+
+// CachingProvider answers cacheable requests (see WithCacheable) from a
+// ResponseCache, and caches the provider's answers to those it hasn't seen.
+// Other requests go straight to the provider. Cached answers report no
+// tokens, since none were spent. The cache is only an optimization: when it
+// can't be read or written, requests go to the provider as usual.
+type CachingProvider struct {
+	provider Provider
+	cache    ResponseCache
+	opts     CacheOptions
+}
+
+// NewCachingProvider creates a provider answering from cache where it can
+func NewCachingProvider(p Provider, cache ResponseCache, opts CacheOptions) *CachingProvider {
+	return &CachingProvider{provider: p, cache: cache, opts: opts}
+}
+
+// key identifies a request in the cache: besides RequestKey, the request
+// class picks the route, and the response schema and reasoning effort shape
+// the answer. Attachments must be inlined first (see inlineParts) for their
+// content to count.
+func (c *CachingProvider) key(ctx context.Context, messages []Message, tools []Tool) string {
+	scope := c.opts.Model + "|" + string(RequestClassFrom(ctx))
+	if schema := ResponseSchemaFrom(ctx); schema != nil {
+		data, _ := json.Marshal(schema)
+		scope += "|schema=" + string(data)
+	}
+	if effort := ReasoningFrom(ctx); effort != "" {
+		scope += "|reasoning=" + effort
+	}
+	return RequestKey(scope, messages, tools)
+}
+
+// lookup returns the cached answer to a request, or nil
+func (c *CachingProvider) lookup(ctx context.Context, key string) *Response {
+	data, _ := c.cache.Get(ctx, key)
+	var response *Response
+	if data != nil && json.Unmarshal(data, &response) != nil {
+		response = nil
+	}
+	if c.opts.OnLookup != nil {
+		c.opts.OnLookup(ctx, response != nil)
+	}
+	return response
+}
+
+// store caches the answer to a request, without its token counts
+func (c *CachingProvider) store(ctx context.Context, key string, response Response) {
+	response.PromptTokens, response.CompletionTokens, response.TotalTokens = 0, 0, 0
+	response.CachedTokens, response.ReasoningTokens = 0, 0
+	if data, err := json.Marshal(response); err == nil {
+		_ = c.cache.Put(ctx, key, data)
+	}
+}
+
+func (c *CachingProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
+	return c.ChatContext(context.Background(), messages, tools)
+}
+
+// ChatContext implements ProviderV2
+func (c *CachingProvider) ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	if !IsCacheable(ctx) {
+		return V2(c.provider).ChatContext(ctx, messages, tools)
+	}
+
+	messages, err := inlineParts(messages)
+	if err != nil {
+		return nil, err
+	}
+	key := c.key(ctx, messages, tools)
+	if cached := c.lookup(ctx, key); cached != nil {
+		return cached, nil
+	}
+	response, err := V2(c.provider).ChatContext(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	c.store(ctx, key, *response)
+	return response, nil
+}
+
+func (c *CachingProvider) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
+	return c.ChatStreamContext(context.Background(), messages, tools, callback)
+}
+
+// ChatStreamContext implements ProviderV2. A cached answer arrives as a
+// single final chunk.
+func (c *CachingProvider) ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error {
+	if !IsCacheable(ctx) {
+		return V2(c.provider).ChatStreamContext(ctx, messages, tools, callback)
+	}
+
+	messages, err := inlineParts(messages)
+	if err != nil {
+		return err
+	}
+	key := c.key(ctx, messages, tools)
+	if cached := c.lookup(ctx, key); cached != nil {
+		return callback(cached)
+	}
+
+	var whole Response
+	var content, reasoning strings.Builder
+	err = V2(c.provider).ChatStreamContext(ctx, messages, tools, func(response *Response) error {
+		content.WriteString(response.Content)
+		reasoning.WriteString(response.Reasoning)
+		whole.ToolCalls = append(whole.ToolCalls, response.ToolCalls...)
+		return callback(response)
+	})
+	if err != nil {
+		return err
+	}
+	whole.Content, whole.Reasoning, whole.Done = content.String(), reasoning.String(), true
+	c.store(ctx, key, whole)
+	return nil
+}
+
+func (c *CachingProvider) GetModels() ([]Model, error) { return c.provider.GetModels() }
+func (c *CachingProvider) GetName() string             { return c.provider.GetName() }
+func (c *CachingProvider) RequiresAuth() bool          { return c.provider.RequiresAuth() }
+
+// SupportsResponseSchema implements SchemaSupporter for the provider behind
+// the cache
+func (c *CachingProvider) SupportsResponseSchema() bool {
+	supporter, ok := c.provider.(SchemaSupporter)
+	return ok && supporter.SupportsResponseSchema()
+}
@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// memoryCache is a ResponseCache in a map
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[key], nil
+}
+
+func (m *memoryCache) Put(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = make(map[string][]byte)
+	}
+	m.entries[key] = data
+	return nil
+}
+
+// countingProvider answers every request with "ok" and keeps what it was sent
+type countingProvider struct {
+	calls    int
+	messages [][]Message
+}
+
+func (p *countingProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
+	p.calls++
+	p.messages = append(p.messages, messages)
+	return &Response{Content: "ok", Done: true}, nil
+}
+
+func (p *countingProvider) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
+	response, _ := p.Chat(messages, tools)
+	return callback(response)
+}
+
+func (p *countingProvider) GetModels() ([]Model, error) { return nil, nil }
+func (p *countingProvider) GetName() string             { return "counting" }
+func (p *countingProvider) RequiresAuth() bool          { return false }
+
+func TestCachingProviderKey(t *testing.T) {
+	provider := &countingProvider{}
+	cache := NewCachingProvider(provider, &memoryCache{}, CacheOptions{Model: "m"})
+	ctx := WithCacheable(context.Background())
+
+	chat := func(ctx context.Context, messages []Message) {
+		t.Helper()
+		if _, err := cache.ChatContext(ctx, messages, nil); err != nil {
+			t.Fatalf("ChatContext failed: %v", err)
+		}
+	}
+	messages := []Message{{Role: "user", Content: "classify"}}
+
+	chat(ctx, messages)
+	chat(ctx, messages)
+	if provider.calls != 1 {
+		t.Errorf("expected the repeat to be answered from cache, got %d calls", provider.calls)
+	}
+
+	// Schemas with the same name but different fields are different requests
+	schema := func(field string) *Schema {
+		return &Schema{Name: "verdict", Schema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{field: map[string]interface{}{"type": "string"}},
+		}}
+	}
+	chat(WithResponseSchema(ctx, schema("approved")), messages)
+	chat(WithResponseSchema(ctx, schema("approved")), messages)
+	chat(WithResponseSchema(ctx, schema("rejected")), messages)
+	if provider.calls != 3 {
+		t.Errorf("expected one call per schema, got %d calls", provider.calls)
+	}
+}
+
+func TestCachingProviderAttachments(t *testing.T) {
+	provider := &countingProvider{}
+	cache := NewCachingProvider(provider, &memoryCache{}, CacheOptions{Model: "m"})
+	ctx := WithCacheable(context.Background())
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	chat := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		messages := []Message{{Role: "user", Content: "summarize", Parts: []Part{FilePart(path)}}}
+		if _, err := cache.ChatContext(ctx, messages, nil); err != nil {
+			t.Fatalf("ChatContext failed: %v", err)
+		}
+		if messages[0].Parts[0].Data != "" {
+			t.Error("expected the caller's messages to be left alone")
+		}
+	}
+
+	chat("first")
+	chat("first")
+	chat("second")
+	if provider.calls != 2 {
+		t.Fatalf("expected a changed attachment to miss the cache, got %d calls", provider.calls)
+	}
+
+	// The provider gets the content the key was made from
+	sent := provider.messages[1][0].Parts[0].Data
+	if data, _ := base64.StdEncoding.DecodeString(sent); string(data) != "second" {
+		t.Errorf("expected the attachment to be sent as read, got %q", data)
+	}
+}
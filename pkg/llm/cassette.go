@@ -0,0 +1,344 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CassetteVersion is the format version of cassette files
+const CassetteVersion = 1
+
+// ErrCassetteMiss is returned by ReplayProvider for requests the cassette
+// has no recording of
+var ErrCassetteMiss = errors.New("no recorded response")
+
+// Cassette holds recorded provider interactions, for deterministic tests
+// and offline demos
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and what the provider sent back
+type Interaction struct {
+	Key      string     `json:"key"` // RequestKey of the request
+	Model    string     `json:"model,omitempty"`
+	Stream   bool       `json:"stream,omitempty"`
+	Messages []Message  `json:"messages"`
+	Tools    []string   `json:"tools,omitempty"` // Names of the tools offered
+	Chunks   []Response `json:"chunks"`          // Streamed responses, or the one Chat response
+	Error    string     `json:"error,omitempty"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+	}
+	if c.Version > CassetteVersion {
+		return nil, fmt.Errorf("cassette %s has version %d, newer than this smith supports (%d)", path, c.Version, CassetteVersion)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette, replacing the file in one step
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cassette: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating cassette directory: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// RequestKey identifies a request by what decides the answer: the model,
+// the messages with whitespace and line endings normalized, and the names
+// of the tools offered. Tool descriptions and schemas are left out so
+// rewording them doesn't invalidate recordings.
+func RequestKey(model string, messages []Message, tools []Tool) string {
+	type normalized struct {
+		Role       string                   `json:"role"`
+		Content    string                   `json:"content"`
+		ToolCalls  []map[string]interface{} `json:"tool_calls,omitempty"`
+		ToolCallID string                   `json:"tool_call_id,omitempty"`
+		Parts      []Part                   `json:"parts,omitempty"`
+	}
+	request := struct {
+		Model    string       `json:"model"`
+		Messages []normalized `json:"messages"`
+		Tools    []string     `json:"tools"`
+	}{Model: model, Tools: toolNames(tools)}
+
+	for _, msg := range messages {
+		n := normalized{Role: msg.Role, Content: normalizeText(msg.Content), ToolCallID: msg.ToolCallID}
+		for _, part := range msg.Parts {
+			// Attachments are identified by name, not where they were attached from
+			if part.Path != "" {
+				part.Path = filepath.Base(part.Path)
+			}
+			n.Parts = append(n.Parts, part)
+		}
+		for _, call := range msg.ToolCalls {
+			// Maps encode with sorted keys, so equal inputs hash the same
+			n.ToolCalls = append(n.ToolCalls, map[string]interface{}{"id": call.ID, "name": call.Name, "input": call.Input})
+		}
+		request.Messages = append(request.Messages, n)
+	}
+
+	data, _ := json.Marshal(request)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+func normalizeText(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+func toolNames(tools []Tool) []string {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RecordingProvider passes requests to another provider and writes each
+// request with its response, streamed chunks and tool calls included, to
+// a cassette file
+type RecordingProvider struct {
+	inner    Provider
+	model    string
+	recorder *recorder // Shared with copies made by WithModel
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	path     string
+	cassette Cassette
+}
+
+// NewRecordingProvider records the requests sent to inner in a new cassette
+// at path. The file is rewritten after every request, so a run that stops
+// early keeps what it recorded.
+func NewRecordingProvider(inner Provider, path string) *RecordingProvider {
+	return &RecordingProvider{
+		inner:    inner,
+		recorder: &recorder{path: path, cassette: Cassette{Version: CassetteVersion}},
+	}
+}
+
+// WithModel implements ModelSelector; the model is part of the recording
+func (p *RecordingProvider) WithModel(model string) Provider {
+	return &RecordingProvider{inner: WithModel(p.inner, model), model: model, recorder: p.recorder}
+}
+
+func (p *RecordingProvider) record(stream bool, messages []Message, tools []Tool, chunks []Response, err error) error {
+	interaction := Interaction{
+		Key:      RequestKey(p.model, messages, tools),
+		Model:    p.model,
+		Stream:   stream,
+		Messages: messages,
+		Tools:    toolNames(tools),
+		Chunks:   chunks,
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+
+	r := p.recorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	return r.cassette.Save(r.path)
+}
+
+func (p *RecordingProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
+	return p.ChatContext(context.Background(), messages, tools)
+}
+
+// ChatContext implements ProviderV2
+func (p *RecordingProvider) ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	response, err := V2(p.inner).ChatContext(ctx, messages, tools)
+	if ctx.Err() != nil {
+		return response, err // Cancelled requests say nothing about the provider
+	}
+	var chunks []Response
+	if response != nil {
+		chunks = []Response{*response}
+	}
+	if recordErr := p.record(false, messages, tools, chunks, err); recordErr != nil {
+		return nil, recordErr
+	}
+	return response, err
+}
+
+func (p *RecordingProvider) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
+	return p.ChatStreamContext(context.Background(), messages, tools, callback)
+}
+
+// ChatStreamContext implements ProviderV2
+func (p *RecordingProvider) ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error {
+	var chunks []Response
+	var callbackErr error
+	err := V2(p.inner).ChatStreamContext(ctx, messages, tools, func(response *Response) error {
+		chunks = append(chunks, *response)
+		callbackErr = callback(response)
+		return callbackErr
+	})
+	if ctx.Err() != nil || callbackErr != nil {
+		return err // The caller stopped the stream; the recording would be cut short
+	}
+	if recordErr := p.record(true, messages, tools, chunks, err); recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+func (p *RecordingProvider) GetModels() ([]Model, error) { return p.inner.GetModels() }
+func (p *RecordingProvider) GetName() string             { return p.inner.GetName() }
+func (p *RecordingProvider) RequiresAuth() bool          { return p.inner.RequiresAuth() }
+
+// ReplayProvider answers requests from a cassette without any network.
+// Requests are matched by RequestKey; a request made more often than it was
+// recorded gets the last recording again.
+type ReplayProvider struct {
+	model    string
+	replayer *replayer // Shared with copies made by WithModel
+}
+
+type replayer struct {
+	mu           sync.Mutex
+	interactions map[string][]Interaction
+	next         map[string]int
+}
+
+// NewReplayProvider replays the interactions of c
+func NewReplayProvider(c *Cassette) *ReplayProvider {
+	r := &replayer{interactions: make(map[string][]Interaction), next: make(map[string]int)}
+	for _, interaction := range c.Interactions {
+		r.interactions[interaction.Key] = append(r.interactions[interaction.Key], interaction)
+	}
+	return &ReplayProvider{replayer: r}
+}
+
+// LoadReplayProvider replays the cassette file at path
+func LoadReplayProvider(path string) (*ReplayProvider, error) {
+	c, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayProvider(c), nil
+}
+
+// WithModel implements ModelSelector; only recordings of model match
+func (p *ReplayProvider) WithModel(model string) Provider {
+	return &ReplayProvider{model: model, replayer: p.replayer}
+}
+
+// replay returns the recorded response chunks of a request
+func (p *ReplayProvider) replay(messages []Message, tools []Tool) ([]Response, error) {
+	key := RequestKey(p.model, messages, tools)
+
+	r := p.replayer
+	r.mu.Lock()
+	recorded := r.interactions[key]
+	if len(recorded) == 0 {
+		r.mu.Unlock()
+		var last string
+		if len(messages) > 0 {
+			last = normalizeText(messages[len(messages)-1].Content)
+			if len(last) > 60 {
+				last = last[:60] + "..."
+			}
+		}
+		return nil, fmt.Errorf("%w for request %s (model %q, last message %q)", ErrCassetteMiss, key, p.model, last)
+	}
+	i := r.next[key]
+	if i < len(recorded)-1 {
+		r.next[key] = i + 1
+	}
+	interaction := recorded[i]
+	r.mu.Unlock()
+
+	if interaction.Error != "" {
+		return interaction.Chunks, errors.New(interaction.Error)
+	}
+	return interaction.Chunks, nil
+}
+
+func (p *ReplayProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
+	return p.ChatContext(context.Background(), messages, tools)
+}
+
+// ChatContext implements ProviderV2. Recorded streams are merged into one
+// response.
+func (p *ReplayProvider) ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	chunks, err := p.replay(messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &Response{Done: true}
+	var content strings.Builder
+	for _, chunk := range chunks {
+		content.WriteString(chunk.Content)
+		response.ToolCalls = append(response.ToolCalls, chunk.ToolCalls...)
+		if chunk.TotalTokens > 0 {
+			response.PromptTokens, response.CompletionTokens, response.TotalTokens = chunk.PromptTokens, chunk.CompletionTokens, chunk.TotalTokens
+		}
+	}
+	response.Content = content.String()
+	return response, nil
+}
+
+func (p *ReplayProvider) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
+	return p.ChatStreamContext(context.Background(), messages, tools, callback)
+}
+
+// ChatStreamContext implements ProviderV2
+func (p *ReplayProvider) ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	chunks, err := p.replay(messages, tools)
+	for i := range chunks {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		chunk := chunks[i]
+		if callbackErr := callback(&chunk); callbackErr != nil {
+			return callbackErr
+		}
+	}
+	return err
+}
+
+func (p *ReplayProvider) GetModels() ([]Model, error) { return nil, nil }
+func (p *ReplayProvider) GetName() string             { return "Replay" }
+func (p *ReplayProvider) RequiresAuth() bool          { return false }
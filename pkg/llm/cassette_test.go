@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequestKey(t *testing.T) {
+	base := RequestKey("m", []Message{{Role: "user", Content: "hello\nworld"}}, []Tool{{Name: "read"}, {Name: "write"}})
+
+	tests := []struct {
+		name     string
+		model    string
+		messages []Message
+		tools    []Tool
+		same     bool
+	}{
+		{"line endings and outer whitespace", "m", []Message{{Role: "user", Content: "  hello\r\nworld\n"}}, []Tool{{Name: "read"}, {Name: "write"}}, true},
+		{"tool order and descriptions", "m", []Message{{Role: "user", Content: "hello\nworld"}}, []Tool{{Name: "write", Description: "Writes"}, {Name: "read"}}, true},
+		{"model", "other", []Message{{Role: "user", Content: "hello\nworld"}}, []Tool{{Name: "read"}, {Name: "write"}}, false},
+		{"content", "m", []Message{{Role: "user", Content: "hello world"}}, []Tool{{Name: "read"}, {Name: "write"}}, false},
+		{"role", "m", []Message{{Role: "system", Content: "hello\nworld"}}, []Tool{{Name: "read"}, {Name: "write"}}, false},
+		{"tool set", "m", []Message{{Role: "user", Content: "hello\nworld"}}, []Tool{{Name: "read"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RequestKey(tt.model, tt.messages, tt.tools); (got == base) != tt.same {
+				t.Errorf("RequestKey matched = %v, want %v", got == base, tt.same)
+			}
+		})
+	}
+
+	// Attachments match by file name wherever they were attached from
+	a := RequestKey("m", []Message{{Role: "user", Parts: []Part{{Type: PartFile, Path: "/home/a/notes.txt"}}}}, nil)
+	b := RequestKey("m", []Message{{Role: "user", Parts: []Part{{Type: PartFile, Path: "/tmp/b/notes.txt"}}}}, nil)
+	if a != b {
+		t.Error("expected attachments with the same name to share a key")
+	}
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "run.json")
+	ctx := context.Background()
+	tools := []Tool{{Name: "read"}}
+	first := []Message{{Role: "user", Content: "first"}}
+	second := []Message{{Role: "user", Content: "second"}}
+
+	recording := NewRecordingProvider(&countingProvider{}, path).WithModel("m")
+	if _, err := V2(recording).ChatContext(ctx, first, tools); err != nil {
+		t.Fatalf("recording Chat failed: %v", err)
+	}
+	if err := V2(recording).ChatStreamContext(ctx, second, tools, func(*Response) error { return nil }); err != nil {
+		t.Fatalf("recording ChatStream failed: %v", err)
+	}
+
+	replay, err := LoadReplayProvider(path)
+	if err != nil {
+		t.Fatalf("LoadReplayProvider failed: %v", err)
+	}
+	provider := V2(replay.WithModel("m"))
+
+	// Requests match with whitespace differences, and repeats get the last recording
+	for i := 0; i < 2; i++ {
+		response, err := provider.ChatContext(ctx, []Message{{Role: "user", Content: "first\r\n"}}, tools)
+		if err != nil {
+			t.Fatalf("replay %d failed: %v", i, err)
+		}
+		if response.Content != "ok" {
+			t.Errorf("replay %d content = %q, want %q", i, response.Content, "ok")
+		}
+	}
+
+	var chunks []string
+	if err := provider.ChatStreamContext(ctx, second, tools, func(r *Response) error {
+		chunks = append(chunks, r.Content)
+		return nil
+	}); err != nil {
+		t.Fatalf("stream replay failed: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0] != "ok" {
+		t.Errorf("stream replay chunks = %q, want [ok]", chunks)
+	}
+
+	misses := []struct {
+		name     string
+		provider Provider
+		messages []Message
+		tools    []Tool
+	}{
+		{"other model", replay.WithModel("other"), first, tools},
+		{"other message", replay.WithModel("m"), []Message{{Role: "user", Content: "third"}}, tools},
+		{"other tools", replay.WithModel("m"), first, nil},
+	}
+	for _, tt := range misses {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := V2(tt.provider).ChatContext(ctx, tt.messages, tt.tools); !errors.Is(err, ErrCassetteMiss) {
+				t.Errorf("error = %v, want ErrCassetteMiss", err)
+			}
+		})
+	}
+}
+
+func TestReplayProviderSequence(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "again"}}
+	key := RequestKey("", messages, nil)
+	provider := NewReplayProvider(&Cassette{Version: CassetteVersion, Interactions: []Interaction{
+		{Key: key, Chunks: []Response{{Content: "one"}}},
+		{Key: key, Chunks: []Response{{Content: "two"}}},
+		{Key: key, Error: "rate limited"},
+	}})
+
+	for _, want := range []string{"one", "two"} {
+		response, err := provider.Chat(messages, nil)
+		if err != nil || response.Content != want {
+			t.Fatalf("Chat = %v, %v; want %q", response, err, want)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Chat(messages, nil); err == nil || err.Error() != "rate limited" {
+			t.Errorf("expected the recorded error to be replayed, got %v", err)
+		}
+	}
+}
+
+func TestLoadCassetteNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.json")
+	if err := (&Cassette{Version: CassetteVersion + 1}).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCassette(path); err == nil {
+		t.Error("expected a cassette from a newer version to be refused")
+	}
+}
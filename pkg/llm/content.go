@@ -0,0 +1,291 @@
+package llm
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of message content parts
+const (
+	PartText  = "text"
+	PartImage = "image"
+	PartFile  = "file"
+)
+
+// MaxAttachmentSize is the largest file Attachment accepts
+const MaxAttachmentSize = 10 << 20
+
+// ErrUnsupportedContent is returned for content a model or provider can't take
+var ErrUnsupportedContent = errors.New("content not supported")
+
+// Part is a piece of message content after Message.Content: more text, an
+// image or a file. Images and files are read from Path when the request is
+// sent, unless their base64 content is given in Data.
+type Part struct {
+	Type     string `json:"type"` // PartText, PartImage or PartFile
+	Text     string `json:"text,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Data     string `json:"data,omitempty"`      // Base64 content
+	MIMEType string `json:"mime_type,omitempty"` // e.g. "image/png"; detected if empty
+}
+
+// TextPart returns a text part
+func TextPart(text string) Part {
+	return Part{Type: PartText, Text: text}
+}
+
+// ImagePart returns an image part read from path when sent
+func ImagePart(path string) Part {
+	return Part{Type: PartImage, Path: path}
+}
+
+// ImageData returns an image part with base64 content
+func ImageData(mimeType, data string) Part {
+	return Part{Type: PartImage, MIMEType: mimeType, Data: data}
+}
+
+// FilePart returns a file part read from path when sent
+func FilePart(path string) Part {
+	return Part{Type: PartFile, Path: path}
+}
+
+// Attachment reads a file into a part: an image part for images, a file
+// part for text files and PDFs. Other files fail with ErrUnsupportedContent.
+func Attachment(path string) (Part, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Part{}, err
+	}
+	if info.IsDir() {
+		return Part{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > MaxAttachmentSize {
+		return Part{}, fmt.Errorf("%s is larger than %d MB", path, MaxAttachmentSize>>20)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Part{}, err
+	}
+	part := Part{Type: PartFile, Path: path, Data: base64.StdEncoding.EncodeToString(data), MIMEType: detectMIMEType(path, data)}
+	switch {
+	case strings.HasPrefix(part.MIMEType, "image/"):
+		part.Type = PartImage
+	case part.MIMEType == "application/pdf" || isText(data):
+		// Sent as a document, or inline as text
+	default:
+		return Part{}, fmt.Errorf("%w: %s is neither an image, a PDF nor text (%s)", ErrUnsupportedContent, path, part.MIMEType)
+	}
+	return part, nil
+}
+
+// detectMIMEType sniffs the type of a file's content, falling back to its extension
+func detectMIMEType(path string, data []byte) string {
+	sniffed, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if sniffed != "application/octet-stream" && sniffed != "text/plain" {
+		return sniffed
+	}
+	if byExt, _, _ := strings.Cut(mime.TypeByExtension(filepath.Ext(path)), ";"); byExt != "" {
+		return byExt
+	}
+	return sniffed
+}
+
+// isText reports whether data reads as text
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !strings.ContainsRune(string(data), 0)
+}
+
+// load returns the content of an image or file part
+func (p Part) load() ([]byte, error) {
+	if p.Data != "" {
+		data, err := base64.StdEncoding.DecodeString(p.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s part: %w", p.Type, err)
+		}
+		return data, nil
+	}
+	if p.Path == "" {
+		return nil, fmt.Errorf("%s part has neither a path nor data", p.Type)
+	}
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading attachment: %w", err)
+	}
+	return data, nil
+}
+
+// inlineParts returns messages with the images and files given only by path
+// read into Data, so a request is identified and sent with the same content
+func inlineParts(messages []Message) ([]Message, error) {
+	inlined := messages
+	for i, msg := range messages {
+		var parts []Part
+		for j, part := range msg.Parts {
+			if part.Type == PartText || part.Data != "" {
+				continue
+			}
+			data, err := part.load()
+			if err != nil {
+				return nil, err
+			}
+			if parts == nil {
+				parts = append([]Part(nil), msg.Parts...)
+			}
+			parts[j].Data = base64.StdEncoding.EncodeToString(data)
+		}
+		if parts == nil {
+			continue
+		}
+		if &inlined[0] == &messages[0] {
+			inlined = append([]Message(nil), messages...)
+		}
+		inlined[i].Parts = parts
+	}
+	return inlined, nil
+}
+
+// name is how a part's file is called in requests
+func (p Part) name() string {
+	if p.Path != "" {
+		return filepath.Base(p.Path)
+	}
+	return "attachment"
+}
+
+// hasParts reports whether any message has a part of type kind
+func hasParts(messages []Message, kind string) bool {
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			if part.Type == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imageURL is the image of an image_url content part
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// fileData is the document of a file content part
+type fileData struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
+}
+
+// contentPart is a part of a message's content in the OpenAI format
+type contentPart struct {
+	Type         string            `json:"type"`
+	Text         string            `json:"text,omitempty"`
+	ImageURL     *imageURL         `json:"image_url,omitempty"`
+	File         *fileData         `json:"file,omitempty"`
+	CacheControl map[string]string `json:"cache_control,omitempty"`
+}
+
+// wireMessage is a message in the OpenAI chat completions format
+type wireMessage struct {
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"` // A string, or []contentPart
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+	ToolCallID string      `json:"tool_call_id,omitempty"`
+}
+
+// encodeMessages converts messages to the OpenAI format. documents tells
+// whether the API takes PDFs as file parts.
+func encodeMessages(messages []Message, documents bool) ([]wireMessage, error) {
+	wire := make([]wireMessage, len(messages))
+	for i, msg := range messages {
+		content, err := encodeContent(msg, documents)
+		if err != nil {
+			return nil, err
+		}
+		wire[i] = wireMessage{Role: msg.Role, Content: content, ToolCalls: msg.ToolCalls, ToolCallID: msg.ToolCallID}
+	}
+	return wire, nil
+}
+
+// encodeContent returns the content of a message in the OpenAI format: the
+// plain text, or a list of parts for messages with attachments. Images are
+// sent as data URLs and text files inline.
+func encodeContent(msg Message, documents bool) (interface{}, error) {
+	if len(msg.Parts) == 0 {
+		return msg.Content, nil
+	}
+
+	var parts []contentPart
+	if msg.Content != "" {
+		parts = append(parts, contentPart{Type: "text", Text: msg.Content})
+	}
+	for _, part := range msg.Parts {
+		if part.Type == PartText {
+			parts = append(parts, contentPart{Type: "text", Text: part.Text})
+			continue
+		}
+
+		data, err := part.load()
+		if err != nil {
+			return nil, err
+		}
+		mimeType := part.MIMEType
+		if mimeType == "" {
+			mimeType = detectMIMEType(part.Path, data)
+		}
+		dataURL := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+
+		switch {
+		case part.Type == PartImage:
+			parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: dataURL}})
+		case part.Type != PartFile:
+			return nil, fmt.Errorf("%w: unknown part type %q", ErrUnsupportedContent, part.Type)
+		case mimeType == "application/pdf":
+			if !documents {
+				return nil, fmt.Errorf("%w: PDF attachments (%s)", ErrUnsupportedContent, part.name())
+			}
+			parts = append(parts, contentPart{Type: "file", File: &fileData{Filename: part.name(), FileData: dataURL}})
+		case isText(data):
+			text := fmt.Sprintf("Attached file %s:\n```\n%s\n```", part.name(), strings.TrimRight(string(data), "\n"))
+			parts = append(parts, contentPart{Type: "text", Text: text})
+		default:
+			return nil, fmt.Errorf("%w: binary file %s", ErrUnsupportedContent, part.name())
+		}
+	}
+	return parts, nil
+}
+
+// CheckParts returns an error matching ErrUnsupportedContent if model, as
+// p lists it, can't take the parts: images need vision and PDFs document
+// input. Models p can't list are let through; the API has the last word.
+func CheckParts(p Provider, model string, parts []Part) error {
+	if len(parts) == 0 || model == "" {
+		return nil
+	}
+	models, err := p.GetModels()
+	if err != nil {
+		return nil
+	}
+	for _, m := range models {
+		if m.ID != model {
+			continue
+		}
+		for _, part := range parts {
+			switch {
+			case part.Type == PartImage && !m.Vision:
+				return fmt.Errorf("%w: %s doesn't accept images", ErrUnsupportedContent, model)
+			case part.Type == PartFile && part.MIMEType == "application/pdf" && !m.Documents:
+				return fmt.Errorf("%w: %s doesn't accept PDF documents", ErrUnsupportedContent, model)
+			}
+		}
+		return nil
+	}
+	return nil
+}
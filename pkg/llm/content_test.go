@@ -0,0 +1,226 @@
+package llm
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	pdfHeader = "%PDF-1.7\n"
+)
+
+// writeFile writes content to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAttachment(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		typ      string
+		mimeType string
+		err      error
+	}{
+		{"png", "shot.png", pngHeader, PartImage, "image/png", nil},
+		{"png without extension", "shot", pngHeader, PartImage, "image/png", nil},
+		{"pdf", "spec.pdf", pdfHeader, PartFile, "application/pdf", nil},
+		{"text", "notes.txt", "hello\n", PartFile, "text/plain", nil},
+		{"go source", "main.go", "package main\n", PartFile, "text/", nil}, // text/x-go where the system knows .go
+		{"binary", "blob.bin", "\x00\x01\x02\xff", "", "", ErrUnsupportedContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			part, err := Attachment(writeFile(t, dir, tt.file, tt.content))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Attachment failed: %v", err)
+			}
+			if part.Type != tt.typ || !strings.HasPrefix(part.MIMEType, tt.mimeType) {
+				t.Errorf("part = %s %s, want %s %s", part.Type, part.MIMEType, tt.typ, tt.mimeType)
+			}
+			if data, _ := base64.StdEncoding.DecodeString(part.Data); string(data) != tt.content {
+				t.Errorf("expected the content to be read into the part, got %q", data)
+			}
+		})
+	}
+
+	t.Run("size cap", func(t *testing.T) {
+		path := filepath.Join(dir, "big.txt")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Truncate(MaxAttachmentSize + 1); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if _, err := Attachment(path); err == nil || !strings.Contains(err.Error(), "larger than") {
+			t.Errorf("error = %v, want the size cap", err)
+		}
+
+		if err := os.Truncate(path, MaxAttachmentSize); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Attachment(path); err != nil && strings.Contains(err.Error(), "larger than") {
+			t.Errorf("expected a file of exactly MaxAttachmentSize to be accepted, got %v", err)
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		if _, err := Attachment(dir); err == nil {
+			t.Error("expected a directory to be refused")
+		}
+	})
+}
+
+func TestEncodeContent(t *testing.T) {
+	dir := t.TempDir()
+	png := writeFile(t, dir, "shot.png", pngHeader)
+	pdf := writeFile(t, dir, "spec.pdf", pdfHeader)
+	text := writeFile(t, dir, "notes.md", "# Notes\n\n")
+	binary := writeFile(t, dir, "blob.bin", "\x00\x01\x02\xff")
+	encoded := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name      string
+		parts     []Part
+		documents bool
+		want      []contentPart
+		err       error
+	}{
+		{"image from path", []Part{ImagePart(png)}, false, []contentPart{
+			{Type: "image_url", ImageURL: &imageURL{URL: "data:image/png;base64," + encoded(pngHeader)}},
+		}, nil},
+		{"image data keeps its type", []Part{ImageData("image/webp", encoded("webp"))}, false, []contentPart{
+			{Type: "image_url", ImageURL: &imageURL{URL: "data:image/webp;base64," + encoded("webp")}},
+		}, nil},
+		{"pdf as document", []Part{FilePart(pdf)}, true, []contentPart{
+			{Type: "file", File: &fileData{Filename: "spec.pdf", FileData: "data:application/pdf;base64," + encoded(pdfHeader)}},
+		}, nil},
+		{"pdf without documents", []Part{FilePart(pdf)}, false, nil, ErrUnsupportedContent},
+		{"text inline", []Part{FilePart(text)}, false, []contentPart{
+			{Type: "text", Text: "Attached file notes.md:\n```\n# Notes\n```"},
+		}, nil},
+		{"text part", []Part{TextPart("more")}, false, []contentPart{{Type: "text", Text: "more"}}, nil},
+		{"binary file", []Part{FilePart(binary)}, true, nil, ErrUnsupportedContent},
+		{"unknown type", []Part{{Type: "audio", Data: encoded("x")}}, true, nil, ErrUnsupportedContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeContent(Message{Role: "user", Content: "look", Parts: tt.parts}, tt.documents)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encodeContent failed: %v", err)
+			}
+			parts, ok := got.([]contentPart)
+			if !ok {
+				t.Fatalf("content = %T, want []contentPart", got)
+			}
+			// The message text comes first
+			want := append([]contentPart{{Type: "text", Text: "look"}}, tt.want...)
+			if len(parts) != len(want) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(want))
+			}
+			for i := range want {
+				if !equalContentPart(parts[i], want[i]) {
+					t.Errorf("part %d = %+v, want %+v", i, parts[i], want[i])
+				}
+			}
+		})
+	}
+
+	// Messages without parts stay plain strings
+	if got, err := encodeContent(Message{Role: "user", Content: "plain"}, false); err != nil || got != "plain" {
+		t.Errorf("encodeContent = %v, %v; want the plain text", got, err)
+	}
+}
+
+func equalContentPart(a, b contentPart) bool {
+	if a.Type != b.Type || a.Text != b.Text {
+		return false
+	}
+	if (a.ImageURL == nil) != (b.ImageURL == nil) || (a.ImageURL != nil && *a.ImageURL != *b.ImageURL) {
+		return false
+	}
+	return (a.File == nil) == (b.File == nil) && (a.File == nil || *a.File == *b.File)
+}
+
+// modelsProvider lists fixed models
+type modelsProvider struct {
+	countingProvider
+	models []Model
+	err    error
+}
+
+func (p *modelsProvider) GetModels() ([]Model, error) { return p.models, p.err }
+
+func TestCheckParts(t *testing.T) {
+	provider := &modelsProvider{models: []Model{
+		{ID: "text-only"},
+		{ID: "vision", Vision: true},
+		{ID: "documents", Vision: true, Documents: true},
+	}}
+	image := ImageData("image/png", "")
+	pdf := Part{Type: PartFile, MIMEType: "application/pdf"}
+	text := Part{Type: PartFile, MIMEType: "text/plain"}
+
+	tests := []struct {
+		name  string
+		model string
+		parts []Part
+		ok    bool
+	}{
+		{"no parts", "text-only", nil, true},
+		{"no model", "", []Part{image}, true},
+		{"image without vision", "text-only", []Part{image}, false},
+		{"image with vision", "vision", []Part{image}, true},
+		{"pdf without documents", "vision", []Part{pdf}, false},
+		{"pdf with documents", "documents", []Part{image, pdf}, true},
+		{"text file", "text-only", []Part{text}, true},
+		{"unlisted model", "unknown", []Part{image, pdf}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckParts(provider, tt.model, tt.parts)
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrUnsupportedContent) {
+				t.Errorf("error = %v, want ErrUnsupportedContent", err)
+			}
+		})
+	}
+
+	// Providers that can't list models let everything through
+	failing := &modelsProvider{err: errors.New("offline")}
+	if err := CheckParts(failing, "text-only", []Part{image}); err != nil {
+		t.Errorf("expected parts to pass when models can't be listed, got %v", err)
+	}
+}
@@ -1,13 +1,14 @@
 package llm
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/speier/smith/internal/config"
@@ -19,10 +20,17 @@ type CopilotProvider struct {
 	deviceCodeURL   string
 	accessTokenURL  string
 	copilotTokenURL string
-	client          *http.Client
+	model           string
+	profile         string       // Credential profile the sign-in is saved to
+	client          *http.Client // Sign-in requests
+	transport       *Transport   // API requests
+	authMu          *sync.Mutex  // Guards auth; shared with copies
 	auth            *CopilotAuth
 }
 
+// DefaultCopilotModel is used until a model is selected with WithModel
+const DefaultCopilotModel = "gpt-4o"
+
 // CopilotAuth stores authentication tokens
 type CopilotAuth struct {
 	RefreshToken string    // GitHub OAuth token
@@ -63,12 +71,38 @@ func NewCopilotProvider() *CopilotProvider {
 		deviceCodeURL:   "https://github.com/login/device/code",
 		accessTokenURL:  "https://github.com/login/oauth/access_token",
 		copilotTokenURL: "https://api.github.com/copilot_internal/v2/token",
+		model:           DefaultCopilotModel,
+		profile:         config.DefaultProfile,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		transport: NewTransport("copilot", 30*time.Second),
+		authMu:    &sync.Mutex{},
 	}
 }
 
+// SetProfile selects the credential profile that LoadAuth and SetAuth use
+func (c *CopilotProvider) SetProfile(profile string) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	c.profile = profile
+	c.auth = nil
+}
+
+// WithModel returns a copy of the provider that uses model; the copy shares
+// the sign-in of the original
+func (c *CopilotProvider) WithModel(model string) Provider {
+	selected := *c
+	selected.model = model
+	return &selected
+}
+
+// SupportsResponseSchema implements SchemaSupporter. Copilot gets no tools
+// (see post), so schemas always go through response_format.
+func (c *CopilotProvider) SupportsResponseSchema() bool {
+	return true
+}
+
 // Authorize starts the device flow and returns instructions for the user
 func (c *CopilotProvider) Authorize() (*DeviceCodeResponse, error) {
 	payload := map[string]string{
@@ -164,7 +198,7 @@ func (c *CopilotProvider) GetCopilotToken(githubToken string) (*CopilotTokenResp
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("copilot token failed (%d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("copilot token failed: %w", newAPIError(resp, body))
 	}
 
 	var result CopilotTokenResponse
@@ -175,10 +209,14 @@ func (c *CopilotProvider) GetCopilotToken(githubToken string) (*CopilotTokenResp
 	return &result, nil
 }
 
-// EnsureAuth ensures we have a valid Copilot token, refreshing if needed
+// EnsureAuth ensures we have a valid Copilot token, refreshing if needed.
+// Refreshed tokens are saved so other runs can reuse them.
 func (c *CopilotProvider) EnsureAuth() error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
 	if c.auth == nil {
-		return fmt.Errorf("not authenticated - run authentication flow first")
+		return fmt.Errorf("%w: not signed in to GitHub Copilot - run 'smith auth login copilot'", ErrAuth)
 	}
 
 	// If access token is still valid, we're done
@@ -196,10 +234,15 @@ func (c *CopilotProvider) EnsureAuth() error {
 	c.auth.AccessToken = copilotToken.Token
 	c.auth.ExpiresAt = time.Unix(copilotToken.ExpiresAt, 0)
 
+	if err := c.saveAuth(); err != nil {
+		return fmt.Errorf("saving refreshed copilot token: %w", err)
+	}
+
 	return nil
 }
 
-// SetAuth sets the authentication tokens (after successful login)
+// SetAuth sets the authentication tokens (after successful login) and saves
+// them to the credential profile
 func (c *CopilotProvider) SetAuth(refreshToken string) error {
 	// Get initial Copilot token
 	copilotToken, err := c.GetCopilotToken(refreshToken)
@@ -207,34 +250,42 @@ func (c *CopilotProvider) SetAuth(refreshToken string) error {
 		return fmt.Errorf("getting initial copilot token: %w", err)
 	}
 
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
 	c.auth = &CopilotAuth{
 		RefreshToken: refreshToken,
 		AccessToken:  copilotToken.Token,
 		ExpiresAt:    time.Unix(copilotToken.ExpiresAt, 0),
 	}
 
-	// Save to disk
-	authData := map[string]interface{}{
-		"refresh_token": refreshToken,
-		"access_token":  copilotToken.Token,
-		"expires_at":    copilotToken.ExpiresAt,
-	}
-	if err := config.SaveAuth("copilot", authData); err != nil {
+	if err := c.saveAuth(); err != nil {
 		return fmt.Errorf("saving auth: %w", err)
 	}
 
 	return nil
 }
 
-// LoadAuth loads authentication from disk
+// saveAuth writes the tokens to the credential store; callers hold authMu
+func (c *CopilotProvider) saveAuth() error {
+	return config.SaveAuth("copilot", c.profile, map[string]interface{}{
+		"refresh_token": c.auth.RefreshToken,
+		"access_token":  c.auth.AccessToken,
+		"expires_at":    c.auth.ExpiresAt.Unix(),
+	})
+}
+
+// LoadAuth loads the sign-in saved to the credential profile
 func (c *CopilotProvider) LoadAuth() error {
-	auth, err := config.LoadAuth("copilot")
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	auth, err := config.LoadAuth("copilot", c.profile)
 	if err != nil {
 		return fmt.Errorf("loading auth: %w", err)
 	}
 
 	if auth == nil {
-		return fmt.Errorf("no authentication found - please run 'smith auth login'")
+		return fmt.Errorf("not signed in to GitHub Copilot (profile %s) - run 'smith auth login copilot'", c.profile)
 	}
 
 	refreshToken, ok := auth.Data["refresh_token"].(string)
@@ -261,8 +312,26 @@ func (c *CopilotProvider) LoadAuth() error {
 	return nil
 }
 
-// Chat implements the Provider interface
-func (c *CopilotProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
+// newRequest builds a request to the Copilot API with the current token
+func (c *CopilotProvider) newRequest(method, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authMu.Lock()
+	req.Header.Set("Authorization", "Bearer "+c.auth.AccessToken)
+	c.authMu.Unlock()
+	req.Header.Set("User-Agent", "GitHubCopilotChat/0.26.7")
+	req.Header.Set("Editor-Version", "vscode/1.99.3")
+	req.Header.Set("Editor-Plugin-Version", "copilot-chat/0.26.7")
+	return req, nil
+}
+
+// post sends a chat completion request
+func (c *CopilotProvider) post(ctx context.Context, messages []Message, stream bool) (*http.Response, error) {
 	if err := c.EnsureAuth(); err != nil {
 		return nil, err
 	}
@@ -270,19 +339,36 @@ func (c *CopilotProvider) Chat(messages []Message, tools []Tool) (*Response, err
 	// Copilot uses OpenAI-compatible chat completions API
 	apiURL := "https://api.githubcopilot.com/chat/completions"
 
-	// Convert messages to OpenAI format
-	apiMessages := make([]map[string]string, len(messages))
+	// Convert messages to OpenAI format; Copilot takes images but no documents
+	apiMessages := make([]map[string]interface{}, len(messages))
 	for i, msg := range messages {
-		apiMessages[i] = map[string]string{
+		content, err := encodeContent(msg, false)
+		if err != nil {
+			return nil, err
+		}
+		apiMessages[i] = map[string]interface{}{
 			"role":    msg.Role,
-			"content": msg.Content,
+			"content": content,
 		}
 	}
+	vision := hasParts(messages, PartImage)
 
 	payload := map[string]interface{}{
 		"messages": apiMessages,
-		"model":    "gpt-4o", // Copilot model
-		"stream":   false,
+		"model":    c.model,
+		"stream":   stream,
+	}
+	if schema := ResponseSchemaFrom(ctx); schema != nil {
+		payload["response_format"] = responseFormat(schema)
+	}
+	if effort := ReasoningFrom(ctx); effort != "" {
+		// Only models that reason take these; others reject them
+		switch {
+		case thinksWithBudget(c.model):
+			payload["thinking_budget"] = thinkingBudgets[effort]
+		case takesReasoningEffort(c.model):
+			payload["reasoning_effort"] = effort
+		}
 	}
 
 	// TODO: Add tools support (for future function calling support)
@@ -295,38 +381,48 @@ func (c *CopilotProvider) Chat(messages []Message, tools []Tool) (*Response, err
 		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
+	return c.transport.Do(ctx, func() (*http.Request, error) {
+		req, err := c.newRequest("POST", apiURL, body)
+		if err == nil && stream {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		if err == nil && vision {
+			req.Header.Set("Copilot-Vision-Request", "true")
+		}
+		return req, err
+	})
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.auth.AccessToken)
-	req.Header.Set("User-Agent", "GitHubCopilotChat/0.26.7")
-	req.Header.Set("Editor-Version", "vscode/1.99.3")
-	req.Header.Set("Editor-Plugin-Version", "copilot-chat/0.26.7")
+// Chat implements the Provider interface
+func (c *CopilotProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
+	return c.ChatContext(context.Background(), messages, tools)
+}
 
-	resp, err := c.client.Do(req)
+// ChatContext implements ProviderV2
+func (c *CopilotProvider) ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	resp, err := c.post(ctx, messages, false)
 	if err != nil {
-		return nil, fmt.Errorf("api request: %w", err)
+		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("api error (%d): %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	var result struct {
 		Choices []struct {
 			Message struct {
 				Content string `json:"content"`
+				reasoningDelta
 			} `json:"message"`
 		} `json:"choices"`
 		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			TotalTokens         int `json:"total_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+			CompletionTokensDetails struct {
+				ReasoningTokens int `json:"reasoning_tokens"`
+			} `json:"completion_tokens_details"`
 		} `json:"usage"`
 	}
 
@@ -343,111 +439,22 @@ func (c *CopilotProvider) Chat(messages []Message, tools []Tool) (*Response, err
 		PromptTokens:     result.Usage.PromptTokens,
 		CompletionTokens: result.Usage.CompletionTokens,
 		TotalTokens:      result.Usage.TotalTokens,
+		CachedTokens:     result.Usage.PromptTokensDetails.CachedTokens,
+		ReasoningTokens:  result.Usage.CompletionTokensDetails.ReasoningTokens,
+		Reasoning:        result.Choices[0].Message.text(),
 	}, nil
 }
 
 func (c *CopilotProvider) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
-	if err := c.EnsureAuth(); err != nil {
-		return err
-	}
-
-	// Copilot uses OpenAI-compatible chat completions API with streaming
-	apiURL := "https://api.githubcopilot.com/chat/completions"
-
-	// Convert messages to OpenAI format
-	apiMessages := make([]map[string]string, len(messages))
-	for i, msg := range messages {
-		apiMessages[i] = map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
-		}
-	}
-
-	payload := map[string]interface{}{
-		"messages": apiMessages,
-		"model":    "gpt-4o", // Copilot model
-		"stream":   true,     // Enable streaming
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("marshaling request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.auth.AccessToken)
-	req.Header.Set("User-Agent", "GitHubCopilotChat/0.26.7")
-	req.Header.Set("Editor-Version", "vscode/1.99.3")
-	req.Header.Set("Editor-Plugin-Version", "copilot-chat/0.26.7")
-	req.Header.Set("Accept", "text/event-stream")
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("api request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("api error (%d): %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	// Read SSE (Server-Sent Events) stream
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// SSE format: "data: {...}"
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-
-		// OpenAI sends "[DONE]" when stream is complete
-		if data == "[DONE]" {
-			break
-		}
-
-		// Parse JSON chunk
-		var chunk struct {
-			Choices []struct {
-				Delta struct {
-					Content string `json:"content"`
-				} `json:"delta"`
-			} `json:"choices"`
-		}
-
-		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			// Skip malformed chunks
-			continue
-		}
-
-		// Send content chunk to callback
-		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-			if err := callback(&Response{
-				Content: chunk.Choices[0].Delta.Content,
-				Done:    false,
-			}); err != nil {
-				return err
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("reading stream: %w", err)
-	}
+	return c.ChatStreamContext(context.Background(), messages, tools, callback)
+}
 
-	// Send final "done" signal
-	return callback(&Response{
-		Content: "",
-		Done:    true,
-	})
+// ChatStreamContext implements ProviderV2. A stream that breaks before any
+// text arrived is requested again; Copilot can't continue a partial answer.
+func (c *CopilotProvider) ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error {
+	return streamChat(ctx, c.transport, messages, false, func(messages []Message) (*http.Response, error) {
+		return c.post(ctx, messages, true)
+	}, callback)
 }
 
 func (c *CopilotProvider) GetModels() ([]Model, error) {
@@ -459,33 +466,25 @@ func (c *CopilotProvider) GetModels() ([]Model, error) {
 	// Fetch models from GitHub Copilot API
 	apiURL := "https://api.githubcopilot.com/models"
 
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+c.auth.AccessToken)
-	req.Header.Set("User-Agent", "GitHubCopilotChat/0.26.7")
-	req.Header.Set("Editor-Version", "vscode/1.99.3")
-	req.Header.Set("Editor-Plugin-Version", "copilot-chat/0.26.7")
-
-	resp, err := c.client.Do(req)
+	resp, err := c.transport.Do(context.Background(), func() (*http.Request, error) {
+		return c.newRequest("GET", apiURL, nil)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("fetching models: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
-	}
-
 	var result struct {
 		Data []struct {
-			ID      string `json:"id"`
-			Object  string `json:"object"`
-			Created int64  `json:"created"`
-			OwnedBy string `json:"owned_by"`
+			ID           string `json:"id"`
+			Object       string `json:"object"`
+			Created      int64  `json:"created"`
+			OwnedBy      string `json:"owned_by"`
+			Capabilities struct {
+				Supports struct {
+					Vision bool `json:"vision"`
+				} `json:"supports"`
+			} `json:"capabilities"`
 		} `json:"data"`
 	}
 
@@ -508,6 +507,7 @@ func (c *CopilotProvider) GetModels() ([]Model, error) {
 			Name:        m.ID, // Use ID as name - clean and provider-agnostic
 			Description: fmt.Sprintf("Available via %s", c.GetName()),
 			ContextSize: 128000, // Default context size
+			Vision:      m.Capabilities.Supports.Vision,
 		}
 
 		// Categorize by model family
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of provider failures, matched with errors.Is
+var (
+	ErrAuth          = errors.New("authentication failed")   // Missing, invalid or expired credentials
+	ErrRateLimit     = errors.New("rate limited")            // Too many requests, even after retrying
+	ErrContextLength = errors.New("context length exceeded") // Prompt doesn't fit the model
+	ErrServer        = errors.New("server error")            // Provider failed (5xx), even after retrying
+	ErrUnavailable   = errors.New("provider unreachable")    // Network failure, even after retrying
+)
+
+// APIError is a failed request to a provider API
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // Wait asked for by the provider, if any
+	Kind       error         // ErrAuth, ErrRateLimit, ErrContextLength, ErrServer or nil
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error (%d): %s", e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// newAPIError classifies a failed response
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+
+	lower := strings.ToLower(e.Body)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		e.Kind = ErrAuth
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.Kind = ErrRateLimit
+	case strings.Contains(lower, "context_length_exceeded") || strings.Contains(lower, "context length") ||
+		strings.Contains(lower, "maximum context") || strings.Contains(lower, "too many tokens"):
+		e.Kind = ErrContextLength
+	case resp.StatusCode >= 500:
+		e.Kind = ErrServer
+	}
+	return e
+}
+
+// retryable reports whether the request may succeed if sent again
+func (e *APIError) retryable() bool {
+	return e.Kind == ErrRateLimit || e.Kind == ErrServer
+}
+
+// parseRetryAfter reads a Retry-After header: delay in seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		status     int
+		body       string
+		retryAfter string
+		kind       error
+		wait       time.Duration
+	}{
+		{401, "bad token", "", ErrAuth, 0},
+		{403, "forbidden", "", ErrAuth, 0},
+		{429, "slow down", "7", ErrRateLimit, 7 * time.Second},
+		{400, `{"error":{"code":"context_length_exceeded"}}`, "", ErrContextLength, 0},
+		{400, "This model's maximum context length is 8192 tokens", "", ErrContextLength, 0},
+		{413, "Too many tokens in request", "", ErrContextLength, 0},
+		{500, "internal error", "", ErrServer, 0},
+		{503, "overloaded", "2", ErrServer, 2 * time.Second},
+		{400, "invalid tool schema", "", nil, 0},
+		{404, "no such model", "", nil, 0},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		err := newAPIError(resp, []byte(" "+tt.body+"\n"))
+		if err.Kind != tt.kind || err.RetryAfter != tt.wait || err.Body != tt.body {
+			t.Errorf("%d %q: got kind %v, wait %v, body %q", tt.status, tt.body, err.Kind, err.RetryAfter, err.Body)
+		}
+		if retry := tt.kind == ErrRateLimit || tt.kind == ErrServer; err.retryable() != retry {
+			t.Errorf("%d %q: retryable = %v", tt.status, tt.body, err.retryable())
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -32,33 +32,47 @@ func GetAvailableProviders() []ProviderInfo {
 	}
 }
 
-// NewProvider creates a provider based on configuration
-func NewProvider() (Provider, error) {
-	cfg, err := config.Load()
+// NewProvider creates the provider configured for the project
+// (see config.Resolve for how settings are layered)
+func NewProvider(projectPath string) (Provider, error) {
+	cfg, err := config.Resolve(projectPath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("loading config: %w", err)
 	}
 
-	return NewProviderByID(cfg.Provider)
+	return NewProviderForProfile(cfg.Provider, cfg.Profile)
 }
 
-// NewProviderByID creates a provider by ID
+// NewProviderByID creates a provider by ID, signed in with the default
+// credential profile
 func NewProviderByID(providerID string) (Provider, error) {
+	return NewProviderForProfile(providerID, config.DefaultProfile)
+}
+
+// NewProviderForProfile creates a provider by ID, signed in with a credential
+// profile ("" is the default one)
+func NewProviderForProfile(providerID, profile string) (Provider, error) {
 	// Handle empty provider - user needs to configure
 	if providerID == "" {
-		return nil, fmt.Errorf("no provider configured - please run /settings to select a provider and model")
+		return nil, fmt.Errorf("no provider configured - run 'smith init' to select a provider and model")
+	}
+	if profile == "" {
+		profile = config.DefaultProfile
 	}
 
 	switch providerID {
 	case "copilot":
 		provider := NewCopilotProvider()
+		provider.SetProfile(profile)
 		// Try to load auth, but don't fail if it doesn't exist
 		// Auth will be required when Chat is called
 		_ = provider.LoadAuth()
 		return provider, nil
 
 	case "openrouter":
-		return NewOpenRouterProvider(), nil
+		provider := NewOpenRouterProvider()
+		provider.SetProfile(profile)
+		return provider, nil
 
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", providerID)
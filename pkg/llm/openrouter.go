@@ -1,88 +1,203 @@
 package llm
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/speier/smith/internal/config"
 )
 
+// DefaultOpenRouterModel is used until a model is selected with WithModel
+const DefaultOpenRouterModel = "anthropic/claude-3.5-sonnet"
+
 // OpenRouterProvider implements OpenRouter API access
 type OpenRouterProvider struct {
-	apiKey   string
-	endpoint string
-	client   *http.Client
+	apiKey    string
+	authErr   error // Why the saved key couldn't be read
+	profile   string
+	endpoint  string
+	model     string
+	transport *Transport
 }
 
+// NewOpenRouterProvider creates an OpenRouter provider signed in with
+// OPENROUTER_API_KEY, or else the key saved by 'smith auth login openrouter'
 func NewOpenRouterProvider() *OpenRouterProvider {
-	apiKey := os.Getenv("OPENROUTER_API_KEY")
-	return &OpenRouterProvider{
-		apiKey:   apiKey,
-		endpoint: "https://openrouter.ai/api/v1/chat/completions",
-		client:   &http.Client{Timeout: 60 * time.Second},
+	o := &OpenRouterProvider{
+		endpoint:  "https://openrouter.ai/api/v1/chat/completions",
+		model:     DefaultOpenRouterModel,
+		transport: NewTransport("openrouter", 60*time.Second),
 	}
+	o.SetProfile(config.DefaultProfile)
+	return o
 }
 
-func (o *OpenRouterProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
-	if o.apiKey == "" {
-		return nil, fmt.Errorf("OPENROUTER_API_KEY environment variable not set")
+// SetProfile signs in with the key saved for a credential profile;
+// OPENROUTER_API_KEY still wins when set
+func (o *OpenRouterProvider) SetProfile(profile string) {
+	o.profile = profile
+	o.apiKey, o.authErr = os.Getenv("OPENROUTER_API_KEY"), nil
+	if o.apiKey != "" {
+		return
 	}
+	auth, err := config.LoadAuth("openrouter", profile)
+	if err != nil {
+		o.authErr = err
+		return
+	}
+	if auth != nil {
+		o.apiKey, _ = auth.Data["api_key"].(string)
+	}
+}
 
-	// Convert to OpenAI format
-	reqMessages := make([]map[string]string, len(messages))
-	for i, msg := range messages {
-		reqMessages[i] = map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
+// SetAuth checks an API key with OpenRouter and saves it to the profile
+func (o *OpenRouterProvider) SetAuth(apiKey string) error {
+	resp, err := o.transport.Do(context.Background(), func() (*http.Request, error) {
+		req, err := o.newRequest("GET", "https://openrouter.ai/api/v1/auth/key", nil)
+		if err == nil {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
 		}
+		return req, err
+	})
+	if err != nil {
+		return fmt.Errorf("checking key: %w", err)
 	}
+	_ = resp.Body.Close()
 
-	reqBody := map[string]interface{}{
-		"model":    "anthropic/claude-3.5-sonnet", // Default model
-		"messages": reqMessages,
+	if err := config.SaveAuth("openrouter", o.profile, map[string]interface{}{"api_key": apiKey}); err != nil {
+		return fmt.Errorf("saving auth: %w", err)
 	}
+	o.apiKey, o.authErr = apiKey, nil
+	return nil
+}
 
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
+// SupportsResponseSchema implements SchemaSupporter; OpenRouter passes
+// response_format on to models that support it
+func (o *OpenRouterProvider) SupportsResponseSchema() bool {
+	return true
+}
+
+// checkAuth returns an ErrAuth error if there is no API key
+func (o *OpenRouterProvider) checkAuth() error {
+	if o.apiKey != "" {
+		return nil
+	}
+	if o.authErr != nil {
+		return fmt.Errorf("%w: reading saved key: %w", ErrAuth, o.authErr)
 	}
+	return fmt.Errorf("%w: not signed in to OpenRouter - run 'smith auth login openrouter' or set OPENROUTER_API_KEY", ErrAuth)
+}
+
+// WithModel returns a copy of the provider that uses model (an OpenRouter
+// model ID like "openai/gpt-4o")
+func (o *OpenRouterProvider) WithModel(model string) Provider {
+	selected := *o
+	selected.model = model
+	return &selected
+}
 
-	req, err := http.NewRequest("POST", o.endpoint, bytes.NewReader(bodyBytes))
+// newRequest builds an authenticated request to the OpenRouter API
+func (o *OpenRouterProvider) newRequest(method, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+o.apiKey)
 	req.Header.Set("HTTP-Referer", "https://github.com/speier/smith")
 	req.Header.Set("X-Title", "Smith Agent System")
+	return req, nil
+}
 
-	resp, err := o.client.Do(req)
+// post sends a chat completion request; tools are offered with automatic
+// tool choice
+func (o *OpenRouterProvider) post(ctx context.Context, messages []Message, tools []Tool, stream bool) (*http.Response, error) {
+	if err := o.checkAuth(); err != nil {
+		return nil, err
+	}
+
+	wire, err := encodeMessages(messages, true)
 	if err != nil {
-		return nil, fmt.Errorf("api request: %w", err)
+		return nil, err
+	}
+	reqBody := map[string]interface{}{
+		"model":    o.model,
+		"messages": withPromptCache(o.model, wire),
+	}
+	if stream {
+		reqBody["stream"] = true
+	}
+	if len(tools) > 0 {
+		reqBody["tools"] = convertTools(tools)
+		reqBody["tool_choice"] = "auto"
+	}
+	if schema := ResponseSchemaFrom(ctx); schema != nil {
+		reqBody["response_format"] = responseFormat(schema)
+	}
+	if effort := ReasoningFrom(ctx); effort != "" {
+		// OpenRouter maps the effort to each model's own setting, but
+		// Anthropic models are given their thinking budget directly
+		reasoning := map[string]interface{}{"effort": effort}
+		if thinksWithBudget(o.model) {
+			reasoning = map[string]interface{}{"max_tokens": thinkingBudgets[effort]}
+		}
+		reqBody["reasoning"] = reasoning
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("api error (%d): %s", resp.StatusCode, string(bodyBytes))
+	return o.transport.Do(ctx, func() (*http.Request, error) {
+		return o.newRequest("POST", o.endpoint, bodyBytes)
+	})
+}
+
+func (o *OpenRouterProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
+	return o.ChatContext(context.Background(), messages, tools)
+}
+
+// ChatContext implements ProviderV2
+func (o *OpenRouterProvider) ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	resp, err := o.post(ctx, messages, tools, false)
+	if err != nil {
+		return nil, err
 	}
+	defer func() { _ = resp.Body.Close() }()
 
 	var result struct {
 		Choices []struct {
 			Message struct {
 				Content string `json:"content"`
+				reasoningDelta
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string          `json:"name"`
+						Arguments json.RawMessage `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
 			} `json:"message"`
 		} `json:"choices"`
 		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			TotalTokens         int `json:"total_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+			CompletionTokensDetails struct {
+				ReasoningTokens int `json:"reasoning_tokens"`
+			} `json:"completion_tokens_details"`
 		} `json:"usage"`
 	}
 
@@ -94,148 +209,55 @@ func (o *OpenRouterProvider) Chat(messages []Message, tools []Tool) (*Response,
 		return nil, fmt.Errorf("no response from API")
 	}
 
-	return &Response{
+	response := &Response{
 		Content:          result.Choices[0].Message.Content,
+		Done:             true,
 		PromptTokens:     result.Usage.PromptTokens,
 		CompletionTokens: result.Usage.CompletionTokens,
 		TotalTokens:      result.Usage.TotalTokens,
-	}, nil
-}
-
-func (o *OpenRouterProvider) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
-	if o.apiKey == "" {
-		return fmt.Errorf("OPENROUTER_API_KEY environment variable not set")
+		CachedTokens:     result.Usage.PromptTokensDetails.CachedTokens,
+		ReasoningTokens:  result.Usage.CompletionTokensDetails.ReasoningTokens,
+		Reasoning:        result.Choices[0].Message.text(),
 	}
-
-	// Convert to OpenAI format
-	reqMessages := make([]map[string]string, len(messages))
-	for i, msg := range messages {
-		reqMessages[i] = map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
-		}
+	for _, tc := range result.Choices[0].Message.ToolCalls {
+		response.ToolCalls = append(response.ToolCalls, ToolCall{
+			ID:    tc.ID,
+			Name:  tc.Function.Name,
+			Input: decodeArguments(tc.Function.Arguments),
+		})
 	}
+	return response, nil
+}
 
-	reqBody := map[string]interface{}{
-		"model":    "anthropic/claude-3.5-sonnet", // Default model
-		"messages": reqMessages,
-		"stream":   true, // Enable streaming
-	}
-
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("marshaling request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", o.endpoint, bytes.NewReader(bodyBytes))
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+o.apiKey)
-	req.Header.Set("HTTP-Referer", "https://github.com/speier/smith")
-	req.Header.Set("X-Title", "Smith Agent System")
-
-	resp, err := o.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("api request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("api error (%d): %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	// Parse streaming response
-	scanner := bufio.NewScanner(resp.Body)
-	var totalPromptTokens, totalCompletionTokens, totalTokens int
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
-		}
-
-		var chunk struct {
-			Choices []struct {
-				Delta struct {
-					Content string `json:"content"`
-				} `json:"delta"`
-				FinishReason *string `json:"finish_reason"`
-			} `json:"choices"`
-			Usage struct {
-				PromptTokens     int `json:"prompt_tokens"`
-				CompletionTokens int `json:"completion_tokens"`
-				TotalTokens      int `json:"total_tokens"`
-			} `json:"usage"`
-		}
-
-		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			continue // Skip malformed chunks
-		}
-
-		// Capture usage data from any chunk that includes it
-		if chunk.Usage.TotalTokens > 0 {
-			totalPromptTokens = chunk.Usage.PromptTokens
-			totalCompletionTokens = chunk.Usage.CompletionTokens
-			totalTokens = chunk.Usage.TotalTokens
-		}
-
-		if len(chunk.Choices) > 0 {
-			content := chunk.Choices[0].Delta.Content
-			done := chunk.Choices[0].FinishReason != nil
-
-			if err := callback(&Response{
-				Content:          content,
-				Done:             done,
-				PromptTokens:     totalPromptTokens,
-				CompletionTokens: totalCompletionTokens,
-				TotalTokens:      totalTokens,
-			}); err != nil {
-				return err
-			}
-		}
-	}
+func (o *OpenRouterProvider) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
+	return o.ChatStreamContext(context.Background(), messages, tools, callback)
+}
 
-	return scanner.Err()
+// ChatStreamContext implements ProviderV2. OpenRouter continues a trailing
+// assistant message, so a stream that breaks midway resumes where it stopped.
+func (o *OpenRouterProvider) ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error {
+	return streamChat(ctx, o.transport, messages, true, func(messages []Message) (*http.Response, error) {
+		return o.post(ctx, messages, tools, true)
+	}, callback)
 }
 
 func (o *OpenRouterProvider) GetModels() ([]Model, error) {
 	// Check authentication first
-	if o.apiKey == "" {
-		return nil, fmt.Errorf("not authenticated - set OPENROUTER_API_KEY environment variable")
+	if err := o.checkAuth(); err != nil {
+		return nil, err
 	}
 
 	// Fetch models from OpenRouter API
 	apiURL := "https://openrouter.ai/api/v1/models"
 
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+o.apiKey)
-	req.Header.Set("HTTP-Referer", "https://github.com/speier/smith")
-	req.Header.Set("X-Title", "Smith Agent System")
-
-	resp, err := o.client.Do(req)
+	resp, err := o.transport.Do(context.Background(), func() (*http.Request, error) {
+		return o.newRequest("GET", apiURL, nil)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("fetching models: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
-	}
-
 	var result struct {
 		Data []struct {
 			ID          string `json:"id"`
@@ -246,6 +268,9 @@ func (o *OpenRouterProvider) GetModels() ([]Model, error) {
 				Completion string `json:"completion"`
 			} `json:"pricing"`
 			ContextLength int `json:"context_length"`
+			Architecture  struct {
+				InputModalities []string `json:"input_modalities"`
+			} `json:"architecture"`
 		} `json:"data"`
 	}
 
@@ -271,6 +296,14 @@ func (o *OpenRouterProvider) GetModels() ([]Model, error) {
 			Description: m.Description,
 			ContextSize: m.ContextLength,
 		}
+		for _, modality := range m.Architecture.InputModalities {
+			switch modality {
+			case "image":
+				model.Vision = true
+			case "file":
+				model.Documents = true
+			}
+		}
 
 		idLower := strings.ToLower(m.ID)
 		// Categorize by model family (most capable first)
@@ -2,11 +2,13 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 )
 
 // Provider interface for different LLM providers
@@ -18,16 +20,120 @@ type Provider interface {
 	RequiresAuth() bool
 }
 
+// ProviderV2 is the context-first provider API: requests stop when ctx is
+// done, and failed requests return errors matching ErrAuth, ErrRateLimit,
+// ErrContextLength or ErrServer (see APIError)
+type ProviderV2 interface {
+	ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error)
+	ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error
+	GetModels() ([]Model, error)
+	GetName() string
+	RequiresAuth() bool
+}
+
+// V2 returns p as a ProviderV2. Providers without context support are
+// adapted: their requests keep running in the background when ctx ends,
+// but the caller returns right away and no more stream callbacks are made.
+func V2(p Provider) ProviderV2 {
+	if v2, ok := p.(ProviderV2); ok {
+		return v2
+	}
+	return providerAdapter{p}
+}
+
+// providerAdapter runs a Provider under a context
+type providerAdapter struct {
+	Provider
+}
+
+func (a providerAdapter) ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type outcome struct {
+		response *Response
+		err      error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		response, err := a.Chat(messages, tools)
+		done <- outcome{response, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case out := <-done:
+		return out.response, out.err
+	}
+}
+
+func (a providerAdapter) ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex // Keeps callbacks from running after we returned
+	stopped := false
+	done := make(chan error, 1)
+	go func() {
+		done <- a.ChatStream(messages, tools, func(response *Response) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if stopped {
+				return ctx.Err()
+			}
+			return callback(response)
+		})
+	}()
+
+	select {
+	case <-ctx.Done():
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
+
+// ModelSelector is implemented by providers that can serve more than one model
+type ModelSelector interface {
+	// WithModel returns a copy of the provider that sends requests to model
+	WithModel(model string) Provider
+}
+
+// WithModel returns p configured for model, or p itself if model is empty
+// or p has no model selection
+func WithModel(p Provider, model string) Provider {
+	if selector, ok := p.(ModelSelector); ok && model != "" {
+		return selector.WithModel(model)
+	}
+	return p
+}
+
 type Model struct {
 	ID          string
 	Name        string
 	Description string
 	ContextSize int
+	Vision      bool // Takes images
+	Documents   bool // Takes PDF documents
 }
 
 type Message struct {
-	Role    string `json:"role"` // "user", "assistant", "system"
+	Role    string `json:"role"` // "user", "assistant", "system", "tool"
 	Content string `json:"content"`
+
+	// ToolCalls are the calls an assistant message asked for
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a "tool" message to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+
+	// Parts are attachments sent after Content: images, files or more text
+	Parts []Part `json:"parts,omitempty"`
 }
 
 type Tool struct {
@@ -37,24 +143,64 @@ type Tool struct {
 }
 
 type Response struct {
-	Content          string
-	ToolCalls        []ToolCall
-	Done             bool
-	PromptTokens     int // Tokens used in the prompt
-	CompletionTokens int // Tokens generated in the completion
-	TotalTokens      int // Total tokens used (prompt + completion)
+	Content          string     `json:"content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+	Done             bool       `json:"done,omitempty"`
+	PromptTokens     int        `json:"prompt_tokens,omitempty"`     // Tokens used in the prompt
+	CompletionTokens int        `json:"completion_tokens,omitempty"` // Tokens generated in the completion
+	TotalTokens      int        `json:"total_tokens,omitempty"`      // Total tokens used (prompt + completion)
+	CachedTokens     int        `json:"cached_tokens,omitempty"`     // Prompt tokens read from the provider's prompt cache
+	ReasoningTokens  int        `json:"reasoning_tokens,omitempty"`  // Completion tokens spent thinking
+
+	// Reasoning is the model's thinking before its answer, streamed like
+	// Content when a reasoning effort is set (see WithReasoning)
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 type ToolCall struct {
+	ID    string
 	Name  string
 	Input map[string]interface{}
 }
 
+// MarshalJSON encodes the call in the OpenAI chat completions format
+func (tc ToolCall) MarshalJSON() ([]byte, error) {
+	arguments, err := json.Marshal(tc.Input)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{
+		"id":   tc.ID,
+		"type": "function",
+		"function": map[string]string{
+			"name":      tc.Name,
+			"arguments": string(arguments),
+		},
+	})
+}
+
+// UnmarshalJSON decodes a call in the OpenAI chat completions format
+func (tc *ToolCall) UnmarshalJSON(data []byte) error {
+	var call struct {
+		ID       string `json:"id"`
+		Function struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &call); err != nil {
+		return err
+	}
+	*tc = ToolCall{ID: call.ID, Name: call.Function.Name, Input: decodeArguments(call.Function.Arguments)}
+	return nil
+}
+
 // OpenAIProvider uses OpenAI-compatible APIs (OpenAI, Groq, OpenRouter, etc.)
 type OpenAIProvider struct {
-	apiKey   string
-	endpoint string
-	model    string
+	apiKey    string
+	endpoint  string
+	model     string
+	transport *Transport
 }
 
 // NewOpenAI creates an OpenAI provider
@@ -66,18 +212,20 @@ func NewOpenAI() *OpenAIProvider {
 	}
 
 	return &OpenAIProvider{
-		apiKey:   apiKey,
-		endpoint: "https://api.openai.com/v1/chat/completions",
-		model:    "gpt-4o-mini", // Fast and cheap for testing
+		apiKey:    apiKey,
+		endpoint:  "https://api.openai.com/v1/chat/completions",
+		model:     "gpt-4o-mini", // Fast and cheap for testing
+		transport: NewTransport("openai", 60*time.Second),
 	}
 }
 
 // NewOpenAICustom creates a custom OpenAI-compatible provider
 func NewOpenAICustom(apiKey, endpoint, model string) *OpenAIProvider {
 	return &OpenAIProvider{
-		apiKey:   apiKey,
-		endpoint: endpoint,
-		model:    model,
+		apiKey:    apiKey,
+		endpoint:  endpoint,
+		model:     model,
+		transport: NewTransport("openai", 60*time.Second),
 	}
 }
 
@@ -103,32 +251,27 @@ func (p *OpenAIProvider) Chat(messages []Message, tools []Tool) (*Response, erro
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", p.endpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := p.transport.Do(context.Background(), func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", p.endpoint, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("API request failed: %w", err)
+		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
 	var apiResp struct {
 		Choices []struct {
 			Message struct {
 				Role      string `json:"role"`
 				Content   string `json:"content"`
 				ToolCalls []struct {
+					ID       string `json:"id"`
 					Function struct {
 						Name      string          `json:"name"`
 						Arguments json.RawMessage `json:"arguments"`
@@ -155,11 +298,10 @@ func (p *OpenAIProvider) Chat(messages []Message, tools []Tool) (*Response, erro
 
 	// Parse tool calls if present
 	for _, tc := range choice.Message.ToolCalls {
-		var input map[string]interface{}
-		_ = json.Unmarshal(tc.Function.Arguments, &input)
 		response.ToolCalls = append(response.ToolCalls, ToolCall{
+			ID:    tc.ID,
 			Name:  tc.Function.Name,
-			Input: input,
+			Input: decodeArguments(tc.Function.Arguments),
 		})
 	}
 
@@ -168,8 +310,9 @@ func (p *OpenAIProvider) Chat(messages []Message, tools []Tool) (*Response, erro
 
 // OllamaProvider for local LLMs
 type OllamaProvider struct {
-	endpoint string
-	model    string
+	endpoint  string
+	model     string
+	transport *Transport
 }
 
 // NewOllama creates an Ollama provider for local LLMs
@@ -178,9 +321,13 @@ func NewOllama(model string) *OllamaProvider {
 		model = "llama3.2" // Default model
 	}
 
+	// Local models are slow to answer but need no rate limit
+	transport := NewTransport("ollama", 5*time.Minute)
+	transport.Limiter = nil
 	return &OllamaProvider{
-		endpoint: "http://localhost:11434/api/chat",
-		model:    model,
+		endpoint:  "http://localhost:11434/api/chat",
+		model:     model,
+		transport: transport,
 	}
 }
 
@@ -196,17 +343,18 @@ func (p *OllamaProvider) Chat(messages []Message, tools []Tool) (*Response, erro
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := http.Post(p.endpoint, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := p.transport.Do(context.Background(), func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", p.endpoint, bytes.NewReader(jsonData))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("ollama request failed (is Ollama running?): %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama error (status %d): %s", resp.StatusCode, string(body))
-	}
-
 	var apiResp struct {
 		Message struct {
 			Role    string `json:"role"`
@@ -239,3 +387,15 @@ func convertTools(tools []Tool) []map[string]interface{} {
 	}
 	return result
 }
+
+// decodeArguments decodes tool call arguments, which are usually a
+// JSON-encoded string of the object
+func decodeArguments(arguments json.RawMessage) map[string]interface{} {
+	var encoded string
+	if json.Unmarshal(arguments, &encoded) == nil {
+		arguments = json.RawMessage(encoded)
+	}
+	var input map[string]interface{}
+	_ = json.Unmarshal(arguments, &input)
+	return input
+}
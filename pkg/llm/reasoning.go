@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// Reasoning efforts, as set by an agent's reasoning setting
+const (
+	ReasoningLow    = "low"
+	ReasoningMedium = "medium"
+	ReasoningHigh   = "high"
+)
+
+// thinkingBudgets are the tokens Anthropic models may think for at each effort
+var thinkingBudgets = map[string]int{
+	ReasoningLow:    1024,
+	ReasoningMedium: 4096,
+	ReasoningHigh:   16384,
+}
+
+type reasoningKey struct{}
+
+// WithReasoning asks for the requests made with ctx to be answered with
+// effort (ReasoningLow, ReasoningMedium or ReasoningHigh) spent thinking
+// first. Models that can't reason answer as usual.
+func WithReasoning(ctx context.Context, effort string) context.Context {
+	return context.WithValue(ctx, reasoningKey{}, effort)
+}
+
+// ReasoningFrom returns the reasoning effort of ctx, or "" for the model default
+func ReasoningFrom(ctx context.Context) string {
+	effort, _ := ctx.Value(reasoningKey{}).(string)
+	return effort
+}
+
+// thinksWithBudget reports whether model is an Anthropic model that reasons
+// within a token budget instead of at an effort
+func thinksWithBudget(model string) bool {
+	for _, family := range []string{"claude-3.7", "claude-3-7", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4"} {
+		if strings.Contains(model, family) {
+			return true
+		}
+	}
+	return false
+}
+
+// takesReasoningEffort reports whether model is an OpenAI model that takes
+// reasoning_effort
+func takesReasoningEffort(model string) bool {
+	model = strings.TrimPrefix(model, "openai/")
+	for _, family := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(model, family) {
+			return true
+		}
+	}
+	return false
+}
+
+// reasoningDelta is the thinking in a message or stream delta, which
+// OpenAI-compatible APIs put in differently named fields
+type reasoningDelta struct {
+	Reasoning        string `json:"reasoning"`
+	ReasoningContent string `json:"reasoning_content"`
+	ReasoningText    string `json:"reasoning_text"`
+}
+
+func (d reasoningDelta) text() string {
+	return d.Reasoning + d.ReasoningContent + d.ReasoningText
+}
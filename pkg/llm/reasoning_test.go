@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestReasoningModels(t *testing.T) {
+	tests := []struct {
+		model  string
+		budget bool // thinksWithBudget
+		effort bool // takesReasoningEffort
+	}{
+		{"claude-3-7-sonnet-20250219", true, false},
+		{"claude-3.7-sonnet", true, false},
+		{"anthropic/claude-sonnet-4.5", true, false},
+		{"claude-opus-4-1", true, false},
+		{"claude-haiku-4-5", true, false},
+		{"claude-3-5-sonnet-20241022", false, false},
+		{"o1-mini", false, true},
+		{"o3", false, true},
+		{"openai/o4-mini", false, true},
+		{"gpt-5-codex", false, true},
+		{"gpt-4o", false, false},
+		{"openrouter/gpt-5", false, false}, // Only the openai/ prefix is known
+		{"llama3.1", false, false},
+		{"", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := thinksWithBudget(tt.model); got != tt.budget {
+				t.Errorf("thinksWithBudget(%q) = %v, want %v", tt.model, got, tt.budget)
+			}
+			if got := takesReasoningEffort(tt.model); got != tt.effort {
+				t.Errorf("takesReasoningEffort(%q) = %v, want %v", tt.model, got, tt.effort)
+			}
+		})
+	}
+}
+
+func TestReasoningFrom(t *testing.T) {
+	if effort := ReasoningFrom(context.Background()); effort != "" {
+		t.Errorf("expected no effort by default, got %q", effort)
+	}
+	if effort := ReasoningFrom(WithReasoning(context.Background(), ReasoningHigh)); effort != ReasoningHigh {
+		t.Errorf("ReasoningFrom = %q, want %q", effort, ReasoningHigh)
+	}
+	for _, effort := range []string{ReasoningLow, ReasoningMedium, ReasoningHigh} {
+		if thinkingBudgets[effort] == 0 {
+			t.Errorf("no thinking budget for %q", effort)
+		}
+	}
+}
+
+func TestReasoningDelta(t *testing.T) {
+	for _, field := range []string{"reasoning", "reasoning_content", "reasoning_text"} {
+		var delta reasoningDelta
+		if err := json.Unmarshal([]byte(`{"`+field+`": "thinking"}`), &delta); err != nil {
+			t.Fatal(err)
+		}
+		if got := delta.text(); got != "thinking" {
+			t.Errorf("text() with %s = %q, want %q", field, got, "thinking")
+		}
+	}
+}
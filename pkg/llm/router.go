@@ -0,0 +1,248 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrBudgetExceeded is returned by Router once the session token budget is
+// used up and there is no fast route to downgrade to
+var ErrBudgetExceeded = errors.New("token budget exceeded")
+
+// RequestClass tells a Router what a request is for
+type RequestClass string
+
+const (
+	ClassDefault RequestClass = ""     // Regular work on the routes' own models
+	ClassFast    RequestClass = "fast" // Intent classification, summaries: cheap and quick
+)
+
+type requestClassKey struct{}
+
+// WithRequestClass marks the requests made with ctx as class
+func WithRequestClass(ctx context.Context, class RequestClass) context.Context {
+	return context.WithValue(ctx, requestClassKey{}, class)
+}
+
+// RequestClassFrom returns the request class of ctx (ClassDefault if unset)
+func RequestClassFrom(ctx context.Context) RequestClass {
+	class, _ := ctx.Value(requestClassKey{}).(RequestClass)
+	return class
+}
+
+// Route is a provider set to one model
+type Route struct {
+	ProviderID string // e.g. "openrouter"
+	Model      string // Empty for the provider's default model
+	Provider   Provider
+	Reasoning  string // Effort for requests that don't set one (see WithReasoning)
+}
+
+// context returns ctx with the route's reasoning effort, unless ctx has one
+func (r Route) context(ctx context.Context) context.Context {
+	if r.Reasoning == "" || ReasoningFrom(ctx) != "" {
+		return ctx
+	}
+	return WithReasoning(ctx, r.Reasoning)
+}
+
+// String names the route in errors, e.g. "openrouter:openai/gpt-4o"
+func (r Route) String() string {
+	switch {
+	case r.ProviderID != "" && r.Model != "":
+		return r.ProviderID + ":" + r.Model
+	case r.ProviderID != "":
+		return r.ProviderID
+	case r.Model != "":
+		return r.Model
+	}
+	return r.Provider.GetName()
+}
+
+// Usage is the token count of one request
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	CachedTokens     int // Prompt tokens read from the provider's prompt cache
+	ReasoningTokens  int // Completion tokens spent thinking
+}
+
+// usageOf returns the token counts of a response
+func usageOf(response *Response) Usage {
+	return Usage{response.PromptTokens, response.CompletionTokens, response.TotalTokens, response.CachedTokens, response.ReasoningTokens}
+}
+
+// Budget caps the tokens spent in a session. Zero Limit means no cap.
+type Budget struct {
+	Limit     int
+	Downgrade bool                                   // Use the fast route once spent, instead of failing
+	Spent     func(ctx context.Context) (int, error) // Tokens spent so far
+}
+
+// RouterOptions configures a Router
+type RouterOptions struct {
+	Fast    *Route // Route for ClassFast requests and budget downgrades
+	Budget  Budget
+	OnUsage func(ctx context.Context, route Route, usage Usage) // Called after each request that reported usage
+}
+
+// Router is a Provider over an ordered chain of routes: when a route fails
+// with an auth error, rate limit or outage before any text was streamed,
+// the request goes to the next one
+type Router struct {
+	routes []Route
+	opts   RouterOptions
+}
+
+// NewRouter creates a router trying routes in order
+func NewRouter(routes []Route, opts RouterOptions) *Router {
+	return &Router{routes: routes, opts: opts}
+}
+
+// plan returns the routes to try for a request
+func (r *Router) plan(ctx context.Context) ([]Route, error) {
+	routes := r.routes
+	fast := r.opts.Fast
+	if RequestClassFrom(ctx) == ClassFast && fast != nil {
+		routes = append([]Route{*fast}, routes...)
+	}
+
+	budget := r.opts.Budget
+	if budget.Limit > 0 && budget.Spent != nil {
+		// The budget is a guard: requests still go out if usage can't be read
+		if spent, err := budget.Spent(ctx); err == nil && spent >= budget.Limit {
+			if budget.Downgrade && fast != nil {
+				return []Route{*fast}, nil
+			}
+			return nil, fmt.Errorf("%w: %d of %d tokens used this session", ErrBudgetExceeded, spent, budget.Limit)
+		}
+	}
+
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no routes configured")
+	}
+	return routes, nil
+}
+
+// fallsThrough reports whether a failed request should go to the next route
+func fallsThrough(err error) bool {
+	return errors.Is(err, ErrAuth) || errors.Is(err, ErrRateLimit) ||
+		errors.Is(err, ErrServer) || errors.Is(err, ErrUnavailable)
+}
+
+// try sends a request down the routes until one succeeds or fails in a way
+// the next route can't fix
+func (r *Router) try(ctx context.Context, send func(route Route) (Usage, bool, error)) error {
+	routes, err := r.plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, route := range routes {
+		usage, delivered, err := send(route)
+		if usage.TotalTokens > 0 && r.opts.OnUsage != nil {
+			r.opts.OnUsage(ctx, route, usage)
+		}
+		if err == nil {
+			return nil
+		}
+		if len(routes) == 1 {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", route, err))
+		if delivered || ctx.Err() != nil || !fallsThrough(err) || i == len(routes)-1 {
+			break
+		}
+	}
+	return fmt.Errorf("all routes failed: %w", errors.Join(errs...))
+}
+
+func (r *Router) Chat(messages []Message, tools []Tool) (*Response, error) {
+	return r.ChatContext(context.Background(), messages, tools)
+}
+
+// ChatContext implements ProviderV2
+func (r *Router) ChatContext(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	var response *Response
+	err := r.try(ctx, func(route Route) (Usage, bool, error) {
+		var err error
+		response, err = V2(route.Provider).ChatContext(route.context(ctx), messages, tools)
+		if err != nil {
+			return Usage{}, false, err
+		}
+		return usageOf(response), false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (r *Router) ChatStream(messages []Message, tools []Tool, callback func(*Response) error) error {
+	return r.ChatStreamContext(context.Background(), messages, tools, callback)
+}
+
+// ChatStreamContext implements ProviderV2. Once a route has streamed text
+// its failure is returned, since the next route would repeat the answer.
+func (r *Router) ChatStreamContext(ctx context.Context, messages []Message, tools []Tool, callback func(*Response) error) error {
+	return r.try(ctx, func(route Route) (Usage, bool, error) {
+		var usage Usage
+		delivered := false
+		err := V2(route.Provider).ChatStreamContext(route.context(ctx), messages, tools, func(response *Response) error {
+			// Streaming providers report the running total
+			if response.TotalTokens > 0 {
+				usage = usageOf(response)
+			}
+			if response.Content != "" || len(response.ToolCalls) > 0 {
+				delivered = true
+			}
+			return callback(response)
+		})
+		return usage, delivered, err
+	})
+}
+
+// GetModels lists the models of the first route's provider
+func (r *Router) GetModels() ([]Model, error) {
+	if len(r.routes) == 0 {
+		return nil, fmt.Errorf("no routes configured")
+	}
+	return r.routes[0].Provider.GetModels()
+}
+
+func (r *Router) GetName() string {
+	names := make([]string, len(r.routes))
+	for i, route := range r.routes {
+		names[i] = route.String()
+	}
+	return "Router (" + strings.Join(names, " → ") + ")"
+}
+
+func (r *Router) RequiresAuth() bool {
+	for _, route := range r.routes {
+		if route.Provider.RequiresAuth() {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsResponseSchema implements SchemaSupporter: only if every route,
+// the fast one included, can take the schema natively
+func (r *Router) SupportsResponseSchema() bool {
+	routes := r.routes
+	if r.opts.Fast != nil {
+		routes = append([]Route{*r.opts.Fast}, routes...)
+	}
+	for _, route := range routes {
+		supporter, ok := route.Provider.(SchemaSupporter)
+		if !ok || !supporter.SupportsResponseSchema() {
+			return false
+		}
+	}
+	return len(routes) > 0
+}
@@ -0,0 +1,245 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// errStreamBroken marks a stream that ended before the model finished
+var errStreamBroken = errors.New("stream ended early")
+
+// streamChat reads an OpenAI-compatible event stream opened by open and
+// passes text, reasoning and tool calls to callback.
+// A stream that breaks midway is opened again: from the start if no text
+// was delivered yet, or, if resume is set, with the delivered text as the
+// start of the assistant answer. Models that don't continue from it answer
+// from the start again, so text repeating what was delivered is dropped.
+// Tool calls are only delivered once complete, so partial ones are dropped.
+func streamChat(ctx context.Context, t *Transport, messages []Message, resume bool,
+	open func(messages []Message) (*http.Response, error), callback func(*Response) error) error {
+
+	var delivered strings.Builder
+	for attempt := 0; ; attempt++ {
+		request := messages
+		if delivered.Len() > 0 {
+			request = append(append([]Message{}, messages...), Message{Role: "assistant", Content: delivered.String()})
+		}
+
+		resp, err := open(request)
+		if err != nil {
+			return err
+		}
+		skip := &echo{text: delivered.String()}
+		err = readStream(resp.Body, func(response *Response) error {
+			response.Content = skip.strip(response.Content)
+			delivered.WriteString(response.Content)
+			if response.Content == "" && response.Reasoning == "" && !response.Done && len(response.ToolCalls) == 0 {
+				return nil
+			}
+			return callback(response)
+		})
+		_ = resp.Body.Close()
+
+		if err == nil || !errors.Is(err, errStreamBroken) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if (delivered.Len() > 0 && !resume) || attempt >= t.MaxRetries {
+			return err
+		}
+		if err := sleep(ctx, t.backoff(attempt, 0)); err != nil {
+			return err
+		}
+	}
+}
+
+// echo drops the start of a resumed answer that repeats text already
+// delivered. Once the answer moves past or away from that text, everything
+// is kept.
+type echo struct {
+	text string // Delivered text not yet repeated
+}
+
+// strip returns the part of content that is new
+func (e *echo) strip(content string) string {
+	if e.text == "" || content == "" {
+		return content
+	}
+	switch {
+	case strings.HasPrefix(e.text, content):
+		e.text = e.text[len(content):]
+		return ""
+	case strings.HasPrefix(content, e.text):
+		content, e.text = content[len(e.text):], ""
+		return content
+	}
+	// The model continued instead of repeating, or its new answer diverged
+	e.text = ""
+	return content
+}
+
+// streamedToolCall collects a tool call whose arguments arrive in pieces
+type streamedToolCall struct {
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+// readStream parses server-sent events until the model finishes, passing
+// text and reasoning as they arrive, then a single Done response with the
+// tool calls and usage. Errors from callback are returned as is; a stream
+// that ends before a finish reason or [DONE] returns an error wrapping
+// errStreamBroken.
+func readStream(body io.Reader, callback func(*Response) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // Tool arguments can make long lines
+	var totalPromptTokens, totalCompletionTokens, totalTokens, cachedTokens, reasoningTokens int
+
+	// Tool calls are keyed by their index and sent with the final response
+	calls := make(map[int]*streamedToolCall)
+	takeCalls := func() []ToolCall {
+		indexes := make([]int, 0, len(calls))
+		for index := range calls {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+
+		var toolCalls []ToolCall
+		for _, index := range indexes {
+			call := calls[index]
+			toolCalls = append(toolCalls, ToolCall{
+				ID:    call.id,
+				Name:  call.name,
+				Input: decodeArguments(json.RawMessage(call.arguments.String())),
+			})
+		}
+		calls = make(map[int]*streamedToolCall)
+		return toolCalls
+	}
+
+	// The final response waits for usage, which can come in a chunk of its
+	// own after the finish reason, so Done is delivered once, at the end
+	finished := false
+	finish := func() error {
+		return callback(&Response{
+			Done:             true,
+			ToolCalls:        takeCalls(),
+			PromptTokens:     totalPromptTokens,
+			CompletionTokens: totalCompletionTokens,
+			TotalTokens:      totalTokens,
+			CachedTokens:     cachedTokens,
+			ReasoningTokens:  reasoningTokens,
+		})
+	}
+
+	for scanner.Scan() {
+		// SSE format: "data: {...}"
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		// OpenAI-compatible APIs send "[DONE]" when the stream is complete
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			return finish()
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+					reasoningDelta
+					ToolCalls []struct {
+						Index    int    `json:"index"`
+						ID       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage struct {
+				PromptTokens        int `json:"prompt_tokens"`
+				CompletionTokens    int `json:"completion_tokens"`
+				TotalTokens         int `json:"total_tokens"`
+				PromptTokensDetails struct {
+					CachedTokens int `json:"cached_tokens"`
+				} `json:"prompt_tokens_details"`
+				CompletionTokensDetails struct {
+					ReasoningTokens int `json:"reasoning_tokens"`
+				} `json:"completion_tokens_details"`
+			} `json:"usage"`
+		}
+
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue // Skip malformed chunks
+		}
+
+		// Capture usage data from any chunk that includes it
+		if chunk.Usage.TotalTokens > 0 {
+			totalPromptTokens = chunk.Usage.PromptTokens
+			totalCompletionTokens = chunk.Usage.CompletionTokens
+			totalTokens = chunk.Usage.TotalTokens
+			cachedTokens = chunk.Usage.PromptTokensDetails.CachedTokens
+			reasoningTokens = chunk.Usage.CompletionTokensDetails.ReasoningTokens
+		}
+
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		for _, tc := range choice.Delta.ToolCalls {
+			call, ok := calls[tc.Index]
+			if !ok {
+				call = &streamedToolCall{}
+				calls[tc.Index] = call
+			}
+			if tc.ID != "" {
+				call.id = tc.ID
+			}
+			if tc.Function.Name != "" {
+				call.name = tc.Function.Name
+			}
+			call.arguments.WriteString(tc.Function.Arguments)
+		}
+
+		if choice.FinishReason != nil {
+			finished = true
+		}
+		response := &Response{
+			Content:          choice.Delta.Content,
+			Reasoning:        choice.Delta.text(),
+			PromptTokens:     totalPromptTokens,
+			CompletionTokens: totalCompletionTokens,
+			TotalTokens:      totalTokens,
+			CachedTokens:     cachedTokens,
+			ReasoningTokens:  reasoningTokens,
+		}
+		if response.Content != "" || response.Reasoning != "" {
+			if err := callback(response); err != nil {
+				return err
+			}
+		}
+	}
+
+	if finished {
+		// The answer is complete even if the stream ended without [DONE]
+		return finish()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading stream: %w: %w", errStreamBroken, err)
+	}
+	return fmt.Errorf("reading stream: %w", errStreamBroken)
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sse formats chunks as a server-sent event stream
+func sse(chunks ...string) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		b.WriteString("data: " + chunk + "\n\n")
+	}
+	return b.String()
+}
+
+// collect reads a stream, returning its text and the responses marked Done
+func collect(t *testing.T, stream string) (string, []*Response, error) {
+	t.Helper()
+	var text strings.Builder
+	var done []*Response
+	err := readStream(strings.NewReader(stream), func(response *Response) error {
+		text.WriteString(response.Content)
+		if response.Done {
+			done = append(done, response)
+		}
+		return nil
+	})
+	return text.String(), done, err
+}
+
+func TestReadStream(t *testing.T) {
+	// Usage in a chunk of its own after the finish, as OpenAI sends it
+	text, done, err := collect(t, sse(
+		`{"choices":[{"delta":{"reasoning":"Need a file. "}}]}`,
+		`{"choices":[{"delta":{"content":"Reading "}}]}`,
+		`{"choices":[{"delta":{"content":"it.","tool_calls":[{"index":0,"id":"call_1","function":{"name":"read_file","arguments":"{\"path\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.go\"}"}}]},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":2}}}`,
+		`[DONE]`,
+	))
+	if err != nil {
+		t.Fatalf("readStream failed: %v", err)
+	}
+	if text != "Reading it." {
+		t.Errorf("text = %q", text)
+	}
+	if len(done) != 1 {
+		t.Fatalf("Done delivered %d times, want once", len(done))
+	}
+	final := done[0]
+	if len(final.ToolCalls) != 1 || final.ToolCalls[0].ID != "call_1" || final.ToolCalls[0].Input["path"] != "a.go" {
+		t.Errorf("unexpected tool calls %+v", final.ToolCalls)
+	}
+	if final.TotalTokens != 15 || final.CachedTokens != 4 || final.ReasoningTokens != 2 {
+		t.Errorf("unexpected usage %+v", final)
+	}
+
+	// Streams that end after the finish without [DONE] are complete
+	if _, done, err := collect(t, sse(`{"choices":[{"delta":{"content":"Hi"},"finish_reason":"stop"}]}`)); err != nil || len(done) != 1 {
+		t.Errorf("expected one Done and no error, got %d and %v", len(done), err)
+	}
+
+	// Streams that end before the finish are broken
+	if _, _, err := collect(t, sse(`{"choices":[{"delta":{"content":"Hi"}}]}`)); !errors.Is(err, errStreamBroken) {
+		t.Errorf("expected errStreamBroken, got %v", err)
+	}
+
+	// Callback errors stop the stream as they are
+	stop := errors.New("stop")
+	err = readStream(strings.NewReader(sse(`{"choices":[{"delta":{"content":"Hi"}}]}`, `[DONE]`)), func(*Response) error { return stop })
+	if err != stop {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+}
+
+func TestStreamChatResume(t *testing.T) {
+	broken := sse(`{"choices":[{"delta":{"content":"Hello, "}}]}`, `{"choices":[{"delta":{"content":"wor"}}]}`)
+	tests := []struct {
+		name    string
+		resumed string // Stream after the break
+	}{
+		{"model continues", sse(`{"choices":[{"delta":{"content":"ld!"},"finish_reason":"stop"}]}`)},
+		{"model starts over", sse(
+			`{"choices":[{"delta":{"content":"Hello"}}]}`,
+			`{"choices":[{"delta":{"content":", wo"}}]}`,
+			`{"choices":[{"delta":{"content":"rld!"},"finish_reason":"stop"}]}`,
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests [][]Message
+			open := func(messages []Message) (*http.Response, error) {
+				requests = append(requests, messages)
+				body := broken
+				if len(requests) > 1 {
+					body = tt.resumed
+				}
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+			}
+
+			var text strings.Builder
+			transport := &Transport{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+			err := streamChat(context.Background(), transport, []Message{{Role: "user", Content: "greet"}}, true, open, func(response *Response) error {
+				text.WriteString(response.Content)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("streamChat failed: %v", err)
+			}
+			if text.String() != "Hello, world!" {
+				t.Errorf("text = %q, want %q", text.String(), "Hello, world!")
+			}
+			if len(requests) != 2 {
+				t.Fatalf("%d requests, want 2", len(requests))
+			}
+			if last := requests[1][len(requests[1])-1]; last.Role != "assistant" || last.Content != "Hello, wor" {
+				t.Errorf("expected the partial answer as prefill, got %+v", last)
+			}
+		})
+	}
+
+	// Without resume, a stream that broke after text fails
+	open := func([]Message) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(broken))}, nil
+	}
+	err := streamChat(context.Background(), &Transport{MaxRetries: 2}, nil, false, open, func(*Response) error { return nil })
+	if !errors.Is(err, errStreamBroken) {
+		t.Errorf("expected errStreamBroken, got %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidOutput is returned by Structured when the model doesn't answer
+// with a value matching the schema, even after being asked again
+var ErrInvalidOutput = errors.New("response does not match schema")
+
+// StructuredAttempts is how many times Structured asks for a valid answer
+const StructuredAttempts = 3
+
+// Schema is the JSON schema a response must match
+type Schema struct {
+	Name        string                 // Identifier like "review_verdict"; names the tool on providers without native support
+	Description string                 // What the value is for
+	Schema      map[string]interface{} // JSON schema of an object
+}
+
+// SchemaSupporter is implemented by providers that can constrain responses
+// to a schema natively (response_format json_schema)
+type SchemaSupporter interface {
+	SupportsResponseSchema() bool
+}
+
+type responseSchemaKey struct{}
+
+// WithResponseSchema asks providers with native support to answer requests
+// made with ctx with JSON matching schema
+func WithResponseSchema(ctx context.Context, schema *Schema) context.Context {
+	return context.WithValue(ctx, responseSchemaKey{}, schema)
+}
+
+// ResponseSchemaFrom returns the response schema of ctx, or nil
+func ResponseSchemaFrom(ctx context.Context) *Schema {
+	schema, _ := ctx.Value(responseSchemaKey{}).(*Schema)
+	return schema
+}
+
+// responseFormat is the OpenAI response_format of a schema
+func responseFormat(schema *Schema) map[string]interface{} {
+	jsonSchema := map[string]interface{}{
+		"name":   schema.Name,
+		"strict": true,
+		"schema": schema.Schema,
+	}
+	if schema.Description != "" {
+		jsonSchema["description"] = schema.Description
+	}
+	return map[string]interface{}{"type": "json_schema", "json_schema": jsonSchema}
+}
+
+// Structured asks p for a value matching schema and decodes it into a T.
+// Providers with native support get the schema as response_format; others
+// are offered a single tool taking the value as its arguments, and answers
+// in plain text are searched for a JSON object. Answers that don't match the
+// schema are sent back with the problem, up to StructuredAttempts times.
+// A nil schema is derived from T with SchemaFor.
+func Structured[T any](ctx context.Context, p Provider, messages []Message, schema *Schema) (*T, error) {
+	if schema == nil {
+		schema = SchemaFor[T]("result", "")
+	}
+
+	native := false
+	if supporter, ok := p.(SchemaSupporter); ok {
+		native = supporter.SupportsResponseSchema()
+	}
+
+	request := append([]Message{}, messages...)
+	var tools []Tool
+	if native {
+		ctx = WithResponseSchema(ctx, schema)
+		request = append(request, Message{Role: "user", Content: "Answer with a JSON object matching the " + schema.Name + " schema."})
+	} else {
+		tools = []Tool{{Name: schema.Name, Description: schema.Description, Parameters: schema.Schema}}
+		request = append(request, Message{Role: "user", Content: "Answer by calling the " + schema.Name + " tool."})
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < StructuredAttempts; attempt++ {
+		response, err := V2(p).ChatContext(ctx, request, tools)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, call, err := structuredPayload(response, schema.Name)
+		if err == nil {
+			var value T
+			if err = decodeStructured(raw, schema, &value); err == nil {
+				return &value, nil
+			}
+		}
+		lastErr = err
+
+		// Show the model its answer and what is wrong with it
+		retry := "That answer is invalid: " + err.Error() + ". Answer again"
+		if call != nil {
+			request = append(request,
+				Message{Role: "assistant", Content: response.Content, ToolCalls: []ToolCall{*call}},
+				Message{Role: "tool", ToolCallID: call.ID, Content: retry + " by calling the tool."})
+		} else {
+			request = append(request,
+				Message{Role: "assistant", Content: response.Content},
+				Message{Role: "user", Content: retry + " with only the JSON object."})
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrInvalidOutput, lastErr)
+}
+
+// structuredPayload returns the JSON answer in a response: the arguments of
+// a call to the schema's tool, or else the JSON object in the text
+func structuredPayload(response *Response, name string) ([]byte, *ToolCall, error) {
+	for i := range response.ToolCalls {
+		call := &response.ToolCalls[i]
+		if call.Name != name {
+			continue
+		}
+		if call.Input == nil {
+			return nil, call, errors.New("tool arguments are not a JSON object")
+		}
+		raw, err := json.Marshal(call.Input)
+		return raw, call, err
+	}
+
+	// Models wrap JSON in prose or code fences now and then
+	text := response.Content
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, nil, errors.New("no JSON object in the answer")
+	}
+	return []byte(text[start : end+1]), nil, nil
+}
+
+// decodeStructured checks raw against the schema and decodes it into out
+func decodeStructured(raw []byte, schema *Schema, out interface{}) error {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := validateSchema(value, schema.Schema, "$"); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decoding: %w", err)
+	}
+	return nil
+}
+
+// validateSchema checks value against the commonly used parts of JSON schema:
+// type, enum, properties, required, additionalProperties and items
+func validateSchema(value interface{}, schema map[string]interface{}, path string) error {
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if matchesType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s must be %s", path, strings.Join(types, " or "))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of %v", path, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, field := range v {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s.%s is not allowed", path, name)
+				}
+				continue
+			}
+			if err := validateSchema(field, property, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "null":
+		return value == nil
+	}
+	return true // Unknown types aren't checked
+}
+
+// schemaTypes reads "type", which is a name or a list of names
+func schemaTypes(v interface{}) []string {
+	if t, ok := v.(string); ok {
+		return []string{t}
+	}
+	return schemaStrings(v)
+}
+
+// schemaStrings reads a list of strings from a schema built in Go or decoded from JSON
+func schemaStrings(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		var result []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// SchemaFor derives the schema of T from its JSON encoding. Every field is
+// required and no others are allowed, as strict native schemas demand.
+// Field tags add documentation and constraints:
+//
+//	Verdict string `json:"verdict" desc:"Outcome of the review" enum:"approve,request_changes"`
+func SchemaFor[T any](name, description string) *Schema {
+	var zero T
+	return &Schema{Name: name, Description: description, Schema: schemaOf(reflect.TypeOf(zero))}
+}
+
+// schemaOf returns the JSON schema of a Go type
+func schemaOf(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := schemaOf(field.Type)
+			if desc := field.Tag.Get("desc"); desc != "" {
+				property["description"] = desc
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				var values []interface{}
+				for _, v := range strings.Split(enum, ",") {
+					values = append(values, v)
+				}
+				property["enum"] = values
+			}
+			properties[name] = property
+			required = append(required, name)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"verdict": {"type": "string", "enum": ["approve", "reject"]},
+			"count": {"type": "integer"},
+			"score": {"type": ["number", "null"]},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		},
+		"required": ["verdict"],
+		"additionalProperties": false
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		err   string // Substring of the error; "" for valid values
+	}{
+		{"valid", `{"verdict": "approve", "count": 2, "score": 0.5, "tags": ["a"]}`, ""},
+		{"only required", `{"verdict": "reject"}`, ""},
+		{"null in type list", `{"verdict": "reject", "score": null}`, ""},
+		{"not an object", `["approve"]`, "$ must be object"},
+		{"enum", `{"verdict": "maybe"}`, "$.verdict must be one of"},
+		{"required", `{"count": 1}`, "$.verdict is required"},
+		{"additional property", `{"verdict": "approve", "extra": true}`, "$.extra is not allowed"},
+		{"integer", `{"verdict": "approve", "count": 1.5}`, "$.count must be integer"},
+		{"integer as string", `{"verdict": "approve", "count": "1"}`, "$.count must be integer"},
+		{"type list", `{"verdict": "approve", "score": "high"}`, "$.score must be number or null"},
+		{"array item", `{"verdict": "approve", "tags": ["a", 2]}`, "$.tags[1] must be string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := validateSchema(value, schema, "$")
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// additionalProperties only rejects when false
+	open := map[string]interface{}{"type": "object", "additionalProperties": true}
+	if err := validateSchema(map[string]interface{}{"any": 1.0}, open, "$"); err != nil {
+		t.Errorf("unexpected error with additionalProperties true: %v", err)
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	type inner struct {
+		Name string `json:"name"`
+	}
+	type sample struct {
+		Verdict  string            `json:"verdict" desc:"Outcome" enum:"approve,reject"`
+		Count    int               `json:"count,omitempty"`
+		Inner    *inner            `json:"inner"`
+		Labels   map[string]int    `json:"labels"`
+		Skipped  string            `json:"-"`
+		Untagged bool              // Named after the field
+		hidden   string            // Unexported fields are left out
+		Extra    map[string]string `json:"extra"`
+	}
+
+	object := func(properties map[string]interface{}, required ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+
+	tests := []struct {
+		name string
+		typ  reflect.Type
+		want map[string]interface{}
+	}{
+		{"string", reflect.TypeOf(""), map[string]interface{}{"type": "string"}},
+		{"unsigned", reflect.TypeOf(uint8(0)), map[string]interface{}{"type": "integer"}},
+		{"float", reflect.TypeOf(0.0), map[string]interface{}{"type": "number"}},
+		{"pointer", reflect.TypeOf(new(int)), map[string]interface{}{"type": "integer"}},
+		{"slice", reflect.TypeOf([]bool{}), map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "boolean"}}},
+		{"map", reflect.TypeOf(map[string]float64{}), map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "number"}}},
+		{"pointer to struct", reflect.TypeOf(&inner{}), object(map[string]interface{}{"name": map[string]interface{}{"type": "string"}}, "name")},
+		{"struct", reflect.TypeOf(sample{}), object(map[string]interface{}{
+			"verdict":  map[string]interface{}{"type": "string", "description": "Outcome", "enum": []interface{}{"approve", "reject"}},
+			"count":    map[string]interface{}{"type": "integer"},
+			"inner":    object(map[string]interface{}{"name": map[string]interface{}{"type": "string"}}, "name"),
+			"labels":   map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "integer"}},
+			"Untagged": map[string]interface{}{"type": "boolean"},
+			"extra":    map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+		}, "verdict", "count", "inner", "labels", "Untagged", "extra")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaOf(tt.typ); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schemaOf(%s) =\n%v\nwant\n%v", tt.typ, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Retry defaults of NewTransport
+const (
+	DefaultMaxRetries = 4
+	DefaultBaseDelay  = 500 * time.Millisecond
+	DefaultMaxDelay   = 30 * time.Second
+)
+
+// Rate limit defaults for providers without SetRateLimit
+const (
+	DefaultRequestsPerSecond = 2.0
+	DefaultBurst             = 5
+)
+
+// Transport sends provider requests: it waits for the provider's rate
+// limiter, and retries network errors, 429 and 5xx responses with
+// exponential backoff and jitter, honoring Retry-After
+type Transport struct {
+	Client     *http.Client
+	Limiter    *RateLimiter // Shared by every request to the provider
+	MaxRetries int          // Attempts after the first; 0 disables retries
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// NewTransport creates a transport using the process-wide limiter of providerID
+func NewTransport(providerID string, timeout time.Duration) *Transport {
+	return &Transport{
+		Client:     &http.Client{Timeout: timeout},
+		Limiter:    LimiterFor(providerID),
+		MaxRetries: DefaultMaxRetries,
+		BaseDelay:  DefaultBaseDelay,
+		MaxDelay:   DefaultMaxDelay,
+	}
+}
+
+// Do sends the request built by newRequest, which is called again for every
+// attempt since request bodies can only be read once. Responses other than
+// 2xx are returned as *APIError once retries are used up.
+func (t *Transport) Do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+
+		var lastErr error
+		var retryAfter time.Duration
+		resp, err := t.Client.Do(req.WithContext(ctx))
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = fmt.Errorf("api request: %w: %w", ErrUnavailable, err)
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return resp, nil
+		default:
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			apiErr := newAPIError(resp, body)
+			if !apiErr.retryable() {
+				return nil, apiErr
+			}
+			if apiErr.Kind == ErrRateLimit && apiErr.RetryAfter > 0 {
+				// Hold back every agent using this provider, not just this request
+				t.Limiter.Pause(apiErr.RetryAfter)
+			}
+			lastErr, retryAfter = apiErr, apiErr.RetryAfter
+		}
+
+		if attempt >= t.MaxRetries {
+			return nil, lastErr
+		}
+		if err := sleep(ctx, t.backoff(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the wait before retry attempt+1: Retry-After if the
+// provider sent one, otherwise an exponential delay with jitter
+func (t *Transport) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := t.BaseDelay << attempt
+	if delay <= 0 || delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	// Half fixed, half random so agents that failed together spread out
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimiter is a token bucket: requests take a token, tokens refill at a
+// fixed rate up to the burst size. A nil limiter allows everything.
+type RateLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // Tokens per second; 0 means unlimited
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewRateLimiter allows perSecond requests on average and burst at once
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// SetRate changes the limits, keeping the tokens already available
+func (l *RateLimiter) SetRate(perSecond float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.burst = perSecond, float64(burst)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	for {
+		l.mu.Lock()
+		wait := l.reserve(time.Now())
+		l.mu.Unlock()
+		if wait <= 0 {
+			return ctx.Err()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise returns how long
+// until one is
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Pause holds all requests for d, e.g. after the provider answered 429
+// with Retry-After
+func (l *RateLimiter) Pause(d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*RateLimiter)
+)
+
+// LimiterFor returns the process-wide limiter of a provider, so all agents
+// share one budget
+func LimiterFor(providerID string) *RateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	l, ok := limiters[providerID]
+	if !ok {
+		l = NewRateLimiter(DefaultRequestsPerSecond, DefaultBurst)
+		limiters[providerID] = l
+	}
+	return l
+}
+
+// SetRateLimit changes the limits of a provider for the whole process;
+// perSecond 0 removes the limit
+func SetRateLimit(providerID string, perSecond float64, burst int) {
+	LimiterFor(providerID).SetRate(perSecond, burst)
+}
@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testTransport retries quickly without a rate limit
+func testTransport(client *http.Client) *Transport {
+	return &Transport{Client: client, MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+}
+
+// statusServer answers each request with the next status, repeating the last
+func statusServer(t *testing.T, headers http.Header, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		status := statuses[len(statuses)-1]
+		if n <= len(statuses) {
+			status = statuses[n-1]
+		}
+		for key, values := range headers {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func get(srv *httptest.Server) func() (*http.Request, error) {
+	return func() (*http.Request, error) { return http.NewRequest("GET", srv.URL, nil) }
+}
+
+func TestTransportRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		status   int   // Status of the final response
+		kind     error // Kind of the error, if the request fails
+		requests int32
+	}{
+		{"succeeds at once", []int{200}, 200, nil, 1},
+		{"retries server errors", []int{503, 502, 200}, 200, nil, 3},
+		{"retries rate limits", []int{429, 200}, 200, nil, 2},
+		{"gives up on server errors", []int{500}, 500, ErrServer, 4},
+		{"gives up on rate limits", []int{429}, 429, ErrRateLimit, 4},
+		{"does not retry auth errors", []int{401, 200}, 401, ErrAuth, 1},
+		{"does not retry bad requests", []int{400, 200}, 400, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := statusServer(t, nil, tt.statuses...)
+			resp, err := testTransport(srv.Client()).Do(context.Background(), get(srv))
+
+			if tt.status == 200 {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				_ = resp.Body.Close()
+			} else {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Kind != tt.kind {
+					t.Errorf("expected a %d error of kind %v, got %v", tt.status, tt.kind, err)
+				}
+			}
+			if got := atomic.LoadInt32(requests); got != tt.requests {
+				t.Errorf("%d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestTransportRetryAfter(t *testing.T) {
+	srv, requests := statusServer(t, http.Header{"Retry-After": {"1"}}, 429, 200)
+	transport := testTransport(srv.Client())
+	transport.Limiter = NewRateLimiter(0, 1)
+
+	start := time.Now()
+	resp, err := transport.Do(context.Background(), get(srv))
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// The wait comes from the header, not the millisecond backoff, and
+	// holds back the whole provider
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least 1s", elapsed)
+	}
+	if *requests != 2 {
+		t.Errorf("%d requests, want 2", *requests)
+	}
+	if transport.Limiter.pausedUntil.IsZero() {
+		t.Error("expected the limiter to be paused")
+	}
+}
+
+func TestTransportFailures(t *testing.T) {
+	// Unreachable servers are retried, then reported as ErrUnavailable
+	srv := httptest.NewServer(http.NotFoundHandler())
+	client := srv.Client()
+	srv.Close()
+	if _, err := testTransport(client).Do(context.Background(), get(srv)); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+
+	// Cancellation stops the retries
+	srv, _ = statusServer(t, nil, 503)
+	transport := testTransport(srv.Client())
+	transport.BaseDelay, transport.MaxDelay = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := transport.Do(ctx, get(srv)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop retries, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	transport := &Transport{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{0, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 0, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 0, 500 * time.Millisecond, time.Second},
+		{62, 0, 500 * time.Millisecond, time.Second}, // Shift overflow
+		{0, 3 * time.Second, 3 * time.Second, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := transport.backoff(tt.attempt, tt.retryAfter); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d, %v) = %v, want %v to %v", tt.attempt, tt.retryAfter, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2, 2)
+	now := l.last
+
+	// The burst is available at once, then tokens come at the rate
+	for i := 0; i < 2; i++ {
+		if wait := l.reserve(now); wait != 0 {
+			t.Fatalf("request %d waits %v, want none", i+1, wait)
+		}
+	}
+	if wait := l.reserve(now); wait != 500*time.Millisecond {
+		t.Errorf("third request waits %v, want 500ms", wait)
+	}
+	if wait := l.reserve(now.Add(500 * time.Millisecond)); wait != 0 {
+		t.Errorf("request after refill waits %v, want none", wait)
+	}
+
+	// Tokens don't pile up beyond the burst
+	later := now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		l.reserve(later)
+	}
+	if wait := l.reserve(later); wait <= 0 {
+		t.Error("expected the burst to cap saved tokens")
+	}
+
+	// A pause holds everything
+	l.pausedUntil = later.Add(time.Minute)
+	if wait := l.reserve(later); wait != time.Minute {
+		t.Errorf("paused request waits %v, want 1m", wait)
+	}
+
+	// No rate means no limit; a nil limiter allows everything
+	unlimited := NewRateLimiter(0, 1)
+	for i := 0; i < 10; i++ {
+		if wait := unlimited.reserve(now); wait != 0 {
+			t.Fatalf("unlimited request waits %v", wait)
+		}
+	}
+	var none *RateLimiter
+	if err := none.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+
+	// Waiting stops with the context
+	slow := NewRateLimiter(0.001, 1)
+	slow.reserve(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := slow.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop waiting, got %v", err)
+	}
+}